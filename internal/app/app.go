@@ -5,13 +5,13 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
 	"gpt-load/internal/config"
 	db "gpt-load/internal/db/migrations"
 	"gpt-load/internal/keypool"
-	"gpt-load/internal/models"
 	"gpt-load/internal/proxy"
 	"gpt-load/internal/services"
 	"gpt-load/internal/store"
@@ -33,6 +33,8 @@ type App struct {
 	logCleanupService *services.LogCleanupService
 	requestLogService *services.RequestLogService
 	cronChecker       *keypool.CronChecker
+	rebalancer        *keypool.Rebalancer
+	clusterService    *services.ClusterService
 	keyPoolProvider   *keypool.KeyProvider
 	proxyServer       *proxy.ProxyServer
 	storage           store.Store
@@ -50,6 +52,8 @@ type AppParams struct {
 	LogCleanupService *services.LogCleanupService
 	RequestLogService *services.RequestLogService
 	CronChecker       *keypool.CronChecker
+	Rebalancer        *keypool.Rebalancer
+	ClusterService    *services.ClusterService
 	KeyPoolProvider   *keypool.KeyProvider
 	ProxyServer       *proxy.ProxyServer
 	Storage           store.Store
@@ -66,6 +70,8 @@ func NewApp(params AppParams) *App {
 		logCleanupService: params.LogCleanupService,
 		requestLogService: params.RequestLogService,
 		cronChecker:       params.CronChecker,
+		rebalancer:        params.Rebalancer,
+		clusterService:    params.ClusterService,
 		keyPoolProvider:   params.KeyPoolProvider,
 		proxyServer:       params.ProxyServer,
 		storage:           params.Storage,
@@ -73,25 +79,27 @@ func NewApp(params AppParams) *App {
 	}
 }
 
+// StartOptions controls how the application starts.
+type StartOptions struct {
+	// SkipMigrations leaves database migrations to the migrate command, such as in an init container.
+	SkipMigrations bool
+}
+
 // Start runs the application, it is a non-blocking call.
-func (a *App) Start() error {
+func (a *App) Start(opts StartOptions) error {
 	// Master 节点执行初始化
 	if a.configManager.IsMaster() {
 		logrus.Info("Starting as Master Node.")
 
-		// 数据库迁移
-		if err := a.db.AutoMigrate(
-			&models.SystemSetting{},
-			&models.Group{},
-			&models.APIKey{},
-			&models.RequestLog{},
-			&models.GroupHourlyStat{},
-		); err != nil {
-			return fmt.Errorf("database auto-migration failed: %w", err)
+		// 数据库迁移，已执行过的数据迁移会被跳过
+		if opts.SkipMigrations {
+			a.checkPendingMigrations()
+		} else {
+			if err := db.Run(a.db); err != nil {
+				return err
+			}
+			logrus.Info("Database auto-migration completed.")
 		}
-		// 数据修复
-		db.MigrateDatabase(a.db)
-		logrus.Info("Database auto-migration completed.")
 
 		// 初始化系统设置
 		if err := a.settingsManager.EnsureSettingsInitialized(a.configManager.GetAuthConfig()); err != nil {
@@ -111,15 +119,25 @@ func (a *App) Start() error {
 		a.requestLogService.Start()
 		a.logCleanupService.Start()
 		a.cronChecker.Start()
+		a.rebalancer.Start()
 	} else {
 		logrus.Info("Starting as Slave Node.")
 		a.settingsManager.Initialize(a.storage, a.groupManager, a.configManager.IsMaster())
+
+		// 从节点只写入本节点本地缓冲中的请求日志
+		a.requestLogService.Start()
+	}
+
+	// Redis 不可用时各节点在内存中重建密钥池，恢复后由 Master 将期间的状态变化同步回 Redis
+	if degradable, ok := a.storage.(store.Degradable); ok {
+		degradable.OnBackendChange(a.onStoreBackendChange)
 	}
 
 	// 显示配置并启动所有后台服务
 	a.configManager.DisplayServerConfig()
 
 	a.groupManager.Initialize()
+	a.clusterService.Start()
 
 	// Create HTTP server
 	serverConfig := a.configManager.GetEffectiveServerConfig()
@@ -134,7 +152,8 @@ func (a *App) Start() error {
 
 	// Start HTTP server in a new goroutine
 	go func() {
-		logrus.Infof("GPT-Load proxy server started successfully on Version: %s", version.Version)
+		buildInfo := version.Get()
+		logrus.Infof("GPT-Load proxy server started successfully on Version: %s (commit: %s, built: %s)", buildInfo.Version, buildInfo.Commit, buildInfo.BuildTime)
 		logrus.Infof("Server address: http://%s:%d", serverConfig.Host, serverConfig.Port)
 		logrus.Info("")
 		if err := a.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -145,6 +164,33 @@ func (a *App) Start() error {
 	return nil
 }
 
+// checkPendingMigrations warns when migrations were skipped but the database still needs some.
+func (a *App) checkPendingMigrations() {
+	pending, err := db.Pending(a.db)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to check pending database migrations")
+		return
+	}
+	if len(pending) > 0 {
+		logrus.Warnf("Database migrations skipped, but %d are pending: %s. Run 'gpt-load migrate' to apply them.", len(pending), strings.Join(pending, ", "))
+		return
+	}
+	logrus.Info("Database migrations skipped.")
+}
+
+// onStoreBackendChange rebuilds the key pool after the store switched to or back from its
+// in-memory fallback. Only the master writes the pool back to Redis on recovery.
+func (a *App) onStoreBackendChange(degraded bool) {
+	if !degraded && !a.configManager.IsMaster() {
+		return
+	}
+	if err := a.keyPoolProvider.ReloadKeys(); err != nil {
+		logrus.WithError(err).Error("Failed to rebuild the key pool after the store backend changed")
+		return
+	}
+	logrus.WithField("degraded", degraded).Info("Key pool rebuilt after the store backend changed")
+}
+
 // Stop gracefully shuts down the application.
 func (a *App) Stop(ctx context.Context) {
 	logrus.Info("Shutting down server...")
@@ -170,13 +216,15 @@ func (a *App) Stop(ctx context.Context) {
 	stoppableServices := []func(context.Context){
 		a.groupManager.Stop,
 		a.settingsManager.Stop,
+		a.clusterService.Stop,
+		a.requestLogService.Stop,
 	}
 
 	if serverConfig.IsMaster {
 		stoppableServices = append(stoppableServices,
 			a.cronChecker.Stop,
+			a.rebalancer.Stop,
 			a.logCleanupService.Stop,
-			a.requestLogService.Stop,
 		)
 	}
 
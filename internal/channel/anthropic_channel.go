@@ -17,12 +17,18 @@ import (
 
 func init() {
 	Register("anthropic", newAnthropicChannel)
+	RegisterKeyFormat("anthropic", isAnthropicKeyFormat)
 }
 
 type AnthropicChannel struct {
 	*BaseChannel
 }
 
+// isAnthropicKeyFormat accepts Anthropic API keys, which start with "sk-ant-".
+func isAnthropicKeyFormat(key string) bool {
+	return strings.HasPrefix(key, "sk-ant-")
+}
+
 func newAnthropicChannel(f *Factory, group *models.Group) (ChannelProxy, error) {
 	base, err := f.newBaseChannel("anthropic", group)
 	if err != nil {
@@ -38,6 +44,22 @@ func newAnthropicChannel(f *Factory, group *models.Group) (ChannelProxy, error)
 func (ch *AnthropicChannel) ModifyRequest(req *http.Request, apiKey *models.APIKey, group *models.Group) {
 	req.Header.Set("x-api-key", apiKey.KeyValue)
 	req.Header.Set("anthropic-version", "2023-06-01")
+	ch.setUserAgent(req)
+}
+
+// IsSuccessResponse accepts the group's success status codes, and rejects bodies of type "error",
+// the Anthropic error format.
+func (ch *AnthropicChannel) IsSuccessResponse(statusCode int, body []byte) bool {
+	if !isSuccessStatus(ch.SuccessStatusCodes, statusCode) {
+		return false
+	}
+	if body == nil {
+		return true
+	}
+	var payload struct {
+		Type string `json:"type"`
+	}
+	return json.Unmarshal(body, &payload) != nil || payload.Type != "error"
 }
 
 // IsStreamRequest checks if the request is for a streaming response using the pre-read body.
@@ -63,7 +85,7 @@ func (ch *AnthropicChannel) IsStreamRequest(c *gin.Context, bodyBytes []byte) bo
 
 // ValidateKey checks if the given API key is valid by making a messages request.
 func (ch *AnthropicChannel) ValidateKey(ctx context.Context, key string) (bool, error) {
-	upstreamURL := ch.getUpstreamURL()
+	upstreamURL := ch.getUpstreamURL(nil)
 	if upstreamURL == nil {
 		return false, fmt.Errorf("no upstream URL configured for channel %s", ch.Name)
 	}
@@ -97,8 +119,9 @@ func (ch *AnthropicChannel) ValidateKey(ctx context.Context, key string) (bool,
 	req.Header.Set("x-api-key", key)
 	req.Header.Set("anthropic-version", "2023-06-01")
 	req.Header.Set("Content-Type", "application/json")
+	ch.setUserAgent(req)
 
-	resp, err := ch.HTTPClient.Do(req)
+	resp, err := ch.ValidationClient.Do(req)
 	if err != nil {
 		return false, fmt.Errorf("failed to send validation request: %w", err)
 	}
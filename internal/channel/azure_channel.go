@@ -0,0 +1,195 @@
+package channel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/models"
+	"gpt-load/internal/utils"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultAzureAPIVersion is used when the group does not configure an api-version.
+const defaultAzureAPIVersion = "2024-10-21"
+
+func init() {
+	Register("azure", newAzureChannel)
+}
+
+type AzureChannel struct {
+	*BaseChannel
+	apiVersion  string
+	deployments map[string]string
+}
+
+func newAzureChannel(f *Factory, group *models.Group) (ChannelProxy, error) {
+	base, err := f.newBaseChannel("azure", group)
+	if err != nil {
+		return nil, err
+	}
+
+	groupConfig, err := utils.ParseGroupConfig(group.Config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config for azure channel: %w", err)
+	}
+
+	apiVersion := groupConfig.AzureAPIVersion
+	if apiVersion == "" {
+		apiVersion = defaultAzureAPIVersion
+	}
+
+	return &AzureChannel{
+		BaseChannel: base,
+		apiVersion:  apiVersion,
+		deployments: groupConfig.AzureDeployments,
+	}, nil
+}
+
+// deploymentFor maps a model name to its configured deployment, falling back to the model name itself.
+func (ch *AzureChannel) deploymentFor(model string) string {
+	if deployment, ok := ch.deployments[model]; ok {
+		return deployment
+	}
+	return model
+}
+
+// ModifyRequest rewrites the OpenAI-style path into Azure's deployment-based path and sets the api-key header.
+func (ch *AzureChannel) ModifyRequest(req *http.Request, apiKey *models.APIKey, group *models.Group) {
+	req.Header.Set("api-key", apiKey.KeyValue)
+	ch.setUserAgent(req)
+
+	q := req.URL.Query()
+	q.Set("api-version", ch.apiVersion)
+	req.URL.RawQuery = q.Encode()
+
+	if strings.Contains(req.URL.Path, "/openai/deployments/") {
+		return
+	}
+
+	idx := strings.LastIndex(req.URL.Path, "/v1/")
+	if idx < 0 {
+		return
+	}
+
+	model := requestModel(req)
+	if model == "" {
+		return
+	}
+
+	prefix := req.URL.Path[:idx]
+	rest := req.URL.Path[idx+len("/v1"):]
+	req.URL.Path = prefix + "/openai/deployments/" + url.PathEscape(ch.deploymentFor(model)) + rest
+	req.URL.RawPath = ""
+}
+
+// requestModel reads the "model" field from a replayable JSON request body.
+func requestModel(req *http.Request) string {
+	if req.GetBody == nil {
+		return ""
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return ""
+	}
+	defer body.Close()
+
+	var payload struct {
+		Model string `json:"model"`
+	}
+	if err := json.NewDecoder(body).Decode(&payload); err != nil {
+		return ""
+	}
+	return payload.Model
+}
+
+// IsStreamRequest checks if the request is for a streaming response using the pre-read body.
+func (ch *AzureChannel) IsStreamRequest(c *gin.Context, bodyBytes []byte) bool {
+	if strings.Contains(c.GetHeader("Accept"), "text/event-stream") {
+		return true
+	}
+
+	if c.Query("stream") == "true" {
+		return true
+	}
+
+	type streamPayload struct {
+		Stream bool `json:"stream"`
+	}
+	var p streamPayload
+	if err := json.Unmarshal(bodyBytes, &p); err == nil {
+		return p.Stream
+	}
+
+	return false
+}
+
+// SupportsStreamUsage reports that Azure OpenAI accepts stream_options.include_usage.
+func (ch *AzureChannel) SupportsStreamUsage() bool {
+	return true
+}
+
+// ValidateKey checks if the given API key is valid by making a chat completion request against the test model's deployment.
+func (ch *AzureChannel) ValidateKey(ctx context.Context, key string) (bool, error) {
+	upstreamURL := ch.getUpstreamURL(nil)
+	if upstreamURL == nil {
+		return false, fmt.Errorf("no upstream URL configured for channel %s", ch.Name)
+	}
+
+	validationEndpoint := ch.ValidationEndpoint
+	if validationEndpoint == "" {
+		validationEndpoint = "/openai/deployments/" + url.PathEscape(ch.deploymentFor(ch.TestModel)) + "/chat/completions"
+	}
+	reqURL, err := url.JoinPath(upstreamURL.String(), validationEndpoint)
+	if err != nil {
+		return false, fmt.Errorf("failed to join upstream URL and validation endpoint: %w", err)
+	}
+	reqURL += "?api-version=" + url.QueryEscape(ch.apiVersion)
+
+	// Use a minimal, low-cost payload for validation
+	payload := gin.H{
+		"messages": []gin.H{
+			{"role": "user", "content": "hi"},
+		},
+		"max_tokens": 100,
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal validation payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", reqURL, bytes.NewBuffer(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create validation request: %w", err)
+	}
+	req.Header.Set("api-key", key)
+	req.Header.Set("Content-Type", "application/json")
+	ch.setUserAgent(req)
+
+	resp, err := ch.ValidationClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to send validation request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// A 200 OK status code indicates the key is valid and can make requests.
+	if resp.StatusCode == http.StatusOK {
+		return true, nil
+	}
+
+	// For non-200 responses, parse the body to provide a more specific error reason.
+	errorBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, fmt.Errorf("key is invalid (status %d), but failed to read error body: %w", resp.StatusCode, err)
+	}
+
+	parsedError := app_errors.ParseUpstreamError(errorBody)
+
+	return false, fmt.Errorf("[status %d] %s", resp.StatusCode, parsedError)
+}
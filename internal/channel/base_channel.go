@@ -10,15 +10,28 @@ import (
 	"reflect"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/sirupsen/logrus"
 	"gorm.io/datatypes"
 )
 
+const (
+	// upstreamFailureThreshold is the number of consecutive connection failures that opens an upstream's circuit.
+	upstreamFailureThreshold = 3
+	// upstreamOpenDuration is how long an upstream with an open circuit is skipped before it is tried again.
+	upstreamOpenDuration = 30 * time.Second
+)
+
 // UpstreamInfo holds the information for a single upstream server, including its weight.
 type UpstreamInfo struct {
 	URL           *url.URL
 	Weight        int
 	CurrentWeight int
+
+	// Circuit breaker state, guarded by BaseChannel.upstreamLock.
+	failures  int
+	openUntil time.Time
 }
 
 // BaseChannel provides common functionality for channel proxies.
@@ -27,18 +40,23 @@ type BaseChannel struct {
 	Upstreams          []UpstreamInfo
 	HTTPClient         *http.Client
 	StreamClient       *http.Client
+	ValidationClient   *http.Client
 	TestModel          string
 	ValidationEndpoint string
+	SuccessStatusCodes []StatusCodeRange
+	UserAgent          string
 	upstreamLock       sync.Mutex
 
 	// Cached fields from the group for stale check
 	channelType     string
 	groupUpstreams  datatypes.JSON
+	groupConfig     datatypes.JSONMap
 	effectiveConfig *types.SystemSettings
 }
 
 // getUpstreamURL selects an upstream URL using a smooth weighted round-robin algorithm.
-func (b *BaseChannel) getUpstreamURL() *url.URL {
+// Upstreams in tried, keyed by URL, are skipped so a retry moves on to another upstream.
+func (b *BaseChannel) getUpstreamURL(tried map[string]bool) *url.URL {
 	b.upstreamLock.Lock()
 	defer b.upstreamLock.Unlock()
 
@@ -49,11 +67,31 @@ func (b *BaseChannel) getUpstreamURL() *url.URL {
 		return b.Upstreams[0].URL
 	}
 
+	// Prefer untried upstreams with a closed circuit, then untried ones, then any with a closed circuit.
+	now := time.Now()
+	untried := func(up *UpstreamInfo) bool { return !tried[up.URL.String()] }
+	closed := func(up *UpstreamInfo) bool { return now.After(up.openUntil) }
+	var candidates []*UpstreamInfo
+	for _, accept := range []func(*UpstreamInfo) bool{
+		func(up *UpstreamInfo) bool { return untried(up) && closed(up) },
+		untried,
+		closed,
+		func(*UpstreamInfo) bool { return true },
+	} {
+		for i := range b.Upstreams {
+			if accept(&b.Upstreams[i]) {
+				candidates = append(candidates, &b.Upstreams[i])
+			}
+		}
+		if len(candidates) > 0 {
+			break
+		}
+	}
+
 	totalWeight := 0
 	var best *UpstreamInfo
 
-	for i := range b.Upstreams {
-		up := &b.Upstreams[i]
+	for _, up := range candidates {
 		totalWeight += up.Weight
 		up.CurrentWeight += up.Weight
 
@@ -70,23 +108,73 @@ func (b *BaseChannel) getUpstreamURL() *url.URL {
 	return best.URL
 }
 
-// BuildUpstreamURL constructs the target URL for the upstream service.
-func (b *BaseChannel) BuildUpstreamURL(originalURL *url.URL, group *models.Group) (string, error) {
-	base := b.getUpstreamURL()
+// ReportUpstreamResult records whether the upstream serving upstreamURL could be reached.
+// Consecutive connection failures open the upstream's circuit so getUpstreamURL skips it for a while.
+func (b *BaseChannel) ReportUpstreamResult(upstreamURL string, reachable bool) {
+	b.upstreamLock.Lock()
+	defer b.upstreamLock.Unlock()
+
+	// The full request URL extends the upstream base URL, so the longest matching prefix is its upstream.
+	var matched *UpstreamInfo
+	for i := range b.Upstreams {
+		up := &b.Upstreams[i]
+		base := up.URL.String()
+		if strings.HasPrefix(upstreamURL, base) && (matched == nil || len(base) > len(matched.URL.String())) {
+			matched = up
+		}
+	}
+	if matched == nil {
+		return
+	}
+
+	if reachable {
+		matched.failures = 0
+		matched.openUntil = time.Time{}
+		return
+	}
+	matched.failures++
+	if matched.failures >= upstreamFailureThreshold {
+		matched.openUntil = time.Now().Add(upstreamOpenDuration)
+		logrus.WithFields(logrus.Fields{"channel": b.Name, "upstream": matched.URL.Redacted(), "failures": matched.failures}).Warn("Upstream is unreachable, skipping it temporarily.")
+	}
+}
+
+// BuildUpstreamURL constructs the target URL for the upstream service, preferring upstreams not in
+// triedUpstreams. It also returns the URL of the chosen upstream.
+func (b *BaseChannel) BuildUpstreamURL(originalURL *url.URL, group *models.Group, triedUpstreams map[string]bool) (string, string, error) {
+	base := b.getUpstreamURL(triedUpstreams)
 	if base == nil {
-		return "", fmt.Errorf("no upstream URL configured for channel %s", b.Name)
+		return "", "", fmt.Errorf("no upstream URL configured for channel %s", b.Name)
+	}
+	return buildUpstreamURL(base, originalURL, group), base.String(), nil
+}
+
+// BuildUpstreamURLs constructs the target URL on every upstream of the channel, bypassing load balancing.
+func (b *BaseChannel) BuildUpstreamURLs(originalURL *url.URL, group *models.Group) []string {
+	urls := make([]string, 0, len(b.Upstreams))
+	for _, up := range b.Upstreams {
+		urls = append(urls, buildUpstreamURL(up.URL, originalURL, group))
 	}
+	return urls
+}
 
+func buildUpstreamURL(base *url.URL, originalURL *url.URL, group *models.Group) string {
 	finalURL := *base
-	proxyPrefix := "/proxy/" + group.Name
+	// The group in the path is the one the client called, which differs from group for fallback
+	// groups and members of virtual groups.
 	requestPath := originalURL.Path
-	requestPath = strings.TrimPrefix(requestPath, proxyPrefix)
+	if rest, ok := strings.CutPrefix(requestPath, "/proxy/"); ok {
+		requestPath = ""
+		if i := strings.IndexByte(rest, '/'); i >= 0 {
+			requestPath = rest[i:]
+		}
+	}
 
 	finalURL.Path = strings.TrimRight(finalURL.Path, "/") + requestPath
 
 	finalURL.RawQuery = originalURL.RawQuery
 
-	return finalURL.String(), nil
+	return finalURL.String()
 }
 
 // IsConfigStale checks if the channel's configuration is stale compared to the provided group.
@@ -106,6 +194,28 @@ func (b *BaseChannel) IsConfigStale(group *models.Group) bool {
 	if !reflect.DeepEqual(b.effectiveConfig, &group.EffectiveConfig) {
 		return true
 	}
+	if !reflect.DeepEqual(b.groupConfig, group.Config) {
+		return true
+	}
+	return false
+}
+
+// setUserAgent replaces the client's User-Agent on an upstream request with the configured one.
+func (b *BaseChannel) setUserAgent(req *http.Request) {
+	req.Header.Set("User-Agent", b.UserAgent)
+}
+
+// IsSuccessResponse accepts the group's success status codes, and rejects bodies carrying a top-level
+// "error" field, which some OpenAI-compatible providers return with a 200 status.
+func (b *BaseChannel) IsSuccessResponse(statusCode int, body []byte) bool {
+	if !isSuccessStatus(b.SuccessStatusCodes, statusCode) {
+		return false
+	}
+	return body == nil || !hasErrorField(body)
+}
+
+// SupportsStreamUsage is false by default; OpenAI-compatible channels override it.
+func (b *BaseChannel) SupportsStreamUsage() bool {
 	return false
 }
 
@@ -11,8 +11,16 @@ import (
 
 // ChannelProxy defines the interface for different API channel proxies.
 type ChannelProxy interface {
-	// BuildUpstreamURL constructs the target URL for the upstream service.
-	BuildUpstreamURL(originalURL *url.URL, group *models.Group) (string, error)
+	// BuildUpstreamURL constructs the target URL for the upstream service, preferring upstreams
+	// not in triedUpstreams, and returns it with the URL of the chosen upstream.
+	BuildUpstreamURL(originalURL *url.URL, group *models.Group, triedUpstreams map[string]bool) (upstreamURL string, upstream string, err error)
+
+	// BuildUpstreamURLs constructs the target URL on every upstream, used to fan out a request.
+	BuildUpstreamURLs(originalURL *url.URL, group *models.Group) []string
+
+	// ReportUpstreamResult records whether the upstream serving upstreamURL could be reached,
+	// feeding the per-upstream circuit breaker.
+	ReportUpstreamResult(upstreamURL string, reachable bool)
 
 	// IsConfigStale checks if the channel's configuration is stale compared to the provided group.
 	IsConfigStale(group *models.Group) bool
@@ -26,9 +34,17 @@ type ChannelProxy interface {
 	// ModifyRequest allows the channel to add specific headers or modify the request
 	ModifyRequest(req *http.Request, apiKey *models.APIKey, group *models.Group)
 
+	// IsSuccessResponse decides whether an upstream response succeeded. body is the decoded response
+	// body, or nil for streaming responses, which are judged by status code alone.
+	IsSuccessResponse(statusCode int, body []byte) bool
+
 	// IsStreamRequest checks if the request is for a streaming response,
 	IsStreamRequest(c *gin.Context, bodyBytes []byte) bool
 
+	// SupportsStreamUsage reports whether the upstream accepts stream_options.include_usage
+	// to report token usage in the last chunk of a stream.
+	SupportsStreamUsage() bool
+
 	// ValidateKey checks if the given API key is valid.
 	ValidateKey(ctx context.Context, key string) (bool, error)
 }
@@ -6,6 +6,8 @@ import (
 	"gpt-load/internal/config"
 	"gpt-load/internal/httpclient"
 	"gpt-load/internal/models"
+	"gpt-load/internal/utils"
+	"gpt-load/internal/version"
 	"net/url"
 	"sync"
 	"time"
@@ -13,6 +15,14 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// validationConnectTimeout caps the connect timeout of key validation requests.
+const validationConnectTimeout = 5 * time.Second
+
+// defaultUserAgent identifies gpt-load to upstreams when no User-Agent is configured.
+func defaultUserAgent() string {
+	return "gpt-load/" + version.Get().Version
+}
+
 // channelConstructor defines the function signature for creating a new channel proxy.
 type channelConstructor func(f *Factory, group *models.Group) (ChannelProxy, error)
 
@@ -29,13 +39,17 @@ func Register(channelType string, constructor channelConstructor) {
 	channelRegistry[channelType] = constructor
 }
 
-// GetChannels returns a slice of all registered channel type names.
+// VirtualChannelType marks a group whose upstreams reference other groups instead of upstream URLs.
+// Virtual groups have no channel proxy of their own; requests are delegated to a member group.
+const VirtualChannelType = "virtual"
+
+// GetChannels returns a slice of all registered channel type names, plus the virtual group type.
 func GetChannels() []string {
-	supportedTypes := make([]string, 0, len(channelRegistry))
+	supportedTypes := make([]string, 0, len(channelRegistry)+1)
 	for t := range channelRegistry {
 		supportedTypes = append(supportedTypes, t)
 	}
-	return supportedTypes
+	return append(supportedTypes, VirtualChannelType)
 }
 
 // Factory is responsible for creating channel proxies.
@@ -68,11 +82,7 @@ func (f *Factory) GetChannel(group *models.Group) (ChannelProxy, error) {
 
 	logrus.Debugf("Creating new channel for group %d with type '%s'", group.ID, group.ChannelType)
 
-	constructor, ok := channelRegistry[group.ChannelType]
-	if !ok {
-		return nil, fmt.Errorf("unsupported channel type: %s", group.ChannelType)
-	}
-	channel, err := constructor(f, group)
+	channel, err := f.NewChannel(group)
 	if err != nil {
 		return nil, err
 	}
@@ -80,6 +90,16 @@ func (f *Factory) GetChannel(group *models.Group) (ChannelProxy, error) {
 	return channel, nil
 }
 
+// NewChannel creates a channel proxy for the group without caching it, for groups whose
+// configuration has not been saved yet.
+func (f *Factory) NewChannel(group *models.Group) (ChannelProxy, error) {
+	constructor, ok := channelRegistry[group.ChannelType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported channel type: %s", group.ChannelType)
+	}
+	return constructor(f, group)
+}
+
 // newBaseChannel is a helper function to create and configure a BaseChannel.
 func (f *Factory) newBaseChannel(name string, group *models.Group) (*BaseChannel, error) {
 	type upstreamDef struct {
@@ -109,6 +129,11 @@ func (f *Factory) newBaseChannel(name string, group *models.Group) (*BaseChannel
 		upstreamInfos = append(upstreamInfos, UpstreamInfo{URL: u, Weight: weight})
 	}
 
+	ipPins, err := httpclient.ParseIPPins(group.EffectiveConfig.UpstreamIPPins)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upstream ip pins for %s channel: %w", name, err)
+	}
+
 	// Base configuration for regular requests, derived from the group's effective settings.
 	clientConfig := &httpclient.Config{
 		ConnectTimeout:        time.Duration(group.EffectiveConfig.ConnectTimeout) * time.Second,
@@ -118,36 +143,92 @@ func (f *Factory) newBaseChannel(name string, group *models.Group) (*BaseChannel
 		MaxIdleConnsPerHost:   group.EffectiveConfig.MaxIdleConnsPerHost,
 		ResponseHeaderTimeout: time.Duration(group.EffectiveConfig.ResponseHeaderTimeout) * time.Second,
 		DisableCompression:    false,
-		WriteBufferSize:       32 * 1024,
-		ReadBufferSize:        32 * 1024,
+		WriteBufferSize:       group.EffectiveConfig.WriteBufferSize,
+		ReadBufferSize:        group.EffectiveConfig.ReadBufferSize,
 		ForceAttemptHTTP2:     true,
 		TLSHandshakeTimeout:   15 * time.Second,
 		ExpectContinueTimeout: 1 * time.Second,
+		DNSCacheTTL:           time.Duration(group.EffectiveConfig.DNSCacheTTL) * time.Second,
+		IPPins:                ipPins,
+		ProxyURL:              group.EffectiveConfig.UpstreamProxyURL,
+		Label:                 group.Name,
+	}
+
+	groupOptions, err := utils.ParseGroupConfig(group.Config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse group config for %s channel: %w", name, err)
+	}
+	// An isolated pool keeps a noisy group from exhausting idle connections shared with other groups,
+	// at the cost of one extra set of sockets per group.
+	if groupOptions.IsolatedConnectionPool {
+		clientConfig.PoolKey = fmt.Sprintf("group:%d", group.ID)
+	}
+	clientConfig.FollowRedirects = groupOptions.FollowRedirects
+	successStatusCodes, err := ParseStatusCodes(groupOptions.SuccessStatusCodes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid success status codes for %s channel: %w", name, err)
+	}
+	clientConfig.TLS = httpclient.TLSOptions{
+		ClientCert:         groupOptions.TLSClientCert,
+		ClientKey:          groupOptions.TLSClientKey,
+		CACert:             groupOptions.TLSCACert,
+		InsecureSkipVerify: groupOptions.InsecureSkipVerify,
 	}
 
 	// Create a dedicated configuration for streaming requests.
 	streamConfig := *clientConfig
+	streamConfig.Label = group.Name + "/stream"
 	streamConfig.RequestTimeout = 0
 	streamConfig.DisableCompression = true
 	streamConfig.WriteBufferSize = 0
 	streamConfig.ReadBufferSize = 0
 	// Use a larger, independent connection pool for streaming clients to avoid exhaustion.
-	streamConfig.MaxIdleConns = max(group.EffectiveConfig.MaxIdleConns*2, 50)
-	streamConfig.MaxIdleConnsPerHost = max(group.EffectiveConfig.MaxIdleConnsPerHost*2, 20)
+	streamConfig.MaxIdleConns = max(group.EffectiveConfig.MaxIdleConns*group.EffectiveConfig.StreamPoolMultiplier, 50)
+	streamConfig.MaxIdleConnsPerHost = max(group.EffectiveConfig.MaxIdleConnsPerHost*group.EffectiveConfig.StreamPoolMultiplier, 20)
+
+	// Key validation gets its own small pool, so validating slow keys never holds proxy connections,
+	// and fails fast on unreachable upstreams.
+	validationConfig := *clientConfig
+	validationConfig.RequestTimeout = time.Duration(group.EffectiveConfig.KeyValidationTimeoutSeconds) * time.Second
+	validationConfig.ResponseHeaderTimeout = validationConfig.RequestTimeout
+	validationConfig.ConnectTimeout = min(clientConfig.ConnectTimeout, validationConnectTimeout)
+	validationConfig.MaxIdleConns = 10
+	validationConfig.MaxIdleConnsPerHost = 2
+	validationConfig.PoolKey = "validation:" + clientConfig.PoolKey
+	validationConfig.Label = group.Name + "/validation"
+
+	// Get the clients from the manager using their respective configurations.
+	httpClient, err := f.clientManager.GetClient(clientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP client for %s channel: %w", name, err)
+	}
+	streamClient, err := f.clientManager.GetClient(&streamConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stream HTTP client for %s channel: %w", name, err)
+	}
+	validationClient, err := f.clientManager.GetClient(&validationConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create validation HTTP client for %s channel: %w", name, err)
+	}
 
-	// Get both clients from the manager using their respective configurations.
-	httpClient := f.clientManager.GetClient(clientConfig)
-	streamClient := f.clientManager.GetClient(&streamConfig)
+	userAgent := group.EffectiveConfig.UpstreamUserAgent
+	if userAgent == "" {
+		userAgent = defaultUserAgent()
+	}
 
 	return &BaseChannel{
 		Name:               name,
 		Upstreams:          upstreamInfos,
 		HTTPClient:         httpClient,
 		StreamClient:       streamClient,
+		ValidationClient:   validationClient,
 		TestModel:          group.TestModel,
 		ValidationEndpoint: group.ValidationEndpoint,
+		SuccessStatusCodes: successStatusCodes,
+		UserAgent:          userAgent,
 		channelType:        group.ChannelType,
 		groupUpstreams:     group.Upstreams,
+		groupConfig:        group.Config,
 		effectiveConfig:    &group.EffectiveConfig,
 	}, nil
 }
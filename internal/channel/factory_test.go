@@ -0,0 +1,62 @@
+package channel
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gpt-load/internal/httpclient"
+	"gpt-load/internal/models"
+	"gpt-load/internal/types"
+
+	"gorm.io/datatypes"
+)
+
+func TestValidateKeyEnforcesValidationTimeout(t *testing.T) {
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Hang until the test ends, well past the validation timeout.
+		<-release
+	}))
+	defer upstream.Close()
+	defer close(release)
+
+	group := &models.Group{
+		ID:          1,
+		Name:        "test",
+		ChannelType: "openai",
+		TestModel:   "gpt-4o-mini",
+		Upstreams:   datatypes.JSON(`[{"url":"` + upstream.URL + `","weight":1}]`),
+		EffectiveConfig: types.SystemSettings{
+			RequestTimeout:              600,
+			ResponseHeaderTimeout:       600,
+			ConnectTimeout:              15,
+			IdleConnTimeout:             120,
+			MaxIdleConns:                100,
+			MaxIdleConnsPerHost:         50,
+			StreamPoolMultiplier:        2,
+			KeyValidationTimeoutSeconds: 1,
+		},
+	}
+	ch, err := NewFactory(nil, httpclient.NewHTTPClientManager()).NewChannel(group)
+	if err != nil {
+		t.Fatalf("NewChannel() error = %v", err)
+	}
+
+	// No deadline on the context: the validation client alone must stop the request.
+	start := time.Now()
+	valid, err := ch.ValidateKey(context.Background(), "sk-test")
+	elapsed := time.Since(start)
+
+	if valid || err == nil {
+		t.Fatalf("ValidateKey() = %v, %v; want a timeout error", valid, err)
+	}
+	if elapsed > 3*time.Second {
+		t.Errorf("ValidateKey() took %s, want about the 1s validation timeout", elapsed)
+	}
+	if elapsed < time.Second {
+		t.Errorf("ValidateKey() returned after %s, before the validation timeout: %v", elapsed, err)
+	}
+}
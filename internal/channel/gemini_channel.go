@@ -17,12 +17,18 @@ import (
 
 func init() {
 	Register("gemini", newGeminiChannel)
+	RegisterKeyFormat("gemini", isGeminiKeyFormat)
 }
 
 type GeminiChannel struct {
 	*BaseChannel
 }
 
+// isGeminiKeyFormat accepts Google API keys, which start with "AIza".
+func isGeminiKeyFormat(key string) bool {
+	return strings.HasPrefix(key, "AIza")
+}
+
 func newGeminiChannel(f *Factory, group *models.Group) (ChannelProxy, error) {
 	base, err := f.newBaseChannel("gemini", group)
 	if err != nil {
@@ -43,6 +49,7 @@ func (ch *GeminiChannel) ModifyRequest(req *http.Request, apiKey *models.APIKey,
 		q.Set("key", apiKey.KeyValue)
 		req.URL.RawQuery = q.Encode()
 	}
+	ch.setUserAgent(req)
 }
 
 // IsStreamRequest checks if the request is for a streaming response.
@@ -73,7 +80,7 @@ func (ch *GeminiChannel) IsStreamRequest(c *gin.Context, bodyBytes []byte) bool
 
 // ValidateKey checks if the given API key is valid by making a generateContent request.
 func (ch *GeminiChannel) ValidateKey(ctx context.Context, key string) (bool, error) {
-	upstreamURL := ch.getUpstreamURL()
+	upstreamURL := ch.getUpstreamURL(nil)
 	if upstreamURL == nil {
 		return false, fmt.Errorf("no upstream URL configured for channel %s", ch.Name)
 	}
@@ -102,8 +109,9 @@ func (ch *GeminiChannel) ValidateKey(ctx context.Context, key string) (bool, err
 		return false, fmt.Errorf("failed to create validation request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	ch.setUserAgent(req)
 
-	resp, err := ch.HTTPClient.Do(req)
+	resp, err := ch.ValidationClient.Do(req)
 	if err != nil {
 		return false, fmt.Errorf("failed to send validation request: %w", err)
 	}
@@ -0,0 +1,44 @@
+package channel
+
+import "fmt"
+
+// KeyFormatValidator reports whether a key looks like a valid key for a channel type.
+// It only rejects clearly malformed keys; whether a key actually works is checked by ValidateKey.
+type KeyFormatValidator func(key string) bool
+
+var (
+	// keyFormatRegistry holds the optional key format validator of each channel type.
+	keyFormatRegistry = make(map[string]KeyFormatValidator)
+	// keyFormatHintRegistry holds the optional key format hint of each channel type.
+	keyFormatHintRegistry = make(map[string]KeyFormatValidator)
+)
+
+// RegisterKeyFormat adds a key format validator for a channel type.
+func RegisterKeyFormat(channelType string, validator KeyFormatValidator) {
+	if _, exists := keyFormatRegistry[channelType]; exists {
+		panic(fmt.Sprintf("key format for channel type '%s' is already registered", channelType))
+	}
+	keyFormatRegistry[channelType] = validator
+}
+
+// GetKeyFormatValidator returns the key format validator of a channel type, if it defines one.
+func GetKeyFormatValidator(channelType string) (KeyFormatValidator, bool) {
+	validator, ok := keyFormatRegistry[channelType]
+	return validator, ok
+}
+
+// RegisterKeyFormatHint adds a key format hint for a channel type. Unlike a validator, keys failing
+// a hint are still imported, with a warning. It suits channel types whose groups also serve
+// compatible upstreams that take other providers' keys.
+func RegisterKeyFormatHint(channelType string, hint KeyFormatValidator) {
+	if _, exists := keyFormatHintRegistry[channelType]; exists {
+		panic(fmt.Sprintf("key format hint for channel type '%s' is already registered", channelType))
+	}
+	keyFormatHintRegistry[channelType] = hint
+}
+
+// GetKeyFormatHint returns the key format hint of a channel type, if it defines one.
+func GetKeyFormatHint(channelType string) (KeyFormatValidator, bool) {
+	hint, ok := keyFormatHintRegistry[channelType]
+	return hint, ok
+}
@@ -17,12 +17,19 @@ import (
 
 func init() {
 	Register("openai", newOpenAIChannel)
+	RegisterKeyFormatHint("openai", isOpenAIKeyFormat)
 }
 
 type OpenAIChannel struct {
 	*BaseChannel
 }
 
+// isOpenAIKeyFormat flags keys issued by other providers. It is only a hint: OpenAI groups are also
+// used for OpenAI-compatible upstreams, such as Gemini's OpenAI endpoint, which take those keys.
+func isOpenAIKeyFormat(key string) bool {
+	return !strings.HasPrefix(key, "sk-ant-") && !strings.HasPrefix(key, "AIza")
+}
+
 func newOpenAIChannel(f *Factory, group *models.Group) (ChannelProxy, error) {
 	base, err := f.newBaseChannel("openai", group)
 	if err != nil {
@@ -37,6 +44,7 @@ func newOpenAIChannel(f *Factory, group *models.Group) (ChannelProxy, error) {
 // ModifyRequest sets the Authorization header for the OpenAI service.
 func (ch *OpenAIChannel) ModifyRequest(req *http.Request, apiKey *models.APIKey, group *models.Group) {
 	req.Header.Set("Authorization", "Bearer "+apiKey.KeyValue)
+	ch.setUserAgent(req)
 }
 
 // IsStreamRequest checks if the request is for a streaming response using the pre-read body.
@@ -60,9 +68,14 @@ func (ch *OpenAIChannel) IsStreamRequest(c *gin.Context, bodyBytes []byte) bool
 	return false
 }
 
+// SupportsStreamUsage reports that OpenAI accepts stream_options.include_usage.
+func (ch *OpenAIChannel) SupportsStreamUsage() bool {
+	return true
+}
+
 // ValidateKey checks if the given API key is valid by making a chat completion request.
 func (ch *OpenAIChannel) ValidateKey(ctx context.Context, key string) (bool, error) {
-	upstreamURL := ch.getUpstreamURL()
+	upstreamURL := ch.getUpstreamURL(nil)
 	if upstreamURL == nil {
 		return false, fmt.Errorf("no upstream URL configured for channel %s", ch.Name)
 	}
@@ -95,8 +108,9 @@ func (ch *OpenAIChannel) ValidateKey(ctx context.Context, key string) (bool, err
 	}
 	req.Header.Set("Authorization", "Bearer "+key)
 	req.Header.Set("Content-Type", "application/json")
+	ch.setUserAgent(req)
 
-	resp, err := ch.HTTPClient.Do(req)
+	resp, err := ch.ValidationClient.Do(req)
 	if err != nil {
 		return false, fmt.Errorf("failed to send validation request: %w", err)
 	}
@@ -0,0 +1,66 @@
+package channel
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// StatusCodeRange is an inclusive range of HTTP status codes.
+type StatusCodeRange struct {
+	Min int
+	Max int
+}
+
+// ParseStatusCodes parses a comma-separated list of status codes and ranges, e.g. "200-299,304".
+func ParseStatusCodes(value string) ([]StatusCodeRange, error) {
+	var ranges []StatusCodeRange
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		low, high, isRange := strings.Cut(part, "-")
+		minCode, err := strconv.Atoi(strings.TrimSpace(low))
+		if err != nil {
+			return nil, fmt.Errorf("invalid status code '%s'", part)
+		}
+		maxCode := minCode
+		if isRange {
+			if maxCode, err = strconv.Atoi(strings.TrimSpace(high)); err != nil {
+				return nil, fmt.Errorf("invalid status code range '%s'", part)
+			}
+		}
+		if minCode < 100 || maxCode > 599 || minCode > maxCode {
+			return nil, fmt.Errorf("invalid status code range '%s': codes must be between 100 and 599", part)
+		}
+		ranges = append(ranges, StatusCodeRange{Min: minCode, Max: maxCode})
+	}
+	return ranges, nil
+}
+
+// isSuccessStatus reports whether statusCode is in ranges. Without ranges, any status below 400 is a success.
+func isSuccessStatus(ranges []StatusCodeRange, statusCode int) bool {
+	if len(ranges) == 0 {
+		return statusCode < 400
+	}
+	for _, r := range ranges {
+		if statusCode >= r.Min && statusCode <= r.Max {
+			return true
+		}
+	}
+	return false
+}
+
+// hasErrorField reports whether body is a JSON object with a non-null top-level "error" field,
+// the way OpenAI-compatible and Gemini upstreams report errors.
+func hasErrorField(body []byte) bool {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return false
+	}
+	errorField, ok := doc["error"]
+	return ok && string(errorField) != "null"
+}
@@ -49,6 +49,10 @@ type Config struct {
 	Log         types.LogConfig         `json:"log"`
 	Database    types.DatabaseConfig    `json:"database"`
 	RedisDSN    string                  `json:"redis_dsn"`
+	// RedisKeyPrefix namespaces all Redis keys and channels, so several instances can share one Redis.
+	RedisKeyPrefix string `json:"redis_key_prefix"`
+	// EncryptionKey encrypts API key values at rest when set.
+	EncryptionKey string `json:"-"`
 }
 
 // NewManager creates a new configuration manager
@@ -68,6 +72,20 @@ func (m *Manager) ReloadConfig() error {
 		logrus.Info("Info: Create .env file to support environment variable configuration")
 	}
 
+	// Secrets can also be mounted as files, e.g. AUTH_KEY_FILE=/run/secrets/auth_key
+	secrets := make(map[string]string)
+	for _, key := range []string{"AUTH_KEY", "DATABASE_DSN", "DATABASE_READ_DSN", "REDIS_DSN", "ENCRYPTION_KEY"} {
+		value, err := utils.GetSecretEnv(key)
+		if err != nil {
+			return err
+		}
+		secrets[key] = value
+	}
+	databaseDSN := secrets["DATABASE_DSN"]
+	if databaseDSN == "" {
+		databaseDSN = "./data/gpt-load.db"
+	}
+
 	config := &Config{
 		Server: types.ServerConfig{
 			IsMaster:                !utils.ParseBoolean(os.Getenv("IS_SLAVE"), false),
@@ -77,9 +95,10 @@ func (m *Manager) ReloadConfig() error {
 			WriteTimeout:            utils.ParseInteger(os.Getenv("SERVER_WRITE_TIMEOUT"), 600),
 			IdleTimeout:             utils.ParseInteger(os.Getenv("SERVER_IDLE_TIMEOUT"), 120),
 			GracefulShutdownTimeout: utils.ParseInteger(os.Getenv("SERVER_GRACEFUL_SHUTDOWN_TIMEOUT"), 10),
+			StartupConnectTimeout:   utils.ParseInteger(os.Getenv("STARTUP_CONNECT_TIMEOUT"), 60),
 		},
 		Auth: types.AuthConfig{
-			Key: os.Getenv("AUTH_KEY"),
+			Key: secrets["AUTH_KEY"],
 		},
 		CORS: types.CORSConfig{
 			Enabled:          utils.ParseBoolean(os.Getenv("ENABLE_CORS"), true),
@@ -92,15 +111,22 @@ func (m *Manager) ReloadConfig() error {
 			MaxConcurrentRequests: utils.ParseInteger(os.Getenv("MAX_CONCURRENT_REQUESTS"), 100),
 		},
 		Log: types.LogConfig{
-			Level:      utils.GetEnvOrDefault("LOG_LEVEL", "info"),
-			Format:     utils.GetEnvOrDefault("LOG_FORMAT", "text"),
-			EnableFile: utils.ParseBoolean(os.Getenv("LOG_ENABLE_FILE"), false),
-			FilePath:   utils.GetEnvOrDefault("LOG_FILE_PATH", "./data/logs/app.log"),
+			Level:                utils.GetEnvOrDefault("LOG_LEVEL", "info"),
+			Format:               utils.GetEnvOrDefault("LOG_FORMAT", "text"),
+			EnableFile:           utils.ParseBoolean(os.Getenv("LOG_ENABLE_FILE"), false),
+			FilePath:             utils.GetEnvOrDefault("LOG_FILE_PATH", "./data/logs/app.log"),
+			RequestLogBufferPath: utils.GetEnvOrDefault("REQUEST_LOG_BUFFER_PATH", "./data/request_logs.buffer"),
 		},
 		Database: types.DatabaseConfig{
-			DSN: utils.GetEnvOrDefault("DATABASE_DSN", "./data/gpt-load.db"),
+			DSN:             databaseDSN,
+			ReadDSN:         secrets["DATABASE_READ_DSN"],
+			MaxOpenConns:    utils.ParseInteger(os.Getenv("DB_MAX_OPEN_CONNS"), 500),
+			MaxIdleConns:    utils.ParseInteger(os.Getenv("DB_MAX_IDLE_CONNS"), 50),
+			ConnMaxLifetime: utils.ParseInteger(os.Getenv("DB_CONN_MAX_LIFETIME"), 3600),
 		},
-		RedisDSN: os.Getenv("REDIS_DSN"),
+		RedisDSN:       secrets["REDIS_DSN"],
+		RedisKeyPrefix: os.Getenv("REDIS_KEY_PREFIX"),
+		EncryptionKey:  secrets["ENCRYPTION_KEY"],
 	}
 	m.config = config
 
@@ -142,6 +168,16 @@ func (m *Manager) GetRedisDSN() string {
 	return m.config.RedisDSN
 }
 
+// GetRedisKeyPrefix returns the prefix prepended to all Redis keys and channels.
+func (m *Manager) GetRedisKeyPrefix() string {
+	return m.config.RedisKeyPrefix
+}
+
+// GetEncryptionKey returns the secret used to encrypt API key values at rest.
+func (m *Manager) GetEncryptionKey() string {
+	return m.config.EncryptionKey
+}
+
 // GetDatabaseConfig returns the database configuration.
 func (m *Manager) GetDatabaseConfig() types.DatabaseConfig {
 	return m.config.Database
@@ -176,6 +212,24 @@ func (m *Manager) Validate() error {
 		m.config.Server.GracefulShutdownTimeout = 10
 	}
 
+	if m.config.Server.StartupConnectTimeout < 0 {
+		validationErrors = append(validationErrors, "STARTUP_CONNECT_TIMEOUT cannot be negative")
+	}
+
+	// Validate database connection pool
+	if m.config.Database.MaxOpenConns < 1 {
+		validationErrors = append(validationErrors, "DB_MAX_OPEN_CONNS cannot be less than 1")
+	}
+	if m.config.Database.MaxIdleConns < 0 {
+		validationErrors = append(validationErrors, "DB_MAX_IDLE_CONNS cannot be negative")
+	} else if m.config.Database.MaxOpenConns >= 1 && m.config.Database.MaxIdleConns > m.config.Database.MaxOpenConns {
+		logrus.Warnf("DB_MAX_IDLE_CONNS value %d exceeds DB_MAX_OPEN_CONNS, resetting to %d.", m.config.Database.MaxIdleConns, m.config.Database.MaxOpenConns)
+		m.config.Database.MaxIdleConns = m.config.Database.MaxOpenConns
+	}
+	if m.config.Database.ConnMaxLifetime < 0 {
+		validationErrors = append(validationErrors, "DB_CONN_MAX_LIFETIME cannot be negative")
+	}
+
 	if len(validationErrors) > 0 {
 		logrus.Error("Configuration validation failed:")
 		for _, err := range validationErrors {
@@ -200,6 +254,7 @@ func (m *Manager) DisplayServerConfig() {
 	logrus.Info("  --- Server ---")
 	logrus.Infof("    Listen Address: %s:%d", serverConfig.Host, serverConfig.Port)
 	logrus.Infof("    Graceful Shutdown Timeout: %d seconds", serverConfig.GracefulShutdownTimeout)
+	logrus.Infof("    Startup Connect Timeout: %d seconds", serverConfig.StartupConnectTimeout)
 	logrus.Infof("    Read Timeout: %d seconds", serverConfig.ReadTimeout)
 	logrus.Infof("    Write Timeout: %d seconds", serverConfig.WriteTimeout)
 	logrus.Infof("    Idle Timeout: %d seconds", serverConfig.IdleTimeout)
@@ -214,6 +269,11 @@ func (m *Manager) DisplayServerConfig() {
 		corsStatus = fmt.Sprintf("enabled (Origins: %s)", strings.Join(corsConfig.AllowedOrigins, ", "))
 	}
 	logrus.Infof("    CORS: %s", corsStatus)
+	if m.config.EncryptionKey != "" {
+		logrus.Info("    Key Encryption: enabled")
+	} else {
+		logrus.Info("    Key Encryption: disabled")
+	}
 
 	logrus.Info("  --- Logging ---")
 	logrus.Infof("    Log Level: %s", logConfig.Level)
@@ -222,6 +282,7 @@ func (m *Manager) DisplayServerConfig() {
 	if logConfig.EnableFile {
 		logrus.Infof("    Log File Path: %s", logConfig.FilePath)
 	}
+	logrus.Infof("    Request Log Buffer Path: %s", logConfig.RequestLogBufferPath)
 
 	logrus.Info("  --- Dependencies ---")
 	if dbConfig.DSN != "" {
@@ -229,8 +290,21 @@ func (m *Manager) DisplayServerConfig() {
 	} else {
 		logrus.Info("    Database: not configured")
 	}
+	if dbConfig.ReadDSN != "" {
+		logrus.Info("    Read Replica: configured")
+	}
+	logrus.Infof("    DB Max Open Conns: %d", dbConfig.MaxOpenConns)
+	logrus.Infof("    DB Max Idle Conns: %d", dbConfig.MaxIdleConns)
+	if dbConfig.ConnMaxLifetime > 0 {
+		logrus.Infof("    DB Conn Max Lifetime: %d seconds", dbConfig.ConnMaxLifetime)
+	} else {
+		logrus.Info("    DB Conn Max Lifetime: unlimited")
+	}
 	if m.config.RedisDSN != "" {
 		logrus.Info("    Redis: configured")
+		if m.config.RedisKeyPrefix != "" {
+			logrus.Infof("    Redis Key Prefix: %s", m.config.RedisKeyPrefix)
+		}
 	} else {
 		logrus.Info("    Redis: not configured")
 	}
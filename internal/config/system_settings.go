@@ -2,18 +2,24 @@ package config
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"gpt-load/internal/db"
+	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/httpclient"
 	"gpt-load/internal/models"
 	"gpt-load/internal/store"
 	"gpt-load/internal/syncer"
 	"gpt-load/internal/types"
 	"gpt-load/internal/utils"
+	"net/url"
 	"os"
 	"reflect"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/sirupsen/logrus"
 	"gorm.io/datatypes"
@@ -22,6 +28,9 @@ import (
 
 const SettingsUpdateChannel = "system_settings:updated"
 
+// defaultGroupNamePattern matches the group names accepted when creating a group.
+var defaultGroupNamePattern = regexp.MustCompile("^[a-z0-9_-]{3,30}$")
+
 // SystemSettingsManager 管理系统配置
 type SystemSettingsManager struct {
 	syncer *syncer.CacheSyncer[types.SystemSettings]
@@ -74,13 +83,33 @@ func (sm *SystemSettingsManager) Initialize(store store.Store, gm groupManager,
 		}
 
 		settings.ProxyKeysMap = utils.StringToSet(settings.ProxyKeys, ",")
-
-		sm.DisplaySystemConfig(settings)
+		quotas, err := utils.ParseProxyKeyQuotas(settings.ProxyKeyQuotas)
+		if err != nil {
+			logrus.Warnf("Ignoring invalid proxy key quotas: %v", err)
+		}
+		settings.ProxyKeyQuotasMap = quotas
+		pricing, err := utils.ParseModelPricing(settings.ModelPricing)
+		if err != nil {
+			logrus.Warnf("Ignoring invalid model pricing: %v", err)
+		}
+		settings.ModelPricingMap = pricing
+		errorRules, err := app_errors.ParseErrorRules(settings.ErrorClassificationRules)
+		if err != nil {
+			logrus.Warnf("Ignoring invalid error classification rules: %v", err)
+		}
+		settings.ErrorRules = errorRules
+		redactionPatterns, err := utils.ParseRedactionPatterns(settings.LogRedactionPatterns)
+		if err != nil {
+			// The valid patterns still apply, so one bad line does not stop all redaction.
+			logrus.Warnf("Ignoring invalid log redaction patterns, %d valid patterns remain: %v", len(redactionPatterns), err)
+		}
+		settings.RedactionPatterns = redactionPatterns
 
 		return settings, nil
 	}
 
 	afterLoader := func(newData types.SystemSettings) {
+		sm.DisplaySystemConfig(newData)
 		if !isMaster {
 			return
 		}
@@ -93,6 +122,9 @@ func (sm *SystemSettingsManager) Initialize(store store.Store, gm groupManager,
 		SettingsUpdateChannel,
 		logrus.WithField("syncer", "system_settings"),
 		afterLoader,
+		func(settings types.SystemSettings) time.Duration {
+			return time.Duration(settings.CacheResyncIntervalMinutes) * time.Minute
+		},
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create system settings syncer: %w", err)
@@ -178,12 +210,27 @@ func (sm *SystemSettingsManager) GetAppUrl() string {
 	return fmt.Sprintf("http://%s:%s", host, port)
 }
 
-// UpdateSettings 更新系统配置
-func (sm *SystemSettingsManager) UpdateSettings(settingsMap map[string]any) error {
+// GetDisplayLocation returns the configured display timezone, falling back to the server's local timezone.
+func (sm *SystemSettingsManager) GetDisplayLocation() *time.Location {
+	timezone := sm.GetSettings().DisplayTimezone
+	if timezone == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		logrus.Warnf("Invalid display timezone '%s', using local timezone: %v", timezone, err)
+		return time.Local
+	}
+	return loc
+}
+
+// UpdateSettings 更新系统配置，返回本次修改中需重启服务才能生效的配置项
+func (sm *SystemSettingsManager) UpdateSettings(settingsMap map[string]any) ([]string, error) {
 	// 验证配置项
 	if err := sm.ValidateSettings(settingsMap); err != nil {
-		return err
+		return nil, err
 	}
+	restartRequired := restartRequiredSettings(sm.GetSettings(), settingsMap)
 
 	// 更新数据库
 	var settingsToUpdate []models.SystemSetting
@@ -199,12 +246,34 @@ func (sm *SystemSettingsManager) UpdateSettings(settingsMap map[string]any) erro
 			Columns:   []clause.Column{{Name: "setting_key"}},
 			DoUpdates: clause.AssignmentColumns([]string{"setting_value", "updated_at"}),
 		}).Create(&settingsToUpdate).Error; err != nil {
-			return fmt.Errorf("failed to update system settings: %w", err)
+			return nil, fmt.Errorf("failed to update system settings: %w", err)
 		}
 	}
 
 	// 触发所有实例重新加载
-	return sm.syncer.Invalidate()
+	return restartRequired, sm.syncer.Invalidate()
+}
+
+// restartRequiredSettings 返回 settingsMap 中值发生变化、且字段带有 restart:"true" 标签的配置项，
+// 这些配置项保存后需重启服务才能生效。current 为配置结构体。
+func restartRequiredSettings(current any, settingsMap map[string]any) []string {
+	v := reflect.ValueOf(current)
+	t := v.Type()
+	keys := []string{}
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if field.Tag.Get("restart") != "true" {
+			continue
+		}
+		key := field.Tag.Get("json")
+		value, ok := settingsMap[key]
+		// 与保存时相同，按字符串形式比较
+		if !ok || fmt.Sprintf("%v", value) == fmt.Sprintf("%v", v.Field(i).Interface()) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
 }
 
 // GetEffectiveConfig 获取有效配置 (系统配置 + 分组覆盖)
@@ -215,14 +284,9 @@ func (sm *SystemSettingsManager) GetEffectiveConfig(groupConfigJSON datatypes.JS
 		return effectiveConfig
 	}
 
-	var groupConfig models.GroupConfig
-	groupConfigBytes, err := groupConfigJSON.MarshalJSON()
+	groupConfig, err := utils.ParseGroupConfig(groupConfigJSON)
 	if err != nil {
-		logrus.Warnf("Failed to marshal group config JSON, using system settings only. Error: %v", err)
-		return effectiveConfig
-	}
-	if err := json.Unmarshal(groupConfigBytes, &groupConfig); err != nil {
-		logrus.Warnf("Failed to unmarshal group config, using system settings only. Error: %v", err)
+		logrus.Warnf("Failed to parse group config, using system settings only. Error: %v", err)
 		return effectiveConfig
 	}
 
@@ -277,27 +341,97 @@ func (sm *SystemSettingsManager) ValidateSettings(settingsMap map[string]any) er
 			if floatVal != float64(intVal) {
 				return fmt.Errorf("invalid value for %s: must be an integer", key)
 			}
-
-			if strings.HasPrefix(validateTag, "min=") {
-				minValStr := strings.TrimPrefix(validateTag, "min=")
-				minVal, _ := strconv.Atoi(minValStr)
-				if intVal < minVal {
-					return fmt.Errorf("value for %s (%d) is below minimum value (%d)", key, intVal, minVal)
-				}
+			if err := validateSettingRules(key, intVal, validateTag); err != nil {
+				return err
 			}
 		case reflect.Bool:
 			if _, ok := value.(bool); !ok {
 				return fmt.Errorf("invalid type for %s: expected a boolean, got %T", key, value)
 			}
 		case reflect.String:
-			if _, ok := value.(string); !ok {
+			strVal, ok := value.(string)
+			if !ok {
 				return fmt.Errorf("invalid type for %s: expected a string, got %T", key, value)
 			}
+			if err := validateSettingRules(key, strVal, validateTag); err != nil {
+				return err
+			}
 		default:
 			return fmt.Errorf("unsupported type for setting key validation: %s", key)
 		}
 	}
 
+	if quotas, ok := settingsMap["proxy_key_quotas"].(string); ok {
+		if _, err := utils.ParseProxyKeyQuotas(quotas); err != nil {
+			return err
+		}
+	}
+	if pricing, ok := settingsMap["model_pricing"].(string); ok {
+		if _, err := utils.ParseModelPricing(pricing); err != nil {
+			return err
+		}
+	}
+	if groupName, ok := settingsMap["default_group"].(string); ok && groupName != "" {
+		if !defaultGroupNamePattern.MatchString(groupName) {
+			return fmt.Errorf("invalid default_group '%s': must be a valid group name", groupName)
+		}
+	}
+	if rules, ok := settingsMap["error_classification_rules"].(string); ok {
+		if _, err := app_errors.ParseErrorRules(rules); err != nil {
+			return err
+		}
+	}
+	if patterns, ok := settingsMap["log_redaction_patterns"].(string); ok {
+		if _, err := utils.ParseRedactionPatterns(patterns); err != nil {
+			return err
+		}
+	}
+	if pins, ok := settingsMap["upstream_ip_pins"].(string); ok {
+		if _, err := httpclient.ParseIPPins(pins); err != nil {
+			return err
+		}
+	}
+	if timezone, ok := settingsMap["display_timezone"].(string); ok && timezone != "" {
+		if _, err := time.LoadLocation(timezone); err != nil {
+			return fmt.Errorf("invalid display_timezone '%s': %w", timezone, err)
+		}
+	}
+	if proxyURL, ok := settingsMap["upstream_proxy_url"].(string); ok {
+		if _, err := httpclient.ParseProxyURL(proxyURL); err != nil {
+			return err
+		}
+	}
+	if userAgent, ok := settingsMap["upstream_user_agent"].(string); ok {
+		if err := httpclient.ValidateUserAgent(userAgent); err != nil {
+			return err
+		}
+	}
+	if err := sm.validateIPPinsWithProxy(settingsMap); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// validateIPPinsWithProxy rejects updates that leave both upstream_ip_pins and upstream_proxy_url set.
+// Through a proxy, the proxy resolves the upstream host, so the pins would be silently ignored.
+// Updates that change neither setting are not checked.
+func (sm *SystemSettingsManager) validateIPPinsWithProxy(settingsMap map[string]any) error {
+	pins, pinsChanged := settingsMap["upstream_ip_pins"].(string)
+	proxyURL, proxyChanged := settingsMap["upstream_proxy_url"].(string)
+	if !pinsChanged && !proxyChanged {
+		return nil
+	}
+	current := sm.GetSettings()
+	if !pinsChanged {
+		pins = current.UpstreamIPPins
+	}
+	if !proxyChanged {
+		proxyURL = current.UpstreamProxyURL
+	}
+	if strings.TrimSpace(pins) != "" && strings.TrimSpace(proxyURL) != "" {
+		return fmt.Errorf("upstream_ip_pins cannot be used together with upstream_proxy_url: the proxy resolves upstream hosts itself")
+	}
 	return nil
 }
 
@@ -320,13 +454,35 @@ func (sm *SystemSettingsManager) ValidateGroupConfigOverrides(configMap map[stri
 			continue
 		}
 
+		// Group-only options have no system-level counterpart and are validated by the caller.
 		field, ok := jsonToField[key]
 		if !ok {
-			return fmt.Errorf("invalid setting key: %s", key)
+			continue
 		}
 
 		validateTag := field.Tag.Get("validate")
 
+		if field.Type.Kind() == reflect.String {
+			strVal, ok := value.(string)
+			if !ok {
+				return fmt.Errorf("invalid type for %s: expected a string, got %T", key, value)
+			}
+			if err := validateSettingRules(key, strVal, validateTag); err != nil {
+				return err
+			}
+			if key == "upstream_proxy_url" {
+				if _, err := httpclient.ParseProxyURL(strVal); err != nil {
+					return err
+				}
+			}
+			if key == "upstream_user_agent" {
+				if err := httpclient.ValidateUserAgent(strVal); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
 		floatVal, isFloat := value.(float64)
 		if !isFloat {
 			continue
@@ -336,18 +492,69 @@ func (sm *SystemSettingsManager) ValidateGroupConfigOverrides(configMap map[stri
 			return fmt.Errorf("invalid value for %s: must be an integer", key)
 		}
 
-		if strings.HasPrefix(validateTag, "min=") {
-			minValStr := strings.TrimPrefix(validateTag, "min=")
-			minVal, _ := strconv.Atoi(minValStr)
-			if intVal < minVal {
-				return fmt.Errorf("value for %s (%d) is below minimum value (%d)", key, intVal, minVal)
-			}
+		if err := validateSettingRules(key, intVal, validateTag); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
+// validateSettingRules checks an int or string setting value against the rules of its validate tag.
+// min and max bound an integer's value or a string's length, len requires an exact string length,
+// url requires an http or https URL, and oneof requires one of the space-separated values.
+// Empty strings are only checked by min and len.
+func validateSettingRules(key string, value any, tag string) error {
+	intVal, isInt := value.(int)
+	strVal, _ := value.(string)
+	length := utf8.RuneCountInString(strVal)
+
+	for _, rule := range utils.ParseValidateTag(tag) {
+		switch rule.Name {
+		case "min", "max", "len":
+			bound, err := strconv.Atoi(rule.Param)
+			if err != nil {
+				return fmt.Errorf("invalid validate rule '%s=%s' for %s", rule.Name, rule.Param, key)
+			}
+			if isInt {
+				if rule.Name == "min" && intVal < bound {
+					return fmt.Errorf("value for %s (%d) is below minimum value (%d)", key, intVal, bound)
+				}
+				if rule.Name == "max" && intVal > bound {
+					return fmt.Errorf("value for %s (%d) is above maximum value (%d)", key, intVal, bound)
+				}
+				continue
+			}
+			switch {
+			case rule.Name == "min" && length < bound:
+				return fmt.Errorf("value for %s must be at least %d characters", key, bound)
+			case rule.Name == "max" && length > bound:
+				return fmt.Errorf("value for %s must be at most %d characters", key, bound)
+			case rule.Name == "len" && length != bound:
+				return fmt.Errorf("value for %s must be exactly %d characters", key, bound)
+			}
+		case "url":
+			if strVal == "" {
+				continue
+			}
+			if u, err := url.Parse(strVal); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("invalid %s '%s': must be a valid http or https URL, such as https://example.com", key, strVal)
+			}
+		case "oneof":
+			if strVal == "" {
+				continue
+			}
+			allowed := strings.Fields(rule.Param)
+			if !slices.Contains(allowed, strVal) {
+				return fmt.Errorf("invalid %s '%s': must be one of %s", key, strVal, strings.Join(allowed, ", "))
+			}
+		default:
+			return fmt.Errorf("unknown validate rule '%s' for %s", rule.Name, key)
+		}
+	}
+	return nil
+}
+
 // DisplaySystemConfig displays the current system settings.
 func (sm *SystemSettingsManager) DisplaySystemConfig(settings types.SystemSettings) {
 	logrus.Info("")
@@ -355,7 +562,23 @@ func (sm *SystemSettingsManager) DisplaySystemConfig(settings types.SystemSettin
 	logrus.Info("  --- Basic Settings ---")
 	logrus.Infof("    App URL: %s", settings.AppUrl)
 	logrus.Infof("    Request Log Retention: %d days", settings.RequestLogRetentionDays)
-	logrus.Infof("    Request Log Write Interval: %d minutes", settings.RequestLogWriteIntervalMinutes)
+	logrus.Infof("    Request Log Write Interval: %d minutes, write mode: %s", settings.RequestLogWriteIntervalMinutes, settings.RequestLogWriteMode)
+	logrus.Infof("    Request Log Flush: batches of %d, %d ms apart", settings.RequestLogFlushBatchSize, settings.RequestLogFlushBatchDelayMs)
+	logrus.Infof("    Request Log Cache TTL: %d minutes (at least %dx the write interval), backlog warning above %d", settings.RequestLogCacheTTLMinutes, settings.RequestLogCacheTTLMultiplier, settings.RequestLogBacklogWarnThreshold)
+	if settings.RequestLogFlushTriggerSize > 0 {
+		logrus.Infof("    Request Log Early Flush: at %d pending logs", settings.RequestLogFlushTriggerSize)
+	}
+	if settings.DisplayTimezone != "" {
+		logrus.Infof("    Display Timezone: %s", settings.DisplayTimezone)
+	}
+	if settings.DefaultGroup != "" {
+		logrus.Infof("    Default Group: %s", settings.DefaultGroup)
+	}
+	if len(settings.ProxyKeyQuotasMap) > 0 {
+		logrus.Infof("    Proxy Key Quotas: %d keys", len(settings.ProxyKeyQuotasMap))
+	}
+	logrus.Infof("    Cache Resync Interval: %d minutes", settings.CacheResyncIntervalMinutes)
+	logrus.Infof("    Page Size: %d by default, at most %d", settings.DefaultPageSize, settings.MaxPageSize)
 
 	logrus.Info("  --- Request Behavior ---")
 	logrus.Infof("    Request Timeout: %d seconds", settings.RequestTimeout)
@@ -364,11 +587,37 @@ func (sm *SystemSettingsManager) DisplaySystemConfig(settings types.SystemSettin
 	logrus.Infof("    Idle Connection Timeout: %d seconds", settings.IdleConnTimeout)
 	logrus.Infof("    Max Idle Connections: %d", settings.MaxIdleConns)
 	logrus.Infof("    Max Idle Connections Per Host: %d", settings.MaxIdleConnsPerHost)
+	logrus.Infof("    Stream Pool Multiplier: %d", settings.StreamPoolMultiplier)
+	logrus.Infof("    Write/Read Buffer Size: %d/%d bytes", settings.WriteBufferSize, settings.ReadBufferSize)
+	logrus.Infof("    DNS Cache TTL: %d seconds", settings.DNSCacheTTL)
+	logrus.Infof("    Slow Request Threshold: %d ms", settings.SlowRequestThresholdMs)
+	logrus.Infof("    Max Response Body: %d bytes", settings.MaxResponseBodyBytes)
+	if settings.UpstreamProxyURL != "" {
+		logrus.Infof("    Upstream Proxy: %s", httpclient.MaskProxyURL(settings.UpstreamProxyURL))
+	}
+	if settings.UpstreamUserAgent != "" {
+		logrus.Infof("    Upstream User-Agent: %s", settings.UpstreamUserAgent)
+	}
+
+	if settings.MaintenanceMode {
+		logrus.Warnf("    Maintenance Mode: on, proxy requests get %d", settings.MaintenanceStatusCode)
+	}
 
 	logrus.Info("  --- Key & Group Behavior ---")
 	logrus.Infof("    Max Retries: %d", settings.MaxRetries)
 	logrus.Infof("    Blacklist Threshold: %d", settings.BlacklistThreshold)
+	logrus.Infof("    Rate Limit Cooldown: %d seconds", settings.RateLimitCooldownSeconds)
+	logrus.Infof("    Key Rebalance Interval: %d minutes", settings.KeyRebalanceIntervalMinutes)
+	if len(settings.ErrorRules) > 0 {
+		logrus.Infof("    Error Classification Rules: %d custom", len(settings.ErrorRules))
+	}
+	if len(settings.RedactionPatterns) > 0 {
+		logrus.Infof("    Log Redaction Patterns: %d", len(settings.RedactionPatterns))
+	}
 	logrus.Infof("    Key Validation Interval: %d minutes", settings.KeyValidationIntervalMinutes)
+	if settings.KeyDedupLowercase || settings.KeyDedupStripWhitespace {
+		logrus.Infof("    Key Normalization: lowercase=%t, strip whitespace=%t", settings.KeyDedupLowercase, settings.KeyDedupStripWhitespace)
+	}
 	logrus.Info("====================================")
 	logrus.Info("")
 }
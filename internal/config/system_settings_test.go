@@ -0,0 +1,51 @@
+package config
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRestartRequiredSettings(t *testing.T) {
+	type settings struct {
+		Live    int    `json:"live"`
+		Port    int    `json:"port" restart:"true"`
+		Address string `json:"address" restart:"true"`
+	}
+	current := settings{Live: 1, Port: 3001, Address: "0.0.0.0"}
+
+	tests := []struct {
+		name    string
+		changes map[string]any
+		want    []string
+	}{
+		{"live setting", map[string]any{"live": 2}, []string{}},
+		{"unchanged restart setting", map[string]any{"port": float64(3001), "address": "0.0.0.0"}, []string{}},
+		{"changed restart setting", map[string]any{"live": 2, "port": float64(3002)}, []string{"port"}},
+		{"all restart settings", map[string]any{"port": 3002, "address": "127.0.0.1"}, []string{"port", "address"}},
+	}
+	for _, tt := range tests {
+		if got := restartRequiredSettings(current, tt.changes); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: restartRequiredSettings() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateSettingsRejectsInvalidRedactionPattern(t *testing.T) {
+	sm := NewSystemSettingsManager()
+	if err := sm.ValidateSettings(map[string]any{"log_redaction_patterns": "email\n(unclosed"}); err == nil {
+		t.Error("ValidateSettings() accepted an invalid log redaction pattern")
+	}
+	if err := sm.ValidateSettings(map[string]any{"log_redaction_patterns": "email\nsecret-[0-9]+"}); err != nil {
+		t.Errorf("ValidateSettings() error = %v for valid patterns", err)
+	}
+}
+
+func TestValidateSettingsMaxPageSizeCoversUIPageSizes(t *testing.T) {
+	sm := NewSystemSettingsManager()
+	if err := sm.ValidateSettings(map[string]any{"max_page_size": float64(12)}); err == nil {
+		t.Error("ValidateSettings() accepted a max_page_size below 100")
+	}
+	if err := sm.ValidateSettings(map[string]any{"max_page_size": float64(100)}); err != nil {
+		t.Errorf("ValidateSettings() error = %v for a max_page_size of 100", err)
+	}
+}
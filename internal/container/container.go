@@ -6,6 +6,7 @@ import (
 	"gpt-load/internal/channel"
 	"gpt-load/internal/config"
 	"gpt-load/internal/db"
+	"gpt-load/internal/encryption"
 	"gpt-load/internal/handler"
 	"gpt-load/internal/httpclient"
 	"gpt-load/internal/keypool"
@@ -28,12 +29,18 @@ func BuildContainer() (*dig.Container, error) {
 	if err := container.Provide(db.NewDB); err != nil {
 		return nil, err
 	}
+	if err := container.Provide(db.NewReadDB); err != nil {
+		return nil, err
+	}
 	if err := container.Provide(config.NewSystemSettingsManager); err != nil {
 		return nil, err
 	}
 	if err := container.Provide(store.NewStore); err != nil {
 		return nil, err
 	}
+	if err := container.Provide(encryption.NewService); err != nil {
+		return nil, err
+	}
 	if err := container.Provide(httpclient.NewHTTPClientManager); err != nil {
 		return nil, err
 	}
@@ -57,15 +64,42 @@ func BuildContainer() (*dig.Container, error) {
 	if err := container.Provide(services.NewLogService); err != nil {
 		return nil, err
 	}
+	if err := container.Provide(services.NewAuditLogService); err != nil {
+		return nil, err
+	}
+	if err := container.Provide(services.NewRequestBodyLogService); err != nil {
+		return nil, err
+	}
+	if err := container.Provide(services.NewShadowComparisonService); err != nil {
+		return nil, err
+	}
 	if err := container.Provide(services.NewLogCleanupService); err != nil {
 		return nil, err
 	}
+	if err := container.Provide(services.NewClusterService); err != nil {
+		return nil, err
+	}
 	if err := container.Provide(services.NewRequestLogService); err != nil {
 		return nil, err
 	}
 	if err := container.Provide(services.NewGroupManager); err != nil {
 		return nil, err
 	}
+	if err := container.Provide(services.NewGroupQuotaService); err != nil {
+		return nil, err
+	}
+	if err := container.Provide(services.NewSessionRetryBudgetService); err != nil {
+		return nil, err
+	}
+	if err := container.Provide(services.NewProxyKeyQuotaService); err != nil {
+		return nil, err
+	}
+	if err := container.Provide(services.NewUsageReportService); err != nil {
+		return nil, err
+	}
+	if err := container.Provide(services.NewKeyTestResultService); err != nil {
+		return nil, err
+	}
 	if err := container.Provide(keypool.NewProvider); err != nil {
 		return nil, err
 	}
@@ -75,6 +109,9 @@ func BuildContainer() (*dig.Container, error) {
 	if err := container.Provide(keypool.NewCronChecker); err != nil {
 		return nil, err
 	}
+	if err := container.Provide(keypool.NewRebalancer); err != nil {
+		return nil, err
+	}
 
 	// Handlers
 	if err := container.Provide(handler.NewServer); err != nil {
@@ -3,6 +3,7 @@ package db
 import (
 	"fmt"
 	"gpt-load/internal/types"
+	"gpt-load/internal/utils"
 	"log"
 	"os"
 	"path/filepath"
@@ -10,6 +11,7 @@ import (
 	"time"
 
 	"github.com/glebarez/sqlite"
+	"github.com/sirupsen/logrus"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -20,11 +22,44 @@ var DB *gorm.DB
 
 func NewDB(configManager types.ConfigManager) (*gorm.DB, error) {
 	dbConfig := configManager.GetDatabaseConfig()
-	dsn := dbConfig.DSN
-	if dsn == "" {
+	if dbConfig.DSN == "" {
 		return nil, fmt.Errorf("DATABASE_DSN is not configured")
 	}
 
+	if isSQLiteDSN(dbConfig.DSN) && !configManager.IsMaster() {
+		logrus.Warn("SQLite only supports single-node deployments, use MySQL or PostgreSQL when running slave nodes.")
+	}
+
+	var err error
+	DB, err = openDB(configManager, dbConfig.DSN, "Database")
+	if err != nil {
+		return nil, err
+	}
+	return DB, nil
+}
+
+// ReadDB is the connection for read-only dashboard, stats and log queries.
+// It points at the read replica when DATABASE_READ_DSN is set, and at the primary otherwise.
+type ReadDB struct {
+	*gorm.DB
+}
+
+// NewReadDB connects to the read replica, falling back to the primary connection when none is configured.
+func NewReadDB(configManager types.ConfigManager, primary *gorm.DB) (*ReadDB, error) {
+	readDSN := configManager.GetDatabaseConfig().ReadDSN
+	if readDSN == "" {
+		return &ReadDB{DB: primary}, nil
+	}
+
+	replica, err := openDB(configManager, readDSN, "Read replica")
+	if err != nil {
+		return nil, err
+	}
+	return &ReadDB{DB: replica}, nil
+}
+
+// openDB opens a connection for the DSN, picking the driver from its format.
+func openDB(configManager types.ConfigManager, dsn string, name string) (*gorm.DB, error) {
 	var newLogger logger.Interface
 	if configManager.GetLogConfig().Level == "debug" {
 		newLogger = logger.New(
@@ -54,29 +89,54 @@ func NewDB(configManager types.ConfigManager) (*gorm.DB, error) {
 		}
 		dialector = mysql.Open(dsn)
 	} else {
-		if err := os.MkdirAll(filepath.Dir(dsn), 0755); err != nil {
+		path, _, _ := strings.Cut(dsn, "?")
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
 			return nil, fmt.Errorf("failed to create database directory: %w", err)
 		}
-		dialector = sqlite.Open(dsn + "?_busy_timeout=5000")
+		dialector = sqlite.Open(sqliteDSN(dsn))
 	}
 
-	var err error
-	DB, err = gorm.Open(dialector, &gorm.Config{
-		Logger:      newLogger,
-		PrepareStmt: true,
+	// Wait for the database to come up, e.g. when it starts alongside the app in an orchestrated environment.
+	var conn *gorm.DB
+	maxWait := time.Duration(configManager.GetEffectiveServerConfig().StartupConnectTimeout) * time.Second
+	err := utils.RetryWithBackoff(name, maxWait, func() error {
+		var openErr error
+		conn, openErr = gorm.Open(dialector, &gorm.Config{
+			Logger:      newLogger,
+			PrepareStmt: true,
+		})
+		return openErr
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
+		return nil, fmt.Errorf("failed to connect to %s: %w", strings.ToLower(name), err)
 	}
 
-	sqlDB, err := DB.DB()
+	sqlDB, err := conn.DB()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get sql.DB: %w", err)
 	}
 	// Set connection pool parameters for all drivers
-	sqlDB.SetMaxIdleConns(50)
-	sqlDB.SetMaxOpenConns(500)
-	sqlDB.SetConnMaxLifetime(time.Hour)
+	dbConfig := configManager.GetDatabaseConfig()
+	sqlDB.SetMaxIdleConns(dbConfig.MaxIdleConns)
+	sqlDB.SetMaxOpenConns(dbConfig.MaxOpenConns)
+	sqlDB.SetConnMaxLifetime(time.Duration(dbConfig.ConnMaxLifetime) * time.Second)
 
-	return DB, nil
+	return conn, nil
+}
+
+// sqlitePragmas switch SQLite to WAL so log flushes don't block dashboard and log reads.
+// Transactions take the write lock up front and wait for it instead of failing with SQLITE_BUSY.
+const sqlitePragmas = "_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)&_pragma=busy_timeout(5000)&_txlock=immediate"
+
+// isSQLiteDSN reports whether the DSN is a SQLite file path rather than a PostgreSQL or MySQL DSN.
+func isSQLiteDSN(dsn string) bool {
+	return !strings.HasPrefix(dsn, "postgres://") && !strings.HasPrefix(dsn, "postgresql://") && !strings.Contains(dsn, "@tcp")
+}
+
+// sqliteDSN appends the connection pragmas to a SQLite file path, keeping any parameters it already has.
+func sqliteDSN(dsn string) string {
+	if strings.Contains(dsn, "?") {
+		return dsn + "&" + sqlitePragmas
+	}
+	return dsn + "?" + sqlitePragmas
 }
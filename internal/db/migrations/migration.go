@@ -1,10 +1,107 @@
 package db
 
 import (
+	"fmt"
+	"time"
+
+	"gpt-load/internal/models"
+
+	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
 
+// migration 一个数据迁移，Version 唯一且按执行顺序排列
+type migration struct {
+	Version string
+	Up      func(db *gorm.DB) error
+}
+
+// migrations 按顺序注册的数据迁移。已执行的迁移记录在 schema_migrations 表中，不会重复执行，
+// 因此旧迁移可以一直保留。新增迁移追加到末尾，不要修改已发布迁移的 Version。
+var migrations = []migration{
+	{Version: "v1.0.13_fix_request_logs", Up: V1_0_13_FixRequestLogs},
+	{Version: "v1.0.14_redact_request_log_keys", Up: V1_0_14_RedactRequestLogKeys},
+}
+
+// Run 迁移所有表结构并执行尚未执行过的数据迁移，由 Master 启动时或 migrate 子命令调用
+func Run(db *gorm.DB) error {
+	if err := db.AutoMigrate(
+		&models.SystemSetting{},
+		&models.Group{},
+		&models.APIKey{},
+		&models.RequestLog{},
+		&models.RequestBodyLog{},
+		&models.ShadowComparison{},
+		&models.GroupHourlyStat{},
+		&models.UsageHourlyStat{},
+		&models.KeyDailyStat{},
+		&models.AuditLog{},
+		&models.SchemaMigration{},
+	); err != nil {
+		return fmt.Errorf("database auto-migration failed: %w", err)
+	}
+	if err := MigrateDatabase(db); err != nil {
+		return fmt.Errorf("database migration failed: %w", err)
+	}
+	return nil
+}
+
+// Pending 返回尚未执行的数据迁移版本，用于跳过迁移启动时检查数据库是否已迁移
+func Pending(db *gorm.DB) ([]string, error) {
+	if !db.Migrator().HasTable(&models.SchemaMigration{}) {
+		pending := make([]string, len(migrations))
+		for i, m := range migrations {
+			pending[i] = m.Version
+		}
+		return pending, nil
+	}
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+	var pending []string
+	for _, m := range migrations {
+		if !applied[m.Version] {
+			pending = append(pending, m.Version)
+		}
+	}
+	return pending, nil
+}
+
+func appliedVersions(db *gorm.DB) (map[string]bool, error) {
+	var versions []string
+	if err := db.Model(&models.SchemaMigration{}).Pluck("version", &versions).Error; err != nil {
+		return nil, fmt.Errorf("failed to load applied migrations: %w", err)
+	}
+	applied := make(map[string]bool, len(versions))
+	for _, version := range versions {
+		applied[version] = true
+	}
+	return applied, nil
+}
+
+// MigrateDatabase 依次执行尚未执行过的数据迁移。某个迁移失败时停止并返回错误，之后的迁移不会执行，
+// 下次启动时从失败的迁移重新开始。
 func MigrateDatabase(db *gorm.DB) error {
-	// v1.0.13 修复请求日志数据
-	return V1_0_13_FixRequestLogs(db)
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if applied[m.Version] {
+			continue
+		}
+
+		logrus.Infof("Running database migration %s...", m.Version)
+		start := time.Now()
+		if err := m.Up(db); err != nil {
+			return fmt.Errorf("migration %s failed: %w", m.Version, err)
+		}
+		if err := db.Create(&models.SchemaMigration{Version: m.Version, AppliedAt: time.Now()}).Error; err != nil {
+			return fmt.Errorf("failed to record migration %s: %w", m.Version, err)
+		}
+		logrus.Infof("Database migration %s completed in %s", m.Version, time.Since(start))
+	}
+	return nil
 }
@@ -7,7 +7,7 @@ import (
 	"gorm.io/gorm"
 )
 
-// TODO: 更新迁移，待多个版本后旧版本都升级差不多之后移除。
+// V1_0_13_FixRequestLogs 将旧版请求日志的 key_id 转换为 key_value 和 group_name，并删除 key_id 列
 func V1_0_13_FixRequestLogs(db *gorm.DB) error {
 	// 如果有key_id，就执行修复
 	if !db.Migrator().HasColumn(&models.RequestLog{}, "key_id") {
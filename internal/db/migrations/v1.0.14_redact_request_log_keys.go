@@ -0,0 +1,37 @@
+package db
+
+import (
+	"gpt-load/internal/models"
+	"gpt-load/internal/utils"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// V1_0_14_RedactRequestLogKeys 将请求日志中的明文密钥替换为脱敏值，并补全用于查询和统计的密钥哈希。
+// 旧日志没有记录密钥 ID，api_key_id 保持为 0。
+func V1_0_14_RedactRequestLogKeys(db *gorm.DB) error {
+	var keyValues []string
+	if err := db.Model(&models.RequestLog{}).
+		Where("key_hash = '' AND key_value IS NOT NULL AND key_value != ''").
+		Distinct().Pluck("key_value", &keyValues).Error; err != nil {
+		return err
+	}
+	if len(keyValues) == 0 {
+		return nil
+	}
+
+	logrus.Infof("Redacting %d distinct key values in request_logs...", len(keyValues))
+	for _, keyValue := range keyValues {
+		updates := map[string]any{
+			"key_value": utils.MaskAPIKey(keyValue),
+			"key_hash":  utils.HashKey(keyValue),
+		}
+		if err := db.Model(&models.RequestLog{}).
+			Where("key_hash = '' AND key_value = ?", keyValue).
+			UpdateColumns(updates).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
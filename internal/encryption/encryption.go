@@ -0,0 +1,148 @@
+// Package encryption provides optional application-level encryption of API key values at rest.
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gpt-load/internal/types"
+)
+
+// encryptedPrefix marks values written by this package, so that rows stored before encryption
+// was enabled can still be read as plaintext.
+const encryptedPrefix = "enc:v1:"
+
+// ErrKeyNotConfigured is returned when an encrypted value is read without ENCRYPTION_KEY set.
+var ErrKeyNotConfigured = errors.New("found an encrypted key value but ENCRYPTION_KEY is not set")
+
+// Service encrypts API key values before they are written to the database and decrypts them after reading.
+type Service interface {
+	// Enabled reports whether an encryption key is configured.
+	Enabled() bool
+
+	// Encrypt returns the value to store for a plaintext key.
+	Encrypt(plaintext string) (string, error)
+
+	// Decrypt returns the plaintext of a stored value. Plaintext values are returned unchanged.
+	Decrypt(value string) (string, error)
+
+	// LookupValues returns every stored form the given plaintext keys may have,
+	// for matching key_value columns that may hold both migrated and unmigrated rows.
+	LookupValues(plaintexts []string) []string
+}
+
+// NewService creates the encryption service from the ENCRYPTION_KEY setting.
+// Without a key, values are stored and read as plaintext.
+func NewService(configManager types.ConfigManager) (Service, error) {
+	secret := configManager.GetEncryptionKey()
+	if secret == "" {
+		return &noopService{}, nil
+	}
+	return newAESService(secret)
+}
+
+// IsEncrypted reports whether a stored value was written by an encrypting Service.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix)
+}
+
+type noopService struct{}
+
+func (s *noopService) Enabled() bool {
+	return false
+}
+
+func (s *noopService) Encrypt(plaintext string) (string, error) {
+	return plaintext, nil
+}
+
+func (s *noopService) Decrypt(value string) (string, error) {
+	if IsEncrypted(value) {
+		return "", ErrKeyNotConfigured
+	}
+	return value, nil
+}
+
+func (s *noopService) LookupValues(plaintexts []string) []string {
+	return plaintexts
+}
+
+// aesService encrypts with AES-256-GCM.
+// The nonce is derived from an HMAC of the plaintext, so a key always encrypts to the same value.
+// This keeps equality lookups, deduplication and the (group_id, key_value) unique index working,
+// at the cost of revealing which rows hold the same key.
+type aesService struct {
+	aead   cipher.AEAD
+	macKey []byte
+}
+
+func newAESService(secret string) (*aesService, error) {
+	encKey := sha256.Sum256([]byte("gpt-load key encryption:" + secret))
+	macKey := sha256.Sum256([]byte("gpt-load key nonce:" + secret))
+
+	block, err := aes.NewCipher(encKey[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+
+	return &aesService{aead: aead, macKey: macKey[:]}, nil
+}
+
+func (s *aesService) Enabled() bool {
+	return true
+}
+
+func (s *aesService) Encrypt(plaintext string) (string, error) {
+	if IsEncrypted(plaintext) {
+		return plaintext, nil
+	}
+
+	mac := hmac.New(sha256.New, s.macKey)
+	mac.Write([]byte(plaintext))
+	nonce := mac.Sum(nil)[:s.aead.NonceSize()]
+
+	sealed := s.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (s *aesService) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode encrypted key value: %w", err)
+	}
+	nonceSize := s.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return "", errors.New("encrypted key value is too short")
+	}
+
+	plaintext, err := s.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt key value, check ENCRYPTION_KEY: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+func (s *aesService) LookupValues(plaintexts []string) []string {
+	values := make([]string, 0, len(plaintexts)*2)
+	for _, plaintext := range plaintexts {
+		values = append(values, plaintext)
+		if encrypted, err := s.Encrypt(plaintext); err == nil {
+			values = append(values, encrypted)
+		}
+	}
+	return values
+}
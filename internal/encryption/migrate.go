@@ -0,0 +1,44 @@
+package encryption
+
+import (
+	"fmt"
+
+	"gpt-load/internal/models"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const migrateBatchSize = 500
+
+// EncryptExistingKeys encrypts every api_keys row that is still stored as plaintext.
+// It is safe to run repeatedly; rows that are already encrypted are skipped.
+func EncryptExistingKeys(db *gorm.DB, svc Service) (int, error) {
+	if !svc.Enabled() {
+		return 0, fmt.Errorf("ENCRYPTION_KEY must be set to encrypt existing keys")
+	}
+
+	encryptedCount := 0
+	var keys []models.APIKey
+	err := db.Model(&models.APIKey{}).
+		Select("id, key_value").
+		Where("key_value NOT LIKE ?", encryptedPrefix+"%").
+		FindInBatches(&keys, migrateBatchSize, func(tx *gorm.DB, batch int) error {
+			return db.Transaction(func(tx *gorm.DB) error {
+				for _, key := range keys {
+					encrypted, err := svc.Encrypt(key.KeyValue)
+					if err != nil {
+						return fmt.Errorf("failed to encrypt key %d: %w", key.ID, err)
+					}
+					if err := tx.Model(&models.APIKey{}).Where("id = ?", key.ID).UpdateColumn("key_value", encrypted).Error; err != nil {
+						return fmt.Errorf("failed to update key %d: %w", key.ID, err)
+					}
+				}
+				encryptedCount += len(keys)
+				logrus.Infof("Encrypted %d keys (batch %d)", len(keys), batch)
+				return nil
+			})
+		}).Error
+
+	return encryptedCount, err
+}
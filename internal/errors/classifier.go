@@ -0,0 +1,60 @@
+package errors
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gpt-load/internal/types"
+)
+
+// defaultErrorRules are the built-in upstream error classifications. Custom rules from the
+// error_classification_rules setting are checked first, so operators can override them.
+var defaultErrorRules = []types.ErrorRule{
+	{Class: types.ErrorClassPermanent, Pattern: regexp.MustCompile(`(?i)invalid[ _]api[ _]key|incorrect api key|api key not valid|API_KEY_INVALID`)},
+	{Class: types.ErrorClassPermanent, Pattern: regexp.MustCompile(`(?i)account (has been )?(deactivated|suspended|disabled)`)},
+	{Class: types.ErrorClassRateLimit, Pattern: regexp.MustCompile(`(?i)rate[ _]limit|too many requests|RESOURCE_EXHAUSTED`)},
+}
+
+// ParseErrorRules parses one "class:regex" rule per line, where class is permanent, transient or rate_limit.
+// Blank lines and lines starting with '#' are ignored.
+func ParseErrorRules(value string) ([]types.ErrorRule, error) {
+	var rules []types.ErrorRule
+	for _, line := range strings.Split(value, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		class, expr, ok := strings.Cut(line, ":")
+		if !ok || strings.TrimSpace(expr) == "" {
+			return nil, fmt.Errorf("invalid error classification rule '%s': expected class:regex", line)
+		}
+		errorClass := types.ErrorClass(strings.TrimSpace(class))
+		switch errorClass {
+		case types.ErrorClassPermanent, types.ErrorClassTransient, types.ErrorClassRateLimit:
+		default:
+			return nil, fmt.Errorf("invalid error class '%s': must be one of '%s', '%s' or '%s'", class, types.ErrorClassPermanent, types.ErrorClassTransient, types.ErrorClassRateLimit)
+		}
+
+		pattern, err := regexp.Compile(strings.TrimSpace(expr))
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex in error classification rule '%s': %w", line, err)
+		}
+		rules = append(rules, types.ErrorRule{Class: errorClass, Pattern: pattern})
+	}
+	return rules, nil
+}
+
+// ClassifyUpstreamError classifies an upstream error body by the first matching custom rule,
+// then the built-in rules. Unmatched errors are transient.
+func ClassifyUpstreamError(body []byte, customRules []types.ErrorRule) types.ErrorClass {
+	for _, rules := range [][]types.ErrorRule{customRules, defaultErrorRules} {
+		for _, rule := range rules {
+			if rule.Pattern.Match(body) {
+				return rule.Class
+			}
+		}
+	}
+	return types.ErrorClassTransient
+}
@@ -38,6 +38,9 @@ var (
 	ErrNoActiveKeys       = &APIError{HTTPStatus: http.StatusServiceUnavailable, Code: "NO_ACTIVE_KEYS", Message: "No active API keys available for this group"}
 	ErrMaxRetriesExceeded = &APIError{HTTPStatus: http.StatusBadGateway, Code: "MAX_RETRIES_EXCEEDED", Message: "Request failed after maximum retries"}
 	ErrNoKeysAvailable    = &APIError{HTTPStatus: http.StatusServiceUnavailable, Code: "NO_KEYS_AVAILABLE", Message: "No API keys available to process the request"}
+	ErrQuotaExceeded      = &APIError{HTTPStatus: http.StatusTooManyRequests, Code: "QUOTA_EXCEEDED", Message: "Daily request quota exceeded"}
+	ErrResponseTooLarge   = &APIError{HTTPStatus: http.StatusBadGateway, Code: "RESPONSE_TOO_LARGE", Message: "Upstream response exceeds the maximum allowed size"}
+	ErrTransformFailed    = &APIError{HTTPStatus: http.StatusBadGateway, Code: "TRANSFORM_FAILED", Message: "Transform webhook failed"}
 )
 
 // NewAPIError creates a new APIError with a custom message.
@@ -64,6 +67,12 @@ func ParseDBError(err error) *APIError {
 		return nil
 	}
 
+	// Errors that are already API errors, such as a rejected page size, are passed through.
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		return ErrResourceNotFound
 	}
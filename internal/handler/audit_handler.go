@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"encoding/json"
+	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/httpclient"
+	"gpt-load/internal/middleware"
+	"gpt-load/internal/models"
+	"gpt-load/internal/response"
+	"gpt-load/internal/utils"
+	"maps"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// maxAuditKeys caps the number of masked keys stored in a single audit entry.
+const maxAuditKeys = 100
+
+// GetAuditLogs handles fetching audit logs with filtering and pagination.
+func (s *Server) GetAuditLogs(c *gin.Context) {
+	query := s.AuditLogService.GetAuditLogsQuery(c)
+
+	var logs []models.AuditLog
+	query = query.Order("timestamp desc, id desc")
+	pagination, err := response.Paginate(c, query, &logs, s.pageSizeLimits())
+	if err != nil {
+		response.Error(c, app_errors.ParseDBError(err))
+		return
+	}
+
+	pagination.Items = logs
+	response.Success(c, pagination)
+}
+
+// recordAudit fills in the actor and source IP of entry from the request and writes it.
+func (s *Server) recordAudit(c *gin.Context, entry models.AuditLog) {
+	entry.Actor = c.GetString(middleware.AuthActorContextKey)
+	entry.SourceIP = c.ClientIP()
+	s.AuditLogService.Record(&entry)
+}
+
+// recordChangeAudit records an update with only the fields that changed, masked by mask after comparing
+// so that a changed secret is still recorded. Nothing is recorded if nothing changed.
+func (s *Server) recordChangeAudit(c *gin.Context, entry models.AuditLog, before, after map[string]any, mask func(map[string]any) map[string]any) {
+	changedBefore, changedAfter := diffAuditMaps(before, after)
+	if len(changedBefore) == 0 && len(changedAfter) == 0 {
+		return
+	}
+	entry.Before, entry.After = mask(changedBefore), mask(changedAfter)
+	s.recordAudit(c, entry)
+}
+
+// recordKeysAudit records a key operation on group with its result and the masked keys from keysText.
+func (s *Server) recordKeysAudit(c *gin.Context, action string, group *models.Group, keysText string, details map[string]any) {
+	if details == nil {
+		details = make(map[string]any)
+	}
+	if keysText != "" {
+		keys := s.KeyService.ParseKeysFromText(keysText)
+		details["key_count"] = len(keys)
+		details["keys"] = maskAuditKeys(keys)
+	}
+	s.recordAudit(c, models.AuditLog{
+		Action:     action,
+		TargetType: models.AuditTargetKeys,
+		TargetID:   group.ID,
+		TargetName: group.Name,
+		Details:    details,
+	})
+}
+
+// toAuditMap converts v to a JSON object map, so values compare the same way whether they
+// come from a struct or a request body. The result is never nil.
+func toAuditMap(v any) map[string]any {
+	m := make(map[string]any)
+	data, err := json.Marshal(v)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to encode audit log values")
+		return m
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		logrus.WithError(err).Warn("Failed to decode audit log values")
+	}
+	return m
+}
+
+// diffAuditMaps returns the before and after values of the fields that differ between before and after.
+func diffAuditMaps(before, after map[string]any) (map[string]any, map[string]any) {
+	changedBefore := make(map[string]any)
+	changedAfter := make(map[string]any)
+	for key, afterValue := range after {
+		beforeValue, ok := before[key]
+		if ok && reflect.DeepEqual(beforeValue, afterValue) {
+			continue
+		}
+		if ok {
+			changedBefore[key] = beforeValue
+		}
+		changedAfter[key] = afterValue
+	}
+	return changedBefore, changedAfter
+}
+
+// settingsAuditValues returns the settings in m with proxy keys and proxy credentials masked.
+func settingsAuditValues(m map[string]any) map[string]any {
+	values := maps.Clone(m)
+	if proxyKeys, ok := values["proxy_keys"].(string); ok {
+		values["proxy_keys"] = maskAuditKeyList(proxyKeys)
+	}
+	if quotas, ok := values["proxy_key_quotas"].(string); ok {
+		values["proxy_key_quotas"] = maskProxyKeyQuotas(quotas)
+	}
+	if proxyURL, ok := values["upstream_proxy_url"].(string); ok {
+		values["upstream_proxy_url"] = httpclient.MaskProxyURL(proxyURL)
+	}
+	return values
+}
+
+// groupAuditSnapshot returns the editable fields of group.
+func groupAuditSnapshot(group *models.Group) map[string]any {
+	return toAuditMap(map[string]any{
+		"name":                group.Name,
+		"display_name":        group.DisplayName,
+		"description":         group.Description,
+		"channel_type":        group.ChannelType,
+		"sort":                group.Sort,
+		"test_model":          group.TestModel,
+		"validation_endpoint": group.ValidationEndpoint,
+		"upstreams":           group.Upstreams,
+		"param_overrides":     group.ParamOverrides,
+		"config":              group.Config,
+		"proxy_keys":          group.ProxyKeys,
+	})
+}
+
+// groupAuditValues returns the group fields in m with proxy keys and credentials masked.
+func groupAuditValues(m map[string]any) map[string]any {
+	values := maps.Clone(m)
+	if proxyKeys, ok := values["proxy_keys"].(string); ok {
+		values["proxy_keys"] = maskAuditKeyList(proxyKeys)
+	}
+	if config, ok := values["config"].(map[string]any); ok {
+		values["config"] = map[string]any(redactGroupConfig(config))
+	}
+	return values
+}
+
+// maskAuditKey masks a key for the audit log. Unlike utils.MaskAPIKey, short keys are hidden entirely.
+func maskAuditKey(key string) string {
+	if len(key) <= 8 {
+		return "****"
+	}
+	return utils.MaskAPIKey(key)
+}
+
+// maskAuditKeys masks up to maxAuditKeys keys.
+func maskAuditKeys(keys []string) []string {
+	masked := make([]string, 0, min(len(keys), maxAuditKeys))
+	for _, key := range keys[:min(len(keys), maxAuditKeys)] {
+		masked = append(masked, maskAuditKey(key))
+	}
+	return masked
+}
+
+// maskAuditKeyList masks each key of a comma-separated key list.
+func maskAuditKeyList(value string) string {
+	keys := utils.SplitAndTrim(value, ",")
+	for i, key := range keys {
+		keys[i] = maskAuditKey(key)
+	}
+	return strings.Join(keys, ",")
+}
+
+// maskProxyKeyQuotas masks the proxy keys of a "key=daily/monthly" list and keeps the limits.
+func maskProxyKeyQuotas(value string) string {
+	items := utils.SplitAndTrim(value, ",")
+	for i, item := range items {
+		if idx := strings.LastIndex(item, "="); idx > 0 {
+			items[i] = maskAuditKey(strings.TrimSpace(item[:idx])) + item[idx:]
+		} else {
+			items[i] = maskAuditKey(item)
+		}
+	}
+	return strings.Join(items, ",")
+}
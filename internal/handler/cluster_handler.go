@@ -0,0 +1,23 @@
+package handler
+
+import (
+	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetClusterNodes lists the nodes that sent a heartbeat recently, with their roles.
+func (s *Server) GetClusterNodes(c *gin.Context) {
+	nodes, err := s.ClusterService.ListNodes()
+	if err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInternalServer, err.Error()))
+		return
+	}
+	response.Success(c, nodes)
+}
+
+// GetConnectionPools returns the upstream connection pool stats of this node.
+func (s *Server) GetConnectionPools(c *gin.Context) {
+	response.Success(c, s.HTTPClientManager.Stats())
+}
@@ -3,6 +3,7 @@ package handler
 import (
 	"gpt-load/internal/channel"
 	"gpt-load/internal/response"
+	"gpt-load/internal/version"
 
 	"github.com/gin-gonic/gin"
 )
@@ -20,3 +21,8 @@ func (h *CommonHandler) GetChannelTypes(c *gin.Context) {
 	channelTypes := channel.GetChannels()
 	response.Success(c, channelTypes)
 }
+
+// GetVersion returns the version, git commit and build time of the running build.
+func (h *CommonHandler) GetVersion(c *gin.Context) {
+	response.Success(c, version.Get())
+}
@@ -1,9 +1,11 @@
 package handler
 
 import (
+	"fmt"
 	app_errors "gpt-load/internal/errors"
 	"gpt-load/internal/models"
 	"gpt-load/internal/response"
+	"gpt-load/internal/utils"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -12,9 +14,9 @@ import (
 // Stats Get dashboard statistics
 func (s *Server) Stats(c *gin.Context) {
 	var activeKeys, invalidKeys, groupCount int64
-	s.DB.Model(&models.APIKey{}).Where("status = ?", models.KeyStatusActive).Count(&activeKeys)
-	s.DB.Model(&models.APIKey{}).Where("status = ?", models.KeyStatusInvalid).Count(&invalidKeys)
-	s.DB.Model(&models.Group{}).Count(&groupCount)
+	s.ReadDB.Model(&models.APIKey{}).Where("status = ?", models.KeyStatusActive).Count(&activeKeys)
+	s.ReadDB.Model(&models.APIKey{}).Where("status = ?", models.KeyStatusInvalid).Count(&invalidKeys)
+	s.ReadDB.Model(&models.Group{}).Count(&groupCount)
 
 	now := time.Now()
 	twentyFourHoursAgo := now.Add(-24 * time.Hour)
@@ -90,6 +92,8 @@ func (s *Server) Stats(c *gin.Context) {
 		},
 		RequestCount: models.StatCard{
 			Value:         float64(currentPeriod.TotalRequests),
+			SubValue:      currentPeriod.TotalCancelled,
+			SubValueTip:   "客户端取消的请求数量（不计入请求数和错误率）",
 			Trend:         reqTrend,
 			TrendIsGrowth: reqTrendIsGrowth,
 		},
@@ -103,16 +107,70 @@ func (s *Server) Stats(c *gin.Context) {
 	response.Success(c, stats)
 }
 
+// maxChartPoints caps the number of buckets a single chart query may return.
+const maxChartPoints = 744
+
 // Chart Get dashboard chart data
+// 支持 from、to（RFC3339）和 granularity（hour/day）参数，默认返回最近24小时的小时数据。
 func (s *Server) Chart(c *gin.Context) {
 	groupID := c.Query("groupId")
+	granularity := c.DefaultQuery("granularity", "hour")
+
+	var truncate, next func(time.Time) time.Time
+	switch granularity {
+	case "hour":
+		truncate = func(t time.Time) time.Time { return t.Truncate(time.Hour) }
+		next = func(t time.Time) time.Time { return t.Add(time.Hour) }
+	case "day":
+		truncate = utils.StartOfDay
+		next = func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }
+	default:
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, "granularity must be 'hour' or 'day'"))
+		return
+	}
 
-	now := time.Now()
-	endHour := now.Truncate(time.Hour)
-	startHour := endHour.Add(-23 * time.Hour)
+	loc := s.SettingsManager.GetDisplayLocation()
+	now := time.Now().In(loc)
+	endBucket := truncate(now)
+	startBucket := endBucket
+	for range 23 {
+		startBucket = truncate(startBucket.Add(-time.Minute))
+	}
+
+	if from := c.Query("from"); from != "" {
+		fromTime, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, "invalid 'from' time, expected RFC3339"))
+			return
+		}
+		startBucket = truncate(fromTime.In(loc))
+	}
+	if to := c.Query("to"); to != "" {
+		toTime, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, "invalid 'to' time, expected RFC3339"))
+			return
+		}
+		endBucket = truncate(toTime.In(loc))
+	}
+
+	if endBucket.Before(startBucket) {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, "'from' must be earlier than 'to'"))
+		return
+	}
+
+	var buckets []time.Time
+	for bucket := startBucket; !bucket.After(endBucket); bucket = next(bucket) {
+		if len(buckets) >= maxChartPoints {
+			response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, fmt.Sprintf("time range too large, at most %d data points are allowed", maxChartPoints)))
+			return
+		}
+		buckets = append(buckets, bucket)
+	}
+	rangeEnd := next(endBucket)
 
 	var hourlyStats []models.GroupHourlyStat
-	query := s.DB.Where("time >= ? AND time < ?", startHour, endHour.Add(time.Hour))
+	query := s.ReadDB.Where("time >= ? AND time < ?", startBucket, rangeEnd)
 	if groupID != "" {
 		query = query.Where("group_id = ?", groupID)
 	}
@@ -121,29 +179,31 @@ func (s *Server) Chart(c *gin.Context) {
 		return
 	}
 
-	statsByHour := make(map[time.Time]map[string]int64)
+	statsByBucket := make(map[time.Time]map[string]int64)
 	for _, stat := range hourlyStats {
-		hour := stat.Time.Local().Truncate(time.Hour)
-		if _, ok := statsByHour[hour]; !ok {
-			statsByHour[hour] = make(map[string]int64)
+		bucket := truncate(stat.Time.In(loc))
+		if _, ok := statsByBucket[bucket]; !ok {
+			statsByBucket[bucket] = make(map[string]int64)
 		}
-		statsByHour[hour]["success"] += stat.SuccessCount
-		statsByHour[hour]["failure"] += stat.FailureCount
+		statsByBucket[bucket]["success"] += stat.SuccessCount
+		statsByBucket[bucket]["failure"] += stat.FailureCount
+		statsByBucket[bucket]["cancelled"] += stat.CancelledCount
 	}
 
 	var labels []string
-	var successData, failureData []int64
+	var successData, failureData, cancelledData []int64
 
-	for i := range 24 {
-		hour := startHour.Add(time.Duration(i) * time.Hour)
-		labels = append(labels, hour.Format(time.RFC3339))
+	for _, bucket := range buckets {
+		labels = append(labels, bucket.Format(time.RFC3339))
 
-		if data, ok := statsByHour[hour]; ok {
+		if data, ok := statsByBucket[bucket]; ok {
 			successData = append(successData, data["success"])
 			failureData = append(failureData, data["failure"])
+			cancelledData = append(cancelledData, data["cancelled"])
 		} else {
 			successData = append(successData, 0)
 			failureData = append(failureData, 0)
+			cancelledData = append(cancelledData, 0)
 		}
 	}
 
@@ -160,6 +220,11 @@ func (s *Server) Chart(c *gin.Context) {
 				Data:  failureData,
 				Color: "rgba(255, 70, 70, 1)",
 			},
+			{
+				Label: "客户端取消",
+				Data:  cancelledData,
+				Color: "rgba(150, 150, 150, 1)",
+			},
 		},
 	}
 
@@ -167,14 +232,15 @@ func (s *Server) Chart(c *gin.Context) {
 }
 
 type hourlyStatResult struct {
-	TotalRequests int64
-	TotalFailures int64
+	TotalRequests  int64
+	TotalFailures  int64
+	TotalCancelled int64
 }
 
 func (s *Server) getHourlyStats(startTime, endTime time.Time) (hourlyStatResult, error) {
 	var result hourlyStatResult
-	err := s.DB.Model(&models.GroupHourlyStat{}).
-		Select("sum(success_count) + sum(failure_count) as total_requests, sum(failure_count) as total_failures").
+	err := s.ReadDB.Model(&models.GroupHourlyStat{}).
+		Select("sum(success_count) + sum(failure_count) as total_requests, sum(failure_count) as total_failures, sum(cancelled_count) as total_cancelled").
 		Where("time >= ? AND time < ?", startTime, endTime).
 		Scan(&result).Error
 	return result, err
@@ -4,18 +4,26 @@ package handler
 import (
 	"encoding/json"
 	"fmt"
+	"maps"
+	"math"
 	"net/url"
+	"slices"
 	"sync"
 
 	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/httpclient"
+	"gpt-load/internal/keypool"
 	"gpt-load/internal/models"
 	"gpt-load/internal/response"
+	"gpt-load/internal/services"
 	"gpt-load/internal/utils"
 	"reflect"
 	"regexp"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"gpt-load/internal/channel"
 
@@ -42,7 +50,8 @@ type UpstreamDefinition struct {
 }
 
 // validateAndCleanUpstreams validates and cleans the upstreams JSON.
-func validateAndCleanUpstreams(upstreams json.RawMessage) (datatypes.JSON, error) {
+// For virtual groups, each upstream URL holds the name of a member group.
+func validateAndCleanUpstreams(upstreams json.RawMessage, channelType string) (datatypes.JSON, error) {
 	if len(upstreams) == 0 {
 		return nil, fmt.Errorf("upstreams field is required")
 	}
@@ -61,8 +70,12 @@ func validateAndCleanUpstreams(upstreams json.RawMessage) (datatypes.JSON, error
 		if defs[i].URL == "" {
 			return nil, fmt.Errorf("upstream URL cannot be empty")
 		}
-		// Basic URL format validation
-		if !strings.HasPrefix(defs[i].URL, "http://") && !strings.HasPrefix(defs[i].URL, "https://") {
+		if channelType == channel.VirtualChannelType {
+			if !isValidGroupName(defs[i].URL) {
+				return nil, fmt.Errorf("invalid member group name for virtual group: %s", defs[i].URL)
+			}
+		} else if !strings.HasPrefix(defs[i].URL, "http://") && !strings.HasPrefix(defs[i].URL, "https://") {
+			// Basic URL format validation
 			return nil, fmt.Errorf("invalid URL format for upstream: %s", defs[i].URL)
 		}
 		if defs[i].Weight <= 0 {
@@ -88,6 +101,20 @@ func isValidGroupName(name string) bool {
 	return match
 }
 
+// maxErrorMessageLength caps a custom client-facing error message, in characters.
+const maxErrorMessageLength = 500
+
+// validateErrorMessage checks that a custom error message is short, single-line text.
+func validateErrorMessage(message string) error {
+	if utf8.RuneCountInString(message) > maxErrorMessageLength {
+		return fmt.Errorf("must be at most %d characters", maxErrorMessageLength)
+	}
+	if strings.ContainsFunc(message, unicode.IsControl) {
+		return fmt.Errorf("must not contain control characters")
+	}
+	return nil
+}
+
 // isValidValidationEndpoint checks if the validation endpoint is a valid path.
 func isValidValidationEndpoint(endpoint string) bool {
 	if endpoint == "" {
@@ -102,6 +129,197 @@ func isValidValidationEndpoint(endpoint string) bool {
 	return true
 }
 
+// azureDeploymentNamePattern matches the characters Azure allows in a deployment name.
+var azureDeploymentNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,64}$`)
+
+// validateGroupOptions validates and cleans the group-only fields of a GroupConfig in place.
+func validateGroupOptions(cfg *models.GroupConfig) error {
+	cfg.AzureAPIVersion = strings.TrimSpace(cfg.AzureAPIVersion)
+	if strings.ContainsAny(cfg.AzureAPIVersion, "/?&# ") {
+		return fmt.Errorf("invalid azure_api_version: %s", cfg.AzureAPIVersion)
+	}
+
+	if len(cfg.AzureDeployments) > 0 {
+		cleaned := make(map[string]string, len(cfg.AzureDeployments))
+		for model, deployment := range cfg.AzureDeployments {
+			model = strings.TrimSpace(model)
+			deployment = strings.TrimSpace(deployment)
+			if model == "" {
+				return fmt.Errorf("azure_deployments: model name cannot be empty")
+			}
+			if !azureDeploymentNamePattern.MatchString(deployment) {
+				return fmt.Errorf("azure_deployments: invalid deployment name '%s' for model '%s'", deployment, model)
+			}
+			cleaned[model] = deployment
+		}
+		cfg.AzureDeployments = cleaned
+	}
+
+	cfg.FallbackGroupName = strings.TrimSpace(cfg.FallbackGroupName)
+	if cfg.FallbackGroupName != "" && !isValidGroupName(cfg.FallbackGroupName) {
+		return fmt.Errorf("invalid fallback_group_name: %s", cfg.FallbackGroupName)
+	}
+
+	cfg.ShadowGroup = strings.TrimSpace(cfg.ShadowGroup)
+	if cfg.ShadowGroup != "" && !isValidGroupName(cfg.ShadowGroup) {
+		return fmt.Errorf("invalid shadow_group: %s", cfg.ShadowGroup)
+	}
+	if cfg.ShadowPercent < 0 || cfg.ShadowPercent > 100 {
+		return fmt.Errorf("shadow_percent must be between 0 and 100")
+	}
+
+	if cfg.DailyRequestQuota < 0 {
+		return fmt.Errorf("daily_request_quota cannot be negative")
+	}
+
+	if cfg.KeyWarmUpSeconds < 0 {
+		return fmt.Errorf("key_warmup_seconds cannot be negative")
+	}
+
+	if cfg.LastResortProbeSeconds < 0 {
+		return fmt.Errorf("last_resort_probe_seconds cannot be negative")
+	}
+
+	cfg.TransformWebhookURL = strings.TrimSpace(cfg.TransformWebhookURL)
+	if cfg.TransformWebhookURL != "" {
+		u, err := url.Parse(cfg.TransformWebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("transform_webhook_url must be an http or https URL")
+		}
+	}
+	if cfg.TransformWebhookTimeoutMs < 0 || cfg.TransformWebhookTimeoutMs > 60000 {
+		return fmt.Errorf("transform_webhook_timeout_ms must be between 0 and 60000")
+	}
+
+	if cfg.SessionRetryBudget < 0 {
+		return fmt.Errorf("session_retry_budget cannot be negative")
+	}
+
+	if cfg.SessionRetryWindowSeconds < 0 {
+		return fmt.Errorf("session_retry_window_seconds cannot be negative")
+	}
+
+	if cfg.CoalesceMaxBytes < 0 {
+		return fmt.Errorf("coalesce_max_bytes cannot be negative")
+	}
+
+	cfg.SuccessStatusCodes = strings.TrimSpace(cfg.SuccessStatusCodes)
+	if _, err := channel.ParseStatusCodes(cfg.SuccessStatusCodes); err != nil {
+		return fmt.Errorf("invalid success_status_codes: %w", err)
+	}
+
+	if len(cfg.RemoveParams) > 0 {
+		removeParams := make([]string, 0, len(cfg.RemoveParams))
+		for _, path := range cfg.RemoveParams {
+			path = strings.TrimSpace(path)
+			if path == "" || slices.Contains(removeParams, path) {
+				continue
+			}
+			if _, err := utils.SplitParamPath(path); err != nil {
+				return fmt.Errorf("remove_params: %w", err)
+			}
+			removeParams = append(removeParams, path)
+		}
+		cfg.RemoveParams = removeParams
+	}
+
+	if len(cfg.LogBodiesRedactFields) > 0 {
+		redactFields := make([]string, 0, len(cfg.LogBodiesRedactFields))
+		for _, path := range cfg.LogBodiesRedactFields {
+			path = strings.TrimSpace(path)
+			if path == "" || slices.Contains(redactFields, path) {
+				continue
+			}
+			if _, err := utils.SplitParamPath(path); err != nil {
+				return fmt.Errorf("log_bodies_redact_fields: %w", err)
+			}
+			redactFields = append(redactFields, path)
+		}
+		cfg.LogBodiesRedactFields = redactFields
+	}
+
+	if len(cfg.ShadowCompareFields) > 0 {
+		compareFields := make([]string, 0, len(cfg.ShadowCompareFields))
+		for _, path := range cfg.ShadowCompareFields {
+			path = strings.TrimSpace(path)
+			if path == "" || slices.Contains(compareFields, path) {
+				continue
+			}
+			if _, err := utils.SplitParamPath(path); err != nil {
+				return fmt.Errorf("shadow_compare_fields: %w", err)
+			}
+			compareFields = append(compareFields, path)
+		}
+		cfg.ShadowCompareFields = compareFields
+	}
+
+	for path := range cfg.ForceParams {
+		if _, err := utils.SplitParamPath(path); err != nil {
+			return fmt.Errorf("force_params: %w", err)
+		}
+	}
+
+	if cfg.ForceSystemPrompt != nil {
+		prompt := cfg.ForceSystemPrompt
+		prompt.Mode = strings.TrimSpace(prompt.Mode)
+		switch {
+		case prompt.Mode == "" && strings.TrimSpace(prompt.Content) == "":
+			cfg.ForceSystemPrompt = nil
+		case prompt.Mode != models.SystemPromptModePrepend && prompt.Mode != models.SystemPromptModeReplace && prompt.Mode != models.SystemPromptModeAppend:
+			return fmt.Errorf("invalid force_system_prompt mode '%s': must be prepend, replace or append", prompt.Mode)
+		case strings.TrimSpace(prompt.Content) == "":
+			return fmt.Errorf("force_system_prompt content cannot be empty")
+		}
+	}
+
+	if cfg.ErrorMessages != nil {
+		messages := cfg.ErrorMessages
+		for _, field := range []struct {
+			name    string
+			message *string
+		}{
+			{"no_keys", &messages.NoKeys},
+			{"rate_limited", &messages.RateLimited},
+			{"max_retries", &messages.MaxRetries},
+		} {
+			*field.message = strings.TrimSpace(*field.message)
+			if err := validateErrorMessage(*field.message); err != nil {
+				return fmt.Errorf("error_messages.%s: %w", field.name, err)
+			}
+		}
+		if *messages == (models.ErrorMessages{}) {
+			cfg.ErrorMessages = nil
+		}
+	}
+
+	if len(cfg.StaticModels) > 0 {
+		seen := make(map[string]bool, len(cfg.StaticModels))
+		staticModels := make([]string, 0, len(cfg.StaticModels))
+		for _, model := range cfg.StaticModels {
+			model = strings.TrimSpace(model)
+			if model == "" || seen[model] {
+				continue
+			}
+			seen[model] = true
+			staticModels = append(staticModels, model)
+		}
+		cfg.StaticModels = staticModels
+	}
+
+	cfg.TLSClientCert = strings.TrimSpace(cfg.TLSClientCert)
+	cfg.TLSClientKey = strings.TrimSpace(cfg.TLSClientKey)
+	cfg.TLSCACert = strings.TrimSpace(cfg.TLSCACert)
+	if _, err := httpclient.BuildTLSConfig(httpclient.TLSOptions{
+		ClientCert: cfg.TLSClientCert,
+		ClientKey:  cfg.TLSClientKey,
+		CACert:     cfg.TLSCACert,
+	}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // validateAndCleanConfig validates the group config against the GroupConfig struct and system-defined rules.
 func (s *Server) validateAndCleanConfig(configMap map[string]any) (map[string]any, error) {
 	if configMap == nil {
@@ -142,6 +360,11 @@ func (s *Server) validateAndCleanConfig(configMap map[string]any) (map[string]an
 		return nil, fmt.Errorf("failed to unmarshal into validated config: %w", err)
 	}
 
+	// 4. Validate group-only options that have no system-level counterpart.
+	if err := validateGroupOptions(&validatedConfig); err != nil {
+		return nil, err
+	}
+
 	validatedBytes, err := json.Marshal(validatedConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal validated config: %w", err)
@@ -154,14 +377,78 @@ func (s *Server) validateAndCleanConfig(configMap map[string]any) (map[string]an
 	return finalMap, nil
 }
 
+// Values of the validate_on_save query parameter of CreateGroup and UpdateGroup.
+const (
+	validateOnSaveReject = "reject" // reject the save when validation fails
+	validateOnSaveWarn   = "warn"   // save anyway and report the failure in the response
+)
+
+// parseValidateOnSave reads the validate_on_save query parameter. "true" is accepted as "reject".
+func parseValidateOnSave(c *gin.Context) (string, error) {
+	switch mode := c.Query("validate_on_save"); mode {
+	case "", "false":
+		return "", nil
+	case "true", validateOnSaveReject:
+		return validateOnSaveReject, nil
+	case validateOnSaveWarn:
+		return validateOnSaveWarn, nil
+	default:
+		return "", fmt.Errorf("validate_on_save must be '%s' or '%s'", validateOnSaveReject, validateOnSaveWarn)
+	}
+}
+
+// validateOnSave makes a live validation call for the group when requested, with keyValue or, when it
+// is empty, one of the group's active keys. It returns false, after writing the error response, when
+// the save must be rejected.
+func (s *Server) validateOnSave(c *gin.Context, mode string, group *models.Group, keyValue string) (*keypool.GroupValidationResult, bool) {
+	if mode == "" {
+		return nil, true
+	}
+	result := s.KeyService.KeyValidator.ValidateGroupConfig(group, keyValue)
+	if mode == validateOnSaveReject && !result.Skipped && !result.IsValid {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, fmt.Sprintf("Group validation failed: %s", result.Error)))
+		return result, false
+	}
+	return result, true
+}
+
+// GroupCreateRequest defines the payload for creating a group.
+type GroupCreateRequest struct {
+	Name               string          `json:"name"`
+	DisplayName        string          `json:"display_name"`
+	Description        string          `json:"description"`
+	Upstreams          json.RawMessage `json:"upstreams"`
+	ChannelType        string          `json:"channel_type"`
+	Sort               int             `json:"sort"`
+	TestModel          string          `json:"test_model"`
+	ValidationEndpoint string          `json:"validation_endpoint"`
+	ParamOverrides     map[string]any  `json:"param_overrides"`
+	Config             map[string]any  `json:"config"`
+	ProxyKeys          string          `json:"proxy_keys"`
+	// ValidationKey is the API key used by validate_on_save, as a new group has no keys yet.
+	// It is not added to the group.
+	ValidationKey string `json:"validation_key,omitempty"`
+}
+
 // CreateGroup handles the creation of a new group.
 func (s *Server) CreateGroup(c *gin.Context) {
-	var req models.Group
+	var req GroupCreateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		response.Error(c, app_errors.NewAPIError(app_errors.ErrInvalidJSON, err.Error()))
 		return
 	}
 
+	validateMode, err := parseValidateOnSave(c)
+	if err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, err.Error()))
+		return
+	}
+	validationKey := strings.TrimSpace(req.ValidationKey)
+	if validateMode != "" && validationKey == "" && strings.TrimSpace(req.ChannelType) != channel.VirtualChannelType {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, "validate_on_save requires validation_key when creating a group"))
+		return
+	}
+
 	// Data Cleaning and Validation
 	name := strings.TrimSpace(req.Name)
 	if !isValidGroupName(name) {
@@ -182,7 +469,7 @@ func (s *Server) CreateGroup(c *gin.Context) {
 		return
 	}
 
-	cleanedUpstreams, err := validateAndCleanUpstreams(json.RawMessage(req.Upstreams))
+	cleanedUpstreams, err := validateAndCleanUpstreams(req.Upstreams, channelType)
 	if err != nil {
 		response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, err.Error()))
 		return
@@ -214,15 +501,39 @@ func (s *Server) CreateGroup(c *gin.Context) {
 		ProxyKeys:          strings.TrimSpace(req.ProxyKeys),
 	}
 
+	validation, ok := s.validateOnSave(c, validateMode, &group, validationKey)
+	if !ok {
+		return
+	}
+
 	if err := s.DB.Create(&group).Error; err != nil {
 		response.Error(c, app_errors.ParseDBError(err))
 		return
 	}
 
+	s.recordAudit(c, models.AuditLog{
+		Action:     models.AuditActionGroupCreate,
+		TargetType: models.AuditTargetGroup,
+		TargetID:   group.ID,
+		TargetName: group.Name,
+		After:      groupAuditValues(groupAuditSnapshot(&group)),
+	})
+	warnBodyLogging(&group)
+
 	if err := s.GroupManager.Invalidate(); err != nil {
 		logrus.WithContext(c.Request.Context()).WithError(err).Error("failed to invalidate group cache")
 	}
-	response.Success(c, s.newGroupResponse(&group))
+	groupResponse := s.newGroupResponse(&group)
+	groupResponse.Validation = validation
+	response.Success(c, groupResponse)
+}
+
+// warnBodyLogging logs a warning when a saved group stores request and response bodies,
+// which contain prompts and other sensitive data.
+func warnBodyLogging(group *models.Group) {
+	if groupOptions, err := utils.ParseGroupConfig(group.Config); err == nil && groupOptions.LogBodies {
+		logrus.Warnf("Request body logging is enabled for group '%s': request and response bodies, including prompts, are stored for %s", group.Name, services.RequestBodyLogRetention)
+	}
 }
 
 // ListGroups handles listing all groups.
@@ -270,6 +581,7 @@ func (s *Server) UpdateGroup(c *gin.Context) {
 		response.Error(c, app_errors.ParseDBError(err))
 		return
 	}
+	before := groupAuditSnapshot(&group)
 
 	var req GroupUpdateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -277,13 +589,11 @@ func (s *Server) UpdateGroup(c *gin.Context) {
 		return
 	}
 
-	// Start a transaction
-	tx := s.DB.Begin()
-	if tx.Error != nil {
-		response.Error(c, app_errors.ErrDatabase)
+	validateMode, err := parseValidateOnSave(c)
+	if err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, err.Error()))
 		return
 	}
-	defer tx.Rollback() // Rollback on panic
 
 	// Apply updates from the request, with cleaning and validation
 	if req.Name != nil {
@@ -303,15 +613,6 @@ func (s *Server) UpdateGroup(c *gin.Context) {
 		group.Description = strings.TrimSpace(*req.Description)
 	}
 
-	if req.Upstreams != nil {
-		cleanedUpstreams, err := validateAndCleanUpstreams(req.Upstreams)
-		if err != nil {
-			response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, err.Error()))
-			return
-		}
-		group.Upstreams = cleanedUpstreams
-	}
-
 	if req.ChannelType != nil {
 		cleanedChannelType := strings.TrimSpace(*req.ChannelType)
 		if !isValidChannelType(cleanedChannelType) {
@@ -321,6 +622,20 @@ func (s *Server) UpdateGroup(c *gin.Context) {
 		}
 		group.ChannelType = cleanedChannelType
 	}
+
+	// Upstreams are validated against the final channel type, so a type change re-checks existing upstreams.
+	if req.Upstreams != nil || req.ChannelType != nil {
+		upstreams := json.RawMessage(group.Upstreams)
+		if req.Upstreams != nil {
+			upstreams = req.Upstreams
+		}
+		cleanedUpstreams, err := validateAndCleanUpstreams(upstreams, group.ChannelType)
+		if err != nil {
+			response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, err.Error()))
+			return
+		}
+		group.Upstreams = cleanedUpstreams
+	}
 	if req.Sort != nil {
 		group.Sort = *req.Sort
 	}
@@ -345,6 +660,7 @@ func (s *Server) UpdateGroup(c *gin.Context) {
 	}
 
 	if req.Config != nil {
+		restoreRedactedConfig(req.Config, group.Config)
 		cleanedConfig, err := s.validateAndCleanConfig(req.Config)
 		if err != nil {
 			response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, fmt.Sprintf("Invalid config format: %v", err)))
@@ -357,6 +673,20 @@ func (s *Server) UpdateGroup(c *gin.Context) {
 		group.ProxyKeys = strings.TrimSpace(*req.ProxyKeys)
 	}
 
+	// The live validation call runs before the transaction, so it holds no database lock.
+	validation, ok := s.validateOnSave(c, validateMode, &group, "")
+	if !ok {
+		return
+	}
+
+	// Start a transaction
+	tx := s.DB.Begin()
+	if tx.Error != nil {
+		response.Error(c, app_errors.ErrDatabase)
+		return
+	}
+	defer tx.Rollback() // Rollback on panic
+
 	// Save the updated group object
 	if err := tx.Save(&group).Error; err != nil {
 		response.Error(c, app_errors.ParseDBError(err))
@@ -368,10 +698,27 @@ func (s *Server) UpdateGroup(c *gin.Context) {
 		return
 	}
 
+	s.recordChangeAudit(c, models.AuditLog{
+		Action:     models.AuditActionGroupUpdate,
+		TargetType: models.AuditTargetGroup,
+		TargetID:   group.ID,
+		TargetName: group.Name,
+	}, before, groupAuditSnapshot(&group), groupAuditValues)
+	warnBodyLogging(&group)
+
+	// Put the active keys in order right away when stable_order is turned on.
+	if req.Config != nil {
+		if err := s.KeyService.KeyProvider.SortActiveKeys(group.ID); err != nil {
+			logrus.WithContext(c.Request.Context()).WithError(err).Error("failed to sort active keys")
+		}
+	}
+
 	if err := s.GroupManager.Invalidate(); err != nil {
 		logrus.WithContext(c.Request.Context()).WithError(err).Error("failed to invalidate group cache")
 	}
-	response.Success(c, s.newGroupResponse(&group))
+	groupResponse := s.newGroupResponse(&group)
+	groupResponse.Validation = validation
+	response.Success(c, groupResponse)
 }
 
 // GroupResponse defines the structure for a group response, excluding sensitive or large fields.
@@ -390,8 +737,11 @@ type GroupResponse struct {
 	Config             datatypes.JSONMap `json:"config"`
 	ProxyKeys          string            `json:"proxy_keys"`
 	LastValidatedAt    *time.Time        `json:"last_validated_at"`
+	Draining           bool              `json:"draining"`
 	CreatedAt          time.Time         `json:"created_at"`
 	UpdatedAt          time.Time         `json:"updated_at"`
+	// Validation is the result of the live check requested with validate_on_save.
+	Validation *keypool.GroupValidationResult `json:"validation,omitempty"`
 }
 
 // newGroupResponse creates a new GroupResponse from a models.Group.
@@ -418,14 +768,47 @@ func (s *Server) newGroupResponse(group *models.Group) *GroupResponse {
 		TestModel:          group.TestModel,
 		ValidationEndpoint: group.ValidationEndpoint,
 		ParamOverrides:     group.ParamOverrides,
-		Config:             group.Config,
+		Config:             redactGroupConfig(group.Config),
 		ProxyKeys:          group.ProxyKeys,
 		LastValidatedAt:    group.LastValidatedAt,
+		Draining:           group.Draining,
 		CreatedAt:          group.CreatedAt,
 		UpdatedAt:          group.UpdatedAt,
 	}
 }
 
+// redactedSecret replaces the TLS client key in group configs returned by the API.
+const redactedSecret = "******"
+
+// redactGroupConfig returns a copy of a group config with the TLS client key and proxy credentials
+// masked, for API responses. The stored values never leave the server.
+func redactGroupConfig(config datatypes.JSONMap) datatypes.JSONMap {
+	if config == nil {
+		return nil
+	}
+	redacted := maps.Clone(config)
+	if proxyURL, ok := redacted["upstream_proxy_url"].(string); ok {
+		redacted["upstream_proxy_url"] = httpclient.MaskProxyURL(proxyURL)
+	}
+	if clientKey, ok := redacted["tls_client_key"].(string); ok && clientKey != "" {
+		redacted["tls_client_key"] = redactedSecret
+	}
+	return redacted
+}
+
+// restoreRedactedConfig puts the stored values back into a config update for the secrets the client
+// sent back in their masked form.
+func restoreRedactedConfig(configMap map[string]any, stored datatypes.JSONMap) {
+	if proxyURL, ok := configMap["upstream_proxy_url"].(string); ok {
+		if storedURL, _ := stored["upstream_proxy_url"].(string); httpclient.IsMaskedProxyURL(proxyURL, storedURL) {
+			configMap["upstream_proxy_url"] = storedURL
+		}
+	}
+	if configMap["tls_client_key"] == redactedSecret {
+		configMap["tls_client_key"] = stored["tls_client_key"]
+	}
+}
+
 // DeleteGroup handles deleting a group.
 func (s *Server) DeleteGroup(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
@@ -505,12 +888,81 @@ func (s *Server) DeleteGroup(c *gin.Context) {
 		return
 	}
 
+	s.recordAudit(c, models.AuditLog{
+		Action:     models.AuditActionGroupDelete,
+		TargetType: models.AuditTargetGroup,
+		TargetID:   group.ID,
+		TargetName: group.Name,
+		Before:     groupAuditValues(groupAuditSnapshot(&group)),
+		Details:    map[string]any{"key_count": len(apiKeys)},
+	})
+
 	if err := s.GroupManager.Invalidate(); err != nil {
 		logrus.WithContext(c.Request.Context()).WithError(err).Error("failed to invalidate group cache")
 	}
 	response.Success(c, gin.H{"message": "Group and associated keys deleted successfully"})
 }
 
+// SetGroupDrainingRequest defines the payload for entering or leaving draining.
+type SetGroupDrainingRequest struct {
+	Draining *bool `json:"draining" binding:"required"`
+}
+
+// SetGroupDraining handles putting a group into or out of draining. A draining group hands out no
+// keys and is skipped by the periodic validation, while its keys stay in place for a clean swap.
+func (s *Server) SetGroupDraining(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrBadRequest, "Invalid group ID format"))
+		return
+	}
+
+	var req SetGroupDrainingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInvalidJSON, err.Error()))
+		return
+	}
+
+	var group models.Group
+	if err := s.DB.First(&group, id).Error; err != nil {
+		response.Error(c, app_errors.ParseDBError(err))
+		return
+	}
+
+	if group.ChannelType == channel.VirtualChannelType {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, "Virtual groups have no keys to drain"))
+		return
+	}
+
+	if err := s.KeyService.KeyProvider.SetDraining(group.ID, *req.Draining); err != nil {
+		response.Error(c, app_errors.ParseDBError(err))
+		return
+	}
+
+	if group.Draining != *req.Draining {
+		s.recordAudit(c, models.AuditLog{
+			Action:     models.AuditActionGroupDrain,
+			TargetType: models.AuditTargetGroup,
+			TargetID:   group.ID,
+			TargetName: group.Name,
+			Before:     map[string]any{"draining": group.Draining},
+			After:      map[string]any{"draining": *req.Draining},
+		})
+	}
+	group.Draining = *req.Draining
+
+	if *req.Draining {
+		logrus.Infof("Group '%s' is draining: its keys are no longer selected", group.Name)
+	} else {
+		logrus.Infof("Group '%s' stopped draining", group.Name)
+	}
+
+	if err := s.GroupManager.Invalidate(); err != nil {
+		logrus.WithContext(c.Request.Context()).WithError(err).Error("failed to invalidate group cache")
+	}
+	response.Success(c, s.newGroupResponse(&group))
+}
+
 // ConfigOption represents a single configurable option for a group.
 type ConfigOption struct {
 	Key          string `json:"key"`
@@ -561,6 +1013,9 @@ func (s *Server) GetGroupConfigOptions(c *gin.Context) {
 			if fieldName, ok := jsonToFieldMap[key]; ok {
 				defaultValue = currentSettingsValue.FieldByName(fieldName).Interface()
 			}
+			if key == "upstream_proxy_url" {
+				defaultValue = httpclient.MaskProxyURL(currentSettings.UpstreamProxyURL)
+			}
 
 			option := ConfigOption{
 				Key:          key,
@@ -580,13 +1035,84 @@ type KeyStats struct {
 	TotalKeys   int64 `json:"total_keys"`
 	ActiveKeys  int64 `json:"active_keys"`
 	InvalidKeys int64 `json:"invalid_keys"`
+	// RequestCountVariance and RequestCountCV (standard deviation over mean) measure how evenly
+	// requests are spread across the active keys; a CV near 0 means an even distribution.
+	RequestCountVariance float64 `json:"request_count_variance"`
+	RequestCountCV       float64 `json:"request_count_cv"`
 }
 
 // RequestStats defines the statistics for requests over a period.
 type RequestStats struct {
-	TotalRequests  int64   `json:"total_requests"`
-	FailedRequests int64   `json:"failed_requests"`
-	FailureRate    float64 `json:"failure_rate"`
+	TotalRequests     int64         `json:"total_requests"`
+	FailedRequests    int64         `json:"failed_requests"`
+	CancelledRequests int64         `json:"cancelled_requests"`
+	FailureRate       float64       `json:"failure_rate"`
+	Latency           *LatencyStats `json:"latency,omitempty"`
+}
+
+// LatencyStats defines request latency percentiles (milliseconds) over a period.
+type LatencyStats struct {
+	P50         int64 `json:"p50_ms"`
+	P95         int64 `json:"p95_ms"`
+	P99         int64 `json:"p99_ms"`
+	UpstreamP50 int64 `json:"upstream_p50_ms"`
+	UpstreamP95 int64 `json:"upstream_p95_ms"`
+	UpstreamP99 int64 `json:"upstream_p99_ms"`
+	SampleSize  int   `json:"sample_size"`
+}
+
+// maxLatencySamples caps how many recent request logs are read to compute latency percentiles.
+// Percentiles are computed on the fly rather than pre-aggregated, because percentiles cannot be
+// merged from hourly buckets; the cap keeps the query bounded for busy groups.
+const maxLatencySamples = 10000
+
+// percentile returns the nearest-rank percentile of sorted values.
+func percentile(sorted []int64, p float64) int64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	return sorted[max(rank, 0)]
+}
+
+// queryLatencyStats computes latency percentiles from the most recent request logs of a group.
+// Client-cancelled requests are skipped, as their duration was cut short by the client.
+func (s *Server) queryLatencyStats(groupID uint, since time.Time) (*LatencyStats, error) {
+	var samples []struct {
+		Duration         int64
+		UpstreamDuration int64
+	}
+	err := s.ReadDB.Model(&models.RequestLog{}).
+		Select("duration, upstream_duration").
+		Where("group_id = ? AND timestamp >= ? AND is_client_cancelled = ?", groupID, since, false).
+		Order("timestamp desc").
+		Limit(maxLatencySamples).
+		Scan(&samples).Error
+	if err != nil {
+		return nil, err
+	}
+
+	durations := make([]int64, 0, len(samples))
+	upstreamDurations := make([]int64, 0, len(samples))
+	for _, sample := range samples {
+		durations = append(durations, sample.Duration)
+		// Failed requests carry no upstream duration for a successful attempt.
+		if sample.UpstreamDuration > 0 {
+			upstreamDurations = append(upstreamDurations, sample.UpstreamDuration)
+		}
+	}
+	slices.Sort(durations)
+	slices.Sort(upstreamDurations)
+
+	return &LatencyStats{
+		P50:         percentile(durations, 50),
+		P95:         percentile(durations, 95),
+		P99:         percentile(durations, 99),
+		UpstreamP50: percentile(upstreamDurations, 50),
+		UpstreamP95: percentile(upstreamDurations, 95),
+		UpstreamP99: percentile(upstreamDurations, 99),
+		SampleSize:  len(durations),
+	}, nil
 }
 
 // GroupStatsResponse defines the complete statistics for a group.
@@ -595,6 +1121,8 @@ type GroupStatsResponse struct {
 	HourlyStats RequestStats `json:"hourly_stats"` // 1 hour
 	DailyStats  RequestStats `json:"daily_stats"`  // 24 hours
 	WeeklyStats RequestStats `json:"weekly_stats"` // 7 days
+	// Quota is today's usage against the daily request quota, nil when the group has none.
+	Quota *services.QuotaUsage `json:"quota,omitempty"`
 }
 
 // calculateRequestStats is a helper to compute request statistics.
@@ -638,24 +1166,45 @@ func (s *Server) GetGroupStats(c *gin.Context) {
 		defer wg.Done()
 		var totalKeys, activeKeys int64
 
-		if err := s.DB.Model(&models.APIKey{}).Where("group_id = ?", groupID).Count(&totalKeys).Error; err != nil {
+		if err := s.ReadDB.Model(&models.APIKey{}).Where("group_id = ?", groupID).Count(&totalKeys).Error; err != nil {
 			mu.Lock()
 			errors = append(errors, fmt.Errorf("failed to get total keys: %w", err))
 			mu.Unlock()
 			return
 		}
-		if err := s.DB.Model(&models.APIKey{}).Where("group_id = ? AND status = ?", groupID, models.KeyStatusActive).Count(&activeKeys).Error; err != nil {
+		if err := s.ReadDB.Model(&models.APIKey{}).Where("group_id = ? AND status = ?", groupID, models.KeyStatusActive).Count(&activeKeys).Error; err != nil {
 			mu.Lock()
 			errors = append(errors, fmt.Errorf("failed to get active keys: %w", err))
 			mu.Unlock()
 			return
 		}
 
+		var distribution struct {
+			Mean       float64
+			MeanSquare float64
+		}
+		if err := s.ReadDB.Model(&models.APIKey{}).
+			Select("COALESCE(AVG(request_count), 0) as mean, COALESCE(AVG(request_count * 1.0 * request_count), 0) as mean_square").
+			Where("group_id = ? AND status = ?", groupID, models.KeyStatusActive).
+			Scan(&distribution).Error; err != nil {
+			mu.Lock()
+			errors = append(errors, fmt.Errorf("failed to get key request distribution: %w", err))
+			mu.Unlock()
+			return
+		}
+		variance := max(distribution.MeanSquare-distribution.Mean*distribution.Mean, 0)
+		var cv float64
+		if distribution.Mean > 0 {
+			cv, _ = strconv.ParseFloat(fmt.Sprintf("%.4f", math.Sqrt(variance)/distribution.Mean), 64)
+		}
+
 		mu.Lock()
 		resp.KeyStats = KeyStats{
-			TotalKeys:   totalKeys,
-			ActiveKeys:  activeKeys,
-			InvalidKeys: totalKeys - activeKeys,
+			TotalKeys:            totalKeys,
+			ActiveKeys:           activeKeys,
+			InvalidKeys:          totalKeys - activeKeys,
+			RequestCountVariance: variance,
+			RequestCountCV:       cv,
 		}
 		mu.Unlock()
 	}()
@@ -664,34 +1213,61 @@ func (s *Server) GetGroupStats(c *gin.Context) {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		var total, failed int64
+		var total, failed, cancelled int64
 		now := time.Now()
 		oneHourAgo := now.Add(-1 * time.Hour)
 
-		if err := s.DB.Model(&models.RequestLog{}).Where("group_id = ? AND timestamp BETWEEN ? AND ?", groupID, oneHourAgo, now).Count(&total).Error; err != nil {
+		if err := s.ReadDB.Model(&models.RequestLog{}).Where("group_id = ? AND timestamp BETWEEN ? AND ? AND is_client_cancelled = ?", groupID, oneHourAgo, now, false).Count(&total).Error; err != nil {
 			mu.Lock()
 			errors = append(errors, fmt.Errorf("failed to get hourly total requests: %w", err))
 			mu.Unlock()
 			return
 		}
-		if err := s.DB.Model(&models.RequestLog{}).Where("group_id = ? AND timestamp BETWEEN ? AND ? AND is_success = ?", groupID, oneHourAgo, now, false).Count(&failed).Error; err != nil {
+		if err := s.ReadDB.Model(&models.RequestLog{}).Where("group_id = ? AND timestamp BETWEEN ? AND ? AND is_success = ? AND is_client_cancelled = ?", groupID, oneHourAgo, now, false, false).Count(&failed).Error; err != nil {
 			mu.Lock()
 			errors = append(errors, fmt.Errorf("failed to get hourly failed requests: %w", err))
 			mu.Unlock()
 			return
 		}
+		if err := s.ReadDB.Model(&models.RequestLog{}).Where("group_id = ? AND timestamp BETWEEN ? AND ? AND is_client_cancelled = ?", groupID, oneHourAgo, now, true).Count(&cancelled).Error; err != nil {
+			mu.Lock()
+			errors = append(errors, fmt.Errorf("failed to get hourly cancelled requests: %w", err))
+			mu.Unlock()
+			return
+		}
 
 		mu.Lock()
 		resp.HourlyStats = calculateRequestStats(total, failed)
+		resp.HourlyStats.CancelledRequests = cancelled
 		mu.Unlock()
 	}()
 
+	// 延迟分位数 (查询 request_logs 表，最近1小时和24小时)
+	var hourlyLatency, dailyLatency *LatencyStats
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		now := time.Now()
+		hourly, err := s.queryLatencyStats(groupID, now.Add(-1*time.Hour))
+		if err == nil {
+			dailyLatency, err = s.queryLatencyStats(groupID, now.Add(-24*time.Hour))
+		}
+		if err != nil {
+			mu.Lock()
+			errors = append(errors, fmt.Errorf("failed to get latency stats: %w", err))
+			mu.Unlock()
+			return
+		}
+		hourlyLatency = hourly
+	}()
+
 	// 4. 24小时和7天统计 (查询 group_hourly_stats 表)
 	// 辅助函数，用于从 group_hourly_stats 查询
 	queryHourlyStats := func(duration time.Duration) (RequestStats, error) {
 		var result struct {
-			SuccessCount int64
-			FailureCount int64
+			SuccessCount   int64
+			FailureCount   int64
+			CancelledCount int64
 		}
 		now := time.Now()
 		// 结束时间为当前小时的整点，查询时不包含该小时
@@ -699,14 +1275,16 @@ func (s *Server) GetGroupStats(c *gin.Context) {
 		endTime := now.Truncate(time.Hour)
 		startTime := endTime.Add(-duration)
 
-		err := s.DB.Model(&models.GroupHourlyStat{}).
-			Select("SUM(success_count) as success_count, SUM(failure_count) as failure_count").
+		err := s.ReadDB.Model(&models.GroupHourlyStat{}).
+			Select("SUM(success_count) as success_count, SUM(failure_count) as failure_count, SUM(cancelled_count) as cancelled_count").
 			Where("group_id = ? AND time >= ? AND time < ?", groupID, startTime, endTime).
 			Scan(&result).Error
 		if err != nil {
 			return RequestStats{}, err
 		}
-		return calculateRequestStats(result.SuccessCount+result.FailureCount, result.FailureCount), nil
+		stats := calculateRequestStats(result.SuccessCount+result.FailureCount, result.FailureCount)
+		stats.CancelledRequests = result.CancelledCount
+		return stats, nil
 	}
 
 	// 24小时统计
@@ -743,6 +1321,15 @@ func (s *Server) GetGroupStats(c *gin.Context) {
 
 	wg.Wait()
 
+	resp.HourlyStats.Latency = hourlyLatency
+	resp.DailyStats.Latency = dailyLatency
+
+	quota, err := s.GroupQuotaService.GetUsage(&group)
+	if err != nil {
+		errors = append(errors, fmt.Errorf("failed to get quota usage: %w", err))
+	}
+	resp.Quota = quota
+
 	if len(errors) > 0 {
 		// 只记录第一个错误，但表明可能存在多个错误
 		logrus.WithContext(c.Request.Context()).WithError(errors[0]).Error("Errors occurred while fetching group stats")
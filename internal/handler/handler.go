@@ -6,10 +6,16 @@ import (
 	"time"
 
 	"gpt-load/internal/config"
+	"gpt-load/internal/db"
+	"gpt-load/internal/httpclient"
+	"gpt-load/internal/response"
 	"gpt-load/internal/services"
+	"gpt-load/internal/store"
 	"gpt-load/internal/types"
+	"gpt-load/internal/version"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
 	"go.uber.org/dig"
 	"gorm.io/gorm"
 )
@@ -17,6 +23,7 @@ import (
 // Server contains dependencies for HTTP handlers
 type Server struct {
 	DB                         *gorm.DB
+	ReadDB                     *db.ReadDB
 	config                     types.ConfigManager
 	SettingsManager            *config.SystemSettingsManager
 	GroupManager               *services.GroupManager
@@ -25,13 +32,25 @@ type Server struct {
 	KeyService                 *services.KeyService
 	KeyImportService           *services.KeyImportService
 	LogService                 *services.LogService
+	AuditLogService            *services.AuditLogService
+	RequestBodyLogService      *services.RequestBodyLogService
+	ShadowComparisonService    *services.ShadowComparisonService
+	RequestLogService          *services.RequestLogService
+	GroupQuotaService          *services.GroupQuotaService
+	ProxyKeyQuotaService       *services.ProxyKeyQuotaService
+	UsageReportService         *services.UsageReportService
+	KeyTestResultService       *services.KeyTestResultService
+	ClusterService             *services.ClusterService
+	HTTPClientManager          *httpclient.HTTPClientManager
 	CommonHandler              *CommonHandler
+	Storage                    store.Store
 }
 
 // NewServerParams defines the dependencies for the NewServer constructor.
 type NewServerParams struct {
 	dig.In
 	DB                         *gorm.DB
+	ReadDB                     *db.ReadDB
 	Config                     types.ConfigManager
 	SettingsManager            *config.SystemSettingsManager
 	GroupManager               *services.GroupManager
@@ -40,13 +59,25 @@ type NewServerParams struct {
 	KeyService                 *services.KeyService
 	KeyImportService           *services.KeyImportService
 	LogService                 *services.LogService
+	AuditLogService            *services.AuditLogService
+	RequestBodyLogService      *services.RequestBodyLogService
+	ShadowComparisonService    *services.ShadowComparisonService
+	RequestLogService          *services.RequestLogService
+	GroupQuotaService          *services.GroupQuotaService
+	ProxyKeyQuotaService       *services.ProxyKeyQuotaService
+	UsageReportService         *services.UsageReportService
+	KeyTestResultService       *services.KeyTestResultService
+	ClusterService             *services.ClusterService
+	HTTPClientManager          *httpclient.HTTPClientManager
 	CommonHandler              *CommonHandler
+	Storage                    store.Store
 }
 
 // NewServer creates a new handler instance with dependencies injected by dig.
 func NewServer(params NewServerParams) *Server {
 	return &Server{
 		DB:                         params.DB,
+		ReadDB:                     params.ReadDB,
 		config:                     params.Config,
 		SettingsManager:            params.SettingsManager,
 		GroupManager:               params.GroupManager,
@@ -55,10 +86,27 @@ func NewServer(params NewServerParams) *Server {
 		KeyService:                 params.KeyService,
 		KeyImportService:           params.KeyImportService,
 		LogService:                 params.LogService,
+		AuditLogService:            params.AuditLogService,
+		RequestBodyLogService:      params.RequestBodyLogService,
+		ShadowComparisonService:    params.ShadowComparisonService,
+		RequestLogService:          params.RequestLogService,
+		GroupQuotaService:          params.GroupQuotaService,
+		ProxyKeyQuotaService:       params.ProxyKeyQuotaService,
+		UsageReportService:         params.UsageReportService,
+		KeyTestResultService:       params.KeyTestResultService,
+		ClusterService:             params.ClusterService,
+		HTTPClientManager:          params.HTTPClientManager,
 		CommonHandler:              params.CommonHandler,
+		Storage:                    params.Storage,
 	}
 }
 
+// pageSizeLimits returns the page size limits of paginated lists from the system settings.
+func (s *Server) pageSizeLimits() response.PageSizeLimits {
+	settings := s.SettingsManager.GetSettings()
+	return response.PageSizeLimits{Default: settings.DefaultPageSize, Max: settings.MaxPageSize}
+}
+
 // LoginRequest represents the login request payload
 type LoginRequest struct {
 	AuthKey string `json:"auth_key" binding:"required"`
@@ -105,9 +153,36 @@ func (s *Server) Health(c *gin.Context) {
 		}
 	}
 
+	// A degraded store still serves requests, so the node stays up but reports it.
+	status := "healthy"
+	degraded := false
+	if degradable, ok := s.Storage.(store.Degradable); ok && degradable.IsDegraded() {
+		status = "degraded"
+		degraded = true
+	}
+
+	// Logs waiting to be written are lost if they outlive their cache TTL, so a growing backlog is reported.
+	logBacklog := gin.H{}
+	if pending, err := s.RequestLogService.PendingLogCount(); err != nil {
+		// The health check is public, so the store error only goes to the log.
+		logrus.Warnf("Health check failed to read the request log backlog: %v", err)
+		logBacklog["error"] = "unavailable"
+	} else {
+		threshold := s.SettingsManager.GetSettings().RequestLogBacklogWarnThreshold
+		logBacklog["pending"] = pending
+		logBacklog["threshold"] = threshold
+		logBacklog["exceeded"] = threshold > 0 && pending > int64(threshold)
+	}
+
+	buildInfo := version.Get()
 	c.JSON(http.StatusOK, gin.H{
-		"status":    "healthy",
-		"timestamp": time.Now().UTC().Format(time.RFC3339),
-		"uptime":    uptime,
+		"status":      status,
+		"degraded":    degraded,
+		"log_backlog": logBacklog,
+		"timestamp":   time.Now().UTC().Format(time.RFC3339),
+		"uptime":      uptime,
+		"version":     buildInfo.Version,
+		"commit":      buildInfo.Commit,
+		"build_time":  buildInfo.BuildTime,
 	})
 }
@@ -3,13 +3,17 @@ package handler
 import (
 	"fmt"
 	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/keypool"
 	"gpt-load/internal/models"
 	"gpt-load/internal/response"
+	"gpt-load/internal/utils"
 	"log"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
 
@@ -57,6 +61,23 @@ type KeyTextRequest struct {
 	KeysText string `json:"keys_text" binding:"required"`
 }
 
+// TestKeysRequest defines the payload for testing keys from a text block.
+type TestKeysRequest struct {
+	GroupID  uint   `json:"group_id" binding:"required"`
+	KeysText string `json:"keys_text" binding:"required"`
+	// SaveResults keeps the results as the group's last test run, see GetKeyTestResults.
+	SaveResults bool `json:"save_results"`
+	// Concurrency is the number of keys tested at a time; zero uses the group's key_validation_concurrency.
+	Concurrency int `json:"concurrency"`
+}
+
+// SetKeysStatusRequest defines the payload for setting the status of keys from a text block.
+type SetKeysStatusRequest struct {
+	GroupID  uint   `json:"group_id" binding:"required"`
+	KeysText string `json:"keys_text" binding:"required"`
+	Status   string `json:"status" binding:"required"`
+}
+
 // GroupIDRequest defines a generic payload for operations requiring only a group ID.
 type GroupIDRequest struct {
 	GroupID uint `json:"group_id" binding:"required"`
@@ -70,7 +91,8 @@ func (s *Server) AddMultipleKeys(c *gin.Context) {
 		return
 	}
 
-	if _, ok := s.findGroupByID(c, req.GroupID); !ok {
+	group, ok := s.findGroupByID(c, req.GroupID)
+	if !ok {
 		return
 	}
 
@@ -79,7 +101,7 @@ func (s *Server) AddMultipleKeys(c *gin.Context) {
 		return
 	}
 
-	result, err := s.KeyService.AddMultipleKeys(req.GroupID, req.KeysText)
+	result, err := s.KeyService.AddMultipleKeys(group, req.KeysText)
 	if err != nil {
 		if strings.Contains(err.Error(), "batch size exceeds the limit") {
 			response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, err.Error()))
@@ -91,6 +113,8 @@ func (s *Server) AddMultipleKeys(c *gin.Context) {
 		return
 	}
 
+	s.recordKeysAudit(c, models.AuditActionKeysAdd, group, req.KeysText, toAuditMap(result))
+
 	response.Success(c, result)
 }
 
@@ -118,9 +142,77 @@ func (s *Server) AddMultipleKeysAsync(c *gin.Context) {
 		return
 	}
 
+	s.recordKeysAudit(c, models.AuditActionKeysImport, group, req.KeysText, nil)
+
 	response.Success(c, taskStatus)
 }
 
+// ReplaceAllKeys replaces all keys of a group with the keys from a text block in one transaction.
+func (s *Server) ReplaceAllKeys(c *gin.Context) {
+	var req KeyTextRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInvalidJSON, err.Error()))
+		return
+	}
+
+	group, ok := s.findGroupByID(c, req.GroupID)
+	if !ok {
+		return
+	}
+
+	if err := validateKeysText(req.KeysText); err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, err.Error()))
+		return
+	}
+
+	result, err := s.KeyService.ReplaceAllKeys(group, req.KeysText)
+	if err != nil {
+		if strings.Contains(err.Error(), "batch size exceeds the limit") {
+			response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, err.Error()))
+		} else if err.Error() == "no valid keys found in the input text" {
+			response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, err.Error()))
+		} else {
+			response.Error(c, app_errors.ParseDBError(err))
+		}
+		return
+	}
+
+	s.recordKeysAudit(c, models.AuditActionKeysReplace, group, req.KeysText, toAuditMap(result))
+
+	response.Success(c, result)
+}
+
+// PreviewImportKeys reports how many keys of a text block would be imported or ignored, without importing them.
+func (s *Server) PreviewImportKeys(c *gin.Context) {
+	var req KeyTextRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInvalidJSON, err.Error()))
+		return
+	}
+
+	group, ok := s.findGroupByID(c, req.GroupID)
+	if !ok {
+		return
+	}
+
+	if err := validateKeysText(req.KeysText); err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, err.Error()))
+		return
+	}
+
+	result, err := s.KeyService.PreviewImport(group, req.KeysText)
+	if err != nil {
+		if err.Error() == "no valid keys found in the input text" {
+			response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, err.Error()))
+		} else {
+			response.Error(c, app_errors.ParseDBError(err))
+		}
+		return
+	}
+
+	response.Success(c, result)
+}
+
 // ListKeysInGroup handles listing all keys within a specific group with pagination.
 func (s *Server) ListKeysInGroup(c *gin.Context) {
 	groupID, err := validateGroupIDFromQuery(c)
@@ -144,11 +236,15 @@ func (s *Server) ListKeysInGroup(c *gin.Context) {
 	query := s.KeyService.ListKeysInGroupQuery(groupID, statusFilter, searchKeyword)
 
 	var keys []models.APIKey
-	paginatedResult, err := response.Paginate(c, query, &keys)
+	paginatedResult, err := response.Paginate(c, query, &keys, s.pageSizeLimits())
 	if err != nil {
 		response.Error(c, app_errors.ParseDBError(err))
 		return
 	}
+	if err := s.KeyService.DecryptKeys(keys); err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInternalServer, err.Error()))
+		return
+	}
 
 	response.Success(c, paginatedResult)
 }
@@ -161,7 +257,8 @@ func (s *Server) DeleteMultipleKeys(c *gin.Context) {
 		return
 	}
 
-	if _, ok := s.findGroupByID(c, req.GroupID); !ok {
+	group, ok := s.findGroupByID(c, req.GroupID)
+	if !ok {
 		return
 	}
 
@@ -182,6 +279,8 @@ func (s *Server) DeleteMultipleKeys(c *gin.Context) {
 		return
 	}
 
+	s.recordKeysAudit(c, models.AuditActionKeysDelete, group, req.KeysText, toAuditMap(result))
+
 	response.Success(c, result)
 }
 
@@ -193,7 +292,8 @@ func (s *Server) RestoreMultipleKeys(c *gin.Context) {
 		return
 	}
 
-	if _, ok := s.findGroupByID(c, req.GroupID); !ok {
+	group, ok := s.findGroupByID(c, req.GroupID)
+	if !ok {
 		return
 	}
 
@@ -214,12 +314,56 @@ func (s *Server) RestoreMultipleKeys(c *gin.Context) {
 		return
 	}
 
+	s.recordKeysAudit(c, models.AuditActionKeysRestore, group, req.KeysText, toAuditMap(result))
+
+	response.Success(c, result)
+}
+
+// SetKeysStatus handles setting the status of keys from a text block within a specific group.
+func (s *Server) SetKeysStatus(c *gin.Context) {
+	var req SetKeysStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInvalidJSON, err.Error()))
+		return
+	}
+
+	if req.Status != models.KeyStatusActive && req.Status != models.KeyStatusInvalid {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, fmt.Sprintf("status must be '%s' or '%s'", models.KeyStatusActive, models.KeyStatusInvalid)))
+		return
+	}
+
+	group, ok := s.findGroupByID(c, req.GroupID)
+	if !ok {
+		return
+	}
+
+	if err := validateKeysText(req.KeysText); err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, err.Error()))
+		return
+	}
+
+	result, err := s.KeyService.SetKeysStatus(req.GroupID, req.KeysText, req.Status)
+	if err != nil {
+		if strings.Contains(err.Error(), "batch size exceeds the limit") {
+			response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, err.Error()))
+		} else if err.Error() == "no valid keys found in the input text" {
+			response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, err.Error()))
+		} else {
+			response.Error(c, app_errors.ParseDBError(err))
+		}
+		return
+	}
+
+	details := toAuditMap(result)
+	details["status"] = req.Status
+	s.recordKeysAudit(c, models.AuditActionKeysSetStatus, group, req.KeysText, details)
+
 	response.Success(c, result)
 }
 
 // TestMultipleKeys handles a one-off validation test for multiple keys.
 func (s *Server) TestMultipleKeys(c *gin.Context) {
-	var req KeyTextRequest
+	var req TestKeysRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		response.Error(c, app_errors.NewAPIError(app_errors.ErrInvalidJSON, err.Error()))
 		return
@@ -241,7 +385,12 @@ func (s *Server) TestMultipleKeys(c *gin.Context) {
 		return
 	}
 
-	results, err := s.KeyService.TestMultipleKeys(group, req.KeysText)
+	if req.Concurrency < 0 || req.Concurrency > keypool.MaxKeyTestConcurrency {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, fmt.Sprintf("concurrency must be between 1 and %d", keypool.MaxKeyTestConcurrency)))
+		return
+	}
+
+	results, err := s.KeyService.TestMultipleKeys(group, req.KeysText, req.Concurrency)
 	if err != nil {
 		if strings.Contains(err.Error(), "batch size exceeds the limit") {
 			response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, err.Error()))
@@ -253,9 +402,37 @@ func (s *Server) TestMultipleKeys(c *gin.Context) {
 		return
 	}
 
+	if req.SaveResults {
+		// The results are still returned inline, so a failure to save them is not fatal.
+		if _, err := s.KeyTestResultService.Save(group, results); err != nil {
+			logrus.WithError(err).Warnf("Failed to save key test results for group %s", group.Name)
+		}
+	}
+
 	response.Success(c, results)
 }
 
+// GetKeyTestResults returns the last saved key test run of a group, or null if there is none.
+func (s *Server) GetKeyTestResults(c *gin.Context) {
+	groupID, err := validateGroupIDFromQuery(c)
+	if err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrBadRequest, err.Error()))
+		return
+	}
+
+	if _, ok := s.findGroupByID(c, groupID); !ok {
+		return
+	}
+
+	run, err := s.KeyTestResultService.Get(groupID)
+	if err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInternalServer, err.Error()))
+		return
+	}
+
+	response.Success(c, run)
+}
+
 // ValidateGroupKeys initiates a manual validation task for all keys in a group.
 func (s *Server) ValidateGroupKeys(c *gin.Context) {
 	var req GroupIDRequest
@@ -292,7 +469,8 @@ func (s *Server) RestoreAllInvalidKeys(c *gin.Context) {
 		return
 	}
 
-	if _, ok := s.findGroupByID(c, req.GroupID); !ok {
+	group, ok := s.findGroupByID(c, req.GroupID)
+	if !ok {
 		return
 	}
 
@@ -302,6 +480,8 @@ func (s *Server) RestoreAllInvalidKeys(c *gin.Context) {
 		return
 	}
 
+	s.recordKeysAudit(c, models.AuditActionKeysRestoreAllInvalid, group, "", map[string]any{"restored_count": rowsAffected})
+
 	response.Success(c, gin.H{"message": fmt.Sprintf("%d keys restored.", rowsAffected)})
 }
 
@@ -313,7 +493,8 @@ func (s *Server) ClearAllInvalidKeys(c *gin.Context) {
 		return
 	}
 
-	if _, ok := s.findGroupByID(c, req.GroupID); !ok {
+	group, ok := s.findGroupByID(c, req.GroupID)
+	if !ok {
 		return
 	}
 
@@ -323,6 +504,8 @@ func (s *Server) ClearAllInvalidKeys(c *gin.Context) {
 		return
 	}
 
+	s.recordKeysAudit(c, models.AuditActionKeysClearAllInvalid, group, "", map[string]any{"deleted_count": rowsAffected})
+
 	response.Success(c, gin.H{"message": fmt.Sprintf("%d invalid keys cleared.", rowsAffected)})
 }
 
@@ -360,3 +543,83 @@ func (s *Server) ExportKeys(c *gin.Context) {
 		log.Printf("Failed to stream keys: %v", err)
 	}
 }
+
+// KeyDailyStatResponse defines a daily statistics entry of a key.
+type KeyDailyStatResponse struct {
+	models.KeyDailyStat
+	KeyID     uint   `json:"key_id"`
+	MaskedKey string `json:"masked_key"`
+}
+
+// maxKeyDailyStatsDays caps the time range of a daily key stats query.
+const maxKeyDailyStatsDays = 366
+
+// GetKeyDailyStats handles retrieving per-key daily request statistics of a group.
+func (s *Server) GetKeyDailyStats(c *gin.Context) {
+	groupID, err := validateGroupIDFromQuery(c)
+	if err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrBadRequest, err.Error()))
+		return
+	}
+
+	if _, ok := s.findGroupByID(c, groupID); !ok {
+		return
+	}
+
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days < 1 || days > maxKeyDailyStatsDays {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, fmt.Sprintf("days must be between 1 and %d", maxKeyDailyStatsDays)))
+		return
+	}
+
+	since := utils.StartOfDay(time.Now().In(s.SettingsManager.GetDisplayLocation())).AddDate(0, 0, 1-days)
+
+	statsQuery := s.ReadDB.Where("group_id = ? AND day >= ?", groupID, since)
+	if keyIDStr := c.Query("key_id"); keyIDStr != "" {
+		var key models.APIKey
+		if err := s.ReadDB.Where("group_id = ? AND id = ?", groupID, keyIDStr).First(&key).Error; err != nil {
+			response.Error(c, app_errors.ParseDBError(err))
+			return
+		}
+		keyValue, err := s.KeyService.Encryption.Decrypt(key.KeyValue)
+		if err != nil {
+			response.Error(c, app_errors.NewAPIError(app_errors.ErrInternalServer, err.Error()))
+			return
+		}
+		key.KeyValue = keyValue
+		statsQuery = statsQuery.Where("key_hash = ?", utils.HashKey(key.KeyValue))
+	}
+
+	var stats []models.KeyDailyStat
+	if err := statsQuery.Order("day asc").Find(&stats).Error; err != nil {
+		response.Error(c, app_errors.ParseDBError(err))
+		return
+	}
+
+	// Stats are indexed by key hash; map them back to the group's keys for display.
+	var keys []models.APIKey
+	if err := s.ReadDB.Select("id, key_value").Where("group_id = ?", groupID).Find(&keys).Error; err != nil {
+		response.Error(c, app_errors.ParseDBError(err))
+		return
+	}
+	if err := s.KeyService.DecryptKeys(keys); err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInternalServer, err.Error()))
+		return
+	}
+	keysByHash := make(map[string]models.APIKey, len(keys))
+	for _, key := range keys {
+		keysByHash[utils.HashKey(key.KeyValue)] = key
+	}
+
+	result := make([]KeyDailyStatResponse, 0, len(stats))
+	for _, stat := range stats {
+		key := keysByHash[stat.KeyHash]
+		result = append(result, KeyDailyStatResponse{
+			KeyDailyStat: stat,
+			KeyID:        key.ID,
+			MaskedKey:    utils.MaskAPIKey(key.KeyValue),
+		})
+	}
+
+	response.Success(c, result)
+}
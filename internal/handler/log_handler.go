@@ -22,7 +22,7 @@ func (s *Server) GetLogs(c *gin.Context) {
 
 	var logs []models.RequestLog
 	query = query.Order("timestamp desc")
-	pagination, err := response.Paginate(c, query, &logs)
+	pagination, err := response.Paginate(c, query, &logs, s.pageSizeLimits())
 	if err != nil {
 		response.Error(c, app_errors.ParseDBError(err))
 		return
@@ -32,6 +32,50 @@ func (s *Server) GetLogs(c *gin.Context) {
 	response.Success(c, pagination)
 }
 
+// GetRequestBodyLogs handles fetching the request and response bodies captured for groups with
+// log_bodies enabled, with filtering and pagination.
+func (s *Server) GetRequestBodyLogs(c *gin.Context) {
+	query := s.RequestBodyLogService.GetRequestBodyLogsQuery(c)
+
+	var logs []models.RequestBodyLog
+	query = query.Order("timestamp desc, id desc")
+	pagination, err := response.Paginate(c, query, &logs, s.pageSizeLimits())
+	if err != nil {
+		response.Error(c, app_errors.ParseDBError(err))
+		return
+	}
+
+	pagination.Items = logs
+	response.Success(c, pagination)
+}
+
+// GetShadowComparisons handles fetching the divergences found between primary and shadow responses
+// of groups with shadow_compare enabled, with filtering and pagination.
+func (s *Server) GetShadowComparisons(c *gin.Context) {
+	query := s.ShadowComparisonService.GetShadowComparisonsQuery(c)
+
+	var comparisons []models.ShadowComparison
+	query = query.Order("timestamp desc, id desc")
+	pagination, err := response.Paginate(c, query, &comparisons, s.pageSizeLimits())
+	if err != nil {
+		response.Error(c, app_errors.ParseDBError(err))
+		return
+	}
+
+	pagination.Items = comparisons
+	response.Success(c, pagination)
+}
+
+// GetLogFlushStats returns the request log backlog and how the last write-behind flush went.
+func (s *Server) GetLogFlushStats(c *gin.Context) {
+	stats, err := s.RequestLogService.FlushStats()
+	if err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInternalServer, err.Error()))
+		return
+	}
+	response.Success(c, stats)
+}
+
 // ExportLogs handles exporting filtered log keys to a CSV file.
 func (s *Server) ExportLogs(c *gin.Context) {
 	filename := fmt.Sprintf("log_keys_export_%s.csv", time.Now().Format("20060102150405"))
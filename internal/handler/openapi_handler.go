@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"gpt-load/internal/httpclient"
+	"gpt-load/internal/keypool"
+	"gpt-load/internal/models"
+	"gpt-load/internal/openapi"
+	"gpt-load/internal/services"
+	"gpt-load/internal/version"
+
+	"github.com/gin-gonic/gin"
+)
+
+// timeRangeParams are the start_time and end_time filters of the log queries.
+var timeRangeParams = []openapi.Param{
+	{Name: "start_time", Description: "RFC3339 time, inclusive"},
+	{Name: "end_time", Description: "RFC3339 time, inclusive"},
+}
+
+// requestLogParams are the filters of the request log list and export.
+var requestLogParams = append([]openapi.Param{
+	{Name: "group_name"}, {Name: "key_value"}, {Name: "is_success", Type: "boolean"}, {Name: "is_client_cancelled", Type: "boolean"},
+	{Name: "is_shadow", Type: "boolean"}, {Name: "status_code", Type: "integer"}, {Name: "source_ip"}, {Name: "error_contains"},
+}, timeRangeParams...)
+
+// apiOperations documents the admin API routes, keyed by "METHOD /path" as registered in the router.
+// Routes missing here are still listed in the OpenAPI document, without schemas.
+var apiOperations = map[string]openapi.Operation{
+	"POST /api/auth/login": {Summary: "Check an auth key", Tag: "Auth", Public: true, Request: LoginRequest{}, Response: LoginResponse{}, Raw: true},
+	"GET /api/version":     {Summary: "Get the build version", Tag: "System", Public: true, Response: version.Info{}},
+	"GET /api/openapi.json": {
+		Summary: "Get this OpenAPI document", Tag: "System", Raw: true,
+	},
+	"GET /api/channel-types": {Summary: "List channel types", Tag: "System", Response: []string{}},
+
+	"POST /api/groups": {
+		Summary: "Create a group", Tag: "Groups",
+		Query:   []openapi.Param{{Name: "validate_on_save", Description: "Validate the group with a live request: reject or warn when it fails"}},
+		Request: GroupCreateRequest{}, Response: GroupResponse{},
+	},
+	"GET /api/groups":                {Summary: "List groups", Tag: "Groups", Response: []GroupResponse{}},
+	"GET /api/groups/list":           {Summary: "List group IDs and names", Tag: "Groups", Response: []models.Group{}},
+	"GET /api/groups/config-options": {Summary: "List group config options with their system defaults", Tag: "Groups", Response: []ConfigOption{}},
+	"PUT /api/groups/:id": {
+		Summary: "Update a group", Tag: "Groups",
+		Query:   []openapi.Param{{Name: "validate_on_save", Description: "Validate the group with a live request: reject or warn when it fails"}},
+		Request: GroupUpdateRequest{}, Response: GroupResponse{},
+	},
+	"DELETE /api/groups/:id":        {Summary: "Delete a group and its keys", Tag: "Groups"},
+	"GET /api/groups/:id/stats":     {Summary: "Get key and request stats of a group", Tag: "Groups", Response: GroupStatsResponse{}},
+	"PUT /api/groups/:id/draining":  {Summary: "Start or stop draining a group", Tag: "Groups", Request: SetGroupDrainingRequest{}, Response: GroupResponse{}},
+	"GET /api/keys":                 {Summary: "List the keys of a group", Tag: "Keys", Query: []openapi.Param{{Name: "group_id", Required: true}, {Name: "status", Description: "active or invalid"}, {Name: "key", Description: "Key search"}}, Response: models.APIKey{}, Paginated: true},
+	"GET /api/keys/export":          {Summary: "Export the keys of a group", Tag: "Keys", Query: []openapi.Param{{Name: "group_id", Required: true}, {Name: "status", Description: "all, active or invalid"}}, ContentType: "text/plain"},
+	"GET /api/keys/daily-stats":     {Summary: "Get daily request counts of keys", Tag: "Keys", Query: []openapi.Param{{Name: "group_id", Required: true}, {Name: "key_id"}, {Name: "days", Type: "integer"}}, Response: []KeyDailyStatResponse{}},
+	"POST /api/keys/add-multiple":   {Summary: "Add keys", Tag: "Keys", Request: KeyTextRequest{}, Response: services.AddKeysResult{}},
+	"POST /api/keys/add-async":      {Summary: "Add keys in a background task", Tag: "Keys", Request: KeyTextRequest{}, Response: services.TaskStatus{}},
+	"POST /api/keys/import-preview": {Summary: "Preview a key import", Tag: "Keys", Request: KeyTextRequest{}, Response: services.ImportPreviewResult{}},
+	"POST /api/keys/replace-all":    {Summary: "Replace all keys of a group", Tag: "Keys", Request: KeyTextRequest{}, Response: services.ReplaceKeysResult{}},
+	"POST /api/keys/delete-multiple": {
+		Summary: "Delete keys", Tag: "Keys", Request: KeyTextRequest{}, Response: services.DeleteKeysResult{},
+	},
+	"POST /api/keys/restore-multiple": {
+		Summary: "Restore invalid keys", Tag: "Keys", Request: KeyTextRequest{}, Response: services.RestoreKeysResult{},
+	},
+	"POST /api/keys/set-status":          {Summary: "Set the status of keys", Tag: "Keys", Request: SetKeysStatusRequest{}, Response: services.SetKeysStatusResult{}},
+	"POST /api/keys/restore-all-invalid": {Summary: "Restore all invalid keys of a group", Tag: "Keys", Request: GroupIDRequest{}},
+	"POST /api/keys/clear-all-invalid":   {Summary: "Delete all invalid keys of a group", Tag: "Keys", Request: GroupIDRequest{}},
+	"POST /api/keys/validate-group":      {Summary: "Validate the keys of a group in a background task", Tag: "Keys", Request: GroupIDRequest{}, Response: services.TaskStatus{}},
+	"POST /api/keys/test-multiple":       {Summary: "Test keys", Tag: "Keys", Request: TestKeysRequest{}, Response: []keypool.KeyTestResult{}},
+	"GET /api/keys/test-results":         {Summary: "Get the last saved key test run of a group", Tag: "Keys", Query: []openapi.Param{{Name: "group_id", Required: true}}, Response: services.KeyTestRun{}},
+
+	"GET /api/tasks":        {Summary: "List background tasks", Tag: "Tasks", Query: []openapi.Param{{Name: "group_name"}}, Response: []services.TaskStatus{}},
+	"GET /api/tasks/status": {Summary: "Get the latest background task", Tag: "Tasks", Query: []openapi.Param{{Name: "group_name"}}, Response: services.TaskStatus{}},
+	"GET /api/tasks/stream": {Summary: "Stream the status of a task", Tag: "Tasks", Query: []openapi.Param{{Name: "id"}, {Name: "group_name"}}, ContentType: "text/event-stream"},
+	"POST /api/tasks/cancel": {
+		Summary: "Cancel a task", Tag: "Tasks", Request: CancelTaskRequest{},
+	},
+
+	"GET /api/dashboard/stats": {Summary: "Get dashboard stats of the last 24 hours", Tag: "Dashboard", Response: models.DashboardStatsResponse{}},
+	"GET /api/dashboard/chart": {
+		Summary: "Get request counts over time", Tag: "Dashboard",
+		Query:    []openapi.Param{{Name: "groupId", Type: "integer"}, {Name: "granularity", Description: "hour or day"}, {Name: "from", Description: "RFC3339 time"}, {Name: "to", Description: "RFC3339 time"}},
+		Response: models.ChartData{},
+	},
+
+	"GET /api/logs": {
+		Summary: "List request logs", Tag: "Logs", Query: requestLogParams,
+		Response: models.RequestLog{}, Paginated: true,
+	},
+	"GET /api/logs/export": {Summary: "Export the keys of request logs as CSV", Tag: "Logs", Query: requestLogParams, ContentType: "text/csv"},
+	"GET /api/logs/bodies": {
+		Summary: "List logged request and response bodies", Tag: "Logs",
+		Query:    append([]openapi.Param{{Name: "group_id"}, {Name: "request_log_id", Type: "string"}, {Name: "status_code", Type: "integer"}}, timeRangeParams...),
+		Response: models.RequestBodyLog{}, Paginated: true,
+	},
+	"GET /api/logs/shadow-comparisons": {
+		Summary: "List diverging shadow responses", Tag: "Logs",
+		Query:    append([]openapi.Param{{Name: "group_id"}, {Name: "shadow_group_id"}, {Name: "primary_status", Type: "integer"}, {Name: "shadow_status", Type: "integer"}}, timeRangeParams...),
+		Response: models.ShadowComparison{}, Paginated: true,
+	},
+	"GET /api/logs/flush-stats": {Summary: "Get the request log backlog and last flush", Tag: "Logs", Response: services.LogFlushStats{}},
+
+	"GET /api/proxy-keys/usage": {Summary: "Get proxy key quota usage", Tag: "Proxy Keys", Response: []services.ProxyKeyUsage{}},
+	"GET /api/usage/report": {
+		Summary: "Get a usage report", Tag: "Usage",
+		Query:    []openapi.Param{{Name: "from", Description: "RFC3339 time"}, {Name: "to", Description: "RFC3339 time"}, {Name: "group_by", Description: "group, proxy_key or model"}, {Name: "format", Description: "json or csv"}},
+		Response: UsageReportResponse{},
+	},
+	"GET /api/cluster/nodes":            {Summary: "List cluster nodes", Tag: "Cluster", Response: []services.ClusterNode{}},
+	"GET /api/cluster/connection-pools": {Summary: "Get upstream connection pool stats of this node", Tag: "Cluster", Response: []httpclient.PoolStats{}},
+	"GET /api/audit-logs": {
+		Summary: "List audit logs", Tag: "Audit",
+		Query:    append([]openapi.Param{{Name: "actor"}, {Name: "action"}, {Name: "target_type"}, {Name: "target_id"}, {Name: "target_name"}}, timeRangeParams...),
+		Response: models.AuditLog{}, Paginated: true,
+	},
+
+	"GET /api/settings":        {Summary: "Get system settings by category", Tag: "Settings", Response: []models.CategorizedSettings{}},
+	"PUT /api/settings":        {Summary: "Update system settings", Tag: "Settings", Request: map[string]any{}},
+	"GET /api/settings/export": {Summary: "Export system settings", Tag: "Settings", Response: SettingsExport{}, Raw: true},
+	"POST /api/settings/import": {
+		Summary: "Import system settings", Tag: "Settings",
+		Query:   []openapi.Param{{Name: "include_environment", Type: "boolean", Description: "Also import environment-specific settings"}},
+		Request: SettingsExport{},
+	},
+}
+
+// GetOpenAPISpec serves the OpenAPI document of the /api routes. It is built on first use, once all
+// routes are registered.
+func (s *Server) GetOpenAPISpec(routes func() gin.RoutesInfo) gin.HandlerFunc {
+	var once sync.Once
+	var document openapi.Document
+	return func(c *gin.Context) {
+		once.Do(func() {
+			var apiRoutes []openapi.Route
+			for _, route := range routes() {
+				if strings.HasPrefix(route.Path, "/api/") {
+					apiRoutes = append(apiRoutes, openapi.Route{Method: route.Method, Path: route.Path})
+				}
+			}
+			document = openapi.Build(openapi.Info{
+				Title:       "GPT-Load Admin API",
+				Version:     version.Get().Version,
+				Description: "Admin API of GPT-Load. Authenticate with the AUTH_KEY as a bearer token.",
+			}, apiRoutes, apiOperations)
+		})
+		c.JSON(http.StatusOK, document)
+	}
+}
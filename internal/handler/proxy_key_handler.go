@@ -0,0 +1,18 @@
+package handler
+
+import (
+	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetProxyKeyUsage returns each proxy key's usage for the current day and month against its quotas.
+func (s *Server) GetProxyKeyUsage(c *gin.Context) {
+	usage, err := s.ProxyKeyQuotaService.ListUsage()
+	if err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInternalServer, err.Error()))
+		return
+	}
+	response.Success(c, usage)
+}
@@ -1,10 +1,15 @@
 package handler
 
 import (
+	"fmt"
 	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/httpclient"
 	"gpt-load/internal/models"
 	"gpt-load/internal/response"
 	"gpt-load/internal/utils"
+	"gpt-load/internal/version"
+	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -16,6 +21,7 @@ import (
 func (s *Server) GetSettings(c *gin.Context) {
 	currentSettings := s.SettingsManager.GetSettings()
 	settingsInfo := utils.GenerateSettingsMetadata(&currentSettings)
+	maskSettingsInfo(settingsInfo)
 
 	// Group settings by category while preserving order
 	categorized := make(map[string][]models.SystemSettingInfo)
@@ -52,23 +58,141 @@ func (s *Server) UpdateSettings(c *gin.Context) {
 		return
 	}
 
-	// Sanitize proxy_keys input
+	sanitizeSettings(settingsMap)
+	s.restoreMaskedSettings(settingsMap)
+
+	before := toAuditMap(s.SettingsManager.GetSettings())
+
+	// 更新配置
+	restartRequired, err := s.SettingsManager.UpdateSettings(settingsMap)
+	if err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrDatabase, err.Error()))
+		return
+	}
+
+	s.recordChangeAudit(c, models.AuditLog{
+		Action:     models.AuditActionSettingsUpdate,
+		TargetType: models.AuditTargetSettings,
+	}, before, settingsMap, settingsAuditValues)
+
+	time.Sleep(100 * time.Millisecond) // 等待异步更新配置
+
+	message := "Settings updated successfully. Configuration will be reloaded in the background across all instances."
+	if len(restartRequired) > 0 {
+		message = fmt.Sprintf("Settings updated successfully. %s will take effect after a restart.", strings.Join(restartRequired, ", "))
+	}
+	response.Success(c, gin.H{
+		"message":          message,
+		"restart_required": restartRequired,
+	})
+}
+
+// sanitizeSettings cleans up settings input before it is validated and saved.
+func sanitizeSettings(settingsMap map[string]any) {
 	if proxyKeys, ok := settingsMap["proxy_keys"]; ok {
 		if proxyKeysStr, ok := proxyKeys.(string); ok {
 			cleanedKeys := utils.SplitAndTrim(proxyKeysStr, ",")
 			settingsMap["proxy_keys"] = strings.Join(cleanedKeys, ",")
 		}
 	}
+}
 
-	// 更新配置
-	if err := s.SettingsManager.UpdateSettings(settingsMap); err != nil {
-		response.Error(c, app_errors.NewAPIError(app_errors.ErrDatabase, err.Error()))
+// maskSettingsInfo masks the credentials of upstream_proxy_url in settings returned by the API.
+func maskSettingsInfo(settingsInfo []models.SystemSettingInfo) {
+	for i := range settingsInfo {
+		if proxyURL, ok := settingsInfo[i].Value.(string); ok && settingsInfo[i].Key == "upstream_proxy_url" {
+			settingsInfo[i].Value = httpclient.MaskProxyURL(proxyURL)
+		}
+	}
+}
+
+// restoreMaskedSettings keeps the stored upstream_proxy_url when the client sends back its masked form.
+func (s *Server) restoreMaskedSettings(settingsMap map[string]any) {
+	proxyURL, ok := settingsMap["upstream_proxy_url"].(string)
+	if !ok {
 		return
 	}
+	if stored := s.SettingsManager.GetSettings().UpstreamProxyURL; httpclient.IsMaskedProxyURL(proxyURL, stored) {
+		settingsMap["upstream_proxy_url"] = stored
+	}
+}
 
-	time.Sleep(100 * time.Millisecond) // 等待异步更新配置
+// environmentSpecificSettings usually differ between deployments. They are flagged in exports
+// and skipped on import unless include_environment=true is given.
+var environmentSpecificSettings = []string{"app_url", "upstream_proxy_url", "upstream_ip_pins", "task_webhook_url"}
+
+// SettingsExport is the document produced by ExportSettings and accepted by ImportSettings.
+type SettingsExport struct {
+	Version             string         `json:"version"`
+	ExportedAt          time.Time      `json:"exported_at"`
+	EnvironmentSpecific []string       `json:"environment_specific"`
+	Settings            map[string]any `json:"settings" binding:"required"`
+}
+
+// ExportSettings handles the GET /api/settings/export request.
+// It downloads all system settings as a JSON document that ImportSettings accepts.
+func (s *Server) ExportSettings(c *gin.Context) {
+	currentSettings := s.SettingsManager.GetSettings()
+	settingsInfo := utils.GenerateSettingsMetadata(&currentSettings)
+	maskSettingsInfo(settingsInfo)
+	settings := make(map[string]any)
+	for _, info := range settingsInfo {
+		settings[info.Key] = info.Value
+	}
+
+	filename := fmt.Sprintf("settings_export_%s.json", time.Now().Format("20060102150405"))
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.JSON(http.StatusOK, SettingsExport{
+		Version:             version.Get().Version,
+		ExportedAt:          time.Now(),
+		EnvironmentSpecific: environmentSpecificSettings,
+		Settings:            settings,
+	})
+}
+
+// ImportSettings handles the POST /api/settings/import request.
+// Environment-specific settings are skipped unless the include_environment query parameter is true.
+func (s *Server) ImportSettings(c *gin.Context) {
+	var req SettingsExport
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInvalidJSON, err.Error()))
+		return
+	}
+
+	includeEnvironment, _ := strconv.ParseBool(c.Query("include_environment"))
+	skipped := []string{}
+	if !includeEnvironment {
+		for _, key := range environmentSpecificSettings {
+			if _, ok := req.Settings[key]; ok {
+				delete(req.Settings, key)
+				skipped = append(skipped, key)
+			}
+		}
+	}
+
+	restartRequired := []string{}
+	if len(req.Settings) > 0 {
+		sanitizeSettings(req.Settings)
+		s.restoreMaskedSettings(req.Settings)
+
+		before := toAuditMap(s.SettingsManager.GetSettings())
+		var err error
+		if restartRequired, err = s.SettingsManager.UpdateSettings(req.Settings); err != nil {
+			response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, err.Error()))
+			return
+		}
+
+		s.recordChangeAudit(c, models.AuditLog{
+			Action:     models.AuditActionSettingsImport,
+			TargetType: models.AuditTargetSettings,
+		}, before, req.Settings, settingsAuditValues)
+
+		time.Sleep(100 * time.Millisecond) // 等待异步更新配置
+	}
 
 	response.Success(c, gin.H{
-		"message": "Settings updated successfully. Configuration will be reloaded in the background across all instances.",
+		"imported_count":   len(req.Settings),
+		"skipped":          skipped,
+		"restart_required": restartRequired,
 	})
 }
@@ -1,18 +1,140 @@
 package handler
 
 import (
+	"encoding/json"
+	"fmt"
 	app_errors "gpt-load/internal/errors"
 	"gpt-load/internal/response"
+	"gpt-load/internal/services"
+	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
 )
 
-// GetTaskStatus handles requests for the status of the global long-running task.
+// taskStreamInterval is how often the task stream polls the store for progress changes.
+const taskStreamInterval = 500 * time.Millisecond
+
+// GetTaskStatus handles requests for the status of the most relevant long-running task,
+// optionally filtered by group name.
 func (s *Server) GetTaskStatus(c *gin.Context) {
-	taskStatus, err := s.TaskService.GetTaskStatus()
+	taskStatus, err := s.TaskService.GetTaskStatus(c.Query("group_name"))
 	if err != nil {
 		response.Error(c, app_errors.NewAPIError(app_errors.ErrInternalServer, "Failed to get task status"))
 		return
 	}
 	response.Success(c, taskStatus)
 }
+
+// ListTasks handles requests for all known tasks, optionally filtered by group name.
+func (s *Server) ListTasks(c *gin.Context) {
+	tasks, err := s.TaskService.ListTasks(c.Query("group_name"))
+	if err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInternalServer, "Failed to list tasks"))
+		return
+	}
+	response.Success(c, tasks)
+}
+
+// CancelTaskRequest defines the payload for cancelling a task.
+type CancelTaskRequest struct {
+	ID string `json:"id" binding:"required"`
+}
+
+// CancelTask handles requests to cancel a running task.
+func (s *Server) CancelTask(c *gin.Context) {
+	var req CancelTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInvalidJSON, err.Error()))
+		return
+	}
+
+	if err := s.TaskService.CancelTask(req.ID); err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrBadRequest, err.Error()))
+		return
+	}
+	response.Success(c, nil)
+}
+
+// StreamTask pushes progress events of a task over Server-Sent Events until the task finishes.
+// The task is selected by id, or by group_name when no id is given.
+func (s *Server) StreamTask(c *gin.Context) {
+	taskID := c.Query("id")
+	groupName := c.Query("group_name")
+
+	getStatus := func() (*services.TaskStatus, error) {
+		if taskID != "" {
+			return s.TaskService.GetTask(taskID)
+		}
+		return s.TaskService.GetTaskStatus(groupName)
+	}
+
+	status, err := getStatus()
+	if err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInternalServer, "Failed to get task status"))
+		return
+	}
+
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrInternalServer, "Streaming unsupported"))
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	var lastPayload []byte
+	send := func(status *services.TaskStatus) bool {
+		payload, err := json.Marshal(status)
+		if err != nil {
+			logrus.Errorf("Failed to serialize task status: %v", err)
+			return false
+		}
+		if string(payload) == string(lastPayload) {
+			return true
+		}
+		lastPayload = payload
+
+		event := "progress"
+		if !status.IsRunning {
+			event = "done"
+		}
+		if _, err := fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+			return false
+		}
+		flusher.Flush()
+		return true
+	}
+
+	if !send(status) || !status.IsRunning {
+		return
+	}
+	// Lock onto the task found first, so a task started later for the group doesn't take over the stream.
+	if taskID == "" {
+		taskID = status.ID
+	}
+
+	ticker := time.NewTicker(taskStreamInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-ticker.C:
+			status, err := getStatus()
+			if err != nil {
+				logrus.Warnf("Failed to get task status for stream: %v", err)
+				continue
+			}
+			if !send(status) || !status.IsRunning {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"time"
+
+	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/response"
+	"gpt-load/internal/services"
+	"gpt-load/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// UsageReportResponse is the JSON form of a usage report.
+type UsageReportResponse struct {
+	From    time.Time                 `json:"from"`
+	To      time.Time                 `json:"to"`
+	GroupBy string                    `json:"group_by"`
+	Items   []services.UsageReportRow `json:"items"`
+}
+
+// GetUsageReport returns request counts, token usage and estimated cost aggregated by group, proxy key or model.
+// 支持 from、to（RFC3339）、group_by（group/proxy_key/model）和 format（json/csv）参数，默认统计本月至今按分组的用量。
+func (s *Server) GetUsageReport(c *gin.Context) {
+	now := time.Now().In(s.SettingsManager.GetDisplayLocation())
+	from := utils.StartOfDay(now).AddDate(0, 0, 1-now.Day())
+	to := now
+
+	if value := c.Query("from"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, "invalid 'from' time, expected RFC3339"))
+			return
+		}
+		from = parsed
+	}
+	if value := c.Query("to"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, "invalid 'to' time, expected RFC3339"))
+			return
+		}
+		to = parsed
+	}
+	if !from.Before(to) {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, "'from' must be earlier than 'to'"))
+		return
+	}
+
+	groupBy := c.DefaultQuery("group_by", services.UsageGroupByGroup)
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, "format must be 'json' or 'csv'"))
+		return
+	}
+
+	switch groupBy {
+	case services.UsageGroupByGroup, services.UsageGroupByProxyKey, services.UsageGroupByModel:
+	default:
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrValidation, "group_by must be 'group', 'proxy_key' or 'model'"))
+		return
+	}
+
+	rows, err := s.UsageReportService.Report(from, to, groupBy)
+	if err != nil {
+		response.Error(c, app_errors.NewAPIError(app_errors.ErrDatabase, err.Error()))
+		return
+	}
+
+	if format == "json" {
+		response.Success(c, UsageReportResponse{From: from, To: to, GroupBy: groupBy, Items: rows})
+		return
+	}
+
+	filename := fmt.Sprintf("usage_report_%s_%s_%s.csv", groupBy, from.Format("20060102"), to.Format("20060102"))
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+
+	writer := csv.NewWriter(c.Writer)
+	records := [][]string{{groupBy, "total_requests", "success_requests", "failed_requests", "prompt_tokens", "completion_tokens", "cost"}}
+	for _, row := range rows {
+		cost := ""
+		if row.Cost != nil {
+			cost = strconv.FormatFloat(*row.Cost, 'f', 6, 64)
+		}
+		records = append(records, []string{
+			row.Name,
+			strconv.FormatInt(row.TotalRequests, 10),
+			strconv.FormatInt(row.SuccessRequests, 10),
+			strconv.FormatInt(row.FailedRequests, 10),
+			strconv.FormatInt(row.PromptTokens, 10),
+			strconv.FormatInt(row.CompletionTokens, 10),
+			cost,
+		})
+	}
+	if err := writer.WriteAll(records); err != nil {
+		logrus.Errorf("Failed to write usage report CSV: %v", err)
+	}
+}
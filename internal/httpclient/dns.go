@@ -0,0 +1,130 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ParseIPPins parses a "host=ip,host2=ip2" string into a host to IP mapping.
+func ParseIPPins(value string) (map[string]string, error) {
+	pins := make(map[string]string)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		host, ip, found := strings.Cut(item, "=")
+		host = strings.ToLower(strings.TrimSpace(host))
+		ip = strings.TrimSpace(ip)
+		if !found || host == "" {
+			return nil, fmt.Errorf("invalid ip pin '%s': expected host=ip", item)
+		}
+		if net.ParseIP(ip) == nil {
+			return nil, fmt.Errorf("invalid ip pin '%s': '%s' is not a valid IP address", item, ip)
+		}
+		pins[host] = ip
+	}
+	return pins, nil
+}
+
+type dnsCacheEntry struct {
+	ips       []string
+	expiresAt time.Time
+}
+
+// cachingDialer resolves upstream hosts through pinned addresses and a TTL cache before dialing.
+// Only the TCP dial target changes; TLS SNI and HTTP/2 connection reuse still key on the request host.
+type cachingDialer struct {
+	dialer *net.Dialer
+	ttl    time.Duration
+	pins   map[string]string
+
+	mu    sync.RWMutex
+	cache map[string]dnsCacheEntry
+}
+
+func newCachingDialer(dialer *net.Dialer, ttl time.Duration, pins map[string]string) *cachingDialer {
+	return &cachingDialer{
+		dialer: dialer,
+		ttl:    ttl,
+		pins:   pins,
+		cache:  make(map[string]dnsCacheEntry),
+	}
+}
+
+// DialContext dials the first reachable resolved address for the host.
+func (d *cachingDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || net.ParseIP(host) != nil {
+		return d.dialer.DialContext(ctx, network, addr)
+	}
+
+	ips, err := d.resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, ip := range ips {
+		conn, dialErr := d.dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+		if dialErr == nil {
+			return conn, nil
+		}
+		lastErr = dialErr
+	}
+
+	// Every cached address failed, so drop the entry to pick up fresh records on the next dial.
+	d.mu.Lock()
+	delete(d.cache, host)
+	d.mu.Unlock()
+
+	return nil, lastErr
+}
+
+func (d *cachingDialer) resolve(ctx context.Context, host string) ([]string, error) {
+	if ip, ok := d.pins[strings.ToLower(host)]; ok {
+		return []string{ip}, nil
+	}
+
+	if d.ttl <= 0 {
+		return d.lookup(ctx, host)
+	}
+
+	d.mu.RLock()
+	entry, ok := d.cache[host]
+	d.mu.RUnlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.ips, nil
+	}
+
+	ips, err := d.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	d.cache[host] = dnsCacheEntry{ips: ips, expiresAt: time.Now().Add(d.ttl)}
+	d.mu.Unlock()
+
+	return ips, nil
+}
+
+func (d *cachingDialer) lookup(ctx context.Context, host string) ([]string, error) {
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no addresses found for host %s", host)
+	}
+
+	ips := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		ips = append(ips, addr.IP.String())
+	}
+	return ips, nil
+}
@@ -1,11 +1,17 @@
 package httpclient
 
 import (
+	"crypto/sha256"
 	"fmt"
+	"maps"
 	"net"
 	"net/http"
+	"slices"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/sirupsen/logrus"
 )
 
 // Config defines the parameters for creating an HTTP client.
@@ -23,35 +29,64 @@ type Config struct {
 	ForceAttemptHTTP2     bool
 	TLSHandshakeTimeout   time.Duration
 	ExpectContinueTimeout time.Duration
+	// DNSCacheTTL caches resolved upstream addresses; zero disables caching.
+	DNSCacheTTL time.Duration
+	// IPPins maps upstream hosts to fixed IP addresses, bypassing DNS.
+	IPPins map[string]string
+	// ProxyURL routes outbound connections through an http, https or socks5 proxy.
+	// Empty means the proxy from the environment is used.
+	ProxyURL string
+	// TLS configures client certificates and a custom CA bundle for mutual TLS upstreams.
+	TLS TLSOptions
+	// FollowRedirects lets the client follow upstream redirects. When false, 3xx responses are
+	// returned as they are.
+	FollowRedirects bool
+	// PoolKey isolates the connection pool: clients with different keys never share a transport
+	// even when the rest of the configuration is identical. Empty means the shared pool.
+	PoolKey string
+	// Label names the user of the client in the pool stats. It is not part of the fingerprint.
+	Label string
+}
+
+// pool is a cached client with the counters and users of its transport.
+type pool struct {
+	client              *http.Client
+	counters            *poolCounters
+	labels              map[string]struct{}
+	poolKey             string
+	maxIdleConns        int
+	maxIdleConnsPerHost int
 }
 
 // HTTPClientManager manages the lifecycle of HTTP clients.
 // It creates and caches clients based on their configuration fingerprint,
 // ensuring that clients with the same configuration are reused.
 type HTTPClientManager struct {
-	clients map[string]*http.Client
+	clients map[string]*pool
 	lock    sync.RWMutex
 }
 
 // NewHTTPClientManager creates a new client manager.
 func NewHTTPClientManager() *HTTPClientManager {
 	return &HTTPClientManager{
-		clients: make(map[string]*http.Client),
+		clients: make(map[string]*pool),
 	}
 }
 
 // GetClient returns an HTTP client that matches the given configuration.
 // If a matching client already exists in the cache, it is returned.
-// Otherwise, a new client is created, cached, and returned.
-func (m *HTTPClientManager) GetClient(config *Config) *http.Client {
+// Otherwise, a new client is created, cached, and returned. Invalid TLS options are an error, so a
+// client never falls back to the default TLS settings.
+func (m *HTTPClientManager) GetClient(config *Config) (*http.Client, error) {
 	fingerprint := config.getFingerprint()
 
 	// Fast path with read lock
 	m.lock.RLock()
-	client, exists := m.clients[fingerprint]
+	p, exists := m.clients[fingerprint]
+	labeled := exists && hasLabel(p, config.Label)
 	m.lock.RUnlock()
-	if exists {
-		return client
+	if labeled {
+		return p.client, nil
 	}
 
 	// Slow path with write lock
@@ -59,21 +94,48 @@ func (m *HTTPClientManager) GetClient(config *Config) *http.Client {
 	defer m.lock.Unlock()
 
 	// Double-check in case another goroutine created the client while we were waiting for the lock.
-	if client, exists = m.clients[fingerprint]; exists {
-		return client
+	if p, exists = m.clients[fingerprint]; exists {
+		if config.Label != "" {
+			p.labels[config.Label] = struct{}{}
+		}
+		return p.client, nil
+	}
+
+	tlsConfig, err := BuildTLSConfig(config.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upstream TLS options: %w", err)
+	}
+
+	counters := &poolCounters{}
+
+	dialer := &net.Dialer{
+		Timeout:   config.ConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	dialContext := dialer.DialContext
+	if config.DNSCacheTTL > 0 || len(config.IPPins) > 0 {
+		dialContext = newCachingDialer(dialer, config.DNSCacheTTL, config.IPPins).DialContext
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if proxyURL, err := ParseProxyURL(config.ProxyURL); err != nil {
+		logrus.Warnf("Ignoring invalid outbound proxy: %v", err)
+	} else if proxyURL != nil {
+		proxy = http.ProxyURL(proxyURL)
+		if len(config.IPPins) > 0 {
+			logrus.Warnf("Upstream IP pins are ignored for %s: connections go through the outbound proxy %s, which resolves upstream hosts itself", config.Label, MaskProxyURL(config.ProxyURL))
+		}
 	}
 
 	// Create a new transport and client with the specified configuration.
 	transport := &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
-		DialContext: (&net.Dialer{
-			Timeout:   config.ConnectTimeout,
-			KeepAlive: 30 * time.Second,
-		}).DialContext,
+		Proxy:                 proxy,
+		DialContext:           counters.wrapDial(dialContext),
 		ForceAttemptHTTP2:     config.ForceAttemptHTTP2,
 		MaxIdleConns:          config.MaxIdleConns,
 		MaxIdleConnsPerHost:   config.MaxIdleConnsPerHost,
 		IdleConnTimeout:       config.IdleConnTimeout,
+		TLSClientConfig:       tlsConfig,
 		TLSHandshakeTimeout:   config.TLSHandshakeTimeout,
 		ExpectContinueTimeout: config.ExpectContinueTimeout,
 		ResponseHeaderTimeout: config.ResponseHeaderTimeout,
@@ -83,18 +145,63 @@ func (m *HTTPClientManager) GetClient(config *Config) *http.Client {
 	}
 
 	newClient := &http.Client{
-		Transport: transport,
+		Transport: &countedTransport{base: transport, counters: counters},
 		Timeout:   config.RequestTimeout,
 	}
+	if !config.FollowRedirects {
+		newClient.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+
+	p = &pool{
+		client:              newClient,
+		counters:            counters,
+		labels:              make(map[string]struct{}),
+		poolKey:             config.PoolKey,
+		maxIdleConns:        config.MaxIdleConns,
+		maxIdleConnsPerHost: config.MaxIdleConnsPerHost,
+	}
+	if config.Label != "" {
+		p.labels[config.Label] = struct{}{}
+	}
+	m.clients[fingerprint] = p
+	return newClient, nil
+}
 
-	m.clients[fingerprint] = newClient
-	return newClient
+// hasLabel reports whether label is already recorded for p. An empty label needs no recording.
+func hasLabel(p *pool, label string) bool {
+	if label == "" {
+		return true
+	}
+	_, ok := p.labels[label]
+	return ok
+}
+
+// Stats returns a snapshot of every cached client's connection pool, ordered by label.
+func (m *HTTPClientManager) Stats() []PoolStats {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	stats := make([]PoolStats, 0, len(m.clients))
+	for _, p := range m.clients {
+		s := p.counters.snapshot()
+		s.Labels = slices.Sorted(maps.Keys(p.labels))
+		s.PoolKey = p.poolKey
+		s.MaxIdleConns = p.maxIdleConns
+		s.MaxIdleConnsPerHost = p.maxIdleConnsPerHost
+		stats = append(stats, s)
+	}
+	slices.SortFunc(stats, func(a, b PoolStats) int {
+		return strings.Compare(strings.Join(a.Labels, ","), strings.Join(b.Labels, ","))
+	})
+	return stats
 }
 
 // getFingerprint generates a unique string representation of the client configuration.
 func (c *Config) getFingerprint() string {
 	return fmt.Sprintf(
-		"ct:%.0fs|rt:%.0fs|it:%.0fs|mic:%d|mich:%d|rht:%.0fs|dc:%t|wbs:%d|rbs:%d|fh2:%t|tlst:%.0fs|ect:%.0fs",
+		"ct:%.0fs|rt:%.0fs|it:%.0fs|mic:%d|mich:%d|rht:%.0fs|dc:%t|wbs:%d|rbs:%d|fh2:%t|tlst:%.0fs|ect:%.0fs|dns:%.0fs|pins:%v|px:%s|tls:%x|isv:%t|fr:%t|pk:%s",
 		c.ConnectTimeout.Seconds(),
 		c.RequestTimeout.Seconds(),
 		c.IdleConnTimeout.Seconds(),
@@ -107,5 +214,12 @@ func (c *Config) getFingerprint() string {
 		c.ForceAttemptHTTP2,
 		c.TLSHandshakeTimeout.Seconds(),
 		c.ExpectContinueTimeout.Seconds(),
+		c.DNSCacheTTL.Seconds(),
+		c.IPPins,
+		c.ProxyURL,
+		sha256.Sum256([]byte(c.TLS.ClientCert+"|"+c.TLS.ClientKey+"|"+c.TLS.CACert)),
+		c.TLS.InsecureSkipVerify,
+		c.FollowRedirects,
+		c.PoolKey,
 	)
 }
@@ -0,0 +1,49 @@
+package httpclient
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestGetClientInsecureSkipVerify(t *testing.T) {
+	tests := []struct {
+		name     string
+		tls      TLSOptions
+		wantNil  bool
+		insecure bool
+	}{
+		{name: "default", tls: TLSOptions{}, wantNil: true},
+		{name: "enabled", tls: TLSOptions{InsecureSkipVerify: true}, insecure: true},
+	}
+	m := NewHTTPClientManager()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := m.GetClient(&Config{TLS: tt.tls, Label: tt.name})
+			if err != nil {
+				t.Fatalf("GetClient() error = %v", err)
+			}
+			transport := client.Transport.(*countedTransport).base.(*http.Transport)
+			if tt.wantNil {
+				if transport.TLSClientConfig != nil {
+					t.Fatalf("TLSClientConfig = %+v, want nil", transport.TLSClientConfig)
+				}
+				return
+			}
+			if transport.TLSClientConfig == nil || transport.TLSClientConfig.InsecureSkipVerify != tt.insecure {
+				t.Fatalf("TLSClientConfig = %+v, want InsecureSkipVerify %v", transport.TLSClientConfig, tt.insecure)
+			}
+		})
+	}
+}
+
+func TestGetClientRejectsInvalidTLSOptions(t *testing.T) {
+	m := NewHTTPClientManager()
+	for _, tls := range []TLSOptions{
+		{CACert: "not a certificate"},
+		{ClientCert: "not a certificate", ClientKey: "not a key"},
+	} {
+		if client, err := m.GetClient(&Config{TLS: tls, Label: "mtls"}); err == nil || client != nil {
+			t.Errorf("GetClient(%+v) = %v, %v, want an error", tls, client, err)
+		}
+	}
+}
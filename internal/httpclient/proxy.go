@@ -0,0 +1,44 @@
+package httpclient
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ParseProxyURL parses and validates an outbound proxy URL. An empty value returns nil.
+func ParseProxyURL(raw string) (*url.URL, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy url: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("invalid proxy url '%s': scheme must be http, https or socks5", MaskProxyURL(raw))
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy url '%s': missing host", MaskProxyURL(raw))
+	}
+	return u, nil
+}
+
+// MaskProxyURL hides the password of a proxy URL for display.
+func MaskProxyURL(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.User == nil {
+		return raw
+	}
+	return u.Redacted()
+}
+
+// IsMaskedProxyURL reports whether value is the masked form of stored, as sent back by a client
+// that edited other settings and left the displayed proxy URL unchanged.
+func IsMaskedProxyURL(value, stored string) bool {
+	return value != stored && value == MaskProxyURL(stored)
+}
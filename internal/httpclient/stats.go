@@ -0,0 +1,136 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PoolStats is a snapshot of the connection pool behind one cached client.
+type PoolStats struct {
+	// Labels name the users of the pool, such as "group/stream". Groups with identical settings share a pool.
+	Labels              []string `json:"labels"`
+	PoolKey             string   `json:"pool_key,omitempty"`
+	MaxIdleConns        int      `json:"max_idle_conns"`
+	MaxIdleConnsPerHost int      `json:"max_idle_conns_per_host"`
+	// OpenConns counts dialed connections that are not closed yet.
+	OpenConns int64 `json:"open_conns"`
+	// InUseConns counts requests whose response body is still being read. With HTTP/2 several of them
+	// may share one connection.
+	InUseConns int64 `json:"in_use_conns"`
+	// IdleConns is OpenConns minus InUseConns, exact for HTTP/1.1 and a lower bound for HTTP/2.
+	IdleConns   int64 `json:"idle_conns"`
+	TotalDials  int64 `json:"total_dials"`
+	ReusedConns int64 `json:"reused_conns"`
+	// WaitCount counts requests that could not take an idle connection and waited for a new or busy one,
+	// for WaitDurationMs in total.
+	WaitCount      int64 `json:"wait_count"`
+	WaitDurationMs int64 `json:"wait_duration_ms"`
+}
+
+// poolCounters are the live counters of one transport.
+type poolCounters struct {
+	openConns atomic.Int64
+	inUse     atomic.Int64
+	dials     atomic.Int64
+	reused    atomic.Int64
+	waitCount atomic.Int64
+	waitNanos atomic.Int64
+}
+
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// wrapDial counts the connections opened by dial until they are closed.
+func (p *poolCounters) wrapDial(dial dialFunc) dialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		p.dials.Add(1)
+		p.openConns.Add(1)
+		return &countedConn{Conn: conn, counters: p}, nil
+	}
+}
+
+// countedConn decrements the open connection count once when closed.
+type countedConn struct {
+	net.Conn
+	counters *poolCounters
+	once     sync.Once
+}
+
+func (c *countedConn) Close() error {
+	c.once.Do(func() { c.counters.openConns.Add(-1) })
+	return c.Conn.Close()
+}
+
+// countedTransport tracks in-flight requests and how long they waited for a connection.
+type countedTransport struct {
+	base     http.RoundTripper
+	counters *poolCounters
+}
+
+func (t *countedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var getConnAt time.Time
+	trace := &httptrace.ClientTrace{
+		GetConn: func(string) { getConnAt = time.Now() },
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				t.counters.reused.Add(1)
+			}
+			if !info.WasIdle && !getConnAt.IsZero() {
+				t.counters.waitCount.Add(1)
+				t.counters.waitNanos.Add(int64(time.Since(getConnAt)))
+			}
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	t.counters.inUse.Add(1)
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		t.counters.inUse.Add(-1)
+		return nil, err
+	}
+	resp.Body = &countedBody{ReadCloser: resp.Body, counters: t.counters}
+	return resp, nil
+}
+
+// CloseIdleConnections lets http.Client.CloseIdleConnections reach the wrapped transport.
+func (t *countedTransport) CloseIdleConnections() {
+	if closer, ok := t.base.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// countedBody ends a request's use of its connection when the response body is closed.
+type countedBody struct {
+	io.ReadCloser
+	counters *poolCounters
+	once     sync.Once
+}
+
+func (b *countedBody) Close() error {
+	b.once.Do(func() { b.counters.inUse.Add(-1) })
+	return b.ReadCloser.Close()
+}
+
+// snapshot returns the current counters of the pool.
+func (p *poolCounters) snapshot() PoolStats {
+	open, inUse := p.openConns.Load(), p.inUse.Load()
+	return PoolStats{
+		OpenConns:      open,
+		InUseConns:     inUse,
+		IdleConns:      max(open-inUse, 0),
+		TotalDials:     p.dials.Load(),
+		ReusedConns:    p.reused.Load(),
+		WaitCount:      p.waitCount.Load(),
+		WaitDurationMs: time.Duration(p.waitNanos.Load()).Milliseconds(),
+	}
+}
@@ -0,0 +1,78 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// TLSOptions holds the optional TLS settings of an upstream client.
+// Certificate values may be either PEM content or a path to a PEM file.
+type TLSOptions struct {
+	ClientCert string
+	ClientKey  string
+	CACert     string
+	// InsecureSkipVerify disables upstream certificate verification. Testing only.
+	InsecureSkipVerify bool
+}
+
+// IsZero reports whether no TLS option is set.
+func (o TLSOptions) IsZero() bool {
+	return o.ClientCert == "" && o.ClientKey == "" && o.CACert == "" && !o.InsecureSkipVerify
+}
+
+// BuildTLSConfig creates a tls.Config from the options, returning nil when none are set.
+func BuildTLSConfig(opts TLSOptions) (*tls.Config, error) {
+	if opts.IsZero() {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: opts.InsecureSkipVerify,
+	}
+
+	if opts.ClientCert != "" || opts.ClientKey != "" {
+		if opts.ClientCert == "" || opts.ClientKey == "" {
+			return nil, fmt.Errorf("tls client certificate and key must be configured together")
+		}
+		certPEM, err := loadPEM(opts.ClientCert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load tls client certificate: %w", err)
+		}
+		keyPEM, err := loadPEM(opts.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load tls client key: %w", err)
+		}
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tls client certificate/key pair: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if opts.CACert != "" {
+		caPEM, err := loadPEM(opts.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load tls ca certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("tls ca certificate contains no valid PEM certificates")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
+
+// loadPEM returns the value itself when it is inline PEM content, otherwise reads it as a file path.
+func loadPEM(value string) ([]byte, error) {
+	value = strings.TrimSpace(value)
+	if strings.Contains(value, "-----BEGIN") {
+		return []byte(value), nil
+	}
+	return os.ReadFile(value)
+}
@@ -0,0 +1,20 @@
+package httpclient
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidateUserAgent checks that value is usable as a User-Agent header: printable ASCII without
+// surrounding whitespace. An empty value is allowed and means the default User-Agent.
+func ValidateUserAgent(value string) error {
+	if value != strings.TrimSpace(value) {
+		return fmt.Errorf("invalid user agent '%s': must not start or end with whitespace", value)
+	}
+	for _, r := range value {
+		if r < 0x20 || r > 0x7e {
+			return fmt.Errorf("invalid user agent %q: only printable ASCII characters are allowed", value)
+		}
+	}
+	return nil
+}
@@ -12,6 +12,9 @@ import (
 	"gorm.io/gorm"
 )
 
+// cronCheckInterval is how often the CronChecker looks for groups due for validation.
+const cronCheckInterval = 5 * time.Minute
+
 // NewCronChecker is responsible for periodically validating invalid keys.
 type CronChecker struct {
 	DB              *gorm.DB
@@ -66,7 +69,7 @@ func (s *CronChecker) runLoop() {
 
 	s.submitValidationJobs()
 
-	ticker := time.NewTicker(5 * time.Minute)
+	ticker := time.NewTicker(cronCheckInterval)
 	defer ticker.Stop()
 
 	for {
@@ -93,6 +96,10 @@ func (s *CronChecker) submitValidationJobs() {
 
 	for i := range groups {
 		group := &groups[i]
+		// Draining groups are about to have their keys swapped, so they are left alone.
+		if group.Draining {
+			continue
+		}
 		group.EffectiveConfig = s.SettingsManager.GetEffectiveConfig(group.Config)
 		interval := time.Duration(group.EffectiveConfig.KeyValidationIntervalMinutes) * time.Minute
 
@@ -4,37 +4,73 @@ import (
 	"errors"
 	"fmt"
 	"gpt-load/internal/config"
+	"gpt-load/internal/encryption"
 	app_errors "gpt-load/internal/errors"
 	"gpt-load/internal/models"
 	"gpt-load/internal/store"
+	"gpt-load/internal/utils"
+	"maps"
+	"math/rand"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
 
+// minWarmUpWeight 是预热刚开始时 Key 被选中的概率。
+const minWarmUpWeight = 0.1
+
+// maxInvalidReasonLength 是记录的 Key 拉黑原因的最大长度。
+const maxInvalidReasonLength = 500
+
+// invalidReasonManual 是通过接口手动禁用 Key 时记录的原因。
+const invalidReasonManual = "disabled manually"
+
+// availabilityEstimateTTL 是分组恢复时间估算的缓存时长，避免每个 503 响应都查询数据库。
+const availabilityEstimateTTL = 5 * time.Second
+
 type KeyProvider struct {
 	db              *gorm.DB
 	store           store.Store
 	settingsManager *config.SystemSettingsManager
+	encryption      encryption.Service
+	// availabilityEstimates 按分组 ID 缓存 availabilityEstimate
+	availabilityEstimates sync.Map
+}
+
+// availabilityEstimate 是缓存的分组恢复时间估算。
+type availabilityEstimate struct {
+	availableAt time.Time
+	ok          bool
+	expiresAt   time.Time
 }
 
 // NewProvider 创建一个新的 KeyProvider 实例。
-func NewProvider(db *gorm.DB, store store.Store, settingsManager *config.SystemSettingsManager) *KeyProvider {
+func NewProvider(db *gorm.DB, store store.Store, settingsManager *config.SystemSettingsManager, encryptionSvc encryption.Service) *KeyProvider {
 	return &KeyProvider{
 		db:              db,
 		store:           store,
 		settingsManager: settingsManager,
+		encryption:      encryptionSvc,
 	}
 }
 
 // SelectKey 为指定的分组原子性地选择并轮换一个可用的 APIKey。
-func (p *KeyProvider) SelectKey(groupID uint) (*models.APIKey, error) {
-	activeKeysListKey := fmt.Sprintf("group:%d:active_keys", groupID)
+// excludeIDs 为本次请求已尝试过的 Key，仅在所有可用 Key 都已尝试过时才会被重复选中。
+// 排空中的分组不分配 Key，返回 ErrNoActiveKeys，排空状态取自缓存的分组。
+func (p *KeyProvider) SelectKey(group *models.Group, excludeIDs map[uint]bool) (*models.APIKey, error) {
+	if group.Draining {
+		return nil, app_errors.ErrNoActiveKeys
+	}
+
+	activeKeysListKey := fmt.Sprintf("group:%d:active_keys", group.ID)
 
-	// 1. Atomically rotate the key ID from the list
-	keyIDStr, err := p.store.Rotate(activeKeysListKey)
+	// 1. Atomically rotate the key ID from the list, skipping tried keys and keys in rate-limit cooldown.
+	// The key details, including its warm-up state, are read while rotating.
+	keyIDStr, keyDetails, err := p.rotateKey(activeKeysListKey, excludeIDs)
 	if err != nil {
 		if errors.Is(err, store.ErrNotFound) {
 			return nil, app_errors.ErrNoActiveKeys
@@ -47,14 +83,7 @@ func (p *KeyProvider) SelectKey(groupID uint) (*models.APIKey, error) {
 		return nil, fmt.Errorf("failed to parse key ID '%s': %w", keyIDStr, err)
 	}
 
-	// 2. Get key details from HASH
-	keyHashKey := fmt.Sprintf("key:%d", keyID)
-	keyDetails, err := p.store.HGetAll(keyHashKey)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get key details for key ID %d: %w", keyID, err)
-	}
-
-	// 3. Manually unmarshal the map into an APIKey struct
+	// 2. Manually unmarshal the map into an APIKey struct
 	failureCount, _ := strconv.ParseInt(keyDetails["failure_count"], 10, 64)
 	createdAt, _ := strconv.ParseInt(keyDetails["created_at"], 10, 64)
 
@@ -63,15 +92,207 @@ func (p *KeyProvider) SelectKey(groupID uint) (*models.APIKey, error) {
 		KeyValue:     keyDetails["key_string"],
 		Status:       keyDetails["status"],
 		FailureCount: failureCount,
-		GroupID:      groupID,
+		GroupID:      group.ID,
 		CreatedAt:    time.Unix(createdAt, 0),
 	}
 
 	return apiKey, nil
 }
 
-// UpdateStatus 异步地提交一个 Key 状态更新任务。
-func (p *KeyProvider) UpdateStatus(apiKey *models.APIKey, group *models.Group, isSuccess bool) {
+// SelectLastResortKey 在分组所有 Key 都已失效时，按配置的间隔取出最久未失败的失效 Key 作为兜底探测。
+// 未开启、未到探测间隔或没有失效 Key 时返回 ErrNoActiveKeys。探测成功后由调用方通过 UpdateStatus 将其恢复。
+func (p *KeyProvider) SelectLastResortKey(group *models.Group) (*models.APIKey, error) {
+	groupOptions := group.Options
+	if groupOptions.LastResortProbeSeconds <= 0 || group.Draining {
+		return nil, app_errors.ErrNoActiveKeys
+	}
+
+	probeKey := fmt.Sprintf("group:%d:last_resort_probe", group.ID)
+	acquired, err := p.store.SetNX(probeKey, []byte("1"), time.Duration(groupOptions.LastResortProbeSeconds)*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire last resort probe slot: %w", err)
+	}
+	if !acquired {
+		return nil, app_errors.ErrNoActiveKeys
+	}
+
+	var key models.APIKey
+	err = p.db.Where("group_id = ? AND status = ?", group.ID, models.KeyStatusInvalid).
+		Order("last_failure_at IS NOT NULL, last_failure_at ASC").
+		First(&key).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, app_errors.ErrNoActiveKeys
+		}
+		return nil, fmt.Errorf("failed to find invalid key to probe: %w", err)
+	}
+
+	keyValue, err := p.encryption.Decrypt(key.KeyValue)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt key %d: %w", key.ID, err)
+	}
+	key.KeyValue = keyValue
+
+	logrus.WithFields(logrus.Fields{"keyID": key.ID, "group": group.Name}).Info("No active keys left, probing an invalid key as a last resort")
+	return &key, nil
+}
+
+// SetDraining 将分组设为排空中或恢复正常。排空中的分组不再分配 Key，已在进行中的请求不受影响，
+// Key 保留在数据库和 Store 中，恢复后立即重新参与轮询。调用方需使分组缓存失效，使集群内所有节点生效。
+func (p *KeyProvider) SetDraining(groupID uint, draining bool) error {
+	return p.db.Model(&models.Group{}).Where("id = ?", groupID).Update("draining", draining).Error
+}
+
+// rotateKey 轮换出下一个 Key ID 并返回其详情，跳过本次请求已尝试过的 Key 和处于限流冷却中的 Key，
+// 预热中的 Key 按预热权重随机跳过。预热权重取自 Key 详情，不需要额外读取 Store。
+// 没有可直接选中的 Key 时，优先返回因预热被跳过的 Key，使重试不会回到已尝试过的 Key；
+// 若所有 Key 都已尝试过或在冷却中，则返回最后轮换到的 Key，交由上游决定是否仍然失败。
+func (p *KeyProvider) rotateKey(activeKeysListKey string, excludeIDs map[uint]bool) (string, map[string]string, error) {
+	checkCooldown := p.settingsManager.GetSettings().RateLimitCooldownSeconds > 0
+
+	var lastID, warmingID string
+	var lastDetails, warmingDetails map[string]string
+	count := int64(1)
+	for i := int64(0); ; i++ {
+		if i == 1 {
+			// The list length is only read once the first key turned out not to be selectable.
+			length, err := p.store.LLen(activeKeysListKey)
+			if err == nil {
+				count = length
+			}
+		}
+		if i >= count {
+			break
+		}
+
+		keyIDStr, err := p.store.Rotate(activeKeysListKey)
+		if err != nil {
+			return "", nil, err
+		}
+
+		skip := p.isExcluded(keyIDStr, excludeIDs, checkCooldown)
+		if skip && lastDetails != nil {
+			continue
+		}
+		keyDetails, err := p.store.HGetAll(fmt.Sprintf("key:%s", keyIDStr))
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to get key details for key ID %s: %w", keyIDStr, err)
+		}
+		lastID, lastDetails = keyIDStr, keyDetails
+		if skip {
+			continue
+		}
+		if rand.Float64() < warmUpWeight(keyDetails) {
+			return keyIDStr, keyDetails, nil
+		}
+		if warmingDetails == nil {
+			warmingID, warmingDetails = keyIDStr, keyDetails
+		}
+	}
+
+	if warmingDetails != nil {
+		return warmingID, warmingDetails, nil
+	}
+	return lastID, lastDetails, nil
+}
+
+// isExcluded 判断轮换到的 Key 是否已被本次请求尝试过，或处于限流冷却中。
+func (p *KeyProvider) isExcluded(keyIDStr string, excludeIDs map[uint]bool, checkCooldown bool) bool {
+	if keyID, err := strconv.ParseUint(keyIDStr, 10, 64); err == nil && excludeIDs[uint(keyID)] {
+		return true
+	}
+	if checkCooldown {
+		if cooling, err := p.store.Exists(cooldownKey(keyIDStr)); err == nil && cooling {
+			return true
+		}
+	}
+	return false
+}
+
+// warmUpWeight 返回 Key 当前被选中的概率：预热期内从 minWarmUpWeight 线性增长到 1，不在预热期则为 1。
+// 预热开始时间和时长保存在 Key 详情的 warmup_start 和 warmup_period 字段中，单位为毫秒。
+func warmUpWeight(keyDetails map[string]string) float64 {
+	periodMs, _ := strconv.ParseInt(keyDetails["warmup_period"], 10, 64)
+	if periodMs <= 0 {
+		return 1
+	}
+	startMs, _ := strconv.ParseInt(keyDetails["warmup_start"], 10, 64)
+	progress := float64(time.Now().UnixMilli()-startMs) / float64(periodMs)
+	return min(max(progress, minWarmUpWeight), 1)
+}
+
+// EstimateKeyAvailableIn 估算没有可用 Key 的分组多久后可能恢复：取下一次定时验证失效 Key 的时间，
+// 开启兜底探测时不超过探测间隔。分组没有失效 Key 或处于排空中时无法估算，返回 false。
+// 估算结果按分组缓存 availabilityEstimateTTL，期间的请求不再查询数据库。
+func (p *KeyProvider) EstimateKeyAvailableIn(group *models.Group) (time.Duration, bool) {
+	if group.Draining {
+		return 0, false
+	}
+
+	now := time.Now()
+	if cached, ok := p.availabilityEstimates.Load(group.ID); ok {
+		if estimate := cached.(availabilityEstimate); now.Before(estimate.expiresAt) {
+			return max(estimate.availableAt.Sub(now), 0), estimate.ok
+		}
+	}
+
+	wait, ok := p.estimateKeyAvailableIn(group)
+	p.availabilityEstimates.Store(group.ID, availabilityEstimate{
+		availableAt: now.Add(wait),
+		ok:          ok,
+		expiresAt:   now.Add(availabilityEstimateTTL),
+	})
+	return wait, ok
+}
+
+// estimateKeyAvailableIn 从数据库计算 EstimateKeyAvailableIn 的估算值。
+func (p *KeyProvider) estimateKeyAvailableIn(group *models.Group) (time.Duration, bool) {
+	var invalidCount int64
+	if err := p.db.Model(&models.APIKey{}).Where("group_id = ? AND status = ?", group.ID, models.KeyStatusInvalid).Count(&invalidCount).Error; err != nil || invalidCount == 0 {
+		return 0, false
+	}
+
+	// 缓存中的分组可能不是最新的验证时间，从数据库读取
+	var current models.Group
+	if err := p.db.Select("id", "last_validated_at").First(&current, group.ID).Error; err != nil {
+		return 0, false
+	}
+
+	// 已到期的分组在下一次巡检时验证，最迟一个巡检周期
+	wait := cronCheckInterval
+	if current.LastValidatedAt != nil {
+		interval := time.Duration(group.EffectiveConfig.KeyValidationIntervalMinutes) * time.Minute
+		if until := time.Until(current.LastValidatedAt.Add(interval)); until > 0 {
+			wait = until
+		}
+	}
+
+	if probeSeconds := group.Options.LastResortProbeSeconds; probeSeconds > 0 {
+		wait = min(wait, time.Duration(probeSeconds)*time.Second)
+	}
+	return wait, true
+}
+
+// ShuffleActiveKeys 随机打乱分组可用 Key 的轮询顺序，纠正批量导入等操作造成的选择偏斜。
+// 开启 stable_order 的分组由调用方跳过。
+func (p *KeyProvider) ShuffleActiveKeys(groupID uint) error {
+	return p.store.Shuffle(fmt.Sprintf("group:%d:active_keys", groupID))
+}
+
+// HasActiveKeys 判断分组当前是否存在可用的 Key，排空中的分组视为没有。
+func (p *KeyProvider) HasActiveKeys(group *models.Group) (bool, error) {
+	if group.Draining {
+		return false, nil
+	}
+	count, err := p.store.LLen(fmt.Sprintf("group:%d:active_keys", group.ID))
+	if err != nil {
+		return false, fmt.Errorf("failed to get active key count: %w", err)
+	}
+	return count > 0, nil
+}
+
+// UpdateStatus 异步地提交一个 Key 状态更新任务。reason 为失败原因，Key 因此被拉黑时会记录下来。
+func (p *KeyProvider) UpdateStatus(apiKey *models.APIKey, group *models.Group, isSuccess bool, reason string) {
 	go func() {
 		keyHashKey := fmt.Sprintf("key:%d", apiKey.ID)
 		activeKeysListKey := fmt.Sprintf("group:%d:active_keys", group.ID)
@@ -81,13 +302,102 @@ func (p *KeyProvider) UpdateStatus(apiKey *models.APIKey, group *models.Group, i
 				logrus.WithFields(logrus.Fields{"keyID": apiKey.ID, "error": err}).Error("Failed to handle key success")
 			}
 		} else {
-			if err := p.handleFailure(apiKey, group, keyHashKey, activeKeysListKey); err != nil {
+			if err := p.handleFailure(apiKey, group, keyHashKey, activeKeysListKey, false, reason); err != nil {
 				logrus.WithFields(logrus.Fields{"keyID": apiKey.ID, "error": err}).Error("Failed to handle key failure")
 			}
 		}
 	}()
 }
 
+// MarkInvalid 异步地将 Key 立即拉黑并记录原因，用于上游返回永久性错误（如密钥失效）的情况。
+func (p *KeyProvider) MarkInvalid(apiKey *models.APIKey, group *models.Group, reason string) {
+	go func() {
+		keyHashKey := fmt.Sprintf("key:%d", apiKey.ID)
+		activeKeysListKey := fmt.Sprintf("group:%d:active_keys", group.ID)
+
+		if err := p.handleFailure(apiKey, group, keyHashKey, activeKeysListKey, true, reason); err != nil {
+			logrus.WithFields(logrus.Fields{"keyID": apiKey.ID, "error": err}).Error("Failed to blacklist key")
+		}
+	}()
+}
+
+// Cooldown 使 Key 在指定时长内不被 SelectKey 选中，且不计入失败次数，用于上游限流的情况。
+func (p *KeyProvider) Cooldown(apiKey *models.APIKey, duration time.Duration) {
+	if duration <= 0 {
+		return
+	}
+	if err := p.store.Set(cooldownKey(strconv.FormatUint(uint64(apiKey.ID), 10)), []byte("1"), duration); err != nil {
+		logrus.WithFields(logrus.Fields{"keyID": apiKey.ID, "error": err}).Error("Failed to put key into cooldown")
+	}
+}
+
+// truncateInvalidReason 将拉黑原因截断到数据库列的长度，并去掉截断产生的不完整字符。
+func truncateInvalidReason(reason string) string {
+	return strings.ToValidUTF8(utils.TruncateString(reason, maxInvalidReasonLength), "")
+}
+
+func cooldownKey(keyID string) string {
+	return fmt.Sprintf("key:%s:cooldown", keyID)
+}
+
+// warmUpPeriod 返回分组配置的恢复 Key 预热时长，未开启时为 0。
+func (p *KeyProvider) warmUpPeriod(groupID uint) time.Duration {
+	var group models.Group
+	if err := p.db.Select("id, config").First(&group, groupID).Error; err != nil {
+		logrus.WithFields(logrus.Fields{"groupID": groupID, "error": err}).Warn("Failed to load group for key warm-up, restoring keys without it")
+		return 0
+	}
+	groupOptions, err := utils.ParseGroupConfig(group.Config)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(groupOptions.KeyWarmUpSeconds) * time.Second
+}
+
+// SortActiveKeys 在分组开启 stable_order 时按 ID 升序重建其 active 列表，用于分组配置变更后。
+func (p *KeyProvider) SortActiveKeys(groupID uint) error {
+	return p.sortActiveKeys(p.db, groupID)
+}
+
+// sortActiveKeys 在分组开启 stable_order 时按 ID 升序重建其 active 列表，使轮询顺序可复现。
+// 列表在临时键上重建后原子替换，之后的轮询从 ID 最小的 Key 开始。tx 用于读取事务内尚未提交的状态。
+func (p *KeyProvider) sortActiveKeys(tx *gorm.DB, groupID uint) error {
+	var group models.Group
+	if err := tx.Select("id, config").First(&group, groupID).Error; err != nil {
+		return fmt.Errorf("failed to load group %d for key ordering: %w", groupID, err)
+	}
+	groupOptions, err := utils.ParseGroupConfig(group.Config)
+	if err != nil || !groupOptions.StableOrder {
+		return nil
+	}
+
+	var activeIDs []uint
+	if err := tx.Model(&models.APIKey{}).Where("group_id = ? AND status = ?", groupID, models.KeyStatusActive).Order("id asc").Pluck("id", &activeIDs).Error; err != nil {
+		return fmt.Errorf("failed to list active keys of group %d: %w", groupID, err)
+	}
+
+	activeKeysListKey := fmt.Sprintf("group:%d:active_keys", groupID)
+	if len(activeIDs) == 0 {
+		return p.store.Delete(activeKeysListKey)
+	}
+	// Rotate takes from the tail and LPush adds to the head, so pushing in ascending order serves the lowest ID first.
+	values := make([]any, len(activeIDs))
+	for i, id := range activeIDs {
+		values[i] = id
+	}
+	swapListKey := activeKeysListKey + ":swap"
+	if err := p.store.Delete(swapListKey); err != nil {
+		return fmt.Errorf("failed to clear swap list: %w", err)
+	}
+	if err := p.store.LPush(swapListKey, values...); err != nil {
+		return fmt.Errorf("failed to build swap list: %w", err)
+	}
+	if err := p.store.Rename(swapListKey, activeKeysListKey); err != nil {
+		return fmt.Errorf("failed to swap active key list: %w", err)
+	}
+	return nil
+}
+
 func (p *KeyProvider) handleSuccess(keyID uint, keyHashKey, activeKeysListKey string) error {
 	keyDetails, err := p.store.HGetAll(keyHashKey)
 	if err != nil {
@@ -97,6 +407,7 @@ func (p *KeyProvider) handleSuccess(keyID uint, keyHashKey, activeKeysListKey st
 	failureCount, _ := strconv.ParseInt(keyDetails["failure_count"], 10, 64)
 	isActive := keyDetails["status"] == models.KeyStatusActive
 
+	// last_success_at of a healthy key is updated in batches along with its request logs.
 	if failureCount == 0 && isActive {
 		return nil
 	}
@@ -112,7 +423,12 @@ func (p *KeyProvider) handleSuccess(keyID uint, keyHashKey, activeKeysListKey st
 			updates["status"] = models.KeyStatusActive
 		}
 
-		if err := tx.Model(&key).Updates(updates).Error; err != nil {
+		dbUpdates := maps.Clone(updates)
+		dbUpdates["last_success_at"] = time.Now()
+		if !isActive {
+			dbUpdates["invalid_reason"] = ""
+		}
+		if err := tx.Model(&key).Updates(dbUpdates).Error; err != nil {
 			return fmt.Errorf("failed to update key in DB: %w", err)
 		}
 
@@ -128,19 +444,26 @@ func (p *KeyProvider) handleSuccess(keyID uint, keyHashKey, activeKeysListKey st
 			if err := p.store.LPush(activeKeysListKey, keyID); err != nil {
 				return fmt.Errorf("failed to LPush key back to active list: %w", err)
 			}
+			if err := p.sortActiveKeys(tx, key.GroupID); err != nil {
+				return err
+			}
 		}
 
 		return nil
 	})
 }
 
-func (p *KeyProvider) handleFailure(apiKey *models.APIKey, group *models.Group, keyHashKey, activeKeysListKey string) error {
+func (p *KeyProvider) handleFailure(apiKey *models.APIKey, group *models.Group, keyHashKey, activeKeysListKey string, forceBlacklist bool, reason string) error {
 	keyDetails, err := p.store.HGetAll(keyHashKey)
 	if err != nil {
 		return fmt.Errorf("failed to get key details from store: %w", err)
 	}
 
 	if keyDetails["status"] == models.KeyStatusInvalid {
+		// 已失效的 Key 再次失败（如兜底探测）时只记录失败时间，使下次探测轮换到其他 Key。
+		if err := p.db.Model(&models.APIKey{}).Where("id = ?", apiKey.ID).Update("last_failure_at", time.Now()).Error; err != nil {
+			return fmt.Errorf("failed to update key last failure time: %w", err)
+		}
 		return nil
 	}
 
@@ -157,10 +480,11 @@ func (p *KeyProvider) handleFailure(apiKey *models.APIKey, group *models.Group,
 
 		newFailureCount := failureCount + 1
 
-		updates := map[string]any{"failure_count": newFailureCount}
-		shouldBlacklist := blacklistThreshold > 0 && newFailureCount >= int64(blacklistThreshold)
+		updates := map[string]any{"failure_count": newFailureCount, "last_failure_at": time.Now()}
+		shouldBlacklist := forceBlacklist || (blacklistThreshold > 0 && newFailureCount >= int64(blacklistThreshold))
 		if shouldBlacklist {
 			updates["status"] = models.KeyStatusInvalid
+			updates["invalid_reason"] = truncateInvalidReason(utils.RedactText(reason, p.settingsManager.GetSettings().RedactionPatterns))
 		}
 
 		if err := tx.Model(&key).Updates(updates).Error; err != nil {
@@ -172,7 +496,11 @@ func (p *KeyProvider) handleFailure(apiKey *models.APIKey, group *models.Group,
 		}
 
 		if shouldBlacklist {
-			logrus.WithFields(logrus.Fields{"keyID": apiKey.ID, "threshold": blacklistThreshold}).Warn("Key has reached blacklist threshold, disabling.")
+			if forceBlacklist {
+				logrus.WithField("keyID", apiKey.ID).Warn("Key returned a permanent upstream error, disabling.")
+			} else {
+				logrus.WithFields(logrus.Fields{"keyID": apiKey.ID, "threshold": blacklistThreshold}).Warn("Key has reached blacklist threshold, disabling.")
+			}
 			if err := p.store.LRem(activeKeysListKey, 0, apiKey.ID); err != nil {
 				return fmt.Errorf("failed to LRem key from active list: %w", err)
 			}
@@ -185,9 +513,21 @@ func (p *KeyProvider) handleFailure(apiKey *models.APIKey, group *models.Group,
 	})
 }
 
+// keysLoadedFlagKey 标记密钥池已从数据库加载到 Store 中。
+const keysLoadedFlagKey = "initialization:db_keys_loaded"
+
+// ReloadKeys 无论是否已加载过，都重新从数据库加载所有密钥到 Store 中，
+// 用于 Store 切换到内存备用存储或从中恢复后重建密钥池。
+func (p *KeyProvider) ReloadKeys() error {
+	if err := p.store.Delete(keysLoadedFlagKey); err != nil {
+		return fmt.Errorf("failed to clear initialization flag: %w", err)
+	}
+	return p.LoadKeysFromDB()
+}
+
 // LoadKeysFromDB 从数据库加载所有分组和密钥，并填充到 Store 中。
 func (p *KeyProvider) LoadKeysFromDB() error {
-	initFlagKey := "initialization:db_keys_loaded"
+	initFlagKey := keysLoadedFlagKey
 
 	exists, err := p.store.Exists(initFlagKey)
 	if err != nil {
@@ -215,6 +555,12 @@ func (p *KeyProvider) LoadKeysFromDB() error {
 		}
 
 		for _, key := range batchKeys {
+			keyValue, err := p.encryption.Decrypt(key.KeyValue)
+			if err != nil {
+				return fmt.Errorf("failed to decrypt key %d: %w", key.ID, err)
+			}
+			key.KeyValue = keyValue
+
 			keyHashKey := fmt.Sprintf("key:%d", key.ID)
 			keyDetails := p.apiKeyToMap(key)
 
@@ -243,7 +589,8 @@ func (p *KeyProvider) LoadKeysFromDB() error {
 		return fmt.Errorf("failed during batch processing of keys: %w", err)
 	}
 
-	// 2. 更新所有分组的 active_keys 列表
+	// 2. 更新所有分组的 active_keys 列表。FindInBatches 按 ID 升序读取，
+	// 因此列表按 ID 升序轮询，开启 stable_order 的分组无需再排序。
 	logrus.Info("Updating active key lists for all groups...")
 	for groupID, activeIDs := range allActiveKeyIDs {
 		if len(activeIDs) > 0 {
@@ -255,6 +602,17 @@ func (p *KeyProvider) LoadKeysFromDB() error {
 		}
 	}
 
+	// 重新加载时清除已没有可用 Key 的分组中残留的列表
+	var groups []models.Group
+	if err := p.db.Model(&models.Group{}).Select("id").Find(&groups).Error; err != nil {
+		return fmt.Errorf("failed to list groups: %w", err)
+	}
+	for _, group := range groups {
+		if len(allActiveKeyIDs[group.ID]) == 0 {
+			p.store.Delete(fmt.Sprintf("group:%d:active_keys", group.ID))
+		}
+	}
+
 	if err := p.store.Set(initFlagKey, []byte("1"), 0); err != nil {
 		logrus.WithField("flagKey", initFlagKey).Error("Failed to set initialization flag after loading keys")
 	}
@@ -263,28 +621,147 @@ func (p *KeyProvider) LoadKeysFromDB() error {
 }
 
 // AddKeys 批量添加新的 Key 到池和数据库中。
+// 传入的 Key 为明文，启用加密时写入数据库前会就地加密。
 func (p *KeyProvider) AddKeys(groupID uint, keys []models.APIKey) error {
 	if len(keys) == 0 {
 		return nil
 	}
 
+	for i := range keys {
+		encrypted, err := p.encryption.Encrypt(keys[i].KeyValue)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt key: %w", err)
+		}
+		keys[i].KeyValue = encrypted
+	}
+
 	err := p.db.Transaction(func(tx *gorm.DB) error {
 		if err := tx.Create(&keys).Error; err != nil {
 			return err
 		}
 
 		for _, key := range keys {
-			if err := p.addKeyToStore(&key); err != nil {
+			if err := p.addKeyToStore(&key, 0); err != nil {
 				logrus.WithFields(logrus.Fields{"keyID": key.ID, "error": err}).Error("Failed to add key to store after DB creation, rolling back transaction")
 				return err
 			}
 		}
-		return nil
+		return p.sortActiveKeys(tx, groupID)
 	})
 
 	return err
 }
 
+// ReplaceKeys 在一个事务中将分组的 Key 整体替换为给定的明文 Key 列表。
+// 两边都存在的 Key 保留原有记录和状态；active 列表在临时键上重建后原子地替换，
+// 因此 SelectKey 在替换过程中不会遇到空池。
+func (p *KeyProvider) ReplaceKeys(groupID uint, keyValues []string) (addedCount int64, removedCount int64, err error) {
+	activeKeysListKey := fmt.Sprintf("group:%d:active_keys", groupID)
+	swapListKey := activeKeysListKey + ":swap"
+
+	err = p.db.Transaction(func(tx *gorm.DB) error {
+		var existingKeys []models.APIKey
+		if err := tx.Where("group_id = ?", groupID).Find(&existingKeys).Error; err != nil {
+			return err
+		}
+
+		wanted := make(map[string]bool, len(keyValues))
+		for _, kv := range keyValues {
+			wanted[kv] = true
+		}
+
+		existingByValue := make(map[string]bool, len(existingKeys))
+		var keysToRemove []models.APIKey
+		var activeIDs []any
+		for _, key := range existingKeys {
+			keyValue, err := p.encryption.Decrypt(key.KeyValue)
+			if err != nil {
+				return fmt.Errorf("failed to decrypt key %d: %w", key.ID, err)
+			}
+			if !wanted[keyValue] {
+				keysToRemove = append(keysToRemove, key)
+				continue
+			}
+			existingByValue[keyValue] = true
+			if key.Status == models.KeyStatusActive {
+				activeIDs = append(activeIDs, key.ID)
+			}
+		}
+
+		var keysToAdd []models.APIKey
+		var addedValues []string
+		for _, kv := range keyValues {
+			if existingByValue[kv] {
+				continue
+			}
+			encrypted, err := p.encryption.Encrypt(kv)
+			if err != nil {
+				return fmt.Errorf("failed to encrypt key: %w", err)
+			}
+			keysToAdd = append(keysToAdd, models.APIKey{
+				GroupID:  groupID,
+				KeyValue: encrypted,
+				Status:   models.KeyStatusActive,
+			})
+			addedValues = append(addedValues, kv)
+		}
+
+		if len(keysToRemove) > 0 {
+			result := tx.Where("id IN ?", pluckIDs(keysToRemove)).Delete(&models.APIKey{})
+			if result.Error != nil {
+				return result.Error
+			}
+			removedCount = result.RowsAffected
+		}
+
+		if len(keysToAdd) > 0 {
+			if err := tx.Create(&keysToAdd).Error; err != nil {
+				return err
+			}
+			addedCount = int64(len(keysToAdd))
+		}
+
+		// 1. 写入新 Key 的详情
+		for i, key := range keysToAdd {
+			storeKey := key
+			storeKey.KeyValue = addedValues[i]
+			if err := p.store.HSet(fmt.Sprintf("key:%d", key.ID), p.apiKeyToMap(&storeKey)); err != nil {
+				return fmt.Errorf("failed to HSet key details for key %d: %w", key.ID, err)
+			}
+			activeIDs = append(activeIDs, key.ID)
+		}
+
+		// 2. 在临时列表上重建 active 列表，再原子替换
+		if len(activeIDs) > 0 {
+			if err := p.store.Delete(swapListKey); err != nil {
+				return fmt.Errorf("failed to clear swap list: %w", err)
+			}
+			if err := p.store.LPush(swapListKey, activeIDs...); err != nil {
+				return fmt.Errorf("failed to build swap list: %w", err)
+			}
+			if err := p.store.Rename(swapListKey, activeKeysListKey); err != nil {
+				return fmt.Errorf("failed to swap active key list: %w", err)
+			}
+			if err := p.sortActiveKeys(tx, groupID); err != nil {
+				return err
+			}
+		} else if err := p.store.Delete(activeKeysListKey); err != nil {
+			return fmt.Errorf("failed to clear active key list: %w", err)
+		}
+
+		// 3. 清理被移除 Key 的详情
+		for _, key := range keysToRemove {
+			if err := p.store.Delete(fmt.Sprintf("key:%d", key.ID)); err != nil {
+				logrus.WithFields(logrus.Fields{"keyID": key.ID, "error": err}).Error("Failed to delete replaced key from store")
+			}
+		}
+
+		return nil
+	})
+
+	return addedCount, removedCount, err
+}
+
 // RemoveKeys 批量从池和数据库中移除 Key。
 func (p *KeyProvider) RemoveKeys(groupID uint, keyValues []string) (int64, error) {
 	if len(keyValues) == 0 {
@@ -295,7 +772,7 @@ func (p *KeyProvider) RemoveKeys(groupID uint, keyValues []string) (int64, error
 	var deletedCount int64
 
 	err := p.db.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Where("group_id = ? AND key_value IN ?", groupID, keyValues).Find(&keysToDelete).Error; err != nil {
+		if err := tx.Where("group_id = ? AND key_value IN ?", groupID, p.encryption.LookupValues(keyValues)).Find(&keysToDelete).Error; err != nil {
 			return err
 		}
 
@@ -328,6 +805,7 @@ func (p *KeyProvider) RemoveKeys(groupID uint, keyValues []string) (int64, error
 func (p *KeyProvider) RestoreKeys(groupID uint) (int64, error) {
 	var invalidKeys []models.APIKey
 	var restoredCount int64
+	warmUp := p.warmUpPeriod(groupID)
 
 	err := p.db.Transaction(func(tx *gorm.DB) error {
 		if err := tx.Where("group_id = ? AND status = ?", groupID, models.KeyStatusInvalid).Find(&invalidKeys).Error; err != nil {
@@ -339,8 +817,9 @@ func (p *KeyProvider) RestoreKeys(groupID uint) (int64, error) {
 		}
 
 		updates := map[string]any{
-			"status":        models.KeyStatusActive,
-			"failure_count": 0,
+			"status":         models.KeyStatusActive,
+			"failure_count":  0,
+			"invalid_reason": "",
 		}
 		result := tx.Model(&models.APIKey{}).Where("group_id = ? AND status = ?", groupID, models.KeyStatusInvalid).Updates(updates)
 		if result.Error != nil {
@@ -351,12 +830,12 @@ func (p *KeyProvider) RestoreKeys(groupID uint) (int64, error) {
 		for _, key := range invalidKeys {
 			key.Status = models.KeyStatusActive
 			key.FailureCount = 0
-			if err := p.addKeyToStore(&key); err != nil {
+			if err := p.addKeyToStore(&key, warmUp); err != nil {
 				logrus.WithFields(logrus.Fields{"keyID": key.ID, "error": err}).Error("Failed to restore key in store after DB update, rolling back transaction")
 				return err
 			}
 		}
-		return nil
+		return p.sortActiveKeys(tx, groupID)
 	})
 
 	return restoredCount, err
@@ -370,10 +849,11 @@ func (p *KeyProvider) RestoreMultipleKeys(groupID uint, keyValues []string) (int
 
 	var keysToRestore []models.APIKey
 	var restoredCount int64
+	warmUp := p.warmUpPeriod(groupID)
 
 	err := p.db.Transaction(func(tx *gorm.DB) error {
 		// 1. 查找要恢复的密钥
-		if err := tx.Where("group_id = ? AND key_value IN ? AND status = ?", groupID, keyValues, models.KeyStatusInvalid).Find(&keysToRestore).Error; err != nil {
+		if err := tx.Where("group_id = ? AND key_value IN ? AND status = ?", groupID, p.encryption.LookupValues(keyValues), models.KeyStatusInvalid).Find(&keysToRestore).Error; err != nil {
 			return err
 		}
 
@@ -385,8 +865,9 @@ func (p *KeyProvider) RestoreMultipleKeys(groupID uint, keyValues []string) (int
 
 		// 2. 更新数据库中的状态
 		updates := map[string]any{
-			"status":        models.KeyStatusActive,
-			"failure_count": 0,
+			"status":         models.KeyStatusActive,
+			"failure_count":  0,
+			"invalid_reason": "",
 		}
 		result := tx.Model(&models.APIKey{}).Where("id IN ?", keyIDsToRestore).Updates(updates)
 		if result.Error != nil {
@@ -398,19 +879,86 @@ func (p *KeyProvider) RestoreMultipleKeys(groupID uint, keyValues []string) (int
 		for _, key := range keysToRestore {
 			key.Status = models.KeyStatusActive
 			key.FailureCount = 0
-			if err := p.addKeyToStore(&key); err != nil {
+			if err := p.addKeyToStore(&key, warmUp); err != nil {
 				// 在事务中，单个失败会回滚整个事务，但这里的日志记录仍然有用
 				logrus.WithFields(logrus.Fields{"keyID": key.ID, "error": err}).Error("Failed to restore key in store after DB update")
 				return err // 返回错误以回滚事务
 			}
 		}
 
-		return nil
+		return p.sortActiveKeys(tx, groupID)
 	})
 
 	return restoredCount, err
 }
 
+// SetKeysStatus 将指定的 Key 设置为目标状态，同时更新数据库和内存存储中的可用列表。
+// 已处于目标状态的 Key 会被忽略。设为可用的 Key 同恢复一样会清零失败次数并按分组配置预热。
+func (p *KeyProvider) SetKeysStatus(groupID uint, keyValues []string, status string) (int64, error) {
+	if len(keyValues) == 0 {
+		return 0, nil
+	}
+	if status != models.KeyStatusActive && status != models.KeyStatusInvalid {
+		return 0, fmt.Errorf("invalid key status: %s", status)
+	}
+
+	var warmUp time.Duration
+	if status == models.KeyStatusActive {
+		warmUp = p.warmUpPeriod(groupID)
+	}
+
+	var keysToUpdate []models.APIKey
+	var updatedCount int64
+
+	err := p.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("group_id = ? AND key_value IN ? AND status <> ?", groupID, p.encryption.LookupValues(keyValues), status).Find(&keysToUpdate).Error; err != nil {
+			return err
+		}
+
+		if len(keysToUpdate) == 0 {
+			return nil
+		}
+
+		updates := map[string]any{"status": status, "invalid_reason": invalidReasonManual}
+		if status == models.KeyStatusActive {
+			updates["failure_count"] = 0
+			updates["invalid_reason"] = ""
+		}
+		result := tx.Model(&models.APIKey{}).Where("id IN ?", pluckIDs(keysToUpdate)).Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		updatedCount = result.RowsAffected
+
+		activeKeysListKey := fmt.Sprintf("group:%d:active_keys", groupID)
+		for _, key := range keysToUpdate {
+			if status == models.KeyStatusActive {
+				key.Status = models.KeyStatusActive
+				key.FailureCount = 0
+				if err := p.addKeyToStore(&key, warmUp); err != nil {
+					logrus.WithFields(logrus.Fields{"keyID": key.ID, "error": err}).Error("Failed to activate key in store after DB update")
+					return err
+				}
+				continue
+			}
+
+			if err := p.store.LRem(activeKeysListKey, 0, key.ID); err != nil {
+				return fmt.Errorf("failed to LRem key %d from active list: %w", key.ID, err)
+			}
+			if err := p.store.HSet(fmt.Sprintf("key:%d", key.ID), map[string]any{"status": models.KeyStatusInvalid}); err != nil {
+				return fmt.Errorf("failed to update key %d status to invalid in store: %w", key.ID, err)
+			}
+		}
+
+		if status == models.KeyStatusActive {
+			return p.sortActiveKeys(tx, groupID)
+		}
+		return nil
+	})
+
+	return updatedCount, err
+}
+
 // RemoveInvalidKeys 移除组内所有无效的 Key。
 func (p *KeyProvider) RemoveInvalidKeys(groupID uint) (int64, error) {
 	var invalidKeys []models.APIKey
@@ -481,10 +1029,24 @@ func (p *KeyProvider) RemoveKeysFromStore(groupID uint, keyIDs []uint) error {
 }
 
 // addKeyToStore is a helper to add a single key to the cache.
-func (p *KeyProvider) addKeyToStore(key *models.APIKey) error {
-	// 1. Store key details in HASH
+// The key value may be encrypted; the store always holds the plaintext.
+// A positive warmUp makes an active key start with a reduced selection probability
+// that ramps up to full over that period.
+func (p *KeyProvider) addKeyToStore(key *models.APIKey, warmUp time.Duration) error {
+	keyValue, err := p.encryption.Decrypt(key.KeyValue)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt key %d: %w", key.ID, err)
+	}
+	storeKey := *key
+	storeKey.KeyValue = keyValue
+
+	// 1. Store key details in HASH, with the warm-up state read by rotateKey
 	keyHashKey := fmt.Sprintf("key:%d", key.ID)
-	keyDetails := p.apiKeyToMap(key)
+	keyDetails := p.apiKeyToMap(&storeKey)
+	if warmUp > 0 && key.Status == models.KeyStatusActive {
+		keyDetails["warmup_start"] = time.Now().UnixMilli()
+		keyDetails["warmup_period"] = warmUp.Milliseconds()
+	}
 	if err := p.store.HSet(keyHashKey, keyDetails); err != nil {
 		return fmt.Errorf("failed to HSet key details for key %d: %w", key.ID, err)
 	}
@@ -525,6 +1087,9 @@ func (p *KeyProvider) apiKeyToMap(key *models.APIKey) map[string]any {
 		"failure_count": key.FailureCount,
 		"group_id":      key.GroupID,
 		"created_at":    key.CreatedAt.Unix(),
+		// Cleared on every write, addKeyToStore sets them for keys restored with a warm-up.
+		"warmup_start":  0,
+		"warmup_period": 0,
 	}
 }
 
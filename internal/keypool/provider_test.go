@@ -0,0 +1,130 @@
+package keypool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"gpt-load/internal/config"
+	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/models"
+	"gpt-load/internal/store"
+	"gpt-load/internal/testutil"
+
+	"gorm.io/datatypes"
+)
+
+// newTestProvider returns a key provider over an in-memory database and store, with a group holding
+// the given keys.
+func newTestProvider(t *testing.T, keyValues ...string) (*KeyProvider, *models.Group, []models.APIKey) {
+	t.Helper()
+	database := testutil.OpenDB(t, &models.SystemSetting{}, &models.Group{}, &models.APIKey{})
+	testutil.UseDB(t, database)
+
+	memoryStore := store.NewMemoryStore()
+	settingsManager := config.NewSystemSettingsManager()
+	if err := settingsManager.Initialize(memoryStore, nil, false); err != nil {
+		t.Fatalf("failed to initialize settings: %v", err)
+	}
+	t.Cleanup(func() { settingsManager.Stop(context.Background()) })
+
+	group := &models.Group{Name: "test", ChannelType: "openai", TestModel: "gpt-4o-mini", Upstreams: datatypes.JSON(`[]`)}
+	if err := database.Create(group).Error; err != nil {
+		t.Fatalf("failed to create group: %v", err)
+	}
+
+	provider := NewProvider(database, memoryStore, settingsManager, testutil.NoEncryption(t))
+	keys := make([]models.APIKey, len(keyValues))
+	for i, value := range keyValues {
+		keys[i] = models.APIKey{GroupID: group.ID, KeyValue: value, Status: models.KeyStatusActive}
+	}
+	if err := provider.AddKeys(group.ID, keys); err != nil {
+		t.Fatalf("failed to add keys: %v", err)
+	}
+	return provider, group, keys
+}
+
+func TestSelectKeyPrefersWarmingKeyOverTriedKey(t *testing.T) {
+	provider, group, keys := newTestProvider(t, "sk-tried", "sk-warming")
+	tried, warming := keys[0], keys[1]
+	// A warm-up that just started selects the key with the minimum probability.
+	if err := provider.addKeyToStore(&warming, time.Hour); err != nil {
+		t.Fatalf("failed to start warm-up: %v", err)
+	}
+
+	for range 50 {
+		apiKey, err := provider.SelectKey(group, map[uint]bool{tried.ID: true})
+		if err != nil {
+			t.Fatalf("SelectKey() error = %v", err)
+		}
+		if apiKey.ID != warming.ID {
+			t.Fatalf("SelectKey() returned the tried key %d instead of the warming key %d", apiKey.ID, warming.ID)
+		}
+	}
+}
+
+func TestWarmUpWeight(t *testing.T) {
+	now := time.Now().UnixMilli()
+	tests := []struct {
+		name    string
+		details map[string]string
+		want    float64
+	}{
+		{"no warm-up", map[string]string{}, 1},
+		{"cleared warm-up", map[string]string{"warmup_start": "0", "warmup_period": "0"}, 1},
+		{"just started", map[string]string{"warmup_start": fmt.Sprint(now), "warmup_period": "3600000"}, minWarmUpWeight},
+		{"finished", map[string]string{"warmup_start": fmt.Sprint(now - 7200000), "warmup_period": "3600000"}, 1},
+	}
+	for _, tt := range tests {
+		if got := warmUpWeight(tt.details); got != tt.want {
+			t.Errorf("%s: warmUpWeight() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSelectKeySkipsDrainingGroup(t *testing.T) {
+	provider, group, _ := newTestProvider(t, "sk-a")
+
+	draining := *group
+	draining.Draining = true
+	if _, err := provider.SelectKey(&draining, nil); !errors.Is(err, app_errors.ErrNoActiveKeys) {
+		t.Errorf("SelectKey() error = %v for a draining group, want ErrNoActiveKeys", err)
+	}
+	if hasKeys, err := provider.HasActiveKeys(&draining); err != nil || hasKeys {
+		t.Errorf("HasActiveKeys() = %v, %v for a draining group, want false", hasKeys, err)
+	}
+
+	if _, err := provider.SelectKey(group, nil); err != nil {
+		t.Errorf("SelectKey() error = %v after draining stopped", err)
+	}
+	if hasKeys, err := provider.HasActiveKeys(group); err != nil || !hasKeys {
+		t.Errorf("HasActiveKeys() = %v, %v, want true", hasKeys, err)
+	}
+}
+
+func TestEstimateKeyAvailableInIsCached(t *testing.T) {
+	provider, group, keys := newTestProvider(t, "sk-a")
+	if err := provider.db.Model(&keys[0]).Update("status", models.KeyStatusInvalid).Error; err != nil {
+		t.Fatalf("failed to invalidate key: %v", err)
+	}
+
+	wait, ok := provider.EstimateKeyAvailableIn(group)
+	if !ok || wait <= 0 || wait > cronCheckInterval {
+		t.Fatalf("EstimateKeyAvailableIn() = %s, %v, want at most one cron check interval", wait, ok)
+	}
+
+	// Within the cache TTL, the estimate does not go back to the database.
+	if err := provider.db.Delete(&keys[0]).Error; err != nil {
+		t.Fatalf("failed to delete key: %v", err)
+	}
+	if cached, ok := provider.EstimateKeyAvailableIn(group); !ok || cached > wait {
+		t.Errorf("EstimateKeyAvailableIn() = %s, %v, want the cached estimate of at most %s", cached, ok, wait)
+	}
+
+	provider.availabilityEstimates.Clear()
+	if _, ok := provider.EstimateKeyAvailableIn(group); ok {
+		t.Error("EstimateKeyAvailableIn() = true once the cache expired and the group has no invalid key")
+	}
+}
@@ -0,0 +1,110 @@
+package keypool
+
+import (
+	"context"
+	"gpt-load/internal/config"
+	"gpt-load/internal/models"
+	"gpt-load/internal/utils"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// rebalanceCheckInterval is how often the rebalancer checks whether a rebalance is due,
+// so changes to the interval setting take effect without a restart.
+const rebalanceCheckInterval = time.Minute
+
+// Rebalancer periodically shuffles the active key list of every group. Keys are rotated
+// round-robin, so keys pushed to the head of the list by imports and restores would otherwise
+// keep their relative order and receive uneven traffic over short windows.
+type Rebalancer struct {
+	DB              *gorm.DB
+	SettingsManager *config.SystemSettingsManager
+	KeyProvider     *KeyProvider
+	stopChan        chan struct{}
+	wg              sync.WaitGroup
+}
+
+// NewRebalancer creates a new Rebalancer.
+func NewRebalancer(
+	db *gorm.DB,
+	settingsManager *config.SystemSettingsManager,
+	keyProvider *KeyProvider,
+) *Rebalancer {
+	return &Rebalancer{
+		DB:              db,
+		SettingsManager: settingsManager,
+		KeyProvider:     keyProvider,
+		stopChan:        make(chan struct{}),
+	}
+}
+
+// Start begins the periodic rebalance.
+func (r *Rebalancer) Start() {
+	logrus.Debug("Starting Rebalancer...")
+	r.wg.Add(1)
+	go r.runLoop()
+}
+
+// Stop stops the rebalancer, respecting the context for shutdown timeout.
+func (r *Rebalancer) Stop(ctx context.Context) {
+	close(r.stopChan)
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		logrus.Info("Rebalancer stopped gracefully.")
+	case <-ctx.Done():
+		logrus.Warn("Rebalancer stop timed out.")
+	}
+}
+
+func (r *Rebalancer) runLoop() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(rebalanceCheckInterval)
+	defer ticker.Stop()
+
+	lastRun := time.Now()
+	for {
+		select {
+		case <-ticker.C:
+			interval := time.Duration(r.SettingsManager.GetSettings().KeyRebalanceIntervalMinutes) * time.Minute
+			if interval > 0 && time.Since(lastRun) >= interval {
+				r.rebalance()
+				lastRun = time.Now()
+			}
+		case <-r.stopChan:
+			return
+		}
+	}
+}
+
+// rebalance shuffles the active key list of every group, except groups with stable_order.
+func (r *Rebalancer) rebalance() {
+	var groups []models.Group
+	if err := r.DB.Select("id, config").Find(&groups).Error; err != nil {
+		logrus.Errorf("Rebalancer: Failed to get groups: %v", err)
+		return
+	}
+
+	shuffled := 0
+	for _, group := range groups {
+		if groupOptions, err := utils.ParseGroupConfig(group.Config); err == nil && groupOptions.StableOrder {
+			continue
+		}
+		if err := r.KeyProvider.ShuffleActiveKeys(group.ID); err != nil {
+			logrus.WithFields(logrus.Fields{"groupID": group.ID, "error": err}).Error("Rebalancer: Failed to shuffle active keys")
+			continue
+		}
+		shuffled++
+	}
+	logrus.Debugf("Rebalancer: Shuffled the active keys of %d groups.", shuffled)
+}
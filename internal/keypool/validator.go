@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"gpt-load/internal/channel"
 	"gpt-load/internal/config"
+	"gpt-load/internal/encryption"
 	"gpt-load/internal/models"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -13,6 +15,9 @@ import (
 	"gorm.io/gorm"
 )
 
+// MaxKeyTestConcurrency caps the concurrency a manual key test may ask for.
+const MaxKeyTestConcurrency = 50
+
 // KeyTestResult holds the validation result for a single key.
 type KeyTestResult struct {
 	KeyValue string `json:"key_value"`
@@ -20,12 +25,21 @@ type KeyTestResult struct {
 	Error    string `json:"error,omitempty"`
 }
 
+// GroupValidationResult holds the result of a live validation of a group's configuration.
+type GroupValidationResult struct {
+	// Skipped is set when the group could not be validated, e.g. it has no active key yet.
+	Skipped bool   `json:"skipped"`
+	IsValid bool   `json:"is_valid"`
+	Error   string `json:"error,omitempty"`
+}
+
 // KeyValidator provides methods to validate API keys.
 type KeyValidator struct {
 	DB              *gorm.DB
 	channelFactory  *channel.Factory
 	SettingsManager *config.SystemSettingsManager
 	keypoolProvider *KeyProvider
+	encryption      encryption.Service
 }
 
 type KeyValidatorParams struct {
@@ -34,6 +48,7 @@ type KeyValidatorParams struct {
 	ChannelFactory  *channel.Factory
 	SettingsManager *config.SystemSettingsManager
 	KeypoolProvider *KeyProvider
+	Encryption      encryption.Service
 }
 
 // NewKeyValidator creates a new KeyValidator.
@@ -43,6 +58,7 @@ func NewKeyValidator(params KeyValidatorParams) *KeyValidator {
 		channelFactory:  params.ChannelFactory,
 		SettingsManager: params.SettingsManager,
 		keypoolProvider: params.KeypoolProvider,
+		encryption:      params.Encryption,
 	}
 }
 
@@ -59,9 +75,18 @@ func (s *KeyValidator) ValidateSingleKey(key *models.APIKey, group *models.Group
 		return false, fmt.Errorf("failed to get channel for group %s: %w", group.Name, err)
 	}
 
-	isValid, validationErr := ch.ValidateKey(ctx, key.KeyValue)
+	keyValue, err := s.encryption.Decrypt(key.KeyValue)
+	if err != nil {
+		return false, fmt.Errorf("failed to decrypt key %d: %w", key.ID, err)
+	}
 
-	s.keypoolProvider.UpdateStatus(key, group, isValid)
+	isValid, validationErr := ch.ValidateKey(ctx, keyValue)
+
+	var reason string
+	if validationErr != nil {
+		reason = "validation failed: " + validationErr.Error()
+	}
+	s.keypoolProvider.UpdateStatus(key, group, isValid, reason)
 
 	if !isValid {
 		logrus.WithFields(logrus.Fields{
@@ -80,42 +105,112 @@ func (s *KeyValidator) ValidateSingleKey(key *models.APIKey, group *models.Group
 	return true, nil
 }
 
-// TestMultipleKeys performs a synchronous validation for a list of key values within a specific group.
-func (s *KeyValidator) TestMultipleKeys(group *models.Group, keyValues []string) ([]KeyTestResult, error) {
+// TestMultipleKeys performs a synchronous validation for a list of key values within a specific group,
+// testing up to concurrency keys at a time. A concurrency of zero uses the group's key_validation_concurrency.
+func (s *KeyValidator) TestMultipleKeys(group *models.Group, keyValues []string, concurrency int) ([]KeyTestResult, error) {
 	results := make([]KeyTestResult, len(keyValues))
 
 	// Find which of the provided keys actually exist in the database for this group
 	var existingKeys []models.APIKey
-	if err := s.DB.Where("group_id = ? AND key_value IN ?", group.ID, keyValues).Find(&existingKeys).Error; err != nil {
+	if err := s.DB.Where("group_id = ? AND key_value IN ?", group.ID, s.encryption.LookupValues(keyValues)).Find(&existingKeys).Error; err != nil {
 		return nil, fmt.Errorf("failed to query keys from DB: %w", err)
 	}
 	existingKeyMap := make(map[string]models.APIKey)
 	for _, k := range existingKeys {
-		existingKeyMap[k.KeyValue] = k
+		keyValue, err := s.encryption.Decrypt(k.KeyValue)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt key %d: %w", k.ID, err)
+		}
+		existingKeyMap[keyValue] = k
+	}
+
+	// Resolve the effective config once, as ValidateSingleKey would otherwise fill it in from every worker.
+	if group.EffectiveConfig.AppUrl == "" {
+		group.EffectiveConfig = s.SettingsManager.GetEffectiveConfig(group.Config)
 	}
+	if concurrency <= 0 {
+		concurrency = group.EffectiveConfig.KeyValidationConcurrency
+	}
+	concurrency = max(min(concurrency, MaxKeyTestConcurrency, len(keyValues)), 1)
 
-	for i, kv := range keyValues {
-		apiKey, exists := existingKeyMap[kv]
-		if !exists {
-			results[i] = KeyTestResult{
-				KeyValue: kv,
-				IsValid:  false,
-				Error:    "Key does not exist in this group or has been removed.",
+	jobs := make(chan int, len(keyValues))
+	for i := range keyValues {
+		jobs <- i
+	}
+	close(jobs)
+
+	var wg sync.WaitGroup
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = s.testKey(group, keyValues[i], existingKeyMap)
 			}
-			continue
-		}
+		}()
+	}
+	wg.Wait()
 
-		isValid, validationErr := s.ValidateSingleKey(&apiKey, group)
+	return results, nil
+}
 
-		results[i] = KeyTestResult{
+// testKey validates a single key value of a manual test against its stored key.
+func (s *KeyValidator) testKey(group *models.Group, kv string, existingKeyMap map[string]models.APIKey) KeyTestResult {
+	apiKey, exists := existingKeyMap[kv]
+	if !exists {
+		return KeyTestResult{
 			KeyValue: kv,
-			IsValid:  isValid,
-			Error:    "",
+			IsValid:  false,
+			Error:    "Key does not exist in this group or has been removed.",
 		}
-		if validationErr != nil {
-			results[i].Error = validationErr.Error()
+	}
+
+	isValid, validationErr := s.ValidateSingleKey(&apiKey, group)
+
+	result := KeyTestResult{
+		KeyValue: kv,
+		IsValid:  isValid,
+	}
+	if validationErr != nil {
+		result.Error = validationErr.Error()
+	}
+	return result
+}
+
+// ValidateGroupConfig makes a single live validation call with keyValue or, when it is empty, one of
+// the group's active keys, using the group as given rather than as saved. Neither the key's status
+// nor the cached channel of the group is changed.
+func (s *KeyValidator) ValidateGroupConfig(group *models.Group, keyValue string) *GroupValidationResult {
+	if group.ChannelType == channel.VirtualChannelType {
+		return &GroupValidationResult{Skipped: true, Error: "virtual groups have no upstream to validate"}
+	}
+
+	if keyValue == "" {
+		// A group being created has no keys yet.
+		var key models.APIKey
+		if group.ID == 0 || s.DB.Where("group_id = ? AND status = ?", group.ID, models.KeyStatusActive).First(&key).Error != nil {
+			return &GroupValidationResult{Skipped: true, Error: "the group has no active key to validate with"}
+		}
+		var err error
+		if keyValue, err = s.encryption.Decrypt(key.KeyValue); err != nil {
+			return &GroupValidationResult{Error: fmt.Sprintf("failed to decrypt key %d: %v", key.ID, err)}
 		}
 	}
 
-	return results, nil
+	validationGroup := *group
+	validationGroup.EffectiveConfig = s.SettingsManager.GetEffectiveConfig(group.Config)
+	ch, err := s.channelFactory.NewChannel(&validationGroup)
+	if err != nil {
+		return &GroupValidationResult{Error: err.Error()}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(validationGroup.EffectiveConfig.KeyValidationTimeoutSeconds)*time.Second)
+	defer cancel()
+
+	isValid, validationErr := ch.ValidateKey(ctx, keyValue)
+	result := &GroupValidationResult{IsValid: isValid}
+	if !isValid && validationErr != nil {
+		result.Error = validationErr.Error()
+	}
+	return result
 }
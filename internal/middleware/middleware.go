@@ -2,14 +2,18 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
+	"gpt-load/internal/config"
 	app_errors "gpt-load/internal/errors"
 	"gpt-load/internal/response"
 	"gpt-load/internal/services"
 	"gpt-load/internal/types"
+	"gpt-load/internal/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
@@ -132,42 +136,90 @@ func Auth(authConfig types.AuthConfig) gin.HandlerFunc {
 			return
 		}
 
+		c.Set(AuthActorContextKey, authActor(key))
 		c.Next()
 	}
 }
 
+// AuthActorContextKey holds the identity of the admin a request authenticated as, for the audit log.
+const AuthActorContextKey = "authActor"
+
+// authActor identifies the admin by a short fingerprint of the auth key, so changes made
+// before and after a key rotation can be told apart without storing the key.
+func authActor(key string) string {
+	return "admin:" + utils.HashKey(key)[:8]
+}
+
+// ProxyKeyHashContextKey holds the hash of the proxy key a request authenticated with.
+const ProxyKeyHashContextKey = "proxyKeyHash"
+
 // ProxyAuth
-func ProxyAuth(gm *services.GroupManager) gin.HandlerFunc {
+func ProxyAuth(gm *services.GroupManager, quotaService *services.ProxyKeyQuotaService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Check key
 		key := extractAuthKey(c)
 		if key == "" {
-			response.Error(c, app_errors.ErrUnauthorized)
+			response.ProxyError(c, app_errors.ErrUnauthorized)
 			c.Abort()
 			return
 		}
 
 		group, err := gm.GetGroupByName(c.Param("group_name"))
 		if err != nil {
-			response.Error(c, app_errors.NewAPIError(app_errors.ErrInternalServer, "Failed to retrieve proxy group"))
+			response.ProxyError(c, app_errors.NewAPIError(app_errors.ErrInternalServer, "Failed to retrieve proxy group"))
 			c.Abort()
 			return
 		}
 
-		// Then check System-wide keys (O(1) lookup)
-		if _, ok := group.EffectiveConfig.ProxyKeysMap[key]; ok {
-			c.Next()
+		_, isSystemKey := group.EffectiveConfig.ProxyKeysMap[key]
+		_, isGroupKey := group.ProxyKeysMap[key]
+		if !isSystemKey && !isGroupKey {
+			response.ProxyError(c, app_errors.ErrUnauthorized)
+			c.Abort()
 			return
 		}
 
-		// Check Group keys first (O(1) lookup)
-		if _, ok := group.ProxyKeysMap[key]; ok {
-			c.Next()
+		c.Set(ProxyKeyHashContextKey, utils.HashKey(key))
+
+		if err := quotaService.Consume(key); err != nil {
+			var quotaErr *services.QuotaExceededError
+			if errors.As(err, &quotaErr) {
+				c.Header("Retry-After", strconv.Itoa(int(time.Until(quotaErr.ResetAt).Seconds())+1))
+				response.ProxyError(c, app_errors.NewAPIError(app_errors.ErrQuotaExceeded, fmt.Sprintf("Proxy key %s", quotaErr.Error())))
+				c.Abort()
+				return
+			}
+			// Don't block traffic because the store is unavailable.
+			logrus.Warnf("Failed to check proxy key quota: %v", err)
+		}
+
+		c.Next()
+	}
+}
+
+// DefaultGroup routes requests on bare API paths, such as /v1/chat/completions, to the group named
+// in the default_group setting, as if they had been sent to /proxy/<default_group>/v1/chat/completions.
+func DefaultGroup(settingsManager *config.SystemSettingsManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupName := settingsManager.GetSettings().DefaultGroup
+		if groupName == "" {
+			response.ProxyError(c, app_errors.ErrResourceNotFound)
+			c.Abort()
 			return
 		}
 
-		response.Error(c, app_errors.ErrUnauthorized)
-		c.Abort()
+		params := make(gin.Params, 0, len(c.Params)+2)
+		for _, param := range c.Params {
+			if param.Key != "group_name" && param.Key != "path" {
+				params = append(params, param)
+			}
+		}
+		c.Params = append(params,
+			gin.Param{Key: "group_name", Value: groupName},
+			gin.Param{Key: "path", Value: c.Request.URL.Path},
+		)
+
+		c.Next()
 	}
 }
 
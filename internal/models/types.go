@@ -25,17 +25,112 @@ type SystemSetting struct {
 
 // GroupConfig 存储特定于分组的配置
 type GroupConfig struct {
-	RequestTimeout               *int `json:"request_timeout,omitempty"`
-	IdleConnTimeout              *int `json:"idle_conn_timeout,omitempty"`
-	ConnectTimeout               *int `json:"connect_timeout,omitempty"`
-	MaxIdleConns                 *int `json:"max_idle_conns,omitempty"`
-	MaxIdleConnsPerHost          *int `json:"max_idle_conns_per_host,omitempty"`
-	ResponseHeaderTimeout        *int `json:"response_header_timeout,omitempty"`
-	MaxRetries                   *int `json:"max_retries,omitempty"`
-	BlacklistThreshold           *int `json:"blacklist_threshold,omitempty"`
-	KeyValidationIntervalMinutes *int `json:"key_validation_interval_minutes,omitempty"`
-	KeyValidationConcurrency     *int `json:"key_validation_concurrency,omitempty"`
-	KeyValidationTimeoutSeconds  *int `json:"key_validation_timeout_seconds,omitempty"`
+	RequestTimeout               *int    `json:"request_timeout,omitempty"`
+	IdleConnTimeout              *int    `json:"idle_conn_timeout,omitempty"`
+	ConnectTimeout               *int    `json:"connect_timeout,omitempty"`
+	MaxIdleConns                 *int    `json:"max_idle_conns,omitempty"`
+	MaxIdleConnsPerHost          *int    `json:"max_idle_conns_per_host,omitempty"`
+	ResponseHeaderTimeout        *int    `json:"response_header_timeout,omitempty"`
+	StreamPoolMultiplier         *int    `json:"stream_pool_multiplier,omitempty"`
+	WriteBufferSize              *int    `json:"write_buffer_size,omitempty"`
+	ReadBufferSize               *int    `json:"read_buffer_size,omitempty"`
+	MaxRetries                   *int    `json:"max_retries,omitempty"`
+	BlacklistThreshold           *int    `json:"blacklist_threshold,omitempty"`
+	KeyValidationIntervalMinutes *int    `json:"key_validation_interval_minutes,omitempty"`
+	KeyValidationConcurrency     *int    `json:"key_validation_concurrency,omitempty"`
+	KeyValidationTimeoutSeconds  *int    `json:"key_validation_timeout_seconds,omitempty"`
+	UpstreamProxyURL             *string `json:"upstream_proxy_url,omitempty"`
+	UpstreamUserAgent            *string `json:"upstream_user_agent,omitempty"`
+	MaxResponseBodyBytes         *int    `json:"max_response_body_bytes,omitempty"`
+	MaintenanceMode              *bool   `json:"maintenance_mode,omitempty"`
+	MaintenanceStatusCode        *int    `json:"maintenance_status_code,omitempty"`
+	MaintenanceMessage           *string `json:"maintenance_message,omitempty"`
+
+	// 以下为分组专属配置，没有对应的系统设置
+	AzureAPIVersion  string            `json:"azure_api_version,omitempty"`
+	AzureDeployments map[string]string `json:"azure_deployments,omitempty"`
+	// 独立连接池：开启后分组使用专属的 HTTP 连接池，避免与其他分组争抢空闲连接，但会增加总连接数
+	IsolatedConnectionPool bool `json:"isolated_connection_pool,omitempty"`
+	// 双向 TLS：客户端证书、私钥及自定义 CA，可填写 PEM 内容或文件路径
+	TLSClientCert string `json:"tls_client_cert,omitempty"`
+	TLSClientKey  string `json:"tls_client_key,omitempty"`
+	TLSCACert     string `json:"tls_ca_cert,omitempty"`
+	// 跳过上游证书校验，仅用于测试自签名网关，必须在分组中显式开启
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+	// 备用分组：当前分组没有可用密钥时，请求转由该分组处理
+	FallbackGroupName string `json:"fallback_group_name,omitempty"`
+	// 每日请求配额：按显示时区的自然日计数，超出后返回 429 直到次日重置，0 为不限制
+	DailyRequestQuota int `json:"daily_request_quota,omitempty"`
+	// 跟随上游重定向：默认不跟随，3xx 响应原样处理
+	FollowRedirects bool `json:"follow_redirects,omitempty"`
+	// 成功状态码：逗号分隔的状态码或范围（如 200-299,304），为空时小于 400 的状态码视为成功
+	SuccessStatusCodes string `json:"success_status_codes,omitempty"`
+	// 恢复 Key 预热时长（秒）：恢复的 Key 以较低概率被选中，在该时长内逐步提升到正常，0 为不预热
+	KeyWarmUpSeconds int `json:"key_warmup_seconds,omitempty"`
+	// 兜底探测间隔（秒）：所有 Key 均失效时，每隔该时长取最久未失败的失效 Key 尝试一次，成功则恢复，0 为不探测
+	LastResortProbeSeconds int `json:"last_resort_probe_seconds,omitempty"`
+	// 记录请求体：将该分组请求和响应的完整内容（截断到固定大小）保存一天，用于排查问题，包含提示词等敏感数据，默认关闭
+	LogBodies bool `json:"log_bodies,omitempty"`
+	// 记录请求体时脱敏的字段：以 . 分隔的路径，如 messages、metadata.user_id，对应的值保存为 [REDACTED]
+	LogBodiesRedactFields []string `json:"log_bodies_redact_fields,omitempty"`
+	// 影子分组：按 shadow_percent 的比例将请求异步复制一份发送到该分组，丢弃其响应，用于在真实流量下测试新上游，不影响客户端
+	ShadowGroup string `json:"shadow_group,omitempty"`
+	// 影子请求比例（0-100）：复制到影子分组的请求百分比
+	ShadowPercent int `json:"shadow_percent,omitempty"`
+	// 影子对比：比较主请求与影子请求的响应，状态码或 shadow_compare_fields 中的字段不一致时记录到对比结果表，按 shadow_percent 抽样，默认关闭
+	ShadowCompare bool `json:"shadow_compare,omitempty"`
+	// 影子对比的 JSON 字段：以 . 分隔的路径，数组元素用下标，如 model、choices.0.finish_reason，仅比较非流式响应
+	ShadowCompareFields []string `json:"shadow_compare_fields,omitempty"`
+	// 回显模式：不选择 Key、不请求上游，直接返回模拟的 OpenAI 格式响应（回显最后一条用户消息），流式请求返回几段模拟的 SSE 数据，用于接入测试
+	EchoMode bool `json:"echo_mode,omitempty"`
+	// 合并相同请求：方法、路径、请求体等完全相同的并发非流式请求共享一次上游调用和同一个响应，仅适用于幂等的请求，默认关闭
+	CoalesceRequests bool `json:"coalesce_requests,omitempty"`
+	// 合并请求的大小上限（字节）：请求体或响应体超过该值时不合并，0 为默认的 1MB
+	CoalesceMaxBytes int `json:"coalesce_max_bytes,omitempty"`
+	// 转换 Webhook：转发前将请求（方法、路径、请求头、请求体）POST 到该地址，按返回的内容修改后再转发，非流式响应同样在返回客户端前经过该地址，每次调用都会增加延迟
+	TransformWebhookURL string `json:"transform_webhook_url,omitempty"`
+	// 转换 Webhook 超时（毫秒）：0 为默认的 1000 毫秒
+	TransformWebhookTimeoutMs int `json:"transform_webhook_timeout_ms,omitempty"`
+	// 转换 Webhook 失败时拒绝请求：默认失败时按原样转发，开启后返回 502
+	TransformWebhookFailClosed bool `json:"transform_webhook_fail_closed,omitempty"`
+	// 会话重试预算：同一 X-Session-Id 在窗口内最多重试的次数，用完后该会话的请求不再重试，直到窗口过期，0 为不限制
+	SessionRetryBudget int `json:"session_retry_budget,omitempty"`
+	// 会话重试窗口（秒）：会话最后一次重试后重试计数保留的时长，0 为默认的 300 秒
+	SessionRetryWindowSeconds int `json:"session_retry_window_seconds,omitempty"`
+	// 稳定顺序：可用 Key 始终按 ID 升序循环轮询，不参与定期重排；添加或恢复 Key 后轮询从 ID 最小的 Key 重新开始
+	StableOrder bool `json:"stable_order,omitempty"`
+	// 移除参数：转发前从请求体中删除这些参数，支持以 . 分隔的嵌套路径，如 user、metadata.user_id
+	RemoveParams []string `json:"remove_params,omitempty"`
+	// 强制参数：转发前按路径设置这些参数，覆盖客户端发送的值，如 {"stream_options.include_usage": true}
+	ForceParams map[string]any `json:"force_params,omitempty"`
+	// 流式用量统计：流式请求未指定 stream_options.include_usage 时自动开启，以便记录流式请求的 Token 用量，仅 OpenAI 和 Azure 分组生效
+	IncludeStreamUsage bool `json:"include_stream_usage,omitempty"`
+	// 强制系统提示词：对带 messages 的对话请求注入系统提示词，不论客户端是否发送
+	ForceSystemPrompt *ForceSystemPrompt `json:"force_system_prompt,omitempty"`
+	// 静态模型列表：配置后模型列表请求直接返回该列表，不再请求上游
+	StaticModels []string `json:"static_models,omitempty"`
+	// 自定义错误提示：替换返回给客户端的常见错误的提示文字，服务日志仍记录详细错误
+	ErrorMessages *ErrorMessages `json:"error_messages,omitempty"`
+}
+
+// ErrorMessages 是分组自定义的客户端错误提示，为空的项使用默认提示
+type ErrorMessages struct {
+	NoKeys      string `json:"no_keys,omitempty"`      // 没有可用的 Key
+	RateLimited string `json:"rate_limited,omitempty"` // 超出每日请求配额，或上游最终返回 429
+	MaxRetries  string `json:"max_retries,omitempty"`  // 重试次数用完后仍然失败
+}
+
+// 强制系统提示词的注入方式
+const (
+	SystemPromptModePrepend = "prepend" // 加在客户端系统提示词之前
+	SystemPromptModeReplace = "replace" // 替换客户端系统提示词
+	SystemPromptModeAppend  = "append"  // 加在客户端系统提示词之后
+)
+
+// ForceSystemPrompt 是分组强制注入的系统提示词
+type ForceSystemPrompt struct {
+	Mode    string `json:"mode"`
+	Content string `json:"content"`
 }
 
 // Group 对应 groups 表
@@ -56,43 +151,97 @@ type Group struct {
 	Config             datatypes.JSONMap    `gorm:"type:json" json:"config"`
 	APIKeys            []APIKey             `gorm:"foreignKey:GroupID" json:"api_keys"`
 	LastValidatedAt    *time.Time           `json:"last_validated_at"`
+	Draining           bool                 `gorm:"not null;default:false" json:"draining"` // 排空中：不再分配该分组的 Key，也不定时验证，Key 保留在数据库中
 	CreatedAt          time.Time            `json:"created_at"`
 	UpdatedAt          time.Time            `json:"updated_at"`
 
 	// For cache
 	ProxyKeysMap map[string]struct{} `gorm:"-" json:"-"`
+	Options      GroupConfig         `gorm:"-" json:"-"` // 解析后的 Config，由 GroupManager 加载时填充，代理请求直接读取
 }
 
 // APIKey 对应 api_keys 表
 type APIKey struct {
-	ID           uint       `gorm:"primaryKey;autoIncrement" json:"id"`
-	KeyValue     string     `gorm:"type:varchar(700);not null;uniqueIndex:idx_group_key" json:"key_value"`
-	GroupID      uint       `gorm:"not null;uniqueIndex:idx_group_key" json:"group_id"`
-	Status       string     `gorm:"type:varchar(50);not null;default:'active'" json:"status"`
-	RequestCount int64      `gorm:"not null;default:0" json:"request_count"`
-	FailureCount int64      `gorm:"not null;default:0" json:"failure_count"`
-	LastUsedAt   *time.Time `json:"last_used_at"`
-	CreatedAt    time.Time  `json:"created_at"`
-	UpdatedAt    time.Time  `json:"updated_at"`
+	ID            uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	KeyValue      string     `gorm:"type:varchar(700);not null;uniqueIndex:idx_group_key" json:"key_value"`
+	GroupID       uint       `gorm:"not null;uniqueIndex:idx_group_key" json:"group_id"`
+	Status        string     `gorm:"type:varchar(50);not null;default:'active'" json:"status"`
+	RequestCount  int64      `gorm:"not null;default:0" json:"request_count"`
+	FailureCount  int64      `gorm:"not null;default:0" json:"failure_count"`
+	InvalidReason string     `gorm:"type:varchar(500)" json:"invalid_reason,omitempty"`
+	LastUsedAt    *time.Time `json:"last_used_at"`
+	LastSuccessAt *time.Time `json:"last_success_at"`
+	LastFailureAt *time.Time `json:"last_failure_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
 }
 
 // RequestLog 对应 request_logs 表
 type RequestLog struct {
-	ID           string    `gorm:"type:varchar(36);primaryKey" json:"id"`
-	Timestamp    time.Time `gorm:"not null;index" json:"timestamp"`
-	GroupID      uint      `gorm:"not null;index" json:"group_id"`
-	GroupName    string    `gorm:"type:varchar(255);index" json:"group_name"`
-	KeyValue     string    `gorm:"type:varchar(700)" json:"key_value"`
-	IsSuccess    bool      `gorm:"not null" json:"is_success"`
-	SourceIP     string    `gorm:"type:varchar(64)" json:"source_ip"`
-	StatusCode   int       `gorm:"not null" json:"status_code"`
-	RequestPath  string    `gorm:"type:varchar(500)" json:"request_path"`
-	Duration     int64     `gorm:"not null" json:"duration_ms"`
-	ErrorMessage string    `gorm:"type:text" json:"error_message"`
-	UserAgent    string    `gorm:"type:varchar(512)" json:"user_agent"`
-	Retries      int       `gorm:"not null" json:"retries"`
-	UpstreamAddr string    `gorm:"type:varchar(500)" json:"upstream_addr"`
-	IsStream     bool      `gorm:"not null" json:"is_stream"`
+	ID                string    `gorm:"type:varchar(36);primaryKey" json:"id"`
+	Timestamp         time.Time `gorm:"not null;index" json:"timestamp"`
+	GroupID           uint      `gorm:"not null;index" json:"group_id"`
+	GroupName         string    `gorm:"type:varchar(255);index" json:"group_name"`
+	KeyValue          string    `gorm:"type:varchar(700)" json:"key_value"`                  // 脱敏后的密钥，日志中从不保存明文
+	APIKeyID          uint      `gorm:"not null;default:0;index" json:"api_key_id"`          // 请求所用密钥的 ID，密钥被删除后不再对应任何密钥
+	KeyHash           string    `gorm:"type:varchar(64);not null;default:'';index" json:"-"` // 密钥明文的 SHA-256，用于按完整密钥查询和统计
+	IsSuccess         bool      `gorm:"not null" json:"is_success"`
+	SourceIP          string    `gorm:"type:varchar(64)" json:"source_ip"`
+	StatusCode        int       `gorm:"not null" json:"status_code"`
+	RequestPath       string    `gorm:"type:varchar(500)" json:"request_path"`
+	Duration          int64     `gorm:"not null" json:"duration_ms"`
+	UpstreamDuration  int64     `gorm:"not null;default:0" json:"upstream_duration_ms"` // 成功请求中上游 client.Do 的耗时
+	ErrorMessage      string    `gorm:"type:text" json:"error_message"`
+	UserAgent         string    `gorm:"type:varchar(512)" json:"user_agent"`
+	Retries           int       `gorm:"not null" json:"retries"`
+	UpstreamAddr      string    `gorm:"type:varchar(500)" json:"upstream_addr"`
+	IsStream          bool      `gorm:"not null" json:"is_stream"`
+	Model             string    `gorm:"type:varchar(255)" json:"model"`
+	ProxyKeyHash      string    `gorm:"type:varchar(64)" json:"proxy_key_hash,omitempty"`  // 请求所用代理密钥的哈希，用于按代理密钥统计
+	IsClientCancelled bool      `gorm:"not null;default:false" json:"is_client_cancelled"` // 客户端在响应完成前断开，不计入失败统计
+	PromptTokens      int       `gorm:"not null;default:0" json:"prompt_tokens"`           // 上游响应中报告的 Token 用量，未报告时为 0
+	CompletionTokens  int       `gorm:"not null;default:0" json:"completion_tokens"`
+	IsShadow          bool      `gorm:"not null;default:false" json:"is_shadow"` // 由 shadow_group 镜像发出的请求，客户端不会收到其响应
+}
+
+// RequestBodyLog 对应 request_body_logs 表，保存开启 log_bodies 的分组的请求和响应内容，过期后自动清理
+type RequestBodyLog struct {
+	ID                uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Timestamp         time.Time `gorm:"not null;index" json:"timestamp"`
+	RequestLogID      string    `gorm:"type:varchar(36);index" json:"request_log_id"`
+	GroupID           uint      `gorm:"not null;index" json:"group_id"`
+	GroupName         string    `gorm:"type:varchar(255)" json:"group_name"`
+	RequestPath       string    `gorm:"type:varchar(500)" json:"request_path"`
+	StatusCode        int       `gorm:"not null" json:"status_code"`
+	RequestBody       string    `gorm:"type:text" json:"request_body"`
+	RequestTruncated  bool      `gorm:"not null;default:false" json:"request_truncated"`
+	ResponseBody      string    `gorm:"type:text" json:"response_body"`
+	ResponseTruncated bool      `gorm:"not null;default:false" json:"response_truncated"`
+}
+
+// ShadowComparison 对应 shadow_comparisons 表，记录开启 shadow_compare 的分组中主请求与影子请求响应不一致的结果
+type ShadowComparison struct {
+	ID              uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Timestamp       time.Time `gorm:"not null;index" json:"timestamp"`
+	GroupID         uint      `gorm:"not null;index" json:"group_id"`
+	GroupName       string    `gorm:"type:varchar(255)" json:"group_name"`
+	ShadowGroupID   uint      `gorm:"not null;index" json:"shadow_group_id"`
+	ShadowGroupName string    `gorm:"type:varchar(255)" json:"shadow_group_name"`
+	RequestPath     string    `gorm:"type:varchar(500)" json:"request_path"`
+	Model           string    `gorm:"type:varchar(255)" json:"model"`
+	IsStream        bool      `gorm:"not null;default:false" json:"is_stream"`
+	PrimaryStatus   int       `gorm:"not null" json:"primary_status"`
+	ShadowStatus    int       `gorm:"not null" json:"shadow_status"`
+	// 是否比较了 JSON 字段：流式响应、响应体过大或不是 JSON 时只比较状态码
+	FieldsCompared bool                                   `gorm:"not null;default:false" json:"fields_compared"`
+	Differences    datatypes.JSONType[[]ShadowDifference] `gorm:"type:json" json:"differences"`
+}
+
+// ShadowDifference 是主请求与影子请求响应中不一致的一项，值为 JSON 编码后的文本（截断），字段不存在时为空
+type ShadowDifference struct {
+	Field   string `json:"field"`
+	Primary string `json:"primary"`
+	Shadow  string `json:"shadow"`
 }
 
 // StatCard 用于仪表盘的单个统计卡片数据
@@ -127,11 +276,85 @@ type ChartData struct {
 
 // GroupHourlyStat 对应 group_hourly_stats 表，用于存储每个分组每小时的请求统计
 type GroupHourlyStat struct {
+	ID             uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Time           time.Time `gorm:"not null;uniqueIndex:idx_group_time" json:"time"` // 整点时间
+	GroupID        uint      `gorm:"not null;uniqueIndex:idx_group_time" json:"group_id"`
+	SuccessCount   int64     `gorm:"not null;default:0" json:"success_count"`
+	FailureCount   int64     `gorm:"not null;default:0" json:"failure_count"`
+	CancelledCount int64     `gorm:"not null;default:0" json:"cancelled_count"` // 客户端取消的请求，不计入成功或失败
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// UsageHourlyStat 对应 usage_hourly_stats 表，按分组、代理密钥和模型存储每小时的请求统计，用于用量报表
+type UsageHourlyStat struct {
+	ID               uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Time             time.Time `gorm:"not null;uniqueIndex:idx_usage_hour" json:"time"` // 整点时间
+	GroupID          uint      `gorm:"not null;uniqueIndex:idx_usage_hour" json:"group_id"`
+	ProxyKeyHash     string    `gorm:"type:varchar(64);not null;default:'';uniqueIndex:idx_usage_hour" json:"-"`
+	Model            string    `gorm:"type:varchar(255);not null;default:'';uniqueIndex:idx_usage_hour" json:"model"`
+	SuccessCount     int64     `gorm:"not null;default:0" json:"success_count"`
+	FailureCount     int64     `gorm:"not null;default:0" json:"failure_count"`
+	PromptTokens     int64     `gorm:"not null;default:0" json:"prompt_tokens"` // 上游报告的 Token 用量之和
+	CompletionTokens int64     `gorm:"not null;default:0" json:"completion_tokens"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// KeyDailyStat 对应 key_daily_stats 表，用于存储每个密钥每天的请求统计，不受日志清理影响
+type KeyDailyStat struct {
 	ID           uint      `gorm:"primaryKey;autoIncrement" json:"id"`
-	Time         time.Time `gorm:"not null;uniqueIndex:idx_group_time" json:"time"` // 整点时间
-	GroupID      uint      `gorm:"not null;uniqueIndex:idx_group_time" json:"group_id"`
+	Day          time.Time `gorm:"not null;uniqueIndex:idx_key_day" json:"day"` // 当天零点
+	GroupID      uint      `gorm:"not null;uniqueIndex:idx_key_day" json:"group_id"`
+	KeyHash      string    `gorm:"type:varchar(64);not null;uniqueIndex:idx_key_day" json:"-"`
 	SuccessCount int64     `gorm:"not null;default:0" json:"success_count"`
 	FailureCount int64     `gorm:"not null;default:0" json:"failure_count"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
+
+// 审计日志的操作类型
+const (
+	AuditActionSettingsUpdate        = "settings.update"
+	AuditActionSettingsImport        = "settings.import"
+	AuditActionGroupCreate           = "group.create"
+	AuditActionGroupUpdate           = "group.update"
+	AuditActionGroupDelete           = "group.delete"
+	AuditActionGroupDrain            = "group.drain"
+	AuditActionKeysAdd               = "keys.add"
+	AuditActionKeysImport            = "keys.import"
+	AuditActionKeysReplace           = "keys.replace_all"
+	AuditActionKeysDelete            = "keys.delete"
+	AuditActionKeysRestore           = "keys.restore"
+	AuditActionKeysSetStatus         = "keys.set_status"
+	AuditActionKeysRestoreAllInvalid = "keys.restore_all_invalid"
+	AuditActionKeysClearAllInvalid   = "keys.clear_all_invalid"
+)
+
+// 审计日志的操作对象类型
+const (
+	AuditTargetSettings = "settings"
+	AuditTargetGroup    = "group"
+	AuditTargetKeys     = "keys"
+)
+
+// AuditLog 对应 audit_logs 表，记录管理接口对系统设置、分组和密钥的变更，其中的密钥均已脱敏
+type AuditLog struct {
+	ID         uint              `gorm:"primaryKey;autoIncrement" json:"id"`
+	Timestamp  time.Time         `gorm:"not null;index" json:"timestamp"`
+	Actor      string            `gorm:"type:varchar(255);not null;index" json:"actor"`
+	SourceIP   string            `gorm:"type:varchar(64)" json:"source_ip"`
+	Action     string            `gorm:"type:varchar(64);not null;index" json:"action"`
+	TargetType string            `gorm:"type:varchar(32);not null;index" json:"target_type"`
+	TargetID   uint              `gorm:"index" json:"target_id,omitempty"`               // 分组 ID，密钥操作时为所属分组
+	TargetName string            `gorm:"type:varchar(255)" json:"target_name,omitempty"` // 分组名称，密钥操作时为所属分组
+	Before     datatypes.JSONMap `gorm:"type:json" json:"before,omitempty"`              // 变更前的值，仅包含变更的字段
+	After      datatypes.JSONMap `gorm:"type:json" json:"after,omitempty"`               // 变更后的值，仅包含变更的字段
+	Details    datatypes.JSONMap `gorm:"type:json" json:"details,omitempty"`             // 操作结果，如密钥操作的数量和脱敏后的密钥
+}
+
+// SchemaMigration 对应 schema_migrations 表，记录已执行的数据迁移，每个迁移只执行一次
+type SchemaMigration struct {
+	Version   string    `gorm:"type:varchar(64);primaryKey" json:"version"`
+	AppliedAt time.Time `gorm:"not null" json:"applied_at"`
+}
@@ -0,0 +1,376 @@
+// Package openapi builds an OpenAPI 3 document of the admin API. Paths come from the registered
+// routes and schemas from the Go types of request and response payloads, so the document follows
+// the code.
+package openapi
+
+import (
+	"encoding/json"
+	"path"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Param is a query parameter of an operation.
+type Param struct {
+	Name        string
+	Type        string // JSON schema type, "string" when empty
+	Description string
+	Required    bool
+}
+
+// Operation describes an API endpoint beyond its method and path.
+type Operation struct {
+	Summary string
+	Tag     string
+	Query   []Param
+	// Request is a value of the JSON request body type, or nil without a body.
+	Request any
+	// Response is a value of the type of the data field of the success envelope. Nil documents the
+	// data as any JSON value.
+	Response any
+	// Paginated wraps Response, the item type, in a paginated list.
+	Paginated bool
+	// Raw means Response is written as is, without the success envelope.
+	Raw bool
+	// ContentType is the media type of a response that is not JSON, such as text/csv.
+	ContentType string
+	// Public means the endpoint needs no auth key.
+	Public bool
+}
+
+// Route is a registered route, in gin path syntax.
+type Route struct {
+	Method string
+	Path   string
+}
+
+// Info is the title and version of the document.
+type Info struct {
+	Title       string
+	Version     string
+	Description string
+}
+
+// Document is an OpenAPI document, serialized as JSON.
+type Document map[string]any
+
+var pathParamPattern = regexp.MustCompile(`[:*]([A-Za-z_][A-Za-z0-9_]*)`)
+
+// Build returns the OpenAPI document of routes. Operations are keyed by "METHOD /path"; routes without
+// one are still listed, with only their path and method.
+func Build(info Info, routes []Route, operations map[string]Operation) Document {
+	b := &schemaBuilder{
+		components: map[string]any{},
+		names:      map[reflect.Type]string{},
+		types:      map[string]reflect.Type{},
+	}
+	b.components["SuccessResponse"] = map[string]any{
+		"type":     "object",
+		"required": []string{"code", "message"},
+		"properties": map[string]any{
+			"code":    map[string]any{"type": "integer"},
+			"message": map[string]any{"type": "string"},
+			"data":    map[string]any{},
+		},
+	}
+	b.components["ErrorResponse"] = map[string]any{
+		"type":     "object",
+		"required": []string{"code", "message"},
+		"properties": map[string]any{
+			"code":    map[string]any{"type": "string"},
+			"message": map[string]any{"type": "string"},
+		},
+	}
+	b.components["Pagination"] = map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"page":          map[string]any{"type": "integer"},
+			"page_size":     map[string]any{"type": "integer"},
+			"max_page_size": map[string]any{"type": "integer"},
+			"total_items":   map[string]any{"type": "integer", "format": "int64"},
+			"total_pages":   map[string]any{"type": "integer"},
+		},
+	}
+
+	routes = slices.Clone(routes)
+	slices.SortStableFunc(routes, func(a, b Route) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	paths := map[string]any{}
+	for _, route := range routes {
+		op := operations[route.Method+" "+route.Path]
+		item, _ := paths[openAPIPath(route.Path)].(map[string]any)
+		if item == nil {
+			item = map[string]any{}
+			paths[openAPIPath(route.Path)] = item
+		}
+		item[strings.ToLower(route.Method)] = b.operation(route, op)
+	}
+
+	return Document{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       info.Title,
+			"version":     info.Version,
+			"description": info.Description,
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": b.components,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{"type": "http", "scheme": "bearer"},
+			},
+		},
+		"security": []any{map[string]any{"bearerAuth": []string{}}},
+	}
+}
+
+// openAPIPath turns gin path parameters such as /:id into /{id}.
+func openAPIPath(ginPath string) string {
+	return pathParamPattern.ReplaceAllString(ginPath, "{$1}")
+}
+
+func (b *schemaBuilder) operation(route Route, op Operation) map[string]any {
+	result := map[string]any{
+		"operationId": operationID(route),
+	}
+	if op.Summary != "" {
+		result["summary"] = op.Summary
+	}
+	if op.Tag != "" {
+		result["tags"] = []string{op.Tag}
+	}
+	if op.Public {
+		result["security"] = []any{}
+	}
+
+	var params []any
+	for _, match := range pathParamPattern.FindAllStringSubmatch(route.Path, -1) {
+		params = append(params, map[string]any{
+			"name":     match[1],
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]any{"type": paramType(match[1], "")},
+		})
+	}
+	query := op.Query
+	if op.Paginated {
+		query = append(slices.Clone(query),
+			Param{Name: "page", Type: "integer", Description: "Page number, from 1"},
+			Param{Name: "page_size", Type: "integer", Description: "Items per page, up to the max_page_size setting"},
+		)
+	}
+	for _, p := range query {
+		param := map[string]any{
+			"name":   p.Name,
+			"in":     "query",
+			"schema": map[string]any{"type": paramType(p.Name, p.Type)},
+		}
+		if p.Description != "" {
+			param["description"] = p.Description
+		}
+		if p.Required {
+			param["required"] = true
+		}
+		params = append(params, param)
+	}
+	if len(params) > 0 {
+		result["parameters"] = params
+	}
+
+	if op.Request != nil {
+		result["requestBody"] = map[string]any{
+			"required": true,
+			"content": map[string]any{
+				"application/json": map[string]any{"schema": b.schema(reflect.TypeOf(op.Request))},
+			},
+		}
+	}
+
+	var content map[string]any
+	switch {
+	case op.ContentType != "":
+		content = map[string]any{op.ContentType: map[string]any{"schema": map[string]any{"type": "string"}}}
+	default:
+		data := map[string]any{}
+		if op.Response != nil {
+			data = b.schema(reflect.TypeOf(op.Response))
+		}
+		if op.Paginated {
+			data = map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"items":      map[string]any{"type": "array", "items": data},
+					"pagination": ref("Pagination"),
+				},
+			}
+		}
+		schema := data
+		if !op.Raw {
+			schema = map[string]any{
+				"allOf": []any{
+					ref("SuccessResponse"),
+					map[string]any{"properties": map[string]any{"data": data}},
+				},
+			}
+		}
+		content = map[string]any{"application/json": map[string]any{"schema": schema}}
+	}
+	result["responses"] = map[string]any{
+		"200": map[string]any{"description": "Success", "content": content},
+		"default": map[string]any{
+			"description": "Error",
+			"content": map[string]any{
+				"application/json": map[string]any{"schema": ref("ErrorResponse")},
+			},
+		},
+	}
+	return result
+}
+
+// operationID derives a stable identifier such as getApiGroupsIdStats from the method and path.
+func operationID(route Route) string {
+	var id strings.Builder
+	id.WriteString(strings.ToLower(route.Method))
+	for _, part := range strings.FieldsFunc(route.Path, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	}) {
+		id.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return id.String()
+}
+
+// paramType defaults parameters named id or *_id to integers and others to strings.
+func paramType(name, declared string) string {
+	switch {
+	case declared != "":
+		return declared
+	case name == "id" || strings.HasSuffix(name, "_id"):
+		return "integer"
+	default:
+		return "string"
+	}
+}
+
+func ref(name string) map[string]any {
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+// schemaBuilder derives JSON schemas from Go types the way encoding/json serializes them. Named
+// structs become components, which also covers recursive types.
+type schemaBuilder struct {
+	components map[string]any
+	names      map[reflect.Type]string
+	types      map[string]reflect.Type
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	componentName = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+)
+
+func (b *schemaBuilder) schema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Implements(marshalerType) || reflect.PointerTo(t).Implements(marshalerType):
+		// Types with their own JSON encoding, such as json.RawMessage and datatypes.JSON, can hold any value.
+		return map[string]any{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return map[string]any{"type": "integer"}
+	case reflect.Int64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+		return ref(b.component(t))
+	default:
+		return map[string]any{}
+	}
+}
+
+// component registers a named struct type and returns its component name.
+func (b *schemaBuilder) component(t reflect.Type) string {
+	if name, ok := b.names[t]; ok {
+		return name
+	}
+	name := componentName.ReplaceAllString(t.Name(), "_")
+	if other, taken := b.types[name]; taken && other != t {
+		name = strings.ToUpper(path.Base(t.PkgPath())[:1]) + path.Base(t.PkgPath())[1:] + name
+	}
+	b.names[t] = name
+	b.types[name] = t
+	b.components[name] = b.structSchema(t)
+	return name
+}
+
+func (b *schemaBuilder) structSchema(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	var required []string
+	b.addFields(t, properties, &required)
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func (b *schemaBuilder) addFields(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		// Embedded structs without a JSON name are flattened, as encoding/json does.
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				b.addFields(embedded, properties, required)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = b.schema(field.Type)
+		if slices.Contains(strings.Split(field.Tag.Get("binding"), ","), "required") {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"gpt-load/internal/models"
+	"gpt-load/internal/utils"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// maxLoggedBodyBytes caps each request and response body kept for groups with log_bodies.
+	maxLoggedBodyBytes = 32 * 1024
+	// redactedBodyValue replaces the fields listed in log_bodies_redact_fields.
+	redactedBodyValue = "[REDACTED]"
+)
+
+// bodyCapture keeps the first maxLoggedBodyBytes bytes of a streamed response body.
+type bodyCapture struct {
+	data      []byte
+	truncated bool
+}
+
+// Write appends p up to the size cap.
+func (b *bodyCapture) Write(p []byte) {
+	room := maxLoggedBodyBytes - len(b.data)
+	if len(p) > room {
+		b.data = append(b.data, p[:room]...)
+		b.truncated = true
+		return
+	}
+	b.data = append(b.data, p...)
+}
+
+// bodyLogOptions returns the group's options if it has log_bodies enabled, or nil.
+func bodyLogOptions(group *models.Group) *models.GroupConfig {
+	if !group.Options.LogBodies {
+		return nil
+	}
+	return &group.Options
+}
+
+// recordBodies stores a redacted, size-capped copy of the request and response bodies of a request
+// of a group with log_bodies enabled, linked to its request log entry. A captured streaming response
+// is passed already capped, with responseTruncated set if bytes were dropped.
+func (ps *ProxyServer) recordBodies(group *models.Group, logEntry *models.RequestLog, requestBody, responseBody []byte, responseTruncated bool) {
+	if ps.bodyLogService == nil {
+		return
+	}
+	groupOptions := bodyLogOptions(group)
+	if groupOptions == nil {
+		return
+	}
+
+	// Fields and patterns are redacted before capping, since a cut-off JSON body can no longer be parsed
+	// and a cut-off match would no longer be recognised.
+	patterns := ps.settingsManager.GetSettings().RedactionPatterns
+	requestBody = redactBody(requestBody, groupOptions.LogBodiesRedactFields, false)
+	requestBody, requestTruncated := capBody([]byte(utils.RedactText(string(requestBody), patterns)))
+	responseBody = redactBody(responseBody, groupOptions.LogBodiesRedactFields, responseTruncated)
+	responseBody, truncated := capBody([]byte(utils.RedactText(string(responseBody), patterns)))
+
+	entry := &models.RequestBodyLog{
+		RequestLogID:      logEntry.ID,
+		GroupID:           group.ID,
+		GroupName:         group.Name,
+		RequestPath:       logEntry.RequestPath,
+		StatusCode:        logEntry.StatusCode,
+		RequestBody:       string(requestBody),
+		RequestTruncated:  requestTruncated,
+		ResponseBody:      string(responseBody),
+		ResponseTruncated: responseTruncated || truncated,
+	}
+	ps.bodyLogService.Record(entry)
+}
+
+// capBody cuts body to maxLoggedBodyBytes and reports whether anything was cut.
+func capBody(body []byte) ([]byte, bool) {
+	if len(body) <= maxLoggedBodyBytes {
+		return body, false
+	}
+	return body[:maxLoggedBodyBytes], true
+}
+
+// redactBody replaces the values at paths in a JSON body, or in each "data:" event of an SSE body.
+// A body that matches neither is kept as is. When the body was cut off, its last incomplete line is
+// dropped so an unparsable fragment is never stored unredacted.
+func redactBody(body []byte, paths []string, truncated bool) []byte {
+	if len(paths) == 0 || len(body) == 0 {
+		return body
+	}
+
+	if redacted, ok := redactJSON(body, paths); ok {
+		return redacted
+	}
+
+	if truncated {
+		if idx := bytes.LastIndexByte(body, '\n'); idx >= 0 {
+			body = body[:idx+1]
+		} else {
+			return nil
+		}
+	}
+	lines := bytes.Split(body, []byte("\n"))
+	for i, line := range lines {
+		data, ok := bytes.CutPrefix(bytes.TrimSpace(line), []byte("data:"))
+		if !ok {
+			continue
+		}
+		if redacted, ok := redactJSON(bytes.TrimSpace(data), paths); ok {
+			lines[i] = append([]byte("data: "), redacted...)
+		}
+	}
+	return bytes.Join(lines, []byte("\n"))
+}
+
+// redactJSON redacts a JSON object body, reporting false if body is not a JSON object.
+func redactJSON(body []byte, paths []string) ([]byte, bool) {
+	var data map[string]any
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, false
+	}
+	for _, path := range paths {
+		redactParam(data, path, redactedBodyValue)
+	}
+	redacted, err := json.Marshal(data)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to encode redacted body")
+		return nil, false
+	}
+	return redacted, true
+}
@@ -0,0 +1,153 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"slices"
+	"time"
+
+	"gpt-load/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+)
+
+// defaultCoalesceMaxBytes is the request and response size cap of coalesced requests when
+// coalesce_max_bytes is not set.
+const defaultCoalesceMaxBytes = 1024 * 1024
+
+// coalesceKeyHeaders are the request headers that can change the upstream response, so requests
+// only coalesce when they match. Other headers, such as the proxy key and SDK telemetry, are ignored.
+var coalesceKeyHeaders = []string{"Accept", "Accept-Encoding", "Content-Type", "Anthropic-Version", "Anthropic-Beta"}
+
+// recordedResponse is a copy of a response written to a client.
+type recordedResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// responseRecorder keeps a copy of the response written to a client, up to limit bytes.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body     bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+// Write relays p to the client and records it.
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	r.record(p)
+	return r.ResponseWriter.Write(p)
+}
+
+// WriteString relays s to the client and records it.
+func (r *responseRecorder) WriteString(s string) (int, error) {
+	r.record([]byte(s))
+	return r.ResponseWriter.WriteString(s)
+}
+
+// response returns a copy of the recorded response. The body is nil if it exceeded the limit.
+func (r *responseRecorder) response() *recordedResponse {
+	var body []byte
+	if !r.overflow {
+		body = bytes.Clone(r.body.Bytes())
+	}
+	return &recordedResponse{status: r.Status(), header: r.Header().Clone(), body: body}
+}
+
+func (r *responseRecorder) record(p []byte) {
+	if r.overflow {
+		return
+	}
+	if r.body.Len()+len(p) > r.limit {
+		r.overflow = true
+		r.body.Reset()
+		return
+	}
+	r.body.Write(p)
+}
+
+// coalesceMaxBytes returns the size cap of coalesced requests if the group has coalesce_requests
+// enabled, or 0.
+func coalesceMaxBytes(group *models.Group) int {
+	if !group.Options.CoalesceRequests {
+		return 0
+	}
+	if group.Options.CoalesceMaxBytes > 0 {
+		return group.Options.CoalesceMaxBytes
+	}
+	return defaultCoalesceMaxBytes
+}
+
+// coalesceKey identifies identical requests by method, URL, negotiation headers and body.
+func coalesceKey(c *gin.Context, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(c.Request.Method + " " + c.Request.URL.RequestURI() + "\n"))
+	for _, name := range coalesceKeyHeaders {
+		for _, value := range c.Request.Header.Values(name) {
+			h.Write([]byte(name + ": " + value + "\n"))
+		}
+	}
+	h.Write([]byte("\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// coalescer returns the single-flight group of a proxy group, so keys never collide across groups.
+func (ps *ProxyServer) coalescer(groupID uint) *singleflight.Group {
+	flight, _ := ps.coalescers.LoadOrStore(groupID, &singleflight.Group{})
+	return flight.(*singleflight.Group)
+}
+
+// executeCoalesced runs execute for the first of concurrent identical requests of a group with
+// coalesce_requests enabled, and replays its response to the others instead of sending them upstream.
+// Bodies over maxBytes, and responses that could not be shared because they were too large or the
+// leading client went away, fall back to each request being sent on its own.
+func (ps *ProxyServer) executeCoalesced(c *gin.Context, group *models.Group, body []byte, maxBytes int, startTime time.Time, execute func()) {
+	if len(body) > maxBytes {
+		execute()
+		return
+	}
+
+	led := false
+	result, _, _ := ps.coalescer(group.ID).Do(coalesceKey(c, body), func() (any, error) {
+		led = true
+		recorder := &responseRecorder{ResponseWriter: c.Writer, limit: maxBytes}
+		c.Writer = recorder
+		defer func() { c.Writer = recorder.ResponseWriter }()
+
+		execute()
+
+		if recorder.overflow || !recorder.Written() || c.Request.Context().Err() != nil {
+			return (*recordedResponse)(nil), nil
+		}
+		return recorder.response(), nil
+	})
+	if led {
+		return
+	}
+
+	shared := result.(*recordedResponse)
+	if shared == nil {
+		execute()
+		return
+	}
+
+	logrus.Debugf("Request for group %s coalesced with an identical in-flight request", group.Name)
+	header := c.Writer.Header()
+	for key, values := range shared.header {
+		// Headers the follower's own middleware already set are kept.
+		if _, ok := header[key]; !ok {
+			header[key] = slices.Clone(values)
+		}
+	}
+	c.Status(shared.status)
+	if _, err := c.Writer.Write(shared.body); err != nil {
+		logUpstreamError("writing coalesced response body", err)
+	}
+	ps.logRequest(c, group, nil, startTime, shared.status, 0, nil, false, "", 0)
+}
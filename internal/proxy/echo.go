@@ -0,0 +1,149 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"gpt-load/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// echoDefaultReply is the reply of echo mode when the request has no user message to echo.
+	echoDefaultReply = "This is a test response from gpt-load echo mode."
+	// echoWordsPerChunk is the number of words sent in each streamed echo chunk.
+	echoWordsPerChunk = 4
+)
+
+// echoModeEnabled reports whether the group has echo_mode enabled.
+func echoModeEnabled(group *models.Group) bool {
+	return group.Options.EchoMode
+}
+
+// serveEcho answers a proxy request of a group in echo mode with an OpenAI chat completion that
+// echoes the last user message, without selecting a key or calling the upstream. Streaming requests
+// get the reply as a few SSE chunks.
+func (ps *ProxyServer) serveEcho(c *gin.Context, group *models.Group) {
+	var requestData map[string]any
+	if body, err := io.ReadAll(c.Request.Body); err == nil && len(body) > 0 {
+		_ = json.Unmarshal(body, &requestData)
+	}
+	c.Request.Body.Close()
+
+	model, _ := requestData["model"].(string)
+	if model == "" {
+		model = "echo"
+	}
+	reply := echoDefaultReply
+	if message := lastUserMessage(requestData); message != "" {
+		reply = "Echo: " + message
+	}
+	stream, _ := requestData["stream"].(bool)
+
+	logrus.Infof("Echo mode served request %s %s for group %s (stream: %t)", c.Request.Method, c.Request.URL.Path, group.Name, stream)
+
+	id := "chatcmpl-echo-" + uuid.NewString()
+	created := time.Now().Unix()
+	// Word counts stand in for token counts, so clients can check they read usage.
+	promptTokens := len(strings.Fields(lastUserMessage(requestData)))
+	completionTokens := len(strings.Fields(reply))
+	usage := gin.H{
+		"prompt_tokens":     promptTokens,
+		"completion_tokens": completionTokens,
+		"total_tokens":      promptTokens + completionTokens,
+	}
+
+	if !stream {
+		c.JSON(http.StatusOK, gin.H{
+			"id":      id,
+			"object":  "chat.completion",
+			"created": created,
+			"model":   model,
+			"choices": []gin.H{{
+				"index":         0,
+				"message":       gin.H{"role": "assistant", "content": reply},
+				"finish_reason": "stop",
+			}},
+			"usage": usage,
+		})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Status(http.StatusOK)
+
+	chunk := func(delta gin.H, finishReason any) gin.H {
+		return gin.H{
+			"id":      id,
+			"object":  "chat.completion.chunk",
+			"created": created,
+			"model":   model,
+			"choices": []gin.H{{"index": 0, "delta": delta, "finish_reason": finishReason}},
+		}
+	}
+	events := []gin.H{chunk(gin.H{"role": "assistant", "content": ""}, nil)}
+	words := strings.SplitAfter(reply, " ")
+	for start := 0; start < len(words); start += echoWordsPerChunk {
+		end := min(start+echoWordsPerChunk, len(words))
+		events = append(events, chunk(gin.H{"content": strings.Join(words[start:end], "")}, nil))
+	}
+	events = append(events, chunk(gin.H{}, "stop"))
+	if streamOptions, ok := requestData["stream_options"].(map[string]any); ok && streamOptions["include_usage"] == true {
+		usageChunk := chunk(gin.H{}, nil)
+		usageChunk["choices"] = []gin.H{}
+		usageChunk["usage"] = usage
+		events = append(events, usageChunk)
+	}
+
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			logrus.Errorf("Failed to encode echo chunk: %v", err)
+			return
+		}
+		if _, err := fmt.Fprintf(c.Writer, "data: %s\n\n", data); err != nil {
+			logUpstreamError("writing echo response", err)
+			return
+		}
+		c.Writer.Flush()
+	}
+	if _, err := io.WriteString(c.Writer, "data: [DONE]\n\n"); err != nil {
+		logUpstreamError("writing echo response", err)
+	}
+	c.Writer.Flush()
+}
+
+// lastUserMessage returns the text of the last user message of a chat request, or "".
+func lastUserMessage(requestData map[string]any) string {
+	messages, _ := requestData["messages"].([]any)
+	for i := len(messages) - 1; i >= 0; i-- {
+		message, ok := messages[i].(map[string]any)
+		if !ok || message["role"] != "user" {
+			continue
+		}
+		switch content := message["content"].(type) {
+		case string:
+			return content
+		case []any:
+			var texts []string
+			for _, part := range content {
+				if p, ok := part.(map[string]any); ok && p["type"] == "text" {
+					if text, ok := p["text"].(string); ok {
+						texts = append(texts, text)
+					}
+				}
+			}
+			return strings.Join(texts, "\n")
+		}
+		return ""
+	}
+	return ""
+}
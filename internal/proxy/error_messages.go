@@ -0,0 +1,26 @@
+package proxy
+
+import (
+	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// groupErrorMessages returns the group's custom client-facing error messages. Unset messages are empty.
+func groupErrorMessages(group *models.Group) models.ErrorMessages {
+	if group.Options.ErrorMessages == nil {
+		return models.ErrorMessages{}
+	}
+	return *group.Options.ErrorMessages
+}
+
+// withClientMessage returns apiErr with message shown to the client instead of its own, or apiErr
+// unchanged if message is empty. The replaced message is logged, so operators keep the detail.
+func withClientMessage(group *models.Group, apiErr *app_errors.APIError, message string) *app_errors.APIError {
+	if message == "" {
+		return apiErr
+	}
+	logrus.Debugf("Sending custom error message of group %s to the client instead of: %s", group.Name, apiErr.Message)
+	return app_errors.NewAPIError(apiErr, message)
+}
@@ -0,0 +1,218 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"gpt-load/internal/channel"
+	"gpt-load/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// modelsListCacheTTL is how long a merged models list is served from memory.
+const modelsListCacheTTL = time.Minute
+
+// modelsListPaths are the models-list endpoints of the supported API formats.
+var modelsListPaths = map[string]bool{
+	"/v1/models":            true,
+	"/v1beta/models":        true,
+	"/v1beta/openai/models": true,
+}
+
+// modelsListCacheEntry is a merged models list of one group, endpoint and query.
+type modelsListCacheEntry struct {
+	body      []byte
+	expiresAt time.Time
+}
+
+// handleModelsList answers models-list requests from the group's static_models, or by merging the
+// lists of all upstreams when the group has more than one. It returns false when the request should
+// be proxied as usual: other endpoints, single-upstream groups, or when no upstream could be listed.
+func (ps *ProxyServer) handleModelsList(c *gin.Context, channelHandler channel.ChannelProxy, group *models.Group, startTime time.Time) bool {
+	path := strings.TrimSuffix(c.Param("path"), "/")
+	if c.Request.Method != http.MethodGet || !modelsListPaths[path] {
+		return false
+	}
+
+	if staticModels := group.Options.StaticModels; len(staticModels) > 0 {
+		c.JSON(http.StatusOK, staticModelsList(path, group, staticModels))
+		ps.logRequest(c, group, nil, startTime, http.StatusOK, 0, nil, false, "", 0)
+		return true
+	}
+
+	upstreamURLs := channelHandler.BuildUpstreamURLs(c.Request.URL, group)
+	if len(upstreamURLs) <= 1 {
+		return false
+	}
+
+	cacheKey := modelsListCacheKey(group, path, c.Request.URL)
+	if value, ok := ps.modelsListCache.Load(cacheKey); ok {
+		if entry := value.(modelsListCacheEntry); time.Now().Before(entry.expiresAt) {
+			c.Data(http.StatusOK, "application/json; charset=utf-8", entry.body)
+			ps.logRequest(c, group, nil, startTime, http.StatusOK, 0, nil, false, "", 0)
+			return true
+		}
+	}
+
+	body := ps.fetchMergedModelsList(c, channelHandler, group, upstreamURLs)
+	if body == nil {
+		return false
+	}
+	ps.modelsListCache.Store(cacheKey, modelsListCacheEntry{body: body, expiresAt: time.Now().Add(modelsListCacheTTL)})
+
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+	ps.logRequest(c, group, nil, startTime, http.StatusOK, 0, nil, false, strings.Join(upstreamURLs, ","), 0)
+	return true
+}
+
+// modelsListCacheKey identifies a merged models list by group, endpoint and normalized query, since
+// query parameters such as Gemini's pageSize change the list. The client's "key" parameter is
+// only the proxy key and is left out.
+func modelsListCacheKey(group *models.Group, path string, requestURL *url.URL) string {
+	query := requestURL.Query()
+	query.Del("key")
+	// Encode sorts the parameters, so their order in the request does not matter.
+	return fmt.Sprintf("%d:%s?%s", group.ID, path, query.Encode())
+}
+
+// fetchMergedModelsList requests the models list from every upstream concurrently and merges the
+// successful responses. It returns nil if none succeeded.
+func (ps *ProxyServer) fetchMergedModelsList(c *gin.Context, channelHandler channel.ChannelProxy, group *models.Group, upstreamURLs []string) []byte {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), time.Duration(group.EffectiveConfig.RequestTimeout)*time.Second)
+	defer cancel()
+
+	bodies := make([][]byte, len(upstreamURLs))
+	var wg sync.WaitGroup
+	for i, upstreamURL := range upstreamURLs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			body, err := ps.fetchModelsList(ctx, c, channelHandler, group, upstreamURL)
+			if err != nil {
+				logrus.Debugf("Failed to list models from upstream %s of group %s: %v", upstreamURL, group.Name, err)
+				return
+			}
+			bodies[i] = body
+		}()
+	}
+	wg.Wait()
+
+	merged, err := mergeModelLists(bodies)
+	if err != nil {
+		logrus.Debugf("Failed to merge models lists of group %s: %v", group.Name, err)
+		return nil
+	}
+	return merged
+}
+
+// fetchModelsList requests the models list from a single upstream with a key of the group.
+func (ps *ProxyServer) fetchModelsList(ctx context.Context, c *gin.Context, channelHandler channel.ChannelProxy, group *models.Group, upstreamURL string) ([]byte, error) {
+	apiKey, err := ps.keyProvider.SelectKey(group, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, upstreamURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = c.Request.Header.Clone()
+	stripClientAuth(req)
+	// Let the transport negotiate compression so the bodies can be decoded for merging.
+	req.Header.Del("Accept-Encoding")
+	channelHandler.ModifyRequest(req, apiKey, group)
+
+	resp, err := channelHandler.GetHTTPClient().Do(req)
+	if err != nil {
+		channelHandler.ReportUpstreamResult(upstreamURL, false)
+		return nil, err
+	}
+	defer resp.Body.Close()
+	channelHandler.ReportUpstreamResult(upstreamURL, true)
+
+	body, err := readLimitedBody(resp.Body, group.EffectiveConfig.MaxResponseBodyBytes)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return body, nil
+}
+
+// mergeModelLists merges models-list responses into the first one, deduplicating the "data"
+// entries by id (OpenAI and Anthropic format) or the "models" entries by name (Gemini format).
+// Nil bodies are skipped.
+func mergeModelLists(bodies [][]byte) ([]byte, error) {
+	var merged map[string]json.RawMessage
+	var listField, idField string
+	var entries []map[string]any
+	seen := make(map[string]bool)
+
+	for _, body := range bodies {
+		if body == nil {
+			continue
+		}
+		var doc map[string]json.RawMessage
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return nil, err
+		}
+
+		field, id := "data", "id"
+		if _, ok := doc[field]; !ok {
+			field, id = "models", "name"
+		}
+		var list []map[string]any
+		if err := json.Unmarshal(doc[field], &list); err != nil {
+			return nil, fmt.Errorf("unexpected models list format: %w", err)
+		}
+
+		if merged == nil {
+			merged, listField, idField = doc, field, id
+		}
+		for _, entry := range list {
+			name, _ := entry[idField].(string)
+			if name == "" || seen[name] {
+				continue
+			}
+			seen[name] = true
+			entries = append(entries, entry)
+		}
+	}
+	if merged == nil {
+		return nil, fmt.Errorf("no upstream returned a models list")
+	}
+
+	list, err := json.Marshal(entries)
+	if err != nil {
+		return nil, err
+	}
+	merged[listField] = list
+	return json.Marshal(merged)
+}
+
+// staticModelsList builds a models-list response from the group's static_models,
+// in the Gemini format for the native Gemini endpoint and the OpenAI format otherwise.
+func staticModelsList(path string, group *models.Group, names []string) gin.H {
+	if path == "/v1beta/models" {
+		list := make([]gin.H, 0, len(names))
+		for _, name := range names {
+			list = append(list, gin.H{"name": "models/" + strings.TrimPrefix(name, "models/")})
+		}
+		return gin.H{"models": list}
+	}
+
+	list := make([]gin.H, 0, len(names))
+	for _, name := range names {
+		list = append(list, gin.H{"id": name, "object": "model", "created": 0, "owned_by": group.Name})
+	}
+	return gin.H{"object": "list", "data": list}
+}
@@ -0,0 +1,27 @@
+package proxy
+
+import (
+	"net/url"
+	"testing"
+
+	"gpt-load/internal/models"
+)
+
+func TestModelsListCacheKey(t *testing.T) {
+	group := &models.Group{ID: 3}
+	cacheKey := func(rawURL string) string {
+		u, _ := url.Parse(rawURL)
+		return modelsListCacheKey(group, "/v1beta/models", u)
+	}
+
+	base := cacheKey("/proxy/test/v1beta/models?pageSize=50&pageToken=abc")
+	if got := cacheKey("/proxy/test/v1beta/models?pageToken=abc&pageSize=50&key=proxy-key"); got != base {
+		t.Errorf("reordered query with proxy key: got %q, want %q", got, base)
+	}
+	if got := cacheKey("/proxy/test/v1beta/models?pageSize=50&pageToken=def"); got == base {
+		t.Errorf("different page token shares cache key %q", got)
+	}
+	if got := cacheKey("/proxy/test/v1beta/models"); got == base {
+		t.Errorf("request without query shares cache key %q", got)
+	}
+}
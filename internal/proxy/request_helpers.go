@@ -4,16 +4,40 @@ import (
 	"bytes"
 	"compress/gzip"
 	"encoding/json"
+	"errors"
+	"gpt-load/internal/channel"
 	app_errors "gpt-load/internal/errors"
 	"gpt-load/internal/models"
+	"gpt-load/internal/types"
+	"gpt-load/internal/utils"
 	"io"
+	"mime"
 	"net/http"
+	"strings"
 
+	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 )
 
-func (ps *ProxyServer) applyParamOverrides(bodyBytes []byte, group *models.Group) ([]byte, error) {
-	if len(group.ParamOverrides) == 0 || len(bodyBytes) == 0 {
+const requestModelContextKey = "requestModel"
+
+// sessionIDHeader identifies the client session whose retries count against session_retry_budget.
+const sessionIDHeader = "X-Session-Id"
+
+// errResponseTooLarge is returned for a non-streaming upstream response over max_response_body_bytes.
+var errResponseTooLarge = errors.New("upstream response body exceeds max_response_body_bytes")
+
+// applyParamOverrides applies the group's parameter overrides, removed and forced parameters,
+// forced system prompt and stream usage option to a JSON request body sent to path.
+func (ps *ProxyServer) applyParamOverrides(bodyBytes []byte, group *models.Group, channelHandler channel.ChannelProxy, path string) ([]byte, error) {
+	if len(bodyBytes) == 0 {
+		return bodyBytes, nil
+	}
+	groupOptions := group.Options
+	// Only chat completions accept stream_options; the Responses API always reports usage.
+	streamUsage := groupOptions.IncludeStreamUsage && channelHandler.SupportsStreamUsage() && strings.HasSuffix(path, "/chat/completions")
+	if len(group.ParamOverrides) == 0 && len(groupOptions.RemoveParams) == 0 &&
+		len(groupOptions.ForceParams) == 0 && groupOptions.ForceSystemPrompt == nil && !streamUsage {
 		return bodyBytes, nil
 	}
 
@@ -26,10 +50,198 @@ func (ps *ProxyServer) applyParamOverrides(bodyBytes []byte, group *models.Group
 	for key, value := range group.ParamOverrides {
 		requestData[key] = value
 	}
+	for _, path := range groupOptions.RemoveParams {
+		removeParam(requestData, path)
+	}
+	for path, value := range groupOptions.ForceParams {
+		setParam(requestData, path, value)
+	}
+	if groupOptions.ForceSystemPrompt != nil {
+		applyForcedSystemPrompt(requestData, group.ChannelType, groupOptions.ForceSystemPrompt)
+	}
+	if streamUsage {
+		injectStreamUsage(requestData)
+	}
 
 	return json.Marshal(requestData)
 }
 
+// removeParam deletes the parameter at a dot-separated path, if present.
+func removeParam(requestData map[string]any, path string) {
+	segments, err := utils.SplitParamPath(path)
+	if err != nil {
+		return
+	}
+	current := requestData
+	for _, segment := range segments[:len(segments)-1] {
+		next, ok := current[segment].(map[string]any)
+		if !ok {
+			return
+		}
+		current = next
+	}
+	delete(current, segments[len(segments)-1])
+}
+
+// redactParam replaces the value at a dot-separated path with value, if present.
+func redactParam(data map[string]any, path string, value any) {
+	segments, err := utils.SplitParamPath(path)
+	if err != nil {
+		return
+	}
+	current := data
+	for _, segment := range segments[:len(segments)-1] {
+		next, ok := current[segment].(map[string]any)
+		if !ok {
+			return
+		}
+		current = next
+	}
+	if _, ok := current[segments[len(segments)-1]]; ok {
+		current[segments[len(segments)-1]] = value
+	}
+}
+
+// setParam sets the parameter at a dot-separated path, creating intermediate objects
+// and replacing intermediate values that are not objects.
+func setParam(requestData map[string]any, path string, value any) {
+	segments, err := utils.SplitParamPath(path)
+	if err != nil {
+		return
+	}
+	current := requestData
+	for _, segment := range segments[:len(segments)-1] {
+		next, ok := current[segment].(map[string]any)
+		if !ok {
+			next = make(map[string]any)
+			current[segment] = next
+		}
+		current = next
+	}
+	current[segments[len(segments)-1]] = value
+}
+
+// setRequestModel records the model a request asks for, used to aggregate usage per model.
+// It reads the "model" field of a JSON body and falls back to the path, where Gemini puts it,
+// e.g. /v1beta/models/gemini-pro:generateContent.
+func setRequestModel(c *gin.Context, bodyBytes []byte) {
+	var payload struct {
+		Model string `json:"model"`
+	}
+	if len(bodyBytes) > 0 && json.Unmarshal(bodyBytes, &payload) == nil && payload.Model != "" {
+		c.Set(requestModelContextKey, utils.TruncateString(payload.Model, 255))
+		return
+	}
+
+	if _, rest, found := strings.Cut(c.Param("path"), "/models/"); found {
+		model, _, _ := strings.Cut(rest, ":")
+		model, _, _ = strings.Cut(model, "/")
+		c.Set(requestModelContextKey, utils.TruncateString(model, 255))
+	}
+}
+
+// forwardedErrorHeaders picks the upstream response headers listed in the forwarded_error_headers
+// setting, such as retry-after and x-ratelimit-*, to relay with the final upstream error.
+func forwardedErrorHeaders(header http.Header, names string) http.Header {
+	patterns := utils.SplitAndTrim(strings.ToLower(names), ",")
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	forwarded := make(http.Header)
+	for key, values := range header {
+		lowerKey := strings.ToLower(key)
+		for _, pattern := range patterns {
+			prefix, isPrefix := strings.CutSuffix(pattern, "*")
+			if lowerKey == pattern || (isPrefix && strings.HasPrefix(lowerKey, prefix)) {
+				forwarded[key] = values
+				break
+			}
+		}
+	}
+	return forwarded
+}
+
+// triedKeyIDs returns the keys that already failed for the current request, so retries pick other keys.
+func triedKeyIDs(retryErrors []types.RetryError) map[uint]bool {
+	if len(retryErrors) == 0 {
+		return nil
+	}
+	tried := make(map[uint]bool, len(retryErrors))
+	for _, retryErr := range retryErrors {
+		tried[retryErr.KeyID] = true
+	}
+	return tried
+}
+
+// triedUpstreams returns the upstreams the failed attempts of a request were sent to.
+func triedUpstreams(retryErrors []types.RetryError) map[string]bool {
+	if len(retryErrors) == 0 {
+		return nil
+	}
+	tried := make(map[string]bool, len(retryErrors))
+	for _, retryErr := range retryErrors {
+		if retryErr.Upstream != "" {
+			tried[retryErr.Upstream] = true
+		}
+	}
+	return tried
+}
+
+// stripClientAuth removes the proxy key the client authenticated with before the request goes upstream.
+func stripClientAuth(req *http.Request) {
+	req.Header.Del("Authorization")
+	req.Header.Del("X-Api-Key")
+	req.Header.Del("X-Goog-Api-Key")
+	q := req.URL.Query()
+	q.Del("key")
+	req.URL.RawQuery = q.Encode()
+}
+
+// isBodylessRequest reports whether the request method carries no body, such as listing models with GET.
+func isBodylessRequest(req *http.Request) bool {
+	return req.Method == http.MethodGet || req.Method == http.MethodHead
+}
+
+// isPassthroughBody reports whether the request carries a non-JSON payload (file or audio upload)
+// that must be forwarded untouched rather than buffered and rewritten by the parameter overrides.
+func isPassthroughBody(req *http.Request) bool {
+	contentType := req.Header.Get("Content-Type")
+	if contentType == "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "multipart/"),
+		strings.HasPrefix(mediaType, "audio/"),
+		strings.HasPrefix(mediaType, "video/"),
+		strings.HasPrefix(mediaType, "image/"),
+		mediaType == "application/octet-stream":
+		return true
+	}
+	return false
+}
+
+// readLimitedBody reads a response body of at most limit bytes, returning errResponseTooLarge
+// without reading the rest once it is exceeded. A limit of 0 reads the whole body.
+func readLimitedBody(body io.Reader, limit int) ([]byte, error) {
+	if limit <= 0 {
+		return io.ReadAll(body)
+	}
+	data, err := io.ReadAll(io.LimitReader(body, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > limit {
+		return nil, errResponseTooLarge
+	}
+	return data, nil
+}
+
 // logUpstreamError provides a centralized way to log errors from upstream interactions.
 func logUpstreamError(context string, err error) {
 	if err == nil {
@@ -42,22 +254,27 @@ func logUpstreamError(context string, err error) {
 	}
 }
 
-// handleGzipCompression checks for gzip encoding and decompresses the body if necessary.
-func handleGzipCompression(resp *http.Response, bodyBytes []byte) []byte {
+// handleGzipCompression checks for gzip encoding and decompresses the body if necessary. A decompressed
+// body over limit bytes returns errResponseTooLarge, so a small compressed response cannot expand
+// without bound; limit 0 disables the check. A body that is not valid gzip is returned as is.
+func handleGzipCompression(resp *http.Response, bodyBytes []byte, limit int) ([]byte, error) {
 	if resp.Header.Get("Content-Encoding") == "gzip" {
 		reader, gzipErr := gzip.NewReader(bytes.NewReader(bodyBytes))
 		if gzipErr != nil {
 			logrus.Warnf("Failed to create gzip reader for error body: %v", gzipErr)
-			return bodyBytes
+			return bodyBytes, nil
 		}
 		defer reader.Close()
 
-		decompressedBody, readAllErr := io.ReadAll(reader)
-		if readAllErr != nil {
-			logrus.Warnf("Failed to decompress gzip error body: %v", readAllErr)
-			return bodyBytes
+		decompressedBody, readErr := readLimitedBody(reader, limit)
+		if errors.Is(readErr, errResponseTooLarge) {
+			return nil, readErr
+		}
+		if readErr != nil {
+			logrus.Warnf("Failed to decompress gzip error body: %v", readErr)
+			return bodyBytes, nil
 		}
-		return decompressedBody
+		return decompressedBody, nil
 	}
-	return bodyBytes
+	return bodyBytes, nil
 }
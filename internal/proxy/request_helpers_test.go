@@ -0,0 +1,90 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIsPassthroughBody(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        bool
+	}{
+		{"", false},
+		{"application/json", false},
+		{"application/json; charset=utf-8", false},
+		{"text/plain", false},
+		{"multipart/form-data; boundary=abc", true},
+		{"audio/mpeg", true},
+		{"video/mp4", true},
+		{"image/png", true},
+		{"application/octet-stream", true},
+		{"multipart/form-data; boundary=", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/proxy/test/v1/files", nil)
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		if got := isPassthroughBody(req); got != tt.want {
+			t.Errorf("isPassthroughBody(%q) = %v, want %v", tt.contentType, got, tt.want)
+		}
+	}
+}
+
+// gzipped compresses data.
+func gzipped(data []byte) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestReadLimitedBody(t *testing.T) {
+	body := strings.Repeat("a", 100)
+	if got, err := readLimitedBody(strings.NewReader(body), 100); err != nil || string(got) != body {
+		t.Errorf("readLimitedBody() at the limit = %d bytes, %v", len(got), err)
+	}
+	if _, err := readLimitedBody(strings.NewReader(body), 99); !errors.Is(err, errResponseTooLarge) {
+		t.Errorf("readLimitedBody() over the limit error = %v, want errResponseTooLarge", err)
+	}
+	if got, err := readLimitedBody(strings.NewReader(body), 0); err != nil || string(got) != body {
+		t.Errorf("readLimitedBody() without a limit = %d bytes, %v", len(got), err)
+	}
+}
+
+func TestHandleGzipCompressionBoundsDecompressedSize(t *testing.T) {
+	gzipHeader := http.Header{"Content-Encoding": []string{"gzip"}}
+	// A megabyte of zeros compresses to about a kilobyte.
+	bomb := gzipped(make([]byte, 1<<20))
+
+	tests := []struct {
+		name    string
+		header  http.Header
+		body    []byte
+		limit   int
+		want    []byte
+		wantErr error
+	}{
+		{"plain body", http.Header{}, []byte(`{"id":"ok"}`), 4, []byte(`{"id":"ok"}`), nil},
+		{"gzip within limit", gzipHeader, gzipped([]byte(`{"id":"ok"}`)), 64, []byte(`{"id":"ok"}`), nil},
+		{"gzip over limit", gzipHeader, bomb, 64 * 1024, nil, errResponseTooLarge},
+		{"gzip without limit", gzipHeader, bomb, 0, make([]byte, 1<<20), nil},
+		{"invalid gzip", gzipHeader, []byte("not gzip"), 64, []byte("not gzip"), nil},
+	}
+	for _, tt := range tests {
+		got, err := handleGzipCompression(&http.Response{Header: tt.header}, tt.body, tt.limit)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: got %d bytes, want %d", tt.name, len(got), len(tt.want))
+		}
+	}
+}
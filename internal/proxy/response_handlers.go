@@ -3,12 +3,59 @@ package proxy
 import (
 	"io"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 )
 
-func (ps *ProxyServer) handleStreamingResponse(c *gin.Context, resp *http.Response) {
+// hopByHopHeaders describe the upstream connection rather than the response, so they are not relayed.
+var hopByHopHeaders = []string{"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Connection", "Te", "Trailer", "Transfer-Encoding", "Upgrade"}
+
+// copyResponseHeaders relays the upstream response headers to the client, without hop-by-hop headers
+// and Content-Length. The client connection is framed on its own: chunked for relayed bodies, or with
+// the length of the body actually written for buffered ones, so a chunked or mislabelled upstream
+// response cannot leave the client waiting for bytes that never come.
+func copyResponseHeaders(c *gin.Context, header http.Header) {
+	skipped := slices.Clone(hopByHopHeaders)
+	for _, value := range header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				skipped = append(skipped, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+
+	dst := c.Writer.Header()
+	for key, values := range header {
+		if key == "Content-Length" || slices.Contains(skipped, key) {
+			continue
+		}
+		dst[key] = slices.Clone(values)
+	}
+}
+
+// writeBufferedResponse writes a fully read upstream body with a Content-Length matching it.
+// A HEAD response keeps the upstream length, as it describes a body that is never sent.
+func writeBufferedResponse(c *gin.Context, resp *http.Response, body []byte) {
+	if c.Request.Method == http.MethodHead {
+		if length := resp.Header.Get("Content-Length"); length != "" {
+			c.Header("Content-Length", length)
+		}
+	} else {
+		c.Header("Content-Length", strconv.Itoa(len(body)))
+	}
+	if _, err := c.Writer.Write(body); err != nil {
+		logUpstreamError("writing response body", err)
+	}
+}
+
+// handleStreamingResponse relays a streaming response and returns the token usage it reported, if any,
+// and the message of an error event sent once the stream had started. When capture is not nil, the
+// start of the stream is also copied into it.
+func (ps *ProxyServer) handleStreamingResponse(c *gin.Context, resp *http.Response, capture *bodyCapture) (*tokenUsage, string) {
 	c.Header("Content-Type", "text/event-stream")
 	c.Header("Cache-Control", "no-cache")
 	c.Header("Connection", "keep-alive")
@@ -18,16 +65,28 @@ func (ps *ProxyServer) handleStreamingResponse(c *gin.Context, resp *http.Respon
 	if !ok {
 		logrus.Error("Streaming unsupported by the writer, falling back to normal response")
 		ps.handleNormalResponse(c, resp)
-		return
+		return nil, ""
+	}
+
+	// A compressed stream cannot be scanned for usage or errors without decompressing it.
+	var scanner *streamUsageScanner
+	if resp.Header.Get("Content-Encoding") == "" {
+		scanner = &streamUsageScanner{}
 	}
 
 	buf := make([]byte, 4*1024)
 	for {
 		n, err := resp.Body.Read(buf)
 		if n > 0 {
+			if scanner != nil {
+				scanner.Write(buf[:n])
+			}
+			if capture != nil {
+				capture.Write(buf[:n])
+			}
 			if _, writeErr := c.Writer.Write(buf[:n]); writeErr != nil {
 				logUpstreamError("writing stream to client", writeErr)
-				return
+				return nil, ""
 			}
 			flusher.Flush()
 		}
@@ -36,9 +95,14 @@ func (ps *ProxyServer) handleStreamingResponse(c *gin.Context, resp *http.Respon
 		}
 		if err != nil {
 			logUpstreamError("reading from upstream", err)
-			return
+			return nil, ""
 		}
 	}
+
+	if scanner == nil {
+		return nil, ""
+	}
+	return scanner.Usage(), scanner.Err()
 }
 
 func (ps *ProxyServer) handleNormalResponse(c *gin.Context, resp *http.Response) {
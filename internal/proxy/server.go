@@ -9,12 +9,16 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"gpt-load/internal/channel"
 	"gpt-load/internal/config"
 	app_errors "gpt-load/internal/errors"
 	"gpt-load/internal/keypool"
+	"gpt-load/internal/middleware"
 	"gpt-load/internal/models"
 	"gpt-load/internal/response"
 	"gpt-load/internal/services"
@@ -25,6 +29,23 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// maxFallbackDepth limits how many fallback groups a single request may traverse.
+const maxFallbackDepth = 3
+
+const fallbackRouteContextKey = "fallback_route"
+
+// fallbackRoute tracks the fallback groups a single request has been sent to.
+type fallbackRoute struct {
+	visited      map[string]bool
+	depth        int
+	originalBody []byte
+	// passthrough is set when the body is streamed to the upstream and cannot be replayed.
+	passthrough bool
+}
+
+// statusClientClosedRequest is the nginx-style status recorded for requests the client cancelled.
+const statusClientClosedRequest = 499
+
 // ProxyServer represents the proxy server
 type ProxyServer struct {
 	keyProvider       *keypool.KeyProvider
@@ -32,6 +53,13 @@ type ProxyServer struct {
 	settingsManager   *config.SystemSettingsManager
 	channelFactory    *channel.Factory
 	requestLogService *services.RequestLogService
+	bodyLogService    *services.RequestBodyLogService
+	quotaService      *services.GroupQuotaService
+	comparisonService *services.ShadowComparisonService
+	retryBudget       *services.SessionRetryBudgetService
+	modelsListCache   sync.Map
+	coalescers        sync.Map
+	shadowSlots       chan struct{}
 }
 
 // NewProxyServer creates a new proxy server
@@ -41,6 +69,10 @@ func NewProxyServer(
 	settingsManager *config.SystemSettingsManager,
 	channelFactory *channel.Factory,
 	requestLogService *services.RequestLogService,
+	bodyLogService *services.RequestBodyLogService,
+	quotaService *services.GroupQuotaService,
+	comparisonService *services.ShadowComparisonService,
+	retryBudget *services.SessionRetryBudgetService,
 ) (*ProxyServer, error) {
 	return &ProxyServer{
 		keyProvider:       keyProvider,
@@ -48,6 +80,11 @@ func NewProxyServer(
 		settingsManager:   settingsManager,
 		channelFactory:    channelFactory,
 		requestLogService: requestLogService,
+		bodyLogService:    bodyLogService,
+		quotaService:      quotaService,
+		comparisonService: comparisonService,
+		retryBudget:       retryBudget,
+		shadowSlots:       make(chan struct{}, maxInFlightShadowRequests),
 	}, nil
 }
 
@@ -58,35 +95,225 @@ func (ps *ProxyServer) HandleProxy(c *gin.Context) {
 
 	group, err := ps.groupManager.GetGroupByName(groupName)
 	if err != nil {
-		response.Error(c, app_errors.ParseDBError(err))
+		response.ProxyError(c, app_errors.ParseDBError(err))
+		return
+	}
+
+	if group.EffectiveConfig.MaintenanceMode {
+		ps.serveMaintenance(c, group)
+		return
+	}
+
+	if echoModeEnabled(group) {
+		ps.serveEcho(c, group)
 		return
 	}
 
+	if !ps.consumeDailyQuota(c, group) {
+		return
+	}
+
+	if group.ChannelType == channel.VirtualChannelType {
+		ps.handleVirtualProxy(c, group, startTime)
+		return
+	}
+
+	route := &fallbackRoute{visited: map[string]bool{group.Name: true}}
+	c.Set(fallbackRouteContextKey, route)
+	group = ps.resolveFallbackGroup(route, group)
+
 	channelHandler, err := ps.channelFactory.GetChannel(group)
 	if err != nil {
-		response.Error(c, app_errors.NewAPIError(app_errors.ErrInternalServer, fmt.Sprintf("Failed to get channel for group '%s': %v", groupName, err)))
+		response.ProxyError(c, app_errors.NewAPIError(app_errors.ErrInternalServer, fmt.Sprintf("Failed to get channel for group '%s': %v", group.Name, err)))
+		return
+	}
+
+	if ps.handleModelsList(c, channelHandler, group, startTime) {
+		return
+	}
+
+	// File and audio uploads are streamed to the upstream as-is instead of being buffered,
+	// and GET/HEAD requests have no body to buffer or override.
+	if isBodylessRequest(c.Request) || isPassthroughBody(c.Request) {
+		route.passthrough = isPassthroughBody(c.Request)
+		setRequestModel(c, nil)
+		isStream := channelHandler.IsStreamRequest(c, nil)
+		ps.executeRequestWithRetry(c, channelHandler, group, nil, isStream, startTime, 0, nil)
 		return
 	}
 
 	bodyBytes, err := io.ReadAll(c.Request.Body)
 	if err != nil {
 		logrus.Errorf("Failed to read request body: %v", err)
-		response.Error(c, app_errors.NewAPIError(app_errors.ErrBadRequest, "Failed to read request body"))
+		response.ProxyError(c, app_errors.NewAPIError(app_errors.ErrBadRequest, "Failed to read request body"))
 		return
 	}
 	c.Request.Body.Close()
+	setRequestModel(c, bodyBytes)
+	route.originalBody = bodyBytes
 
-	finalBodyBytes, err := ps.applyParamOverrides(bodyBytes, group)
-	if err != nil {
-		response.Error(c, app_errors.NewAPIError(app_errors.ErrInternalServer, fmt.Sprintf("Failed to apply parameter overrides: %v", err)))
+	finalBodyBytes, apiErr := ps.prepareRequestBody(c, group, channelHandler, bodyBytes)
+	if apiErr != nil {
+		response.ProxyError(c, apiErr)
 		return
 	}
 
 	isStream := channelHandler.IsStreamRequest(c, bodyBytes)
+	if primary := ps.mirrorToShadowGroup(c, group, bodyBytes, isStream); primary != nil {
+		defer primary.finish(c)
+	}
+
+	if maxBytes := coalesceMaxBytes(group); maxBytes > 0 && !isStream {
+		ps.executeCoalesced(c, group, finalBodyBytes, maxBytes, startTime, func() {
+			ps.executeRequestWithRetry(c, channelHandler, group, finalBodyBytes, isStream, startTime, 0, nil)
+		})
+		return
+	}
 
 	ps.executeRequestWithRetry(c, channelHandler, group, finalBodyBytes, isStream, startTime, 0, nil)
 }
 
+// prepareRequestBody applies the group's parameter overrides and request transform to a buffered
+// request body. Every group that serves a request goes through it, including fallback groups and
+// members of virtual groups.
+func (ps *ProxyServer) prepareRequestBody(c *gin.Context, group *models.Group, channelHandler channel.ChannelProxy, bodyBytes []byte) ([]byte, *app_errors.APIError) {
+	finalBodyBytes, err := ps.applyParamOverrides(bodyBytes, group, channelHandler, c.Request.URL.Path)
+	if err != nil {
+		return nil, app_errors.NewAPIError(app_errors.ErrInternalServer, fmt.Sprintf("Failed to apply parameter overrides: %v", err))
+	}
+	finalBodyBytes, err = ps.transformRequest(c, group, finalBodyBytes)
+	if err != nil {
+		return nil, app_errors.NewAPIError(app_errors.ErrTransformFailed, err.Error())
+	}
+	return finalBodyBytes, nil
+}
+
+// serveMaintenance answers a proxy request with the configured maintenance response instead of forwarding it.
+// A maintenance message that is a JSON object is sent as the body verbatim.
+func (ps *ProxyServer) serveMaintenance(c *gin.Context, group *models.Group) {
+	cfg := group.EffectiveConfig
+	logrus.Debugf("Maintenance mode served request %s %s for group %s", c.Request.Method, c.Request.URL.Path, group.Name)
+
+	c.Header("Cache-Control", "public, max-age=60")
+	message := strings.TrimSpace(cfg.MaintenanceMessage)
+	if strings.HasPrefix(message, "{") && json.Valid([]byte(message)) {
+		c.Data(cfg.MaintenanceStatusCode, "application/json; charset=utf-8", []byte(message))
+		return
+	}
+	response.ProxyError(c, &app_errors.APIError{HTTPStatus: cfg.MaintenanceStatusCode, Code: "MAINTENANCE", Message: message})
+}
+
+// consumeDailyQuota counts the request against the group's daily quota.
+// It responds with 429 and returns false once the quota is used up.
+func (ps *ProxyServer) consumeDailyQuota(c *gin.Context, group *models.Group) bool {
+	allowed, usage, err := ps.quotaService.Consume(group)
+	if err != nil {
+		// Don't block traffic because the store is unavailable.
+		logrus.Warnf("Failed to check daily quota for group %s: %v", group.Name, err)
+		return true
+	}
+	if allowed {
+		return true
+	}
+
+	c.Header("Retry-After", strconv.Itoa(int(time.Until(usage.ResetAt).Seconds())+1))
+	apiErr := app_errors.NewAPIError(app_errors.ErrQuotaExceeded, fmt.Sprintf("Daily request quota of %d for group '%s' exceeded, resets at %s", usage.Quota, group.Name, usage.ResetAt.Format(time.RFC3339)))
+	response.ProxyError(c, withClientMessage(group, apiErr, groupErrorMessages(group).RateLimited))
+	return false
+}
+
+// resolveFallbackGroup follows the fallback_group_name chain while the current group has no active keys.
+// Cycles and chains deeper than maxFallbackDepth stop at the last reachable group.
+func (ps *ProxyServer) resolveFallbackGroup(route *fallbackRoute, group *models.Group) *models.Group {
+	current := group
+	for current.Options.FallbackGroupName != "" {
+		hasKeys, err := ps.keyProvider.HasActiveKeys(current)
+		if err != nil {
+			logrus.Warnf("Failed to check active keys for group %s: %v", current.Name, err)
+			return current
+		}
+		if hasKeys {
+			return current
+		}
+
+		fallback := ps.nextFallbackGroup(route, current)
+		if fallback == nil {
+			return current
+		}
+		logrus.Infof("Group %s has no active keys, falling back to group %s", current.Name, fallback.Name)
+		current = fallback
+	}
+	return current
+}
+
+// nextFallbackGroup returns the fallback group of current and records it on the route, or nil when
+// current has none, it was already visited or the route is maxFallbackDepth deep.
+func (ps *ProxyServer) nextFallbackGroup(route *fallbackRoute, current *models.Group) *models.Group {
+	name := current.Options.FallbackGroupName
+	if name == "" || route.depth >= maxFallbackDepth {
+		return nil
+	}
+	if route.visited[name] {
+		logrus.Warnf("Fallback cycle detected at group %s -> %s", current.Name, name)
+		return nil
+	}
+
+	fallback, err := ps.groupManager.GetGroupByName(name)
+	if err != nil {
+		logrus.Warnf("Fallback group %s for group %s not found: %v", name, current.Name, err)
+		return nil
+	}
+	route.visited[name] = true
+	route.depth++
+	return fallback
+}
+
+// failoverFallbackGroup switches a request whose group ran out of usable keys mid-request to the
+// group's fallback group, with the fallback group's overrides applied to the original body.
+func (ps *ProxyServer) failoverFallbackGroup(c *gin.Context, group *models.Group) (*models.Group, channel.ChannelProxy, []byte, bool) {
+	value, exists := c.Get(fallbackRouteContextKey)
+	if !exists {
+		return nil, nil, nil, false
+	}
+	route := value.(*fallbackRoute)
+	// A streamed body has already been consumed and cannot be replayed against another group.
+	if route.passthrough {
+		return nil, nil, nil, false
+	}
+
+	fallback := ps.nextFallbackGroup(route, group)
+	if fallback == nil {
+		return nil, nil, nil, false
+	}
+	channelHandler, err := ps.channelFactory.GetChannel(fallback)
+	if err != nil {
+		logrus.Warnf("Failed to get channel for fallback group %s: %v", fallback.Name, err)
+		return nil, nil, nil, false
+	}
+	var bodyBytes []byte
+	if route.originalBody != nil {
+		var apiErr *app_errors.APIError
+		if bodyBytes, apiErr = ps.prepareRequestBody(c, fallback, channelHandler, route.originalBody); apiErr != nil {
+			logrus.Warnf("Failed to prepare request for fallback group %s: %s", fallback.Name, apiErr.Message)
+			return nil, nil, nil, false
+		}
+	}
+
+	logrus.Infof("Group %s has no usable keys left, falling back to group %s", group.Name, fallback.Name)
+	return fallback, channelHandler, bodyBytes, true
+}
+
+// retryOnFallbackGroup continues a request whose group has no usable keys left on the group's
+// fallback group, which gets its own retries. It returns false when there is no fallback to use.
+func (ps *ProxyServer) retryOnFallbackGroup(c *gin.Context, group *models.Group, isStream bool, startTime time.Time, retryErrors []types.RetryError) bool {
+	nextGroup, nextChannel, nextBody, ok := ps.failoverFallbackGroup(c, group)
+	if !ok {
+		return false
+	}
+	ps.executeRequestWithRetry(c, nextChannel, nextGroup, nextBody, isStream, startTime, 0, retryErrors)
+	return true
+}
+
 // executeRequestWithRetry is the core recursive function for handling requests and retries.
 func (ps *ProxyServer) executeRequestWithRetry(
 	c *gin.Context,
@@ -100,40 +327,57 @@ func (ps *ProxyServer) executeRequestWithRetry(
 ) {
 	cfg := group.EffectiveConfig
 	if retryCount > cfg.MaxRetries {
-		if len(retryErrors) > 0 {
-			lastError := retryErrors[len(retryErrors)-1]
-			var errorJSON map[string]any
-			if err := json.Unmarshal([]byte(lastError.ErrorMessage), &errorJSON); err == nil {
-				c.JSON(lastError.StatusCode, errorJSON)
-			} else {
-				response.Error(c, app_errors.NewAPIErrorWithUpstream(lastError.StatusCode, "UPSTREAM_ERROR", lastError.ErrorMessage))
-			}
-			logMessage := lastError.ParsedErrorMessage
-			if logMessage == "" {
-				logMessage = lastError.ErrorMessage
-			}
-			logrus.Debugf("Max retries exceeded for group %s after %d attempts. Parsed Error: %s", group.Name, retryCount, logMessage)
-
-			ps.logRequest(c, group, &models.APIKey{KeyValue: lastError.KeyValue}, startTime, lastError.StatusCode, retryCount, errors.New(logMessage), isStream, lastError.UpstreamAddr)
-		} else {
-			response.Error(c, app_errors.ErrMaxRetriesExceeded)
-			logrus.Debugf("Max retries exceeded for group %s after %d attempts.", group.Name, retryCount)
-			ps.logRequest(c, group, nil, startTime, http.StatusServiceUnavailable, retryCount, app_errors.ErrMaxRetriesExceeded, isStream, "")
+		// Failures may have blacklisted the group's last keys.
+		if hasKeys, err := ps.keyProvider.HasActiveKeys(group); err == nil && !hasKeys &&
+			ps.retryOnFallbackGroup(c, group, isStream, startTime, retryErrors) {
+			return
 		}
+		ps.respondRetriesExhausted(c, group, bodyBytes, isStream, startTime, retryCount, retryErrors)
+		return
+	}
+	if retryCount > 0 && !ps.spendSessionRetry(c, group) {
+		ps.respondRetriesExhausted(c, group, bodyBytes, isStream, startTime, retryCount, retryErrors)
 		return
 	}
 
-	apiKey, err := ps.keyProvider.SelectKey(group.ID)
+	triedKeys := triedKeyIDs(retryErrors)
+	apiKey, err := ps.keyProvider.SelectKey(group, triedKeys)
+	if errors.Is(err, app_errors.ErrNoActiveKeys) {
+		apiKey, err = ps.keyProvider.SelectLastResortKey(group)
+	}
 	if err != nil {
+		if nextGroup, nextChannel, nextBody, ok := ps.failoverVirtualMember(c); ok {
+			ps.executeRequestWithRetry(c, nextChannel, nextGroup, nextBody, isStream, startTime, retryCount, retryErrors)
+			return
+		}
+		if errors.Is(err, app_errors.ErrNoActiveKeys) && ps.retryOnFallbackGroup(c, group, isStream, startTime, retryErrors) {
+			return
+		}
 		logrus.Errorf("Failed to select a key for group %s on attempt %d: %v", group.Name, retryCount+1, err)
-		response.Error(c, app_errors.NewAPIError(app_errors.ErrNoKeysAvailable, err.Error()))
-		ps.logRequest(c, group, nil, startTime, http.StatusServiceUnavailable, retryCount, err, isStream, "")
+		if errors.Is(err, app_errors.ErrNoActiveKeys) {
+			// Tell well-behaved clients when invalid keys may be back instead of letting them hammer.
+			if wait, ok := ps.keyProvider.EstimateKeyAvailableIn(group); ok {
+				c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			}
+		}
+		apiErr := app_errors.NewAPIError(app_errors.ErrNoKeysAvailable, err.Error())
+		response.ProxyError(c, withClientMessage(group, apiErr, groupErrorMessages(group).NoKeys))
+		ps.logRequest(c, group, nil, startTime, http.StatusServiceUnavailable, retryCount, err, isStream, "", 0)
+		return
+	}
+	// Every active key has already failed for this request; retrying would only reuse them.
+	if triedKeys[apiKey.ID] {
+		logrus.Debugf("All active keys of group %s have been tried after %d attempts", group.Name, retryCount)
+		if ps.retryOnFallbackGroup(c, group, isStream, startTime, retryErrors) {
+			return
+		}
+		ps.respondRetriesExhausted(c, group, bodyBytes, isStream, startTime, retryCount, retryErrors)
 		return
 	}
 
-	upstreamURL, err := channelHandler.BuildUpstreamURL(c.Request.URL, group)
+	upstreamURL, upstream, err := channelHandler.BuildUpstreamURL(c.Request.URL, group, triedUpstreams(retryErrors))
 	if err != nil {
-		response.Error(c, app_errors.NewAPIError(app_errors.ErrInternalServer, fmt.Sprintf("Failed to build upstream URL: %v", err)))
+		response.ProxyError(c, app_errors.NewAPIError(app_errors.ErrInternalServer, fmt.Sprintf("Failed to build upstream URL: %v", err)))
 		return
 	}
 
@@ -147,23 +391,26 @@ func (ps *ProxyServer) executeRequestWithRetry(
 	}
 	defer cancel()
 
-	req, err := http.NewRequestWithContext(ctx, c.Request.Method, upstreamURL, bytes.NewReader(bodyBytes))
+	passthrough := bodyBytes == nil && isPassthroughBody(c.Request)
+	var reqBody io.Reader = bytes.NewReader(bodyBytes)
+	if passthrough {
+		reqBody = c.Request.Body
+	}
+
+	req, err := http.NewRequestWithContext(ctx, c.Request.Method, upstreamURL, reqBody)
 	if err != nil {
 		logrus.Errorf("Failed to create upstream request: %v", err)
-		response.Error(c, app_errors.ErrInternalServer)
+		response.ProxyError(c, app_errors.ErrInternalServer)
 		return
 	}
-	req.ContentLength = int64(len(bodyBytes))
+	if passthrough {
+		req.ContentLength = c.Request.ContentLength
+	} else {
+		req.ContentLength = int64(len(bodyBytes))
+	}
 
 	req.Header = c.Request.Header.Clone()
-
-	// Clean up client auth key
-	req.Header.Del("Authorization")
-	req.Header.Del("X-Api-Key")
-	req.Header.Del("X-Goog-Api-Key")
-	q := req.URL.Query()
-	q.Del("key")
-	req.URL.RawQuery = q.Encode()
+	stripClientAuth(req)
 
 	channelHandler.ModifyRequest(req, apiKey, group)
 
@@ -175,42 +422,80 @@ func (ps *ProxyServer) executeRequestWithRetry(
 		client = channelHandler.GetHTTPClient()
 	}
 
+	upstreamStart := time.Now()
 	resp, err := client.Do(req)
+	upstreamDuration := time.Since(upstreamStart)
 	if resp != nil {
 		defer resp.Body.Close()
 	}
 
+	// Non-streaming bodies are buffered so the channel can also judge the body, since some providers
+	// report errors with a success status. The decompressed body is bounded by the same limit.
+	var respBody, decoded []byte
+	if err == nil && !isStream {
+		if respBody, err = readLimitedBody(resp.Body, cfg.MaxResponseBodyBytes); err == nil {
+			decoded, err = handleGzipCompression(resp, respBody, cfg.MaxResponseBodyBytes)
+		}
+		if errors.Is(err, errResponseTooLarge) {
+			// Another key would get the same response, so the request fails without retrying or blaming the key.
+			channelHandler.ReportUpstreamResult(upstreamURL, true)
+			logrus.Warnf("Upstream response for group %s exceeds %d bytes, returning 502", group.Name, cfg.MaxResponseBodyBytes)
+			response.ProxyError(c, app_errors.ErrResponseTooLarge)
+			ps.logRequest(c, group, apiKey, startTime, http.StatusBadGateway, retryCount+1, err, isStream, upstreamURL, upstreamDuration)
+			return
+		}
+	}
+
 	// Unified error handling for retries.
-	if err != nil || (resp != nil && resp.StatusCode >= 400) {
+	if err != nil || !channelHandler.IsSuccessResponse(resp.StatusCode, decoded) {
 		if err != nil && app_errors.IsIgnorableError(err) {
-			logrus.Debugf("Client-side ignorable error for key %s, aborting retries: %v", utils.MaskAPIKey(apiKey.KeyValue), err)
-			ps.logRequest(c, group, apiKey, startTime, 499, retryCount+1, err, isStream, upstreamURL)
+			// The client went away: neither the key nor the upstream is at fault, so nothing is reported.
+			logrus.WithFields(logrus.Fields{
+				"group":   group.Name,
+				"key":     utils.MaskAPIKey(apiKey.KeyValue),
+				"retries": retryCount,
+			}).Debugf("Request cancelled by client, aborting retries: %v", err)
+			ps.logRequest(c, group, apiKey, startTime, statusClientClosedRequest, retryCount+1, err, isStream, upstreamURL, upstreamDuration)
 			return
 		}
 
-		ps.keyProvider.UpdateStatus(apiKey, group, false)
-
 		var statusCode int
 		var errorMessage string
 		var parsedError string
+		var errorHeader http.Header
 
 		if err != nil {
+			// Connection-level error: the upstream is at fault, not the key.
+			channelHandler.ReportUpstreamResult(upstreamURL, false)
 			statusCode = 500
 			errorMessage = err.Error()
 			logrus.Debugf("Request failed (attempt %d/%d) for key %s: %v", retryCount+1, cfg.MaxRetries, utils.MaskAPIKey(apiKey.KeyValue), err)
 		} else {
-			// HTTP-level error (status >= 400)
+			// HTTP-level error: a failure status, or an error body the channel rejected.
+			channelHandler.ReportUpstreamResult(upstreamURL, true)
 			statusCode = resp.StatusCode
-			errorBody, readErr := io.ReadAll(resp.Body)
-			if readErr != nil {
-				logrus.Errorf("Failed to read error body: %v", readErr)
-				errorBody = []byte("Failed to read error body")
+			if statusCode < 400 {
+				// Relay the error with a failure status rather than the misleading upstream one.
+				statusCode = http.StatusBadGateway
+			}
+			errorBody := decoded
+			if isStream {
+				var readErr error
+				if errorBody, readErr = readLimitedBody(resp.Body, cfg.MaxResponseBodyBytes); readErr == nil {
+					errorBody, readErr = handleGzipCompression(resp, errorBody, cfg.MaxResponseBodyBytes)
+				}
+				if readErr != nil {
+					logrus.Errorf("Failed to read error body: %v", readErr)
+					errorBody = []byte("Failed to read error body")
+				}
 			}
 
-			errorBody = handleGzipCompression(resp, errorBody)
+			errorHeader = forwardedErrorHeaders(resp.Header, cfg.ForwardedErrorHeaders)
 			errorMessage = string(errorBody)
 			parsedError = app_errors.ParseUpstreamError(errorBody)
-			logrus.Debugf("Request failed with status %d (attempt %d/%d) for key %s. Parsed Error: %s", statusCode, retryCount+1, cfg.MaxRetries, utils.MaskAPIKey(apiKey.KeyValue), parsedError)
+			errorClass := app_errors.ClassifyUpstreamError(errorBody, cfg.ErrorRules)
+			logrus.Debugf("Request failed with status %d (attempt %d/%d, %s) for key %s. Parsed Error: %s", statusCode, retryCount+1, cfg.MaxRetries, errorClass, utils.MaskAPIKey(apiKey.KeyValue), parsedError)
+			ps.updateKeyOnError(apiKey, group, errorClass, fmt.Sprintf("[%s] status %d: %s", errorClass, statusCode, parsedError))
 		}
 
 		newRetryErrors := append(retryErrors, types.RetryError{
@@ -218,28 +503,154 @@ func (ps *ProxyServer) executeRequestWithRetry(
 			ErrorMessage:       errorMessage,
 			ParsedErrorMessage: parsedError,
 			KeyValue:           apiKey.KeyValue,
+			KeyID:              apiKey.ID,
 			Attempt:            retryCount + 1,
 			UpstreamAddr:       upstreamURL,
+			Upstream:           upstream,
+			Header:             errorHeader,
 		})
-		ps.executeRequestWithRetry(c, channelHandler, group, bodyBytes, isStream, startTime, retryCount+1, newRetryErrors)
+		nextRetryCount := retryCount + 1
+		if passthrough {
+			// A streamed body has already been consumed and cannot be replayed against another key.
+			nextRetryCount = cfg.MaxRetries + 1
+		}
+		if !passthrough {
+			if nextGroup, nextChannel, nextBody, ok := ps.failoverVirtualMember(c); ok {
+				ps.executeRequestWithRetry(c, nextChannel, nextGroup, nextBody, isStream, startTime, nextRetryCount, newRetryErrors)
+				return
+			}
+		}
+		ps.executeRequestWithRetry(c, channelHandler, group, bodyBytes, isStream, startTime, nextRetryCount, newRetryErrors)
 		return
 	}
 
+	channelHandler.ReportUpstreamResult(upstreamURL, true)
 	// ps.keyProvider.UpdateStatus(apiKey, group, true) // 请求成功不再重置成功次数，减少IO消耗
+	if apiKey.Status == models.KeyStatusInvalid {
+		// A last resort probe succeeded: bring the key back into rotation.
+		ps.keyProvider.UpdateStatus(apiKey, group, true, "")
+	}
 	logrus.Debugf("Request for group %s succeeded on attempt %d with key %s", group.Name, retryCount+1, utils.MaskAPIKey(apiKey.KeyValue))
-	ps.logRequest(c, group, apiKey, startTime, resp.StatusCode, retryCount+1, nil, isStream, upstreamURL)
-
-	for key, values := range resp.Header {
-		for _, value := range values {
-			c.Header(key, value)
+	// The log entry is built before relaying so durations end at the response headers, and recorded
+	// afterwards with the token usage the response reported.
+	logEntry := ps.newRequestLog(c, group, apiKey, startTime, resp.StatusCode, retryCount+1, nil, isStream, upstreamURL, upstreamDuration)
+
+	// Usage and captured bodies come from the upstream response, before any transformation.
+	var responseBody []byte
+	if !isStream {
+		responseBody = decoded
+		if respBody, err = ps.transformResponse(c, group, resp, respBody, responseBody); err != nil {
+			response.ProxyError(c, app_errors.NewAPIError(app_errors.ErrTransformFailed, err.Error()))
+			ps.logRequest(c, group, apiKey, startTime, http.StatusBadGateway, retryCount+1, err, isStream, upstreamURL, upstreamDuration)
+			return
 		}
 	}
+
+	copyResponseHeaders(c, resp.Header)
 	c.Status(resp.StatusCode)
 
+	// A compressed stream is not captured, as its bytes are meaningless without decompressing it.
+	var capture *bodyCapture
+	if isStream && bodyLogOptions(group) != nil && resp.Header.Get("Content-Encoding") == "" {
+		capture = &bodyCapture{}
+	}
+
+	var usage *tokenUsage
 	if isStream {
-		ps.handleStreamingResponse(c, resp)
+		var streamErr string
+		usage, streamErr = ps.handleStreamingResponse(c, resp, capture)
+		if streamErr != "" {
+			// The status was sent with the first bytes; the log still records that the stream failed.
+			logEntry.IsSuccess = false
+			logEntry.ErrorMessage = "upstream stream error: " + streamErr
+		}
+		if capture != nil {
+			responseBody = capture.data
+		}
 	} else {
-		ps.handleNormalResponse(c, resp)
+		usage = parseUsage(responseBody)
+		writeBufferedResponse(c, resp, respBody)
+	}
+	if usage != nil {
+		logEntry.PromptTokens = usage.PromptTokens
+		logEntry.CompletionTokens = usage.CompletionTokens
+	}
+	ps.recordRequestLog(logEntry)
+	ps.recordBodies(group, logEntry, bodyBytes, responseBody, capture != nil && capture.truncated)
+}
+
+// spendSessionRetry takes a retry from the budget of the request's X-Session-Id and reports whether
+// the retry may be made. Once a session has spent its budget, its requests fail after the first attempt.
+func (ps *ProxyServer) spendSessionRetry(c *gin.Context, group *models.Group) bool {
+	sessionID := c.GetHeader(sessionIDHeader)
+	allowed, err := ps.retryBudget.Spend(group, sessionID)
+	if err != nil {
+		// Don't block retries because the store is unavailable.
+		logrus.Warnf("Failed to check session retry budget for group %s: %v", group.Name, err)
+		return true
+	}
+	if !allowed {
+		logrus.Debugf("Session retry budget of group %s exhausted, not retrying request of session %s", group.Name, utils.TruncateString(sessionID, 64))
+	}
+	return allowed
+}
+
+// respondRetriesExhausted relays the last upstream error once no further attempt will be made.
+func (ps *ProxyServer) respondRetriesExhausted(
+	c *gin.Context,
+	group *models.Group,
+	bodyBytes []byte,
+	isStream bool,
+	startTime time.Time,
+	retryCount int,
+	retryErrors []types.RetryError,
+) {
+	messages := groupErrorMessages(group)
+	if len(retryErrors) > 0 {
+		lastError := retryErrors[len(retryErrors)-1]
+		for key, values := range lastError.Header {
+			for _, value := range values {
+				c.Header(key, value)
+			}
+		}
+		// A custom message replaces the upstream error body; the status code is kept. It only covers
+		// upstream failures: other 4xx errors describe the client's request, so they are relayed as is.
+		// Connection errors are recorded with status 500.
+		upstreamFailure := lastError.StatusCode >= 500 || lastError.StatusCode == http.StatusTooManyRequests
+		switch {
+		case lastError.StatusCode == http.StatusTooManyRequests && messages.RateLimited != "":
+			response.ProxyError(c, app_errors.NewAPIErrorWithUpstream(lastError.StatusCode, "RATE_LIMITED", messages.RateLimited))
+		case upstreamFailure && messages.MaxRetries != "":
+			response.ProxyError(c, app_errors.NewAPIErrorWithUpstream(lastError.StatusCode, app_errors.ErrMaxRetriesExceeded.Code, messages.MaxRetries))
+		default:
+			response.UpstreamError(c, lastError.StatusCode, []byte(lastError.ErrorMessage))
+		}
+		logMessage := lastError.ParsedErrorMessage
+		if logMessage == "" {
+			logMessage = lastError.ErrorMessage
+		}
+		logrus.Debugf("Max retries exceeded for group %s after %d attempts. Parsed Error: %s", group.Name, retryCount, logMessage)
+
+		logEntry := ps.newRequestLog(c, group, &models.APIKey{ID: lastError.KeyID, KeyValue: lastError.KeyValue}, startTime, lastError.StatusCode, retryCount, errors.New(logMessage), isStream, lastError.UpstreamAddr, 0)
+		ps.recordRequestLog(logEntry)
+		ps.recordBodies(group, logEntry, bodyBytes, []byte(lastError.ErrorMessage), false)
+	} else {
+		response.ProxyError(c, withClientMessage(group, app_errors.ErrMaxRetriesExceeded, messages.MaxRetries))
+		logrus.Debugf("Max retries exceeded for group %s after %d attempts.", group.Name, retryCount)
+		ps.logRequest(c, group, nil, startTime, http.StatusServiceUnavailable, retryCount, app_errors.ErrMaxRetriesExceeded, isStream, "", 0)
+	}
+}
+
+// updateKeyOnError applies the classification of an upstream error to the key that caused it.
+// reason is recorded on the key if the error gets it blacklisted.
+func (ps *ProxyServer) updateKeyOnError(apiKey *models.APIKey, group *models.Group, errorClass types.ErrorClass, reason string) {
+	switch errorClass {
+	case types.ErrorClassPermanent:
+		ps.keyProvider.MarkInvalid(apiKey, group, reason)
+	case types.ErrorClassRateLimit:
+		ps.keyProvider.Cooldown(apiKey, time.Duration(group.EffectiveConfig.RateLimitCooldownSeconds)*time.Second)
+	default:
+		ps.keyProvider.UpdateStatus(apiKey, group, false, reason)
 	}
 }
 
@@ -254,34 +665,76 @@ func (ps *ProxyServer) logRequest(
 	finalError error,
 	isStream bool,
 	upstreamAddr string,
+	upstreamDuration time.Duration,
 ) {
-	if ps.requestLogService == nil {
-		return
-	}
+	ps.recordRequestLog(ps.newRequestLog(c, group, apiKey, startTime, statusCode, retries, finalError, isStream, upstreamAddr, upstreamDuration))
+}
 
+// newRequestLog creates a request log entry, measuring the duration up to now, and warns about slow requests.
+func (ps *ProxyServer) newRequestLog(
+	c *gin.Context,
+	group *models.Group,
+	apiKey *models.APIKey,
+	startTime time.Time,
+	statusCode int,
+	retries int,
+	finalError error,
+	isStream bool,
+	upstreamAddr string,
+	upstreamDuration time.Duration,
+) *models.RequestLog {
 	duration := time.Since(startTime).Milliseconds()
 
+	if threshold := group.EffectiveConfig.SlowRequestThresholdMs; threshold > 0 && duration > int64(threshold) {
+		fields := logrus.Fields{
+			"group":                group.Name,
+			"duration_ms":          duration,
+			"upstream_duration_ms": upstreamDuration.Milliseconds(),
+			"status_code":          statusCode,
+			"retries":              retries,
+			"is_stream":            isStream,
+		}
+		if apiKey != nil {
+			fields["key"] = utils.MaskAPIKey(apiKey.KeyValue)
+		}
+		logrus.WithFields(fields).Warn("Slow proxy request")
+	}
+
 	logEntry := &models.RequestLog{
-		GroupID:      group.ID,
-		GroupName:    group.Name,
-		IsSuccess:    finalError == nil && statusCode < 400,
-		SourceIP:     c.ClientIP(),
-		StatusCode:   statusCode,
-		RequestPath:  utils.TruncateString(c.Request.URL.String(), 500),
-		Duration:     duration,
-		UserAgent:    c.Request.UserAgent(),
-		Retries:      retries,
-		IsStream:     isStream,
-		UpstreamAddr: utils.TruncateString(upstreamAddr, 500),
-	}
-	if apiKey != nil {
-		logEntry.KeyValue = apiKey.KeyValue
+		GroupID:           group.ID,
+		GroupName:         group.Name,
+		IsSuccess:         finalError == nil && statusCode < 400,
+		IsClientCancelled: statusCode == statusClientClosedRequest,
+		SourceIP:          c.ClientIP(),
+		StatusCode:        statusCode,
+		RequestPath:       utils.TruncateString(c.Request.URL.String(), 500),
+		Duration:          duration,
+		UpstreamDuration:  upstreamDuration.Milliseconds(),
+		UserAgent:         c.Request.UserAgent(),
+		Retries:           retries,
+		IsStream:          isStream,
+		UpstreamAddr:      utils.TruncateString(upstreamAddr, 500),
+		Model:             c.GetString(requestModelContextKey),
+		ProxyKeyHash:      c.GetString(middleware.ProxyKeyHashContextKey),
+	}
+	if apiKey != nil && apiKey.KeyValue != "" {
+		// The plaintext key is never logged: the entry keeps the key's ID, masked value and hash.
+		logEntry.APIKeyID = apiKey.ID
+		logEntry.KeyValue = utils.MaskAPIKey(apiKey.KeyValue)
+		logEntry.KeyHash = utils.HashKey(apiKey.KeyValue)
 	}
 
 	if finalError != nil {
 		logEntry.ErrorMessage = finalError.Error()
 	}
+	return logEntry
+}
 
+// recordRequestLog hands a request log entry to the request log service.
+func (ps *ProxyServer) recordRequestLog(logEntry *models.RequestLog) {
+	if ps.requestLogService == nil {
+		return
+	}
 	if err := ps.requestLogService.Record(logEntry); err != nil {
 		logrus.Errorf("Failed to record request log: %v", err)
 	}
@@ -0,0 +1,589 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"maps"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gpt-load/internal/channel"
+	"gpt-load/internal/config"
+	"gpt-load/internal/httpclient"
+	"gpt-load/internal/keypool"
+	"gpt-load/internal/models"
+	"gpt-load/internal/services"
+	"gpt-load/internal/store"
+	"gpt-load/internal/testutil"
+	"gpt-load/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+)
+
+// testProxy is a proxy server over an in-memory database and store, with one group whose upstream
+// is a test server.
+type testProxy struct {
+	server   *ProxyServer
+	router   *gin.Engine
+	group    *models.Group
+	keys     []models.APIKey
+	provider *keypool.KeyProvider
+}
+
+// testGroup describes a group created by newTestProxyGroups.
+type testGroup struct {
+	name string
+	// channelType defaults to openai. For a virtual group, upstreamURL is the member group's name.
+	channelType    string
+	upstreamURL    string
+	config         map[string]any
+	paramOverrides map[string]any
+	keys           []string
+}
+
+// newTestProxy creates a proxy for an openai group named "test" that forwards to upstreamURL and
+// holds the given keys. groupConfig is the group's config JSON, or nil.
+func newTestProxy(t *testing.T, upstreamURL string, groupConfig map[string]any, keyValues ...string) *testProxy {
+	t.Helper()
+	return newTestProxyGroups(t, testGroup{name: "test", upstreamURL: upstreamURL, config: groupConfig, keys: keyValues})
+}
+
+// newTestProxyGroups creates a proxy for the given groups. The returned group and keys are
+// those of the first group.
+func newTestProxyGroups(t *testing.T, groups ...testGroup) *testProxy {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	database := testutil.OpenDB(t, &models.SystemSetting{}, &models.Group{}, &models.APIKey{})
+	testutil.UseDB(t, database)
+
+	memoryStore := store.NewMemoryStore()
+	settingsManager := config.NewSystemSettingsManager()
+	if err := settingsManager.Initialize(memoryStore, nil, false); err != nil {
+		t.Fatalf("failed to initialize settings: %v", err)
+	}
+	t.Cleanup(func() { settingsManager.Stop(context.Background()) })
+
+	provider := keypool.NewProvider(database, memoryStore, settingsManager, testutil.NoEncryption(t))
+
+	var firstKeys []models.APIKey
+	for i, spec := range groups {
+		upstreams, _ := json.Marshal([]map[string]any{{"url": spec.upstreamURL, "weight": 1}})
+		channelType := spec.channelType
+		if channelType == "" {
+			channelType = "openai"
+		}
+		group := &models.Group{
+			Name:           spec.name,
+			ChannelType:    channelType,
+			TestModel:      "gpt-4o-mini",
+			Upstreams:      datatypes.JSON(upstreams),
+			Config:         datatypes.JSONMap(spec.config),
+			ParamOverrides: datatypes.JSONMap(spec.paramOverrides),
+		}
+		if err := database.Create(group).Error; err != nil {
+			t.Fatalf("failed to create group %s: %v", spec.name, err)
+		}
+
+		keys := make([]models.APIKey, len(spec.keys))
+		for j, value := range spec.keys {
+			keys[j] = models.APIKey{GroupID: group.ID, KeyValue: value, Status: models.KeyStatusActive}
+		}
+		if len(keys) > 0 {
+			if err := provider.AddKeys(group.ID, keys); err != nil {
+				t.Fatalf("failed to add keys to %s: %v", spec.name, err)
+			}
+		}
+		if i == 0 {
+			firstKeys = keys
+		}
+	}
+
+	groupManager := services.NewGroupManager(database, memoryStore, settingsManager)
+	if err := groupManager.Initialize(); err != nil {
+		t.Fatalf("failed to initialize groups: %v", err)
+	}
+	t.Cleanup(func() { groupManager.Stop(context.Background()) })
+	cached, err := groupManager.GetGroupByName(groups[0].name)
+	if err != nil {
+		t.Fatalf("failed to load group: %v", err)
+	}
+
+	server, _ := NewProxyServer(
+		provider,
+		groupManager,
+		settingsManager,
+		channel.NewFactory(settingsManager, httpclient.NewHTTPClientManager()),
+		nil,
+		nil,
+		services.NewGroupQuotaService(memoryStore, settingsManager),
+		nil,
+		services.NewSessionRetryBudgetService(memoryStore),
+	)
+	router := gin.New()
+	router.Any("/proxy/:group_name/*path", server.HandleProxy)
+
+	return &testProxy{server: server, router: router, group: cached, keys: firstKeys, provider: provider}
+}
+
+// do sends a request through the proxy and returns the recorded response.
+func (tp *testProxy) do(req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	tp.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestHandleProxyStreamsMultipartBody(t *testing.T) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("model", "whisper-1")
+	file, _ := form.CreateFormFile("file", "audio.mp3")
+	file.Write(bytes.Repeat([]byte{0xff, 0x00, '{'}, 1000))
+	form.Close()
+	sent := body.Bytes()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Content-Type"); got != form.FormDataContentType() {
+			t.Errorf("upstream Content-Type = %q, want %q", got, form.FormDataContentType())
+		}
+		if r.ContentLength != int64(len(sent)) {
+			t.Errorf("upstream Content-Length = %d, want %d", r.ContentLength, len(sent))
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer sk-a" {
+			t.Errorf("upstream Authorization = %q", got)
+		}
+		received, _ := io.ReadAll(r.Body)
+		if !bytes.Equal(received, sent) {
+			t.Errorf("upstream body differs from the uploaded body: got %d bytes, want %d", len(received), len(sent))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"text":"hello"}`))
+	}))
+	defer upstream.Close()
+	tp := newTestProxy(t, upstream.URL, nil, "sk-a")
+
+	req := httptest.NewRequest(http.MethodPost, "/proxy/test/v1/audio/transcriptions", bytes.NewReader(sent))
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	w := tp.do(req)
+	if w.Code != http.StatusOK || w.Body.String() != `{"text":"hello"}` {
+		t.Fatalf("got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestHandleProxyFallsBackWhenKeysFailMidRequest(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":{"message":"Incorrect API key provided"}}`))
+	}))
+	defer primary.Close()
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("backup upstream path = %q", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer sk-backup" {
+			t.Errorf("backup upstream Authorization = %q", got)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"backup"}`))
+	}))
+	defer backup.Close()
+
+	tp := newTestProxyGroups(t,
+		testGroup{name: "test", upstreamURL: primary.URL, config: map[string]any{"fallback_group_name": "backup"}, keys: []string{"sk-a"}},
+		testGroup{name: "backup", upstreamURL: backup.URL, keys: []string{"sk-backup"}},
+	)
+
+	req := httptest.NewRequest(http.MethodPost, "/proxy/test/v1/chat/completions", bytes.NewReader([]byte(`{"model":"gpt-4o-mini","messages":[]}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w := tp.do(req)
+	if w.Code != http.StatusOK || w.Body.String() != `{"id":"backup"}` {
+		t.Fatalf("got %d %s, want the backup group's response", w.Code, w.Body.String())
+	}
+}
+
+func TestHandleProxyTransformsVirtualMemberRequests(t *testing.T) {
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload transformPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		if payload.Phase != "request" {
+			w.Write([]byte(`{}`))
+			return
+		}
+		if payload.Group != "member" {
+			t.Errorf("transform webhook group = %q, want member", payload.Group)
+		}
+		w.Write([]byte(`{"body":"{\"model\":\"transformed\"}"}`))
+	}))
+	defer webhook.Close()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ := io.ReadAll(r.Body)
+		if string(received) != `{"model":"transformed"}` {
+			t.Errorf("upstream body = %s, want the transformed body", received)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"ok"}`))
+	}))
+	defer upstream.Close()
+
+	tp := newTestProxyGroups(t,
+		testGroup{name: "test", channelType: channel.VirtualChannelType, upstreamURL: "member"},
+		testGroup{name: "member", upstreamURL: upstream.URL, config: map[string]any{"transform_webhook_url": webhook.URL}, keys: []string{"sk-a"}},
+	)
+
+	req := httptest.NewRequest(http.MethodPost, "/proxy/test/v1/chat/completions", bytes.NewReader([]byte(`{"model":"gpt-4o-mini"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w := tp.do(req)
+	if w.Code != http.StatusOK || w.Body.String() != `{"id":"ok"}` {
+		t.Fatalf("got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestHandleProxyMirrorsUntransformedRequestToShadowGroup(t *testing.T) {
+	// The shadow response arrives while the fallback group's transform webhook is being called, so the
+	// shadow request is logged while the transform rewrites the request.
+	transforming := make(chan struct{})
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload transformPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		if payload.Phase != "request" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		close(transforming)
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte(`{"method":"PUT","path":"v1/responses","headers":{"Content-Type":["application/json"],"X-Transformed":["yes"]}}`))
+	}))
+	defer webhook.Close()
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":{"message":"Incorrect API key provided"}}`))
+	}))
+	defer primary.Close()
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.URL.Path != "/v1/responses" || r.Header.Get("X-Transformed") != "yes" {
+			t.Errorf("backup upstream got %s %s X-Transformed=%q, want the transformed request", r.Method, r.URL.Path, r.Header.Get("X-Transformed"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"backup"}`))
+	}))
+	defer backup.Close()
+	type shadowRequest struct {
+		method, path, transformed string
+	}
+	shadowReceived := make(chan shadowRequest, 1)
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		shadowReceived <- shadowRequest{r.Method, r.URL.Path, r.Header.Get("X-Transformed")}
+		select {
+		case <-transforming:
+		case <-time.After(5 * time.Second):
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"shadow"}`))
+	}))
+	defer shadow.Close()
+
+	tp := newTestProxyGroups(t,
+		testGroup{name: "test", upstreamURL: primary.URL, config: map[string]any{
+			"fallback_group_name": "backup", "shadow_group": "shadow", "shadow_percent": 100,
+		}, keys: []string{"sk-a"}},
+		testGroup{name: "backup", upstreamURL: backup.URL, config: map[string]any{"transform_webhook_url": webhook.URL}, keys: []string{"sk-backup"}},
+		testGroup{name: "shadow", upstreamURL: shadow.URL, keys: []string{"sk-shadow"}},
+	)
+
+	req := httptest.NewRequest(http.MethodPost, "/proxy/test/v1/chat/completions", bytes.NewReader([]byte(`{"model":"gpt-4o-mini","messages":[]}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w := tp.do(req)
+	if w.Code != http.StatusOK || w.Body.String() != `{"id":"backup"}` {
+		t.Fatalf("got %d %s, want the backup group's response", w.Code, w.Body.String())
+	}
+
+	select {
+	case got := <-shadowReceived:
+		if got != (shadowRequest{http.MethodPost, "/v1/chat/completions", ""}) {
+			t.Errorf("shadow upstream got %+v, want the client's request", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("shadow request was not sent")
+	}
+}
+
+func TestNewRequestLogOmitsPlaintextKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/proxy/test/v1/chat/completions", nil)
+
+	const key = "sk-0123456789abcdef"
+	ps := &ProxyServer{}
+	logEntry := ps.newRequestLog(c, &models.Group{ID: 1, Name: "test"}, &models.APIKey{ID: 7, KeyValue: key}, time.Now(), http.StatusOK, 0, nil, false, "", 0)
+
+	if logEntry.APIKeyID != 7 {
+		t.Errorf("APIKeyID = %d, want 7", logEntry.APIKeyID)
+	}
+	if logEntry.KeyValue != utils.MaskAPIKey(key) {
+		t.Errorf("KeyValue = %q, want the masked key", logEntry.KeyValue)
+	}
+	if logEntry.KeyHash != utils.HashKey(key) {
+		t.Errorf("KeyHash = %q, want the key's hash", logEntry.KeyHash)
+	}
+	encoded, _ := json.Marshal(logEntry)
+	if bytes.Contains(encoded, []byte(key)) {
+		t.Errorf("request log contains the plaintext key: %s", encoded)
+	}
+}
+
+func TestHandleProxyForwardsBodylessRequests(t *testing.T) {
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		t.Run(method, func(t *testing.T) {
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != method || r.URL.Path != "/v1/models" {
+					t.Errorf("upstream got %s %s", r.Method, r.URL.Path)
+				}
+				if r.URL.RawQuery != "limit=5" {
+					t.Errorf("upstream query = %q, want limit=5", r.URL.RawQuery)
+				}
+				if received, _ := io.ReadAll(r.Body); len(received) != 0 {
+					t.Errorf("upstream got a body: %s", received)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"object":"list","data":[{"id":"gpt-4o"}]}`))
+			}))
+			defer upstream.Close()
+			// Parameter overrides apply to JSON bodies only and must not give the request a body.
+			tp := newTestProxyGroups(t, testGroup{
+				name:           "test",
+				upstreamURL:    upstream.URL,
+				paramOverrides: map[string]any{"temperature": 0.5},
+				keys:           []string{"sk-a"},
+			})
+
+			w := tp.do(httptest.NewRequest(method, "/proxy/test/v1/models?limit=5", nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", w.Code, w.Body.String())
+			}
+			if method == http.MethodGet && w.Body.String() != `{"object":"list","data":[{"id":"gpt-4o"}]}` {
+				t.Errorf("body = %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandleProxyRetriesEachKeyOnce(t *testing.T) {
+	var mu sync.Mutex
+	attempts := make(map[string]int)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		attempts[r.Header.Get("Authorization")]++
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error":{"message":"upstream failure"}}`))
+	}))
+	defer upstream.Close()
+	tp := newTestProxy(t, upstream.URL, map[string]any{"max_retries": 5}, "sk-a", "sk-b")
+
+	req := httptest.NewRequest(http.MethodPost, "/proxy/test/v1/chat/completions", bytes.NewReader([]byte(`{"model":"gpt-4o-mini"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w := tp.do(req)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want the upstream's 500", w.Code)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := map[string]int{"Bearer sk-a": 1, "Bearer sk-b": 1}
+	if !maps.Equal(attempts, want) {
+		t.Errorf("upstream attempts = %v, want one per key %v", attempts, want)
+	}
+}
+
+func TestHandleProxyRetriesSuccessStatusWithErrorBody(t *testing.T) {
+	var mu sync.Mutex
+	var attempts []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		mu.Lock()
+		attempts = append(attempts, auth)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		if auth == "Bearer sk-a" {
+			// Some providers report errors with a 200 status.
+			w.Write([]byte(`{"error":{"message":"quota exceeded"}}`))
+			return
+		}
+		w.Write([]byte(`{"id":"ok"}`))
+	}))
+	defer upstream.Close()
+	tp := newTestProxy(t, upstream.URL, nil, "sk-a", "sk-b")
+
+	req := httptest.NewRequest(http.MethodPost, "/proxy/test/v1/chat/completions", bytes.NewReader([]byte(`{"model":"gpt-4o-mini"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w := tp.do(req)
+	if w.Code != http.StatusOK || w.Body.String() != `{"id":"ok"}` {
+		t.Fatalf("got %d %s, want the response of the working key", w.Code, w.Body.String())
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if got := attempts[len(attempts)-1]; got != "Bearer sk-b" {
+		t.Errorf("attempts = %v, want the last one with sk-b", attempts)
+	}
+}
+
+func TestHandleProxyRejectsOversizedResponses(t *testing.T) {
+	tests := []struct {
+		name string
+		gzip bool
+	}{
+		{"plain", false},
+		// Small on the wire, but far over the limit once decompressed.
+		{"gzip", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			attempts := 0
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				attempts++
+				mu.Unlock()
+				body := bytes.Repeat([]byte("a"), 64*1024)
+				w.Header().Set("Content-Type", "application/json")
+				if tt.gzip {
+					w.Header().Set("Content-Encoding", "gzip")
+					body = gzipped(body)
+				}
+				w.Write(body)
+			}))
+			defer upstream.Close()
+			tp := newTestProxy(t, upstream.URL, map[string]any{"max_response_body_bytes": 4096}, "sk-a", "sk-b")
+
+			req := httptest.NewRequest(http.MethodPost, "/proxy/test/v1/chat/completions", bytes.NewReader([]byte(`{"model":"gpt-4o-mini"}`)))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Accept-Encoding", "gzip")
+			w := tp.do(req)
+			if w.Code != http.StatusBadGateway || !strings.Contains(w.Body.String(), "response_too_large") {
+				t.Fatalf("got %d %s, want 502 response_too_large", w.Code, w.Body.String())
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if attempts != 1 {
+				t.Errorf("upstream attempts = %d, want 1: an oversized response is not retried", attempts)
+			}
+		})
+	}
+}
+
+func TestHandleProxyReframesChunkedUpstreamResponses(t *testing.T) {
+	const body = `{"id":"chatcmpl-1","choices":[]}`
+	const stream = "data: {\"id\":\"1\"}\n\ndata: [DONE]\n\n"
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "req-1")
+		w.Header().Set("Connection", "X-Hop")
+		w.Header().Set("X-Hop", "1")
+		// Flushing before the end leaves the length unknown, so the upstream answers chunked.
+		if strings.Contains(r.URL.Path, "stream") {
+			w.Header().Set("Content-Type", "text/event-stream")
+			for _, event := range strings.SplitAfter(stream, "\n\n") {
+				io.WriteString(w, event)
+				w.(http.Flusher).Flush()
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, body[:10])
+		w.(http.Flusher).Flush()
+		io.WriteString(w, body[10:])
+	}))
+	defer upstream.Close()
+	tp := newTestProxy(t, upstream.URL, nil, "sk-a")
+	proxy := httptest.NewServer(tp.router)
+	defer proxy.Close()
+
+	tests := []struct {
+		name              string
+		path              string
+		reqBody           string
+		want              string
+		wantContentLength int64
+	}{
+		{"buffered", "/proxy/test/v1/chat/completions", `{"model":"gpt-4o-mini"}`, body, int64(len(body))},
+		{"streamed", "/proxy/test/v1/stream/chat/completions", `{"model":"gpt-4o-mini","stream":true}`, stream, -1},
+	}
+	for _, tt := range tests {
+		resp, err := http.Post(proxy.URL+tt.path, "application/json", strings.NewReader(tt.reqBody))
+		if err != nil {
+			t.Fatalf("%s: request failed: %v", tt.name, err)
+		}
+		got, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("%s: reading the response failed: %v", tt.name, err)
+		}
+
+		if resp.StatusCode != http.StatusOK || string(got) != tt.want {
+			t.Errorf("%s: got %d %q, want 200 %q", tt.name, resp.StatusCode, got, tt.want)
+		}
+		if resp.ContentLength != tt.wantContentLength {
+			t.Errorf("%s: Content-Length = %d, want %d", tt.name, resp.ContentLength, tt.wantContentLength)
+		}
+		if resp.Header.Get("X-Request-Id") != "req-1" {
+			t.Errorf("%s: X-Request-Id was not relayed", tt.name)
+		}
+		if resp.Header.Get("X-Hop") != "" {
+			t.Errorf("%s: X-Hop, named in the upstream Connection header, was relayed", tt.name)
+		}
+	}
+}
+
+func TestHandleProxyCustomMaxRetriesMessageCoversUpstreamFailuresOnly(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		wantCustom bool
+	}{
+		{"server error", http.StatusServiceUnavailable, true},
+		{"rate limited", http.StatusTooManyRequests, true},
+		{"bad request", http.StatusBadRequest, false},
+		{"not found", http.StatusNotFound, false},
+	}
+	const upstreamError = `{"error":{"message":"upstream says no","type":"invalid_request_error"}}`
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				io.WriteString(w, upstreamError)
+			}))
+			defer upstream.Close()
+			tp := newTestProxy(t, upstream.URL, map[string]any{
+				"max_retries":    0,
+				"error_messages": map[string]any{"max_retries": "Upstream temporarily unavailable"},
+			}, "sk-a")
+
+			req := httptest.NewRequest(http.MethodPost, "/proxy/test/v1/chat/completions", bytes.NewReader([]byte(`{"model":"gpt-4o-mini"}`)))
+			req.Header.Set("Content-Type", "application/json")
+			w := tp.do(req)
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want the upstream %d", w.Code, tt.status)
+			}
+			gotCustom := strings.Contains(w.Body.String(), "Upstream temporarily unavailable")
+			if gotCustom != tt.wantCustom {
+				t.Errorf("body = %s, custom message used = %v, want %v", w.Body.String(), gotCustom, tt.wantCustom)
+			}
+			if !tt.wantCustom && !strings.Contains(w.Body.String(), "upstream says no") {
+				t.Errorf("body = %s, want the upstream error", w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// maxInFlightShadowRequests caps the concurrent shadow requests of a node. Requests sampled beyond it
+// are not mirrored, so a slow shadow group can never pile up goroutines or memory.
+const maxInFlightShadowRequests = 64
+
+// mirrorToShadowGroup sends a copy of the request to the group's shadow_group for shadow_percent of
+// requests. The copy runs in the background after the body has been buffered, so it adds no latency
+// to the client's request and does not use its retries.
+//
+// With shadow_compare enabled it also records the primary response for comparison and returns the
+// capture, which the caller must finish once the primary request is done. Otherwise it returns nil.
+func (ps *ProxyServer) mirrorToShadowGroup(c *gin.Context, group *models.Group, bodyBytes []byte, isStream bool) *primaryCapture {
+	groupOptions := group.Options
+	if groupOptions.ShadowGroup == "" || groupOptions.ShadowPercent <= 0 {
+		return nil
+	}
+	if rand.Intn(100) >= groupOptions.ShadowPercent {
+		return nil
+	}
+
+	select {
+	case ps.shadowSlots <- struct{}{}:
+	default:
+		logrus.Debugf("Skipping shadow request of group %s: %d shadow requests in flight", group.Name, maxInFlightShadowRequests)
+		return nil
+	}
+
+	var capture *primaryCapture
+	if groupOptions.ShadowCompare {
+		capture = capturePrimaryResponse(c, isStream)
+	}
+
+	// The gin context is reused once the handler returns, so the shadow request works on a copy. The
+	// copy gets a snapshot of the request, which the primary request may still rewrite, such as the
+	// transform webhook of a fallback group.
+	shadowCtx := c.Copy()
+	shadowCtx.Request = c.Request.Clone(context.Background())
+	go func() {
+		defer func() { <-ps.shadowSlots }()
+		ps.sendShadowRequest(shadowCtx, group, groupOptions, bodyBytes, isStream, capture)
+	}()
+	return capture
+}
+
+// sendShadowRequest sends a single attempt of the request to the shadow group, discards the response
+// and records the result as a shadow request log entry of the shadow group. With a primary capture,
+// the response is also compared with the primary response.
+func (ps *ProxyServer) sendShadowRequest(
+	c *gin.Context,
+	primary *models.Group,
+	primaryOptions models.GroupConfig,
+	bodyBytes []byte,
+	isStream bool,
+	capture *primaryCapture,
+) {
+	shadowGroupName := primaryOptions.ShadowGroup
+	startTime := time.Now()
+
+	group, err := ps.groupManager.GetGroupByName(shadowGroupName)
+	if err != nil {
+		logrus.Warnf("Shadow group %s of group %s not found: %v", shadowGroupName, primary.Name, err)
+		return
+	}
+	channelHandler, err := ps.channelFactory.GetChannel(group)
+	if err != nil {
+		logrus.Warnf("Failed to get channel for shadow group %s: %v", group.Name, err)
+		return
+	}
+	body, err := ps.applyParamOverrides(bodyBytes, group, channelHandler, c.Request.URL.Path)
+	if err != nil {
+		logrus.Warnf("Failed to apply parameter overrides for shadow group %s: %v", group.Name, err)
+		return
+	}
+	apiKey, err := ps.keyProvider.SelectKey(group, nil)
+	if err != nil {
+		logrus.Debugf("No key for shadow request to group %s: %v", group.Name, err)
+		return
+	}
+
+	// The request path names the primary group; the shadow group's upstream URL is built from its own.
+	shadowURL := *c.Request.URL
+	if rest, ok := strings.CutPrefix(shadowURL.Path, "/proxy/"+primary.Name); ok {
+		shadowURL.Path = "/proxy/" + group.Name + rest
+		shadowURL.RawPath = ""
+	}
+	upstreamURL, _, err := channelHandler.BuildUpstreamURL(&shadowURL, group, nil)
+	if err != nil {
+		logrus.Warnf("Failed to build upstream URL for shadow group %s: %v", group.Name, err)
+		return
+	}
+
+	cfg := group.EffectiveConfig
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.RequestTimeout)*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, c.Request.Method, upstreamURL, bytes.NewReader(body))
+	if err != nil {
+		logrus.Warnf("Failed to create shadow request for group %s: %v", group.Name, err)
+		return
+	}
+	req.Header = c.Request.Header.Clone()
+	stripClientAuth(req)
+	channelHandler.ModifyRequest(req, apiKey, group)
+
+	client := channelHandler.GetHTTPClient()
+	if isStream {
+		client = channelHandler.GetStreamClient()
+	}
+
+	upstreamStart := time.Now()
+	resp, err := client.Do(req)
+	upstreamDuration := time.Since(upstreamStart)
+
+	statusCode := http.StatusInternalServerError
+	var shadowErr error
+	var comparedBody []byte
+	if err != nil {
+		channelHandler.ReportUpstreamResult(upstreamURL, false)
+		shadowErr = err
+	} else {
+		defer resp.Body.Close()
+		channelHandler.ReportUpstreamResult(upstreamURL, true)
+		statusCode = resp.StatusCode
+
+		// respBody is the decompressed body, or nil for a successful stream.
+		var respBody []byte
+		if isStream && resp.StatusCode < 400 {
+			_, err = io.Copy(io.Discard, resp.Body)
+		} else if respBody, err = readLimitedBody(resp.Body, cfg.MaxResponseBodyBytes); err == nil {
+			respBody, err = handleGzipCompression(resp, respBody, cfg.MaxResponseBodyBytes)
+		}
+		switch {
+		case err != nil:
+			shadowErr = err
+		case !channelHandler.IsSuccessResponse(resp.StatusCode, respBody):
+			parsedError := app_errors.ParseUpstreamError(respBody)
+			errorClass := app_errors.ClassifyUpstreamError(respBody, cfg.ErrorRules)
+			ps.updateKeyOnError(apiKey, group, errorClass, fmt.Sprintf("[%s] status %d: %s", errorClass, statusCode, parsedError))
+			shadowErr = fmt.Errorf("shadow request failed with status %d: %s", statusCode, parsedError)
+			if statusCode < 400 {
+				statusCode = http.StatusBadGateway
+			}
+		}
+		if err == nil && !isStream {
+			comparedBody = respBody
+		}
+	}
+
+	logrus.Debugf("Shadow request of group %s to group %s finished with status %d in %s", primary.Name, group.Name, statusCode, time.Since(startTime))
+	logEntry := ps.newRequestLog(c, group, apiKey, startTime, statusCode, 1, shadowErr, isStream, upstreamURL, upstreamDuration)
+	logEntry.IsShadow = true
+	ps.recordRequestLog(logEntry)
+
+	if capture != nil {
+		ps.compareWithPrimary(c, primary, group, capture, primaryOptions.ShadowCompareFields, statusCode, comparedBody)
+	}
+}
@@ -0,0 +1,182 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"gpt-load/internal/models"
+	"gpt-load/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/datatypes"
+)
+
+const (
+	// maxComparedBodyBytes caps the primary response body kept for comparison. Larger bodies are
+	// compared by status code only.
+	maxComparedBodyBytes = 1024 * 1024
+	// maxComparedValueLength caps each value stored in a comparison result.
+	maxComparedValueLength = 500
+)
+
+// primaryCapture records the primary response of a request mirrored with shadow_compare and hands it
+// to the shadow request once the primary request is done.
+type primaryCapture struct {
+	recorder *responseRecorder
+	isStream bool
+	result   chan *recordedResponse
+}
+
+// capturePrimaryResponse starts recording the response written to the client.
+func capturePrimaryResponse(c *gin.Context, isStream bool) *primaryCapture {
+	limit := maxComparedBodyBytes
+	if isStream {
+		// Only the status code of streams is compared.
+		limit = 0
+	}
+	recorder := &responseRecorder{ResponseWriter: c.Writer, limit: limit}
+	c.Writer = recorder
+	return &primaryCapture{recorder: recorder, isStream: isStream, result: make(chan *recordedResponse, 1)}
+}
+
+// finish restores the client's writer and passes the recorded response to the shadow request,
+// or nil if no response was written.
+func (p *primaryCapture) finish(c *gin.Context) {
+	c.Writer = p.recorder.ResponseWriter
+	if !p.recorder.Written() {
+		p.result <- nil
+		return
+	}
+	p.result <- p.recorder.response()
+}
+
+// compareWithPrimary waits for the primary response, compares it with the shadow response and records
+// the result if they diverge. shadowBody is nil when the shadow response body was not read.
+func (ps *ProxyServer) compareWithPrimary(
+	c *gin.Context,
+	primaryGroup, shadowGroup *models.Group,
+	capture *primaryCapture,
+	fields []string,
+	shadowStatus int,
+	shadowBody []byte,
+) {
+	var primary *recordedResponse
+	select {
+	case primary = <-capture.result:
+	case <-time.After(time.Duration(primaryGroup.EffectiveConfig.RequestTimeout) * time.Second):
+		logrus.Debugf("Skipping shadow comparison of group %s: primary response not ready in time", primaryGroup.Name)
+		return
+	}
+	if primary == nil {
+		return
+	}
+
+	differences := make([]models.ShadowDifference, 0)
+	if primary.status != shadowStatus {
+		differences = append(differences, models.ShadowDifference{
+			Field:   "status_code",
+			Primary: strconv.Itoa(primary.status),
+			Shadow:  strconv.Itoa(shadowStatus),
+		})
+	}
+
+	fieldsCompared := false
+	if len(fields) > 0 && !capture.isStream && primary.body != nil && shadowBody != nil {
+		primaryBody, err := handleGzipCompression(&http.Response{Header: primary.header}, primary.body, primaryGroup.EffectiveConfig.MaxResponseBodyBytes)
+		var primaryData, shadowData any
+		if err == nil && json.Unmarshal(primaryBody, &primaryData) == nil && json.Unmarshal(shadowBody, &shadowData) == nil {
+			fieldsCompared = true
+			differences = append(differences, compareJSONFields(fields, primaryData, shadowData)...)
+		}
+	}
+
+	if len(differences) == 0 {
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"group":          primaryGroup.Name,
+		"shadowGroup":    shadowGroup.Name,
+		"path":           c.Request.URL.Path,
+		"primaryStatus":  primary.status,
+		"shadowStatus":   shadowStatus,
+		"differentCount": len(differences),
+	}).Info("Shadow response diverged from primary response")
+
+	ps.comparisonService.Record(&models.ShadowComparison{
+		GroupID:         primaryGroup.ID,
+		GroupName:       primaryGroup.Name,
+		ShadowGroupID:   shadowGroup.ID,
+		ShadowGroupName: shadowGroup.Name,
+		RequestPath:     utils.TruncateString(c.Request.URL.Path, 500),
+		Model:           c.GetString(requestModelContextKey),
+		IsStream:        capture.isStream,
+		PrimaryStatus:   primary.status,
+		ShadowStatus:    shadowStatus,
+		FieldsCompared:  fieldsCompared,
+		Differences:     datatypes.NewJSONType(differences),
+	})
+}
+
+// compareJSONFields returns the fields whose values differ between the primary and shadow responses.
+// A field missing from one side counts as a difference.
+func compareJSONFields(fields []string, primaryData, shadowData any) []models.ShadowDifference {
+	var differences []models.ShadowDifference
+	for _, field := range fields {
+		segments, err := utils.SplitParamPath(field)
+		if err != nil {
+			continue
+		}
+		primaryValue, primaryFound := lookupJSONPath(primaryData, segments)
+		shadowValue, shadowFound := lookupJSONPath(shadowData, segments)
+		if primaryFound == shadowFound && reflect.DeepEqual(primaryValue, shadowValue) {
+			continue
+		}
+		differences = append(differences, models.ShadowDifference{
+			Field:   field,
+			Primary: comparedValue(primaryValue, primaryFound),
+			Shadow:  comparedValue(shadowValue, shadowFound),
+		})
+	}
+	return differences
+}
+
+// lookupJSONPath walks decoded JSON along segments, using object keys and array indexes.
+func lookupJSONPath(data any, segments []string) (any, bool) {
+	current := data
+	for _, segment := range segments {
+		switch node := current.(type) {
+		case map[string]any:
+			next, ok := node[segment]
+			if !ok {
+				return nil, false
+			}
+			current = next
+		case []any:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, false
+			}
+			current = node[index]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// comparedValue encodes a compared value for storage, or "" if the field was not found.
+func comparedValue(value any, found bool) string {
+	if !found {
+		return ""
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return utils.TruncateString(string(encoded), maxComparedValueLength)
+}
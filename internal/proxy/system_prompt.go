@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"gpt-load/internal/models"
+)
+
+// applyForcedSystemPrompt injects the group's forced system prompt into a chat request body.
+// Bodies without a "messages" array are left untouched. Anthropic takes the system prompt in the
+// top-level "system" field; other channels take it as a "system" message in the messages array.
+func applyForcedSystemPrompt(requestData map[string]any, channelType string, prompt *models.ForceSystemPrompt) {
+	messages, ok := requestData["messages"].([]any)
+	if !ok {
+		return
+	}
+
+	if channelType == "anthropic" {
+		requestData["system"] = mergeSystemContent(requestData["system"], prompt)
+		return
+	}
+
+	systemIndex := -1
+	for i, message := range messages {
+		if m, ok := message.(map[string]any); ok && m["role"] == "system" {
+			systemIndex = i
+			break
+		}
+	}
+
+	if prompt.Mode == models.SystemPromptModeReplace {
+		kept := make([]any, 0, len(messages)+1)
+		kept = append(kept, map[string]any{"role": "system", "content": prompt.Content})
+		for _, message := range messages {
+			if m, ok := message.(map[string]any); ok && m["role"] == "system" {
+				continue
+			}
+			kept = append(kept, message)
+		}
+		requestData["messages"] = kept
+		return
+	}
+
+	if systemIndex < 0 {
+		requestData["messages"] = append([]any{map[string]any{"role": "system", "content": prompt.Content}}, messages...)
+		return
+	}
+	systemMessage := messages[systemIndex].(map[string]any)
+	systemMessage["content"] = mergeSystemContent(systemMessage["content"], prompt)
+}
+
+// mergeSystemContent combines the client's system content, a string or an array of text parts,
+// with the forced prompt according to its mode.
+func mergeSystemContent(content any, prompt *models.ForceSystemPrompt) any {
+	if prompt.Mode == models.SystemPromptModeReplace {
+		return prompt.Content
+	}
+
+	switch existing := content.(type) {
+	case string:
+		if existing == "" {
+			return prompt.Content
+		}
+		if prompt.Mode == models.SystemPromptModePrepend {
+			return prompt.Content + "\n\n" + existing
+		}
+		return existing + "\n\n" + prompt.Content
+	case []any:
+		part := map[string]any{"type": "text", "text": prompt.Content}
+		if prompt.Mode == models.SystemPromptModePrepend {
+			return append([]any{part}, existing...)
+		}
+		return append(existing, part)
+	default:
+		return prompt.Content
+	}
+}
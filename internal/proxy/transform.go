@@ -0,0 +1,206 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"gpt-load/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultTransformWebhookTimeout applies when transform_webhook_timeout_ms is not set.
+const defaultTransformWebhookTimeout = time.Second
+
+// transformWebhookClient calls transform webhooks. Each call has its own deadline from the group config.
+var transformWebhookClient = &http.Client{}
+
+// transformPayload is what the transform webhook receives: the request about to be forwarded, or the
+// upstream response about to be relayed.
+type transformPayload struct {
+	Phase   string      `json:"phase"`
+	Group   string      `json:"group"`
+	Method  string      `json:"method"`
+	Path    string      `json:"path"`
+	Query   string      `json:"query,omitempty"`
+	Status  int         `json:"status,omitempty"`
+	Headers http.Header `json:"headers"`
+	Body    string      `json:"body"`
+}
+
+// transformResult is the webhook's answer. Omitted fields are left unchanged, and headers, when
+// present, replace all headers.
+type transformResult struct {
+	Method  *string     `json:"method"`
+	Path    *string     `json:"path"`
+	Status  *int        `json:"status"`
+	Headers http.Header `json:"headers"`
+	Body    *string     `json:"body"`
+}
+
+// transformWebhook returns the group's transform webhook options, or nil if it has none.
+func transformWebhook(group *models.Group) *models.GroupConfig {
+	if group.Options.TransformWebhookURL == "" {
+		return nil
+	}
+	return &group.Options
+}
+
+// transformRequest lets the group's transform webhook rewrite the request before it is forwarded,
+// and returns the body to send. When the webhook fails, the request is forwarded unchanged, or an
+// error is returned if transform_webhook_fail_closed is set.
+func (ps *ProxyServer) transformRequest(c *gin.Context, group *models.Group, body []byte) ([]byte, error) {
+	options := transformWebhook(group)
+	if options == nil {
+		return body, nil
+	}
+
+	header := c.Request.Header.Clone()
+	// The proxy key never leaves gpt-load.
+	header.Del("Authorization")
+	header.Del("X-Api-Key")
+	header.Del("X-Goog-Api-Key")
+
+	result, err := ps.callTransformWebhook(options, group, &transformPayload{
+		Phase:   "request",
+		Group:   group.Name,
+		Method:  c.Request.Method,
+		Path:    c.Param("path"),
+		Query:   c.Request.URL.RawQuery,
+		Headers: header,
+		Body:    string(body),
+	})
+	if err != nil {
+		return body, transformFailure(options, group, "request", err)
+	}
+	if result == nil {
+		return body, nil
+	}
+
+	// The rewrite goes to a new request, as a shadow request may still be reading the current one.
+	req := c.Request.Clone(c.Request.Context())
+	if result.Method != nil && *result.Method != "" {
+		req.Method = strings.ToUpper(*result.Method)
+	}
+	if result.Path != nil && *result.Path != "" {
+		prefix := strings.TrimSuffix(req.URL.Path, c.Param("path"))
+		req.URL.Path = prefix + "/" + strings.TrimPrefix(*result.Path, "/")
+		req.URL.RawPath = ""
+	}
+	if result.Headers != nil {
+		req.Header = result.Headers
+	}
+	c.Request = req
+	if result.Body != nil {
+		body = []byte(*result.Body)
+	}
+	return body, nil
+}
+
+// transformResponse lets the group's transform webhook rewrite a non-streaming upstream response
+// before it is relayed. decoded is the body without content encoding. The status and headers are
+// updated on resp, and the body to write is returned; a rewritten body is sent without content
+// encoding. When the webhook fails, the response is relayed unchanged, or an error is returned if
+// transform_webhook_fail_closed is set.
+func (ps *ProxyServer) transformResponse(c *gin.Context, group *models.Group, resp *http.Response, body, decoded []byte) ([]byte, error) {
+	options := transformWebhook(group)
+	if options == nil {
+		return body, nil
+	}
+
+	// The webhook sees the decoded body, so the headers describing the encoded one are left out.
+	header := resp.Header.Clone()
+	header.Del("Content-Encoding")
+	header.Del("Content-Length")
+
+	result, err := ps.callTransformWebhook(options, group, &transformPayload{
+		Phase:   "response",
+		Group:   group.Name,
+		Method:  c.Request.Method,
+		Path:    c.Param("path"),
+		Query:   c.Request.URL.RawQuery,
+		Status:  resp.StatusCode,
+		Headers: header,
+		Body:    string(decoded),
+	})
+	if err != nil {
+		return body, transformFailure(options, group, "response", err)
+	}
+	if result == nil {
+		return body, nil
+	}
+
+	if result.Status != nil && *result.Status >= 100 && *result.Status <= 599 {
+		resp.StatusCode = *result.Status
+	}
+	if result.Headers != nil {
+		resp.Header = result.Headers
+	}
+	body = decoded
+	if result.Body != nil {
+		body = []byte(*result.Body)
+	}
+	resp.Header.Del("Content-Encoding")
+	return body, nil
+}
+
+// callTransformWebhook posts payload to the webhook and returns its answer, or nil if the webhook
+// answered 204 No Content to leave everything unchanged.
+func (ps *ProxyServer) callTransformWebhook(options *models.GroupConfig, group *models.Group, payload *transformPayload) (*transformResult, error) {
+	timeout := defaultTransformWebhookTimeout
+	if options.TransformWebhookTimeoutMs > 0 {
+		timeout = time.Duration(options.TransformWebhookTimeoutMs) * time.Millisecond
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode transform payload: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, options.TransformWebhookURL, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transform request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	start := time.Now()
+	resp, err := transformWebhookClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	logrus.Debugf("Transform webhook of group %s answered the %s phase with status %d in %s", group.Name, payload.Phase, resp.StatusCode, time.Since(start))
+
+	if resp.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	respBody, err := readLimitedBody(resp.Body, group.EffectiveConfig.MaxResponseBodyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transform response: %w", err)
+	}
+	var result transformResult
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("invalid transform response: %w", err)
+	}
+	return &result, nil
+}
+
+// transformFailure logs a failed webhook call and returns the error only if the group fails closed.
+func transformFailure(options *models.GroupConfig, group *models.Group, phase string, err error) error {
+	if options.TransformWebhookFailClosed {
+		logrus.Warnf("Transform webhook of group %s failed in the %s phase, rejecting the request: %v", group.Name, phase, err)
+		return fmt.Errorf("transform webhook failed: %w", err)
+	}
+	logrus.Warnf("Transform webhook of group %s failed in the %s phase, continuing unchanged: %v", group.Name, phase, err)
+	return nil
+}
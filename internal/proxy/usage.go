@@ -0,0 +1,162 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// maxPendingStreamLine caps the partial SSE line kept between reads while looking for usage.
+const maxPendingStreamLine = 64 * 1024
+
+// tokenUsage is the token usage an OpenAI-compatible upstream reports in its "usage" object.
+type tokenUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// UnmarshalJSON reads both the chat completions usage and the Responses API usage, which names its
+// counts input_tokens and output_tokens.
+func (u *tokenUsage) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+		InputTokens      int `json:"input_tokens"`
+		OutputTokens     int `json:"output_tokens"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	u.PromptTokens, u.CompletionTokens = raw.PromptTokens, raw.CompletionTokens
+	if u.PromptTokens == 0 && u.CompletionTokens == 0 {
+		u.PromptTokens, u.CompletionTokens = raw.InputTokens, raw.OutputTokens
+	}
+	return nil
+}
+
+// parseUsage reads the "usage" object of a JSON response body, at the top level or, as in Responses
+// API events, under "response". It returns nil if there is none.
+func parseUsage(body []byte) *tokenUsage {
+	var payload struct {
+		Usage    *tokenUsage `json:"usage"`
+		Response *struct {
+			Usage *tokenUsage `json:"usage"`
+		} `json:"response"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil
+	}
+	if payload.Usage == nil && payload.Response != nil {
+		return payload.Response.Usage
+	}
+	return payload.Usage
+}
+
+// streamError is the error object of a failed stream event.
+type streamError struct {
+	Message string `json:"message"`
+}
+
+// streamEvent holds the fields of an SSE data payload that carry usage or an error. Chat completions
+// put usage in the last chunk and an error in an {"error": ...} chunk; the Responses API reports usage
+// in its response.completed event, and errors as an "error" event or a response.failed event.
+type streamEvent struct {
+	Type     string       `json:"type"`
+	Message  string       `json:"message"`
+	Usage    *tokenUsage  `json:"usage"`
+	Error    *streamError `json:"error"`
+	Response *struct {
+		Usage *tokenUsage  `json:"usage"`
+		Error *streamError `json:"error"`
+	} `json:"response"`
+}
+
+// streamUsageScanner finds the usage and any error among the SSE events of a streaming response as
+// they are relayed. With stream_options.include_usage, OpenAI sends usage as the last chunk before
+// [DONE]; the Responses API always sends it with response.completed.
+type streamUsageScanner struct {
+	pending []byte
+	usage   *tokenUsage
+	err     string
+}
+
+// Write scans the next part of the stream.
+func (s *streamUsageScanner) Write(p []byte) {
+	s.pending = append(s.pending, p...)
+	for {
+		idx := bytes.IndexByte(s.pending, '\n')
+		if idx < 0 {
+			break
+		}
+		s.scanLine(s.pending[:idx])
+		s.pending = s.pending[idx+1:]
+	}
+	if len(s.pending) > maxPendingStreamLine {
+		// Not an SSE line we care about; drop it rather than buffer the whole stream.
+		s.pending = nil
+	}
+}
+
+func (s *streamUsageScanner) scanLine(line []byte) {
+	data, ok := bytes.CutPrefix(bytes.TrimSpace(line), []byte("data:"))
+	if !ok || (!bytes.Contains(data, []byte(`"usage"`)) && !bytes.Contains(data, []byte(`"error"`))) {
+		return
+	}
+	var event streamEvent
+	if err := json.Unmarshal(bytes.TrimSpace(data), &event); err != nil {
+		return
+	}
+
+	usage, streamErr := event.Usage, event.Error
+	if event.Response != nil {
+		if event.Response.Usage != nil {
+			usage = event.Response.Usage
+		}
+		if event.Response.Error != nil {
+			streamErr = event.Response.Error
+		}
+	}
+	if usage != nil {
+		s.usage = usage
+	}
+	switch {
+	case streamErr != nil:
+		s.setError(streamErr.Message)
+	case event.Type == "error":
+		s.setError(event.Message)
+	case event.Type == "response.failed":
+		s.setError("")
+	}
+}
+
+func (s *streamUsageScanner) setError(message string) {
+	if message == "" {
+		message = "unknown error"
+	}
+	s.err = message
+}
+
+// Usage returns the last usage seen in the stream, or nil.
+func (s *streamUsageScanner) Usage() *tokenUsage {
+	return s.usage
+}
+
+// Err returns the message of the last error event seen in the stream, or an empty string.
+func (s *streamUsageScanner) Err() string {
+	return s.err
+}
+
+// injectStreamUsage asks the upstream to report token usage at the end of a streaming request,
+// unless the client already set stream_options.include_usage itself.
+func injectStreamUsage(requestData map[string]any) {
+	if stream, _ := requestData["stream"].(bool); !stream {
+		return
+	}
+	streamOptions, ok := requestData["stream_options"].(map[string]any)
+	if !ok {
+		streamOptions = make(map[string]any)
+		requestData["stream_options"] = streamOptions
+	}
+	if _, set := streamOptions["include_usage"]; !set {
+		streamOptions["include_usage"] = true
+	}
+}
@@ -0,0 +1,195 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gpt-load/internal/channel"
+	"gpt-load/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// responsesStream is a Responses API stream as recorded from OpenAI, trimmed to its event structure.
+const responsesStream = `event: response.created
+data: {"type":"response.created","sequence_number":0,"response":{"id":"resp_1","object":"response","status":"in_progress","usage":null}}
+
+event: response.output_text.delta
+data: {"type":"response.output_text.delta","sequence_number":1,"item_id":"msg_1","output_index":0,"content_index":0,"delta":"Hello"}
+
+event: response.output_text.done
+data: {"type":"response.output_text.done","sequence_number":2,"item_id":"msg_1","output_index":0,"content_index":0,"text":"Hello"}
+
+event: response.completed
+data: {"type":"response.completed","sequence_number":3,"response":{"id":"resp_1","object":"response","status":"completed","error":null,"usage":{"input_tokens":12,"input_tokens_details":{"cached_tokens":0},"output_tokens":5,"output_tokens_details":{"reasoning_tokens":0},"total_tokens":17}}}
+
+`
+
+// responsesFailedStream is a Responses API stream that fails after it started.
+const responsesFailedStream = `event: response.created
+data: {"type":"response.created","sequence_number":0,"response":{"id":"resp_2","object":"response","status":"in_progress"}}
+
+event: response.output_text.delta
+data: {"type":"response.output_text.delta","sequence_number":1,"delta":"Hel"}
+
+event: response.failed
+data: {"type":"response.failed","sequence_number":2,"response":{"id":"resp_2","object":"response","status":"failed","error":{"code":"server_error","message":"The model produced invalid content."},"usage":null}}
+
+`
+
+// responsesErrorStream is a Responses API stream interrupted by an error event.
+const responsesErrorStream = `event: response.created
+data: {"type":"response.created","sequence_number":0,"response":{"id":"resp_3","object":"response","status":"in_progress"}}
+
+event: error
+data: {"type":"error","sequence_number":1,"code":"rate_limit_exceeded","message":"Rate limit reached.","param":null}
+
+`
+
+// chatStream is a chat completions stream with stream_options.include_usage.
+const chatStream = `data: {"id":"chatcmpl-1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"Hi"},"finish_reason":null}],"usage":null}
+
+data: {"id":"chatcmpl-1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{},"finish_reason":"stop"}],"usage":null}
+
+data: {"id":"chatcmpl-1","object":"chat.completion.chunk","choices":[],"usage":{"prompt_tokens":9,"completion_tokens":2,"total_tokens":11}}
+
+data: [DONE]
+
+`
+
+// chatErrorStream is a chat completions stream interrupted by an error chunk.
+const chatErrorStream = `data: {"id":"chatcmpl-2","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"Hi"}}]}
+
+data: {"error":{"message":"The server had an error while processing your request.","type":"server_error"}}
+
+`
+
+func TestStreamUsageScanner(t *testing.T) {
+	tests := []struct {
+		name       string
+		stream     string
+		wantUsage  *tokenUsage
+		wantErrMsg string
+	}{
+		{"responses", responsesStream, &tokenUsage{PromptTokens: 12, CompletionTokens: 5}, ""},
+		{"responses failed", responsesFailedStream, nil, "The model produced invalid content."},
+		{"responses error event", responsesErrorStream, nil, "Rate limit reached."},
+		{"chat completions", chatStream, &tokenUsage{PromptTokens: 9, CompletionTokens: 2}, ""},
+		{"chat completions error", chatErrorStream, nil, "The server had an error while processing your request."},
+	}
+	for _, tt := range tests {
+		// Feed the stream in small reads so events are split across writes.
+		scanner := &streamUsageScanner{}
+		for stream := tt.stream; stream != ""; {
+			n := min(7, len(stream))
+			scanner.Write([]byte(stream[:n]))
+			stream = stream[n:]
+		}
+
+		usage := scanner.Usage()
+		switch {
+		case tt.wantUsage == nil && usage != nil:
+			t.Errorf("%s: Usage() = %+v, want nil", tt.name, *usage)
+		case tt.wantUsage != nil && (usage == nil || *usage != *tt.wantUsage):
+			t.Errorf("%s: Usage() = %v, want %+v", tt.name, usage, *tt.wantUsage)
+		}
+		if got := scanner.Err(); got != tt.wantErrMsg {
+			t.Errorf("%s: Err() = %q, want %q", tt.name, got, tt.wantErrMsg)
+		}
+	}
+}
+
+func TestHandleStreamingResponseRelaysResponsesStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name       string
+		stream     string
+		wantUsage  *tokenUsage
+		wantErrMsg string
+	}{
+		{"completed", responsesStream, &tokenUsage{PromptTokens: 12, CompletionTokens: 5}, ""},
+		{"failed", responsesFailedStream, nil, "The model produced invalid content."},
+	}
+	for _, tt := range tests {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			// Flush each event on its own, as the upstream does.
+			for _, event := range strings.SplitAfter(tt.stream, "\n\n") {
+				io.WriteString(w, event)
+				w.(http.Flusher).Flush()
+			}
+		}))
+		resp, err := http.Get(upstream.URL)
+		if err != nil {
+			upstream.Close()
+			t.Fatalf("%s: request to upstream failed: %v", tt.name, err)
+		}
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/proxy/test/v1/responses", nil)
+		capture := &bodyCapture{}
+		usage, streamErr := (&ProxyServer{}).handleStreamingResponse(c, resp, capture)
+		resp.Body.Close()
+		upstream.Close()
+
+		if w.Body.String() != tt.stream {
+			t.Errorf("%s: relayed stream differs from the upstream stream:\n%s", tt.name, w.Body.String())
+		}
+		if string(capture.data) != tt.stream {
+			t.Errorf("%s: captured %d bytes, want the %d bytes of the stream", tt.name, len(capture.data), len(tt.stream))
+		}
+		if tt.wantUsage == nil && usage != nil || tt.wantUsage != nil && (usage == nil || *usage != *tt.wantUsage) {
+			t.Errorf("%s: usage = %v, want %v", tt.name, usage, tt.wantUsage)
+		}
+		if streamErr != tt.wantErrMsg {
+			t.Errorf("%s: stream error = %q, want %q", tt.name, streamErr, tt.wantErrMsg)
+		}
+	}
+}
+
+func TestParseUsage(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want *tokenUsage
+	}{
+		{"chat completions", `{"object":"chat.completion","usage":{"prompt_tokens":9,"completion_tokens":2,"total_tokens":11}}`, &tokenUsage{PromptTokens: 9, CompletionTokens: 2}},
+		{"responses", `{"object":"response","status":"completed","usage":{"input_tokens":12,"output_tokens":5,"total_tokens":17}}`, &tokenUsage{PromptTokens: 12, CompletionTokens: 5}},
+		{"responses event", `{"type":"response.completed","response":{"object":"response","usage":{"input_tokens":3,"output_tokens":4}}}`, &tokenUsage{PromptTokens: 3, CompletionTokens: 4}},
+		{"no usage", `{"object":"list","data":[]}`, nil},
+		{"not json", `upstream error`, nil},
+	}
+	for _, tt := range tests {
+		got := parseUsage([]byte(tt.body))
+		if tt.want == nil && got != nil || tt.want != nil && (got == nil || *got != *tt.want) {
+			t.Errorf("%s: parseUsage() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestApplyParamOverridesInjectsStreamUsageForChatCompletionsOnly(t *testing.T) {
+	group := &models.Group{Options: models.GroupConfig{IncludeStreamUsage: true}}
+	body := []byte(`{"model":"gpt-4o","stream":true}`)
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/proxy/test/v1/chat/completions", true},
+		{"/openai/deployments/gpt-4o/chat/completions", true},
+		{"/proxy/test/v1/responses", false},
+		{"/proxy/test/v1/completions", false},
+	}
+	for _, tt := range tests {
+		got, err := (&ProxyServer{}).applyParamOverrides(body, group, &channel.OpenAIChannel{}, tt.path)
+		if err != nil {
+			t.Fatalf("%s: applyParamOverrides() error = %v", tt.path, err)
+		}
+		if injected := strings.Contains(string(got), `"stream_options"`); injected != tt.want {
+			t.Errorf("%s: stream_options injected = %v, want %v; body %s", tt.path, injected, tt.want, got)
+		}
+	}
+}
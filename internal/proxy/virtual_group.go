@@ -0,0 +1,178 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"time"
+
+	"gpt-load/internal/channel"
+	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/models"
+	"gpt-load/internal/response"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const virtualRouteContextKey = "virtual_route"
+
+// virtualMember is a weighted reference to a member group of a virtual group.
+type virtualMember struct {
+	Name   string `json:"url"`
+	Weight int    `json:"weight"`
+}
+
+// virtualRoute tracks member selection for a single request sent to a virtual group.
+type virtualRoute struct {
+	group        *models.Group
+	members      []virtualMember
+	originalBody []byte
+	// passthrough is set when the body is streamed to the upstream and cannot be replayed on another member.
+	passthrough bool
+	tried       map[string]bool
+}
+
+// handleVirtualProxy picks a member group by weight and delegates the request to its channel and keys.
+func (ps *ProxyServer) handleVirtualProxy(c *gin.Context, group *models.Group, startTime time.Time) {
+	var members []virtualMember
+	if err := json.Unmarshal(group.Upstreams, &members); err != nil || len(members) == 0 {
+		response.ProxyError(c, app_errors.NewAPIError(app_errors.ErrInternalServer, fmt.Sprintf("Invalid member groups for virtual group '%s'", group.Name)))
+		return
+	}
+
+	route := &virtualRoute{
+		group:   group,
+		members: members,
+		tried:   make(map[string]bool),
+	}
+
+	route.passthrough = isPassthroughBody(c.Request)
+	if !route.passthrough && !isBodylessRequest(c.Request) {
+		bodyBytes, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			logrus.Errorf("Failed to read request body: %v", err)
+			response.ProxyError(c, app_errors.NewAPIError(app_errors.ErrBadRequest, "Failed to read request body"))
+			return
+		}
+		c.Request.Body.Close()
+		route.originalBody = bodyBytes
+	}
+	setRequestModel(c, route.originalBody)
+
+	member := ps.pickVirtualMember(route)
+	if member == nil {
+		apiErr := app_errors.NewAPIError(app_errors.ErrNoKeysAvailable, fmt.Sprintf("No member group of virtual group '%s' has active keys", group.Name))
+		response.ProxyError(c, withClientMessage(group, apiErr, groupErrorMessages(group).NoKeys))
+		ps.logRequest(c, group, nil, startTime, app_errors.ErrNoActiveKeys.HTTPStatus, 0, app_errors.ErrNoActiveKeys, false, "", 0)
+		return
+	}
+	c.Set(virtualRouteContextKey, route)
+
+	channelHandler, bodyBytes, apiErr := ps.prepareVirtualMember(c, route, member)
+	if apiErr != nil {
+		response.ProxyError(c, apiErr)
+		return
+	}
+
+	isStream := channelHandler.IsStreamRequest(c, route.originalBody)
+	if route.originalBody != nil {
+		if primary := ps.mirrorToShadowGroup(c, member, route.originalBody, isStream); primary != nil {
+			defer primary.finish(c)
+		}
+	}
+	ps.executeRequestWithRetry(c, channelHandler, member, bodyBytes, isStream, startTime, 0, nil)
+}
+
+// pickVirtualMember selects an untried member group with active keys, weighted by its upstream weight.
+// It returns nil when no member is available.
+func (ps *ProxyServer) pickVirtualMember(route *virtualRoute) *models.Group {
+	var candidates []*models.Group
+	var weights []int
+	totalWeight := 0
+
+	for _, m := range route.members {
+		if route.tried[m.Name] {
+			continue
+		}
+		member, err := ps.groupManager.GetGroupByName(m.Name)
+		if err != nil {
+			logrus.Warnf("Member group %s of virtual group %s not found: %v", m.Name, route.group.Name, err)
+			continue
+		}
+		// Nested virtual groups are not supported.
+		if member.ChannelType == channel.VirtualChannelType {
+			continue
+		}
+		if hasKeys, err := ps.keyProvider.HasActiveKeys(member); err != nil || !hasKeys {
+			continue
+		}
+		weight := max(m.Weight, 1)
+		candidates = append(candidates, member)
+		weights = append(weights, weight)
+		totalWeight += weight
+	}
+
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	r := rand.Intn(totalWeight)
+	selected := candidates[len(candidates)-1]
+	for i, w := range weights {
+		if r < w {
+			selected = candidates[i]
+			break
+		}
+		r -= w
+	}
+
+	route.tried[selected.Name] = true
+	return selected
+}
+
+// prepareVirtualMember returns the member's channel and the request body prepared for the member,
+// with its parameter overrides and request transform applied like for a direct request.
+func (ps *ProxyServer) prepareVirtualMember(c *gin.Context, route *virtualRoute, member *models.Group) (channel.ChannelProxy, []byte, *app_errors.APIError) {
+	channelHandler, err := ps.channelFactory.GetChannel(member)
+	if err != nil {
+		return nil, nil, app_errors.NewAPIError(app_errors.ErrInternalServer, fmt.Sprintf("Failed to get channel for group '%s': %v", member.Name, err))
+	}
+
+	if route.originalBody == nil {
+		return channelHandler, nil, nil
+	}
+
+	bodyBytes, apiErr := ps.prepareRequestBody(c, member, channelHandler, route.originalBody)
+	if apiErr != nil {
+		return nil, nil, apiErr
+	}
+	return channelHandler, bodyBytes, nil
+}
+
+// failoverVirtualMember switches a virtual group request to another member group, if one is left.
+func (ps *ProxyServer) failoverVirtualMember(c *gin.Context) (*models.Group, channel.ChannelProxy, []byte, bool) {
+	value, exists := c.Get(virtualRouteContextKey)
+	if !exists {
+		return nil, nil, nil, false
+	}
+	route := value.(*virtualRoute)
+	if route.passthrough {
+		return nil, nil, nil, false
+	}
+
+	member := ps.pickVirtualMember(route)
+	if member == nil {
+		return nil, nil, nil, false
+	}
+
+	channelHandler, bodyBytes, apiErr := ps.prepareVirtualMember(c, route, member)
+	if apiErr != nil {
+		logrus.Warnf("Failed to fail over to member group %s of virtual group %s: %s", member.Name, route.group.Name, apiErr.Message)
+		return nil, nil, nil, false
+	}
+
+	logrus.Debugf("Virtual group %s failing over to member group %s", route.group.Name, member.Name)
+	return member, channelHandler, bodyBytes, true
+}
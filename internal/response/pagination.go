@@ -1,24 +1,35 @@
 package response
 
 import (
+	"fmt"
 	"math"
 	"strconv"
 
+	app_errors "gpt-load/internal/errors"
+
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
+// DefaultPageSize and MaxPageSize apply when PageSizeLimits leaves them unset.
 const (
 	DefaultPageSize = 15
 	MaxPageSize     = 1000
 )
 
+// PageSizeLimits are the page size used when a request sets none and the largest one it may ask for.
+type PageSizeLimits struct {
+	Default int
+	Max     int
+}
+
 // Pagination represents the pagination details in a response.
 type Pagination struct {
-	Page       int   `json:"page"`
-	PageSize   int   `json:"page_size"`
-	TotalItems int64 `json:"total_items"`
-	TotalPages int   `json:"total_pages"`
+	Page        int   `json:"page"`
+	PageSize    int   `json:"page_size"`
+	MaxPageSize int   `json:"max_page_size"`
+	TotalItems  int64 `json:"total_items"`
+	TotalPages  int   `json:"total_pages"`
 }
 
 // PaginatedResponse is the standard structure for all paginated API responses.
@@ -28,20 +39,31 @@ type PaginatedResponse struct {
 }
 
 // Paginate performs pagination on a GORM query and returns a standardized response.
-// It takes a Gin context, a GORM query builder, and a destination slice for the results.
-func Paginate(c *gin.Context, query *gorm.DB, dest any) (*PaginatedResponse, error) {
+// It takes a Gin context, a GORM query builder, a destination slice for the results and the page size limits.
+// A page_size above the maximum is rejected with an *app_errors.APIError.
+func Paginate(c *gin.Context, query *gorm.DB, dest any, limits PageSizeLimits) (*PaginatedResponse, error) {
+	maxPageSize := limits.Max
+	if maxPageSize <= 0 {
+		maxPageSize = MaxPageSize
+	}
+	defaultPageSize := limits.Default
+	if defaultPageSize <= 0 {
+		defaultPageSize = DefaultPageSize
+	}
+	defaultPageSize = min(defaultPageSize, maxPageSize)
+
 	// 1. Get page and page size from query parameters
 	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
 	if err != nil || page < 1 {
 		page = 1
 	}
 
-	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(DefaultPageSize)))
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(defaultPageSize)))
 	if err != nil || pageSize <= 0 {
-		pageSize = DefaultPageSize
+		pageSize = defaultPageSize
 	}
-	if pageSize > MaxPageSize {
-		pageSize = MaxPageSize
+	if pageSize > maxPageSize {
+		return nil, app_errors.NewAPIError(app_errors.ErrValidation, fmt.Sprintf("page_size %d exceeds the maximum of %d", pageSize, maxPageSize))
 	}
 
 	// 2. Get total count of items
@@ -63,10 +85,11 @@ func Paginate(c *gin.Context, query *gorm.DB, dest any) (*PaginatedResponse, err
 	paginatedData := &PaginatedResponse{
 		Items: dest,
 		Pagination: Pagination{
-			Page:       page,
-			PageSize:   pageSize,
-			TotalItems: totalItems,
-			TotalPages: totalPages,
+			Page:        page,
+			PageSize:    pageSize,
+			MaxPageSize: maxPageSize,
+			TotalItems:  totalItems,
+			TotalPages:  totalPages,
 		},
 	}
 
@@ -0,0 +1,85 @@
+package response
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	app_errors "gpt-load/internal/errors"
+	"gpt-load/internal/testutil"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type paginatedItem struct {
+	ID uint
+}
+
+// newPaginatedItemsDB returns an in-memory database holding count items.
+func newPaginatedItemsDB(t *testing.T, count int) *gorm.DB {
+	t.Helper()
+	database := testutil.OpenDB(t, &paginatedItem{})
+	items := make([]paginatedItem, count)
+	for i := range items {
+		items[i].ID = uint(i + 1)
+	}
+	if err := database.Create(&items).Error; err != nil {
+		t.Fatalf("failed to create items: %v", err)
+	}
+	return database
+}
+
+func TestPaginate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	database := newPaginatedItemsDB(t, 40)
+
+	tests := []struct {
+		name         string
+		query        string
+		limits       PageSizeLimits
+		wantPageSize int
+		wantMax      int
+		wantItems    int
+	}{
+		{"package defaults", "", PageSizeLimits{}, DefaultPageSize, MaxPageSize, DefaultPageSize},
+		{"configured default", "", PageSizeLimits{Default: 12, Max: 100}, 12, 100, 12},
+		{"default clamped to the maximum", "", PageSizeLimits{Default: 50, Max: 20}, 20, 20, 20},
+		{"requested page size", "?page_size=25", PageSizeLimits{Default: 12, Max: 100}, 25, 100, 25},
+		{"page size at the maximum", "?page_size=100", PageSizeLimits{Default: 12, Max: 100}, 100, 100, 40},
+		{"invalid page size", "?page_size=abc", PageSizeLimits{Default: 12, Max: 100}, 12, 100, 12},
+		{"last page", "?page=2&page_size=30", PageSizeLimits{Default: 12, Max: 100}, 30, 100, 10},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/api/logs"+tt.query, nil)
+
+		var items []paginatedItem
+		result, err := Paginate(c, database.Model(&paginatedItem{}), &items, tt.limits)
+		if err != nil {
+			t.Errorf("%s: Paginate() error = %v", tt.name, err)
+			continue
+		}
+		if result.Pagination.PageSize != tt.wantPageSize || result.Pagination.MaxPageSize != tt.wantMax {
+			t.Errorf("%s: page_size = %d, max_page_size = %d, want %d and %d", tt.name, result.Pagination.PageSize, result.Pagination.MaxPageSize, tt.wantPageSize, tt.wantMax)
+		}
+		if len(items) != tt.wantItems || result.Pagination.TotalItems != 40 {
+			t.Errorf("%s: got %d of %d items, want %d of 40", tt.name, len(items), result.Pagination.TotalItems, tt.wantItems)
+		}
+	}
+}
+
+func TestPaginateRejectsPageSizeAboveMaximum(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	database := newPaginatedItemsDB(t, 1)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/logs?page_size=101", nil)
+
+	var items []paginatedItem
+	_, err := Paginate(c, database.Model(&paginatedItem{}), &items, PageSizeLimits{Default: 12, Max: 100})
+	var apiErr *app_errors.APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != app_errors.ErrValidation.Code || apiErr.HTTPStatus != http.StatusBadRequest {
+		t.Fatalf("Paginate() error = %v, want a validation error", err)
+	}
+}
@@ -2,8 +2,10 @@
 package response
 
 import (
+	"encoding/json"
 	app_errors "gpt-load/internal/errors"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -37,3 +39,55 @@ func Error(c *gin.Context, apiErr *app_errors.APIError) {
 		Message: apiErr.Message,
 	})
 }
+
+// ProxyErrorResponse is the OpenAI-compatible error envelope returned by the proxy endpoints,
+// so clients built on the OpenAI SDKs can parse gateway errors.
+type ProxyErrorResponse struct {
+	Error ProxyErrorDetail `json:"error"`
+}
+
+// ProxyErrorDetail is the body of a ProxyErrorResponse.
+type ProxyErrorDetail struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+	Code    string `json:"code"`
+}
+
+// ProxyError sends an APIError in the OpenAI error envelope.
+func ProxyError(c *gin.Context, apiErr *app_errors.APIError) {
+	c.JSON(apiErr.HTTPStatus, ProxyErrorResponse{
+		Error: ProxyErrorDetail{
+			Message: apiErr.Message,
+			Type:    proxyErrorType(apiErr.HTTPStatus),
+			Code:    strings.ToLower(apiErr.Code),
+		},
+	})
+}
+
+// UpstreamError relays an upstream error response. JSON bodies are passed through verbatim,
+// anything else is wrapped in the OpenAI error envelope.
+func UpstreamError(c *gin.Context, statusCode int, body []byte) {
+	if json.Valid(body) {
+		c.Data(statusCode, "application/json; charset=utf-8", body)
+		return
+	}
+	ProxyError(c, app_errors.NewAPIErrorWithUpstream(statusCode, "UPSTREAM_ERROR", string(body)))
+}
+
+// proxyErrorType maps an HTTP status to the matching OpenAI error type.
+func proxyErrorType(status int) string {
+	switch {
+	case status == http.StatusUnauthorized:
+		return "authentication_error"
+	case status == http.StatusForbidden:
+		return "permission_error"
+	case status == http.StatusNotFound:
+		return "not_found_error"
+	case status == http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case status >= 500:
+		return "server_error"
+	default:
+		return "invalid_request_error"
+	}
+}
@@ -2,6 +2,7 @@ package router
 
 import (
 	"embed"
+	"gpt-load/internal/config"
 	"gpt-load/internal/handler"
 	"gpt-load/internal/middleware"
 	"gpt-load/internal/proxy"
@@ -41,7 +42,9 @@ func NewRouter(
 	serverHandler *handler.Server,
 	proxyServer *proxy.ProxyServer,
 	configManager types.ConfigManager,
+	settingsManager *config.SystemSettingsManager,
 	groupManager *services.GroupManager,
+	proxyKeyQuotaService *services.ProxyKeyQuotaService,
 	buildFS embed.FS,
 	indexPage []byte,
 ) *gin.Engine {
@@ -64,7 +67,8 @@ func NewRouter(
 	// 注册路由
 	registerSystemRoutes(router, serverHandler)
 	registerAPIRoutes(router, serverHandler, configManager)
-	registerProxyRoutes(router, proxyServer, groupManager)
+	registerProxyRoutes(router, proxyServer, groupManager, proxyKeyQuotaService)
+	registerDefaultGroupRoutes(router, proxyServer, settingsManager, groupManager, proxyKeyQuotaService)
 	registerFrontendRoutes(router, buildFS, indexPage)
 
 	return router
@@ -90,17 +94,19 @@ func registerAPIRoutes(
 	// 认证
 	protectedAPI := api.Group("")
 	protectedAPI.Use(middleware.Auth(authConfig))
-	registerProtectedAPIRoutes(protectedAPI, serverHandler)
+	registerProtectedAPIRoutes(protectedAPI, serverHandler, router.Routes)
 }
 
 // registerPublicAPIRoutes 公开API路由
 func registerPublicAPIRoutes(api *gin.RouterGroup, serverHandler *handler.Server) {
 	api.POST("/auth/login", serverHandler.Login)
+	api.GET("/version", serverHandler.CommonHandler.GetVersion)
 }
 
 // registerProtectedAPIRoutes 认证API路由
-func registerProtectedAPIRoutes(api *gin.RouterGroup, serverHandler *handler.Server) {
+func registerProtectedAPIRoutes(api *gin.RouterGroup, serverHandler *handler.Server, routes func() gin.RoutesInfo) {
 	api.GET("/channel-types", serverHandler.CommonHandler.GetChannelTypes)
+	api.GET("/openapi.json", serverHandler.GetOpenAPISpec(routes))
 
 	groups := api.Group("/groups")
 	{
@@ -111,6 +117,7 @@ func registerProtectedAPIRoutes(api *gin.RouterGroup, serverHandler *handler.Ser
 		groups.PUT("/:id", serverHandler.UpdateGroup)
 		groups.DELETE("/:id", serverHandler.DeleteGroup)
 		groups.GET("/:id/stats", serverHandler.GetGroupStats)
+		groups.PUT("/:id/draining", serverHandler.SetGroupDraining)
 	}
 
 	// Key Management Routes
@@ -118,18 +125,26 @@ func registerProtectedAPIRoutes(api *gin.RouterGroup, serverHandler *handler.Ser
 	{
 		keys.GET("", serverHandler.ListKeysInGroup)
 		keys.GET("/export", serverHandler.ExportKeys)
+		keys.GET("/daily-stats", serverHandler.GetKeyDailyStats)
 		keys.POST("/add-multiple", serverHandler.AddMultipleKeys)
 		keys.POST("/add-async", serverHandler.AddMultipleKeysAsync)
+		keys.POST("/import-preview", serverHandler.PreviewImportKeys)
+		keys.POST("/replace-all", serverHandler.ReplaceAllKeys)
 		keys.POST("/delete-multiple", serverHandler.DeleteMultipleKeys)
 		keys.POST("/restore-multiple", serverHandler.RestoreMultipleKeys)
+		keys.POST("/set-status", serverHandler.SetKeysStatus)
 		keys.POST("/restore-all-invalid", serverHandler.RestoreAllInvalidKeys)
 		keys.POST("/clear-all-invalid", serverHandler.ClearAllInvalidKeys)
 		keys.POST("/validate-group", serverHandler.ValidateGroupKeys)
 		keys.POST("/test-multiple", serverHandler.TestMultipleKeys)
+		keys.GET("/test-results", serverHandler.GetKeyTestResults)
 	}
 
 	// Tasks
+	api.GET("/tasks", serverHandler.ListTasks)
 	api.GET("/tasks/status", serverHandler.GetTaskStatus)
+	api.GET("/tasks/stream", serverHandler.StreamTask)
+	api.POST("/tasks/cancel", serverHandler.CancelTask)
 
 	// 仪表板和日志
 	dashboard := api.Group("/dashboard")
@@ -143,27 +158,87 @@ func registerProtectedAPIRoutes(api *gin.RouterGroup, serverHandler *handler.Ser
 	{
 		logs.GET("", serverHandler.GetLogs)
 		logs.GET("/export", serverHandler.ExportLogs)
+		logs.GET("/bodies", serverHandler.GetRequestBodyLogs)
+		logs.GET("/shadow-comparisons", serverHandler.GetShadowComparisons)
+		logs.GET("/flush-stats", serverHandler.GetLogFlushStats)
 	}
 
+	// 代理密钥
+	api.GET("/proxy-keys/usage", serverHandler.GetProxyKeyUsage)
+
+	// 用量报表
+	api.GET("/usage/report", serverHandler.GetUsageReport)
+
+	// 集群节点
+	api.GET("/cluster/nodes", serverHandler.GetClusterNodes)
+	api.GET("/cluster/connection-pools", serverHandler.GetConnectionPools)
+
+	// 审计日志
+	api.GET("/audit-logs", serverHandler.GetAuditLogs)
+
 	// 设置
 	settings := api.Group("/settings")
 	{
 		settings.GET("", serverHandler.GetSettings)
 		settings.PUT("", serverHandler.UpdateSettings)
+		settings.GET("/export", serverHandler.ExportSettings)
+		settings.POST("/import", serverHandler.ImportSettings)
 	}
 }
 
+// proxyMethods 是代理路由转发到上游的请求方法
+var proxyMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+}
+
 // registerProxyRoutes 注册代理路由
 func registerProxyRoutes(
 	router *gin.Engine,
 	proxyServer *proxy.ProxyServer,
 	groupManager *services.GroupManager,
+	proxyKeyQuotaService *services.ProxyKeyQuotaService,
 ) {
+	// Preflight requests carry no proxy key and must not reach the upstream. With CORS enabled
+	// they are answered by the CORS middleware before getting here.
+	router.OPTIONS("/proxy/:group_name/*path", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
 	proxyGroup := router.Group("/proxy")
 
-	proxyGroup.Use(middleware.ProxyAuth(groupManager))
+	proxyGroup.Use(middleware.ProxyAuth(groupManager, proxyKeyQuotaService))
 
-	proxyGroup.Any("/:group_name/*path", proxyServer.HandleProxy)
+	for _, method := range proxyMethods {
+		proxyGroup.Handle(method, "/:group_name/*path", proxyServer.HandleProxy)
+	}
+}
+
+// registerDefaultGroupRoutes 注册不带分组前缀的 API 路由，转发到系统设置中的默认分组
+func registerDefaultGroupRoutes(
+	router *gin.Engine,
+	proxyServer *proxy.ProxyServer,
+	settingsManager *config.SystemSettingsManager,
+	groupManager *services.GroupManager,
+	proxyKeyQuotaService *services.ProxyKeyQuotaService,
+) {
+	for _, prefix := range []string{"/v1", "/v1beta"} {
+		router.OPTIONS(prefix+"/*path", func(c *gin.Context) {
+			c.Status(http.StatusNoContent)
+		})
+
+		defaultGroup := router.Group(prefix)
+		defaultGroup.Use(middleware.DefaultGroup(settingsManager))
+		defaultGroup.Use(middleware.ProxyAuth(groupManager, proxyKeyQuotaService))
+
+		for _, method := range proxyMethods {
+			defaultGroup.Handle(method, "/*path", proxyServer.HandleProxy)
+		}
+	}
 }
 
 // registerFrontendRoutes 注册前端路由
@@ -0,0 +1,66 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"gpt-load/internal/handler"
+	"gpt-load/internal/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testAuthKey = "sk-test-auth-key"
+
+// testConfigManager provides the auth key of the API routes.
+type testConfigManager struct {
+	types.ConfigManager
+}
+
+func (testConfigManager) GetAuthConfig() types.AuthConfig {
+	return types.AuthConfig{Key: testAuthKey}
+}
+
+var pathParamPattern = regexp.MustCompile(`:([A-Za-z_][A-Za-z0-9_]*)`)
+
+func TestAPIRoutesAreDocumented(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	registerAPIRoutes(engine, &handler.Server{CommonHandler: &handler.CommonHandler{}}, testConfigManager{})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil)
+	req.Header.Set("Authorization", "Bearer "+testAuthKey)
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /api/openapi.json status = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	var document struct {
+		Paths map[string]map[string]struct {
+			Summary string `json:"summary"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &document); err != nil {
+		t.Fatalf("failed to decode the OpenAPI document: %v", err)
+	}
+
+	routes := 0
+	for _, route := range engine.Routes() {
+		if !strings.HasPrefix(route.Path, "/api/") {
+			continue
+		}
+		routes++
+		path := pathParamPattern.ReplaceAllString(route.Path, "{$1}")
+		if document.Paths[path][strings.ToLower(route.Method)].Summary == "" {
+			t.Errorf("%s %s has no entry in apiOperations", route.Method, route.Path)
+		}
+	}
+	if routes == 0 {
+		t.Fatal("no /api routes registered")
+	}
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"gpt-load/internal/models"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// AuditLogService records and queries the audit trail of admin changes.
+type AuditLogService struct {
+	DB *gorm.DB
+}
+
+// NewAuditLogService creates a new AuditLogService.
+func NewAuditLogService(db *gorm.DB) *AuditLogService {
+	return &AuditLogService{DB: db}
+}
+
+// Record writes an audit entry. The change it describes has already been applied,
+// so a failure is logged rather than returned.
+func (s *AuditLogService) Record(entry *models.AuditLog) {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	if err := s.DB.Create(entry).Error; err != nil {
+		logrus.WithFields(logrus.Fields{
+			"action":     entry.Action,
+			"targetType": entry.TargetType,
+			"targetID":   entry.TargetID,
+			"error":      err,
+		}).Error("Failed to write audit log")
+	}
+}
+
+// auditFiltersScope returns a GORM scope function that applies audit log filters from the Gin context.
+func auditFiltersScope(c *gin.Context) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if actor := c.Query("actor"); actor != "" {
+			db = db.Where("actor = ?", actor)
+		}
+		if action := c.Query("action"); action != "" {
+			db = db.Where("action = ?", action)
+		}
+		if targetType := c.Query("target_type"); targetType != "" {
+			db = db.Where("target_type = ?", targetType)
+		}
+		if targetIDStr := c.Query("target_id"); targetIDStr != "" {
+			if targetID, err := strconv.Atoi(targetIDStr); err == nil {
+				db = db.Where("target_id = ?", targetID)
+			}
+		}
+		if targetName := c.Query("target_name"); targetName != "" {
+			db = db.Where("target_name LIKE ?", "%"+targetName+"%")
+		}
+		if startTimeStr := c.Query("start_time"); startTimeStr != "" {
+			if startTime, err := time.Parse(time.RFC3339, startTimeStr); err == nil {
+				db = db.Where("timestamp >= ?", startTime)
+			}
+		}
+		if endTimeStr := c.Query("end_time"); endTimeStr != "" {
+			if endTime, err := time.Parse(time.RFC3339, endTimeStr); err == nil {
+				db = db.Where("timestamp <= ?", endTime)
+			}
+		}
+		return db
+	}
+}
+
+// GetAuditLogsQuery returns a GORM query for fetching audit logs with filters.
+func (s *AuditLogService) GetAuditLogsQuery(c *gin.Context) *gorm.DB {
+	return s.DB.Model(&models.AuditLog{}).Scopes(auditFiltersScope(c))
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"gpt-load/internal/store"
+	"gpt-load/internal/types"
+	"gpt-load/internal/version"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// clusterNodesSetKey 记录所有曾注册的节点 ID，过期节点在列出时清理
+	clusterNodesSetKey = "cluster:nodes"
+	// clusterHeartbeatInterval 是节点刷新在线状态的间隔
+	clusterHeartbeatInterval = 10 * time.Second
+	// clusterNodeTTL 是节点在线状态的有效期，超过后视为离线
+	clusterNodeTTL = 30 * time.Second
+)
+
+// 节点角色
+const (
+	NodeRoleMaster = "master"
+	NodeRoleSlave  = "slave"
+)
+
+// ClusterNode 是一个在线节点的心跳信息
+type ClusterNode struct {
+	ID        string    `json:"id"`
+	Role      string    `json:"role"`
+	Hostname  string    `json:"hostname"`
+	Version   string    `json:"version"`
+	StartedAt time.Time `json:"started_at"`
+	LastSeen  time.Time `json:"last_seen"`
+	IsCurrent bool      `json:"is_current"`
+}
+
+// ClusterService 定期将本节点的在线状态写入存储，并列出集群中的在线节点
+type ClusterService struct {
+	store  store.Store
+	node   ClusterNode
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewClusterService 创建集群节点服务，并为本节点生成 ID
+func NewClusterService(store store.Store, configManager types.ConfigManager) *ClusterService {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	role := NodeRoleSlave
+	if configManager.IsMaster() {
+		role = NodeRoleMaster
+	}
+
+	return &ClusterService{
+		store: store,
+		node: ClusterNode{
+			ID:        newNodeID(hostname),
+			Role:      role,
+			Hostname:  hostname,
+			Version:   version.Get().Version,
+			StartedAt: time.Now(),
+		},
+		stopCh: make(chan struct{}),
+	}
+}
+
+// newNodeID 由主机名和随机后缀组成，同一主机上的多个实例也不会冲突
+func newNodeID(hostname string) string {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return fmt.Sprintf("%s-%d", hostname, time.Now().UnixNano())
+	}
+	return hostname + "-" + hex.EncodeToString(suffix)
+}
+
+// NodeID 返回本节点 ID
+func (s *ClusterService) NodeID() string {
+	return s.node.ID
+}
+
+// Start 启动心跳
+func (s *ClusterService) Start() {
+	s.wg.Add(1)
+	go s.run()
+	logrus.WithFields(logrus.Fields{"nodeID": s.node.ID, "role": s.node.Role}).Debug("Cluster heartbeat started")
+}
+
+// Stop 停止心跳并注销本节点
+func (s *ClusterService) Stop(ctx context.Context) {
+	close(s.stopCh)
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		if err := s.store.Delete(nodeKey(s.node.ID)); err != nil {
+			logrus.WithError(err).Warn("Failed to deregister cluster node")
+		}
+		if err := s.store.SRem(clusterNodesSetKey, s.node.ID); err != nil {
+			logrus.WithError(err).Warn("Failed to remove cluster node from the node set")
+		}
+		logrus.Info("ClusterService stopped gracefully.")
+	case <-ctx.Done():
+		logrus.Warn("ClusterService stop timed out.")
+	}
+}
+
+func (s *ClusterService) run() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(clusterHeartbeatInterval)
+	defer ticker.Stop()
+
+	s.heartbeat()
+	for {
+		select {
+		case <-ticker.C:
+			s.heartbeat()
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+// heartbeat 刷新本节点的在线状态
+func (s *ClusterService) heartbeat() {
+	node := s.node
+	node.LastSeen = time.Now()
+	data, err := json.Marshal(node)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to marshal cluster node heartbeat")
+		return
+	}
+	if err := s.store.Set(nodeKey(node.ID), data, clusterNodeTTL); err != nil {
+		logrus.WithError(err).Warn("Failed to write cluster node heartbeat")
+		return
+	}
+	if err := s.store.SAdd(clusterNodesSetKey, node.ID); err != nil {
+		logrus.WithError(err).Warn("Failed to register cluster node")
+	}
+}
+
+// ListNodes 返回所有在线节点，Master 在前，其余按启动时间排序
+func (s *ClusterService) ListNodes() ([]ClusterNode, error) {
+	ids, err := s.store.SMembers(clusterNodesSetKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cluster nodes: %w", err)
+	}
+
+	nodes := make([]ClusterNode, 0, len(ids))
+	for _, id := range ids {
+		data, err := s.store.Get(nodeKey(id))
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				// 心跳已过期，节点离线
+				if err := s.store.SRem(clusterNodesSetKey, id); err != nil {
+					logrus.WithError(err).Warn("Failed to remove offline cluster node")
+				}
+				continue
+			}
+			return nil, fmt.Errorf("failed to get cluster node %s: %w", id, err)
+		}
+
+		var node ClusterNode
+		if err := json.Unmarshal(data, &node); err != nil {
+			logrus.WithFields(logrus.Fields{"nodeID": id, "error": err}).Warn("Skipping malformed cluster node heartbeat")
+			continue
+		}
+		node.IsCurrent = node.ID == s.node.ID
+		nodes = append(nodes, node)
+	}
+
+	sort.Slice(nodes, func(i, j int) bool {
+		if (nodes[i].Role == NodeRoleMaster) != (nodes[j].Role == NodeRoleMaster) {
+			return nodes[i].Role == NodeRoleMaster
+		}
+		return nodes[i].StartedAt.Before(nodes[j].StartedAt)
+	})
+	return nodes, nil
+}
+
+func nodeKey(id string) string {
+	return "cluster:node:" + id
+}
@@ -8,6 +8,10 @@ import (
 	"gpt-load/internal/store"
 	"gpt-load/internal/syncer"
 	"gpt-load/internal/utils"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
@@ -21,6 +25,10 @@ type GroupManager struct {
 	db              *gorm.DB
 	store           store.Store
 	settingsManager *config.SystemSettingsManager
+
+	insecureMu sync.Mutex
+	// insecureGroups holds the groups that had insecure_skip_verify enabled at the previous load.
+	insecureGroups map[string]bool
 }
 
 // NewGroupManager creates a new, uninitialized GroupManager.
@@ -49,12 +57,18 @@ func (gm *GroupManager) Initialize() error {
 			g := *group
 			g.EffectiveConfig = gm.settingsManager.GetEffectiveConfig(g.Config)
 			g.ProxyKeysMap = utils.StringToSet(g.ProxyKeys, ",")
+			groupOptions, err := utils.ParseGroupConfig(g.Config)
+			if err != nil {
+				logrus.Warnf("Failed to parse config of group %s: %v", g.Name, err)
+			}
+			g.Options = groupOptions
 			groupMap[g.Name] = &g
 			logrus.WithFields(logrus.Fields{
 				"group_name":       g.Name,
 				"effective_config": g.EffectiveConfig,
 			}).Debug("Loaded group with effective config")
 		}
+		gm.warnInsecureGroups(groupMap)
 
 		return groupMap, nil
 	}
@@ -65,6 +79,9 @@ func (gm *GroupManager) Initialize() error {
 		GroupUpdateChannel,
 		logrus.WithField("syncer", "groups"),
 		nil,
+		func(map[string]*models.Group) time.Duration {
+			return time.Duration(gm.settingsManager.GetSettings().CacheResyncIntervalMinutes) * time.Minute
+		},
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create group syncer: %w", err)
@@ -73,6 +90,30 @@ func (gm *GroupManager) Initialize() error {
 	return nil
 }
 
+// warnInsecureGroups logs a prominent warning for each group that has enabled insecure_skip_verify
+// since the previous load. Groups that keep it enabled are not warned about again on every reload.
+func (gm *GroupManager) warnInsecureGroups(groups map[string]*models.Group) {
+	gm.insecureMu.Lock()
+	defer gm.insecureMu.Unlock()
+
+	insecure := make(map[string]bool)
+	for name, group := range groups {
+		if !group.Options.InsecureSkipVerify {
+			continue
+		}
+		insecure[name] = true
+		if gm.insecureGroups[name] {
+			continue
+		}
+		logrus.Warn("========================================================")
+		logrus.Warnf("  SECURITY WARNING: group '%s' has insecure_skip_verify", name)
+		logrus.Warn("  enabled. Upstream TLS certificates are NOT verified and")
+		logrus.Warn("  traffic (including API keys) may be intercepted.")
+		logrus.Warn("========================================================")
+	}
+	gm.insecureGroups = insecure
+}
+
 // GetGroupByName retrieves a single group by its name from the cache.
 func (gm *GroupManager) GetGroupByName(name string) (*models.Group, error) {
 	if gm.syncer == nil {
@@ -87,6 +128,23 @@ func (gm *GroupManager) GetGroupByName(name string) (*models.Group, error) {
 	return group, nil
 }
 
+// ListGroups returns all cached groups sorted by name.
+func (gm *GroupManager) ListGroups() ([]*models.Group, error) {
+	if gm.syncer == nil {
+		return nil, fmt.Errorf("GroupManager is not initialized")
+	}
+
+	groups := gm.syncer.Get()
+	result := make([]*models.Group, 0, len(groups))
+	for _, group := range groups {
+		result = append(result, group)
+	}
+	slices.SortFunc(result, func(a, b *models.Group) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return result, nil
+}
+
 // Invalidate triggers a cache reload across all instances.
 func (gm *GroupManager) Invalidate() error {
 	if gm.syncer == nil {
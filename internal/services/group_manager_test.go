@@ -0,0 +1,48 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"gpt-load/internal/models"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestWarnInsecureGroupsOnlyWhenEnabled(t *testing.T) {
+	hook := test.NewGlobal()
+	defer hook.Reset()
+
+	insecure := &models.Group{Name: "insecure", Options: models.GroupConfig{InsecureSkipVerify: true}}
+	secure := &models.Group{Name: "secure"}
+	gm := &GroupManager{}
+
+	warnings := func() int {
+		count := 0
+		for _, entry := range hook.AllEntries() {
+			if entry.Level == logrus.WarnLevel && strings.Contains(entry.Message, "SECURITY WARNING: group 'insecure'") {
+				count++
+			}
+		}
+		hook.Reset()
+		return count
+	}
+
+	loads := []struct {
+		name   string
+		groups map[string]*models.Group
+		want   int
+	}{
+		{"first load", map[string]*models.Group{"insecure": insecure, "secure": secure}, 1},
+		{"reload unchanged", map[string]*models.Group{"insecure": insecure, "secure": secure}, 0},
+		{"disabled", map[string]*models.Group{"secure": secure}, 0},
+		{"enabled again", map[string]*models.Group{"insecure": insecure}, 1},
+	}
+	for _, load := range loads {
+		gm.warnInsecureGroups(load.groups)
+		if got := warnings(); got != load.want {
+			t.Errorf("%s: got %d warnings, want %d", load.name, got, load.want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"gpt-load/internal/config"
+	"gpt-load/internal/models"
+	"gpt-load/internal/store"
+	"gpt-load/internal/utils"
+)
+
+// QuotaUsage reports a group's requests for the current day against its daily quota.
+type QuotaUsage struct {
+	Used    int64     `json:"used"`
+	Quota   int       `json:"quota"`
+	ResetAt time.Time `json:"reset_at"`
+}
+
+// GroupQuotaService enforces the per-group daily request quota.
+// Counters live in the shared store, so the cap is global across cluster nodes.
+type GroupQuotaService struct {
+	store           store.Store
+	settingsManager *config.SystemSettingsManager
+}
+
+// NewGroupQuotaService creates a new GroupQuotaService.
+func NewGroupQuotaService(store store.Store, settingsManager *config.SystemSettingsManager) *GroupQuotaService {
+	return &GroupQuotaService{
+		store:           store,
+		settingsManager: settingsManager,
+	}
+}
+
+// Consume counts one request against the group's daily quota and reports whether it is allowed.
+// Groups without a quota are always allowed and return nil usage.
+func (s *GroupQuotaService) Consume(group *models.Group) (bool, *QuotaUsage, error) {
+	quota := group.Options.DailyRequestQuota
+	if quota <= 0 {
+		return true, nil, nil
+	}
+
+	key, resetAt := s.counterKey(group.ID)
+	// Keep the counter a little past the reset so late requests of the day still see it.
+	ttl := time.Until(resetAt) + time.Hour
+
+	used, err := s.store.IncrBy(key, 1, ttl)
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to increment quota counter: %w", err)
+	}
+
+	usage := &QuotaUsage{Used: used, Quota: quota, ResetAt: resetAt}
+	if used <= int64(quota) {
+		return true, usage, nil
+	}
+
+	// Give the rejected request back so the usage stays at the quota.
+	if used, err = s.store.IncrBy(key, -1, ttl); err == nil {
+		usage.Used = used
+	}
+	return false, usage, nil
+}
+
+// GetUsage returns the group's usage for the current day, or nil if the group has no quota.
+func (s *GroupQuotaService) GetUsage(group *models.Group) (*QuotaUsage, error) {
+	quota := dailyRequestQuota(group)
+	if quota <= 0 {
+		return nil, nil
+	}
+
+	key, resetAt := s.counterKey(group.ID)
+	usage := &QuotaUsage{Quota: quota, ResetAt: resetAt}
+
+	value, err := s.store.Get(key)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return usage, nil
+		}
+		return nil, fmt.Errorf("failed to get quota counter: %w", err)
+	}
+	usage.Used, _ = strconv.ParseInt(string(value), 10, 64)
+	return usage, nil
+}
+
+// counterKey returns the store key of the group's counter for the current day and when that day ends.
+// Days follow the configured display timezone.
+func (s *GroupQuotaService) counterKey(groupID uint) (string, time.Time) {
+	dayStart := utils.StartOfDay(time.Now().In(s.settingsManager.GetDisplayLocation()))
+	key := fmt.Sprintf("group:%d:quota:%s", groupID, dayStart.Format("20060102"))
+	return key, dayStart.AddDate(0, 0, 1)
+}
+
+// dailyRequestQuota parses the quota from the config of a group that was not loaded by the GroupManager.
+func dailyRequestQuota(group *models.Group) int {
+	groupOptions, err := utils.ParseGroupConfig(group.Config)
+	if err != nil {
+		return 0
+	}
+	return groupOptions.DailyRequestQuota
+}
@@ -15,8 +15,9 @@ const (
 
 // KeyImportResult holds the result of an import task.
 type KeyImportResult struct {
-	AddedCount   int `json:"added_count"`
-	IgnoredCount int `json:"ignored_count"`
+	AddedCount       int                  `json:"added_count"`
+	IgnoredCount     int                  `json:"ignored_count"`
+	IgnoredBreakdown IgnoredKeysBreakdown `json:"ignored_breakdown"`
 }
 
 // KeyImportService handles the asynchronous import of a large number of keys.
@@ -35,7 +36,7 @@ func NewKeyImportService(taskService *TaskService, keyService *KeyService) *KeyI
 
 // StartImportTask initiates a new asynchronous key import task.
 func (s *KeyImportService) StartImportTask(group *models.Group, keysText string) (*TaskStatus, error) {
-	keys := s.KeyService.ParseKeysFromText(keysText)
+	keys := s.KeyService.splitKeysText(keysText)
 	if len(keys) == 0 {
 		return nil, fmt.Errorf("no valid keys found in the input text")
 	}
@@ -45,32 +46,36 @@ func (s *KeyImportService) StartImportTask(group *models.Group, keysText string)
 		return nil, err
 	}
 
-	go s.runImport(group, keys)
+	go s.runImport(initialStatus.ID, group, keys)
 
 	return initialStatus, nil
 }
 
-func (s *KeyImportService) runImport(group *models.Group, keys []string) {
+func (s *KeyImportService) runImport(taskID string, group *models.Group, keys []string) {
 	progressCallback := func(processed int) {
-		if err := s.TaskService.UpdateProgress(processed); err != nil {
+		if err := s.TaskService.UpdateProgress(taskID, processed); err != nil {
 			logrus.Warnf("Failed to update task progress for group %d: %v", group.ID, err)
 		}
 	}
 
-	addedCount, ignoredCount, err := s.KeyService.processAndCreateKeys(group.ID, keys, progressCallback)
+	ctx, cancel := s.TaskService.WatchCancel(taskID)
+	defer cancel()
+
+	addedCount, ignoredCount, breakdown, err := s.KeyService.processAndCreateKeys(ctx, group, keys, progressCallback)
+	result := KeyImportResult{
+		AddedCount:       addedCount,
+		IgnoredCount:     ignoredCount,
+		IgnoredBreakdown: breakdown,
+	}
 	if err != nil {
-		if endErr := s.TaskService.EndTask(nil, err); endErr != nil {
+		// Keys added before the failure or cancellation are kept, so report them as partial progress.
+		if endErr := s.TaskService.EndTask(taskID, result, err); endErr != nil {
 			logrus.Errorf("Failed to end task with error for group %d: %v (original error: %v)", group.ID, endErr, err)
 		}
 		return
 	}
 
-	result := KeyImportResult{
-		AddedCount:   addedCount,
-		IgnoredCount: ignoredCount,
-	}
-
-	if endErr := s.TaskService.EndTask(result, nil); endErr != nil {
+	if endErr := s.TaskService.EndTask(taskID, result, nil); endErr != nil {
 		logrus.Errorf("Failed to end task with success result for group %d: %v", group.ID, endErr)
 	}
 }
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"fmt"
 	"gpt-load/internal/config"
 	"gpt-load/internal/keypool"
@@ -59,14 +60,17 @@ func (s *KeyManualValidationService) StartValidationTask(group *models.Group) (*
 	}
 
 	// Run the validation in a separate goroutine
-	go s.runValidation(group, keys)
+	go s.runValidation(taskStatus.ID, group, keys)
 
 	return taskStatus, nil
 }
 
-func (s *KeyManualValidationService) runValidation(group *models.Group, keys []models.APIKey) {
+func (s *KeyManualValidationService) runValidation(taskID string, group *models.Group, keys []models.APIKey) {
 	logrus.Infof("Starting manual validation for group %s", group.Name)
 
+	ctx, cancel := s.TaskService.WatchCancel(taskID)
+	defer cancel()
+
 	jobs := make(chan models.APIKey, len(keys))
 	results := make(chan bool, len(keys))
 
@@ -75,7 +79,7 @@ func (s *KeyManualValidationService) runValidation(group *models.Group, keys []m
 	var wg sync.WaitGroup
 	for range concurrency {
 		wg.Add(1)
-		go s.validationWorker(&wg, group, jobs, results)
+		go s.validationWorker(ctx, &wg, group, jobs, results)
 	}
 
 	for _, key := range keys {
@@ -100,7 +104,7 @@ func (s *KeyManualValidationService) runValidation(group *models.Group, keys []m
 
 		// Throttle progress updates to once per second
 		if time.Since(lastUpdateTime) > time.Second {
-			if err := s.TaskService.UpdateProgress(processedCount); err != nil {
+			if err := s.TaskService.UpdateProgress(taskID, processedCount); err != nil {
 				logrus.Warnf("Failed to update task progress: %v", err)
 			}
 			lastUpdateTime = time.Now()
@@ -108,7 +112,7 @@ func (s *KeyManualValidationService) runValidation(group *models.Group, keys []m
 	}
 
 	// Ensure the final progress is always updated
-	if err := s.TaskService.UpdateProgress(processedCount); err != nil {
+	if err := s.TaskService.UpdateProgress(taskID, processedCount); err != nil {
 		logrus.Warnf("Failed to update final task progress: %v", err)
 	}
 
@@ -117,18 +121,26 @@ func (s *KeyManualValidationService) runValidation(group *models.Group, keys []m
 		ValidKeys:   validCount,
 		InvalidKeys: len(keys) - validCount,
 	}
+	if ctx.Err() != nil {
+		// Only the keys validated before cancellation are counted.
+		result.InvalidKeys = processedCount - validCount
+	}
 
 	// End the task and store the final result
-	if err := s.TaskService.EndTask(result, nil); err != nil {
+	if err := s.TaskService.EndTask(taskID, result, ctx.Err()); err != nil {
 		logrus.Errorf("Failed to end task for group %s: %v", group.Name, err)
 	}
 	logrus.Infof("Manual validation finished for group %s: %+v", group.Name, result)
 }
 
 // validationResult 包含验证结果信息
-func (s *KeyManualValidationService) validationWorker(wg *sync.WaitGroup, group *models.Group, jobs <-chan models.APIKey, results chan<- bool) {
+func (s *KeyManualValidationService) validationWorker(ctx context.Context, wg *sync.WaitGroup, group *models.Group, jobs <-chan models.APIKey, results chan<- bool) {
 	defer wg.Done()
 	for key := range jobs {
+		// Drain the remaining jobs without validating them once the task is cancelled.
+		if ctx.Err() != nil {
+			continue
+		}
 		isValid, _ := s.Validator.ValidateSingleKey(&key, group)
 		results <- isValid
 	}
@@ -1,14 +1,20 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"gpt-load/internal/channel"
+	"gpt-load/internal/config"
+	"gpt-load/internal/encryption"
 	"gpt-load/internal/keypool"
 	"gpt-load/internal/models"
 	"io"
 	"regexp"
 	"strings"
+	"unicode"
 
+	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
 
@@ -19,9 +25,34 @@ const (
 
 // AddKeysResult holds the result of adding multiple keys.
 type AddKeysResult struct {
-	AddedCount   int   `json:"added_count"`
-	IgnoredCount int   `json:"ignored_count"`
-	TotalInGroup int64 `json:"total_in_group"`
+	AddedCount       int                  `json:"added_count"`
+	IgnoredCount     int                  `json:"ignored_count"`
+	IgnoredBreakdown IgnoredKeysBreakdown `json:"ignored_breakdown"`
+	TotalInGroup     int64                `json:"total_in_group"`
+}
+
+// IgnoredKeysBreakdown counts the keys skipped during an import by reason.
+type IgnoredKeysBreakdown struct {
+	Duplicates    int `json:"duplicates"`
+	InvalidFormat int `json:"invalid_format"`
+	Empty         int `json:"empty"`
+}
+
+// ImportPreviewResult holds the outcome an import would have, without any keys being written.
+type ImportPreviewResult struct {
+	TotalCount       int                  `json:"total_count"`
+	NewCount         int                  `json:"new_count"`
+	IgnoredCount     int                  `json:"ignored_count"`
+	IgnoredBreakdown IgnoredKeysBreakdown `json:"ignored_breakdown"`
+}
+
+// ReplaceKeysResult holds the result of replacing all keys of a group.
+type ReplaceKeysResult struct {
+	AddedCount       int                  `json:"added_count"`
+	RemovedCount     int                  `json:"removed_count"`
+	IgnoredCount     int                  `json:"ignored_count"`
+	IgnoredBreakdown IgnoredKeysBreakdown `json:"ignored_breakdown"`
+	TotalInGroup     int64                `json:"total_in_group"`
 }
 
 // DeleteKeysResult holds the result of deleting multiple keys.
@@ -38,26 +69,43 @@ type RestoreKeysResult struct {
 	TotalInGroup  int64 `json:"total_in_group"`
 }
 
+// SetKeysStatusResult holds the result of changing the status of multiple keys.
+type SetKeysStatusResult struct {
+	UpdatedCount int   `json:"updated_count"`
+	IgnoredCount int   `json:"ignored_count"`
+	TotalInGroup int64 `json:"total_in_group"`
+}
+
 // KeyService provides services related to API keys.
 type KeyService struct {
-	DB           *gorm.DB
-	KeyProvider  *keypool.KeyProvider
-	KeyValidator *keypool.KeyValidator
+	DB              *gorm.DB
+	KeyProvider     *keypool.KeyProvider
+	KeyValidator    *keypool.KeyValidator
+	Encryption      encryption.Service
+	SettingsManager *config.SystemSettingsManager
 }
 
 // NewKeyService creates a new KeyService.
-func NewKeyService(db *gorm.DB, keyProvider *keypool.KeyProvider, keyValidator *keypool.KeyValidator) *KeyService {
+func NewKeyService(
+	db *gorm.DB,
+	keyProvider *keypool.KeyProvider,
+	keyValidator *keypool.KeyValidator,
+	encryptionSvc encryption.Service,
+	settingsManager *config.SystemSettingsManager,
+) *KeyService {
 	return &KeyService{
-		DB:           db,
-		KeyProvider:  keyProvider,
-		KeyValidator: keyValidator,
+		DB:              db,
+		KeyProvider:     keyProvider,
+		KeyValidator:    keyValidator,
+		Encryption:      encryptionSvc,
+		SettingsManager: settingsManager,
 	}
 }
 
 // AddMultipleKeys handles the business logic of creating new keys from a text block.
 // deprecated: use KeyImportService for large imports
-func (s *KeyService) AddMultipleKeys(groupID uint, keysText string) (*AddKeysResult, error) {
-	keys := s.ParseKeysFromText(keysText)
+func (s *KeyService) AddMultipleKeys(group *models.Group, keysText string) (*AddKeysResult, error) {
+	keys := s.splitKeysText(keysText)
 	if len(keys) > maxRequestKeys {
 		return nil, fmt.Errorf("batch size exceeds the limit of %d keys, got %d", maxRequestKeys, len(keys))
 	}
@@ -65,93 +113,202 @@ func (s *KeyService) AddMultipleKeys(groupID uint, keysText string) (*AddKeysRes
 		return nil, fmt.Errorf("no valid keys found in the input text")
 	}
 
-	addedCount, ignoredCount, err := s.processAndCreateKeys(groupID, keys, nil)
+	addedCount, ignoredCount, breakdown, err := s.processAndCreateKeys(context.Background(), group, keys, nil)
 	if err != nil {
 		return nil, err
 	}
 
 	var totalInGroup int64
-	if err := s.DB.Model(&models.APIKey{}).Where("group_id = ?", groupID).Count(&totalInGroup).Error; err != nil {
+	if err := s.DB.Model(&models.APIKey{}).Where("group_id = ?", group.ID).Count(&totalInGroup).Error; err != nil {
 		return nil, err
 	}
 
 	return &AddKeysResult{
-		AddedCount:   addedCount,
-		IgnoredCount: ignoredCount,
-		TotalInGroup: totalInGroup,
+		AddedCount:       addedCount,
+		IgnoredCount:     ignoredCount,
+		IgnoredBreakdown: breakdown,
+		TotalInGroup:     totalInGroup,
 	}, nil
 }
 
 // processAndCreateKeys is the lowest-level reusable function for adding keys.
+// Cancelling ctx stops before the next chunk; keys added so far are kept and counted.
+// The breakdown explains why keys were skipped before any of them was written.
 func (s *KeyService) processAndCreateKeys(
-	groupID uint,
+	ctx context.Context,
+	group *models.Group,
 	keys []string,
 	progressCallback func(processed int),
-) (addedCount int, ignoredCount int, err error) {
-	// 1. Get existing keys in the group for deduplication
+) (addedCount int, ignoredCount int, breakdown IgnoredKeysBreakdown, err error) {
+	groupID := group.ID
+
+	// 1. Deduplicate and validate the keys
+	newKeysToCreate, breakdown, err := s.prepareNewKeys(group, keys)
+	if err != nil {
+		return 0, 0, breakdown, err
+	}
+
+	if len(newKeysToCreate) == 0 {
+		return 0, len(keys), breakdown, nil
+	}
+
+	// 2. Use KeyProvider to add keys in chunks
+	for i := 0; i < len(newKeysToCreate); i += chunkSize {
+		if err := ctx.Err(); err != nil {
+			return addedCount, len(keys) - addedCount, breakdown, err
+		}
+		end := i + chunkSize
+		if end > len(newKeysToCreate) {
+			end = len(newKeysToCreate)
+		}
+		chunk := newKeysToCreate[i:end]
+		if err := s.KeyProvider.AddKeys(groupID, chunk); err != nil {
+			return addedCount, len(keys) - addedCount, breakdown, err
+		}
+		addedCount += len(chunk)
+
+		if progressCallback != nil {
+			progressCallback(i + len(chunk))
+		}
+	}
+
+	return addedCount, len(keys) - addedCount, breakdown, nil
+}
+
+// prepareNewKeys deduplicates keys against the group and each other and checks their format.
+// It returns the keys that would be created and the reasons the rest were skipped, without writing anything.
+func (s *KeyService) prepareNewKeys(group *models.Group, keys []string) ([]models.APIKey, IgnoredKeysBreakdown, error) {
 	var existingKeys []models.APIKey
-	if err := s.DB.Where("group_id = ?", groupID).Select("key_value").Find(&existingKeys).Error; err != nil {
-		return 0, 0, err
+	if err := s.DB.Where("group_id = ?", group.ID).Select("key_value").Find(&existingKeys).Error; err != nil {
+		return nil, IgnoredKeysBreakdown{}, err
 	}
+	// Existing keys are compared by their dedup key too, so a key that differs only in case or whitespace
+	// from a stored one counts as a duplicate. On case-insensitive collations (the MySQL default)
+	// inserting it would violate the unique index.
+	dedupKey := s.dedupKeyFunc()
 	existingKeyMap := make(map[string]bool)
 	for _, k := range existingKeys {
-		existingKeyMap[k.KeyValue] = true
+		keyValue, err := s.Encryption.Decrypt(k.KeyValue)
+		if err != nil {
+			return nil, IgnoredKeysBreakdown{}, err
+		}
+		existingKeyMap[dedupKey(keyValue)] = true
+	}
+
+	validKeys, breakdown := s.filterImportKeys(group, keys, existingKeyMap)
+
+	newKeys := make([]models.APIKey, 0, len(validKeys))
+	for _, keyValue := range validKeys {
+		newKeys = append(newKeys, models.APIKey{
+			GroupID:  group.ID,
+			KeyValue: keyValue,
+			Status:   models.KeyStatusActive,
+		})
 	}
 
-	// 2. Prepare new keys for creation
-	var newKeysToCreate []models.APIKey
-	uniqueNewKeys := make(map[string]bool)
+	return newKeys, breakdown, nil
+}
+
+// filterImportKeys trims keys and drops empty, duplicate and malformed ones. Duplicates are found by
+// comparing dedup keys, and keys found in existing (a set of dedup keys) also count as duplicates.
+// The remaining keys keep their input order and are returned as entered, only trimmed.
+func (s *KeyService) filterImportKeys(group *models.Group, keys []string, existing map[string]bool) ([]string, IgnoredKeysBreakdown) {
+	var breakdown IgnoredKeysBreakdown
+	dedupKey := s.dedupKeyFunc()
 
+	var validKeys []string
+	var unusualKeys int
+	hint, hasHint := channel.GetKeyFormatHint(group.ChannelType)
+	seen := make(map[string]bool)
 	for _, keyVal := range keys {
 		trimmedKey := strings.TrimSpace(keyVal)
 		if trimmedKey == "" {
+			breakdown.Empty++
+			continue
+		}
+		normalizedKey := dedupKey(trimmedKey)
+		if existing[normalizedKey] || seen[normalizedKey] {
+			breakdown.Duplicates++
 			continue
 		}
-		if existingKeyMap[trimmedKey] || uniqueNewKeys[trimmedKey] {
+		if !s.isValidKeyFormatForChannel(group.ChannelType, trimmedKey) {
+			breakdown.InvalidFormat++
 			continue
 		}
-		if s.isValidKeyFormat(trimmedKey) {
-			uniqueNewKeys[trimmedKey] = true
-			newKeysToCreate = append(newKeysToCreate, models.APIKey{
-				GroupID:  groupID,
-				KeyValue: trimmedKey,
-				Status:   models.KeyStatusActive,
-			})
+		if hasHint && !hint(trimmedKey) {
+			unusualKeys++
 		}
+		seen[normalizedKey] = true
+		validKeys = append(validKeys, trimmedKey)
+	}
+	if unusualKeys > 0 {
+		logrus.Warnf("%d keys for group %s look like keys of another provider; they are accepted for %s-compatible upstreams", unusualKeys, group.Name, group.ChannelType)
 	}
 
-	if len(newKeysToCreate) == 0 {
-		return 0, len(keys), nil
+	return validKeys, breakdown
+}
+
+// ReplaceAllKeys replaces every key of a group with the keys in a text block, in one transaction.
+// Keys present before and after keep their records; the group never has an empty pool mid-swap.
+func (s *KeyService) ReplaceAllKeys(group *models.Group, keysText string) (*ReplaceKeysResult, error) {
+	keys := s.splitKeysText(keysText)
+	if len(keys) > maxRequestKeys {
+		return nil, fmt.Errorf("batch size exceeds the limit of %d keys, got %d", maxRequestKeys, len(keys))
 	}
 
-	// 3. Use KeyProvider to add keys in chunks
-	for i := 0; i < len(newKeysToCreate); i += chunkSize {
-		end := i + chunkSize
-		if end > len(newKeysToCreate) {
-			end = len(newKeysToCreate)
-		}
-		chunk := newKeysToCreate[i:end]
-		if err := s.KeyProvider.AddKeys(groupID, chunk); err != nil {
-			return addedCount, len(keys) - addedCount, err
-		}
-		addedCount += len(chunk)
+	validKeys, breakdown := s.filterImportKeys(group, keys, nil)
+	if len(validKeys) == 0 {
+		return nil, fmt.Errorf("no valid keys found in the input text")
+	}
 
-		if progressCallback != nil {
-			progressCallback(i + len(chunk))
-		}
+	addedCount, removedCount, err := s.KeyProvider.ReplaceKeys(group.ID, validKeys)
+	if err != nil {
+		return nil, err
 	}
 
-	return addedCount, len(keys) - addedCount, nil
+	return &ReplaceKeysResult{
+		AddedCount:       int(addedCount),
+		RemovedCount:     int(removedCount),
+		IgnoredCount:     len(keys) - len(validKeys),
+		IgnoredBreakdown: breakdown,
+		TotalInGroup:     int64(len(validKeys)),
+	}, nil
+}
+
+// PreviewImport runs the import checks on a text block and reports the outcome without creating any keys.
+func (s *KeyService) PreviewImport(group *models.Group, keysText string) (*ImportPreviewResult, error) {
+	keys := s.splitKeysText(keysText)
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no valid keys found in the input text")
+	}
+
+	newKeys, breakdown, err := s.prepareNewKeys(group, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ImportPreviewResult{
+		TotalCount:       len(keys),
+		NewCount:         len(newKeys),
+		IgnoredCount:     len(keys) - len(newKeys),
+		IgnoredBreakdown: breakdown,
+	}, nil
 }
 
 // ParseKeysFromText parses a string of keys from various formats into a string slice.
 // This function is exported to be shared with the handler layer.
 func (s *KeyService) ParseKeysFromText(text string) []string {
+	return s.filterValidKeys(s.splitKeysText(text))
+}
+
+// splitKeysText splits a text block into candidate keys without validating them,
+// so that imports can report why individual entries were ignored.
+func (s *KeyService) splitKeysText(text string) []string {
 	var keys []string
 
 	// First, try to parse as a JSON array of strings
 	if json.Unmarshal([]byte(text), &keys) == nil && len(keys) > 0 {
-		return s.filterValidKeys(keys)
+		return keys
 	}
 
 	// 通用解析：通过分隔符分割文本，不使用复杂的正则表达式
@@ -165,7 +322,7 @@ func (s *KeyService) ParseKeysFromText(text string) []string {
 		}
 	}
 
-	return s.filterValidKeys(keys)
+	return keys
 }
 
 // filterValidKeys validates and filters potential API keys
@@ -180,6 +337,40 @@ func (s *KeyService) filterValidKeys(keys []string) []string {
 	return validKeys
 }
 
+// dedupKeyFunc returns the function that turns a key into the form compared when deduplicating keys.
+// Keys are always trimmed; lowercasing and stripping inner whitespace follow the system settings.
+// The dedup key is only compared, never stored: keys are validated and stored as entered.
+func (s *KeyService) dedupKeyFunc() func(string) string {
+	settings := s.SettingsManager.GetSettings()
+	return func(key string) string {
+		key = strings.TrimSpace(key)
+		if settings.KeyDedupStripWhitespace {
+			key = strings.Map(func(r rune) rune {
+				if unicode.IsSpace(r) {
+					return -1
+				}
+				return r
+			}, key)
+		}
+		if settings.KeyDedupLowercase {
+			key = strings.ToLower(key)
+		}
+		return key
+	}
+}
+
+// isValidKeyFormatForChannel checks a key against the channel's own key format, if the channel defines one,
+// and against the generic format otherwise.
+func (s *KeyService) isValidKeyFormatForChannel(channelType string, key string) bool {
+	if !s.isValidKeyFormat(key) {
+		return false
+	}
+	if validator, ok := channel.GetKeyFormatValidator(channelType); ok {
+		return validator(key)
+	}
+	return true
+}
+
 // isValidKeyFormat performs basic validation on key format
 func (s *KeyService) isValidKeyFormat(key string) bool {
 	if len(key) < 4 || len(key) > 1000 {
@@ -233,6 +424,39 @@ func (s *KeyService) RestoreMultipleKeys(groupID uint, keysText string) (*Restor
 	}, nil
 }
 
+// SetKeysStatus handles the business logic of setting the status of keys from a text block.
+// Keys that do not exist in the group or already have the status are ignored.
+func (s *KeyService) SetKeysStatus(groupID uint, keysText string, status string) (*SetKeysStatusResult, error) {
+	keysToUpdate := s.ParseKeysFromText(keysText)
+	if len(keysToUpdate) > maxRequestKeys {
+		return nil, fmt.Errorf("batch size exceeds the limit of %d keys, got %d", maxRequestKeys, len(keysToUpdate))
+	}
+	if len(keysToUpdate) == 0 {
+		return nil, fmt.Errorf("no valid keys found in the input text")
+	}
+
+	var totalUpdatedCount int64
+	for i := 0; i < len(keysToUpdate); i += chunkSize {
+		end := min(i+chunkSize, len(keysToUpdate))
+		updatedCount, err := s.KeyProvider.SetKeysStatus(groupID, keysToUpdate[i:end], status)
+		if err != nil {
+			return nil, err
+		}
+		totalUpdatedCount += updatedCount
+	}
+
+	var totalInGroup int64
+	if err := s.DB.Model(&models.APIKey{}).Where("group_id = ?", groupID).Count(&totalInGroup).Error; err != nil {
+		return nil, err
+	}
+
+	return &SetKeysStatusResult{
+		UpdatedCount: int(totalUpdatedCount),
+		IgnoredCount: len(keysToUpdate) - int(totalUpdatedCount),
+		TotalInGroup: totalInGroup,
+	}, nil
+}
+
 // RestoreAllInvalidKeys sets the status of all 'inactive' keys in a group to 'active'.
 func (s *KeyService) RestoreAllInvalidKeys(groupID uint) (int64, error) {
 	return s.KeyProvider.RestoreKeys(groupID)
@@ -290,7 +514,12 @@ func (s *KeyService) ListKeysInGroupQuery(groupID uint, statusFilter string, sea
 	}
 
 	if searchKeyword != "" {
-		query = query.Where("key_value LIKE ?", "%"+searchKeyword+"%")
+		if s.Encryption.Enabled() {
+			// Encrypted values can only be matched as a whole key.
+			query = query.Where("key_value IN ?", s.Encryption.LookupValues([]string{searchKeyword}))
+		} else {
+			query = query.Where("key_value LIKE ?", "%"+searchKeyword+"%")
+		}
 	}
 
 	query = query.Order("last_used_at desc, updated_at desc")
@@ -298,8 +527,21 @@ func (s *KeyService) ListKeysInGroupQuery(groupID uint, statusFilter string, sea
 	return query
 }
 
-// TestMultipleKeys handles a one-off validation test for multiple keys.
-func (s *KeyService) TestMultipleKeys(group *models.Group, keysText string) ([]keypool.KeyTestResult, error) {
+// DecryptKeys replaces the stored key values with their plaintext, in place.
+func (s *KeyService) DecryptKeys(keys []models.APIKey) error {
+	for i := range keys {
+		keyValue, err := s.Encryption.Decrypt(keys[i].KeyValue)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt key %d: %w", keys[i].ID, err)
+		}
+		keys[i].KeyValue = keyValue
+	}
+	return nil
+}
+
+// TestMultipleKeys handles a one-off validation test for multiple keys, testing up to concurrency
+// keys at a time. A concurrency of zero uses the group's key_validation_concurrency.
+func (s *KeyService) TestMultipleKeys(group *models.Group, keysText string, concurrency int) ([]keypool.KeyTestResult, error) {
 	keysToTest := s.ParseKeysFromText(keysText)
 	if len(keysToTest) > maxRequestKeys {
 		return nil, fmt.Errorf("batch size exceeds the limit of %d keys, got %d", maxRequestKeys, len(keysToTest))
@@ -315,7 +557,7 @@ func (s *KeyService) TestMultipleKeys(group *models.Group, keysText string) ([]k
 			end = len(keysToTest)
 		}
 		chunk := keysToTest[i:end]
-		results, err := s.KeyValidator.TestMultipleKeys(group, chunk)
+		results, err := s.KeyValidator.TestMultipleKeys(group, chunk, concurrency)
 		if err != nil {
 			return nil, err
 		}
@@ -340,7 +582,11 @@ func (s *KeyService) StreamKeysToWriter(groupID uint, statusFilter string, write
 	var keys []models.APIKey
 	err := query.FindInBatches(&keys, chunkSize, func(tx *gorm.DB, batch int) error {
 		for _, key := range keys {
-			if _, err := writer.Write([]byte(key.KeyValue + "\n")); err != nil {
+			keyValue, err := s.Encryption.Decrypt(key.KeyValue)
+			if err != nil {
+				return err
+			}
+			if _, err := writer.Write([]byte(keyValue + "\n")); err != nil {
 				return err
 			}
 		}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"gpt-load/internal/config"
+	"gpt-load/internal/models"
+	"gpt-load/internal/store"
+	"gpt-load/internal/testutil"
+)
+
+// newTestSettingsManager returns a settings manager over an in-memory database holding the given
+// system settings, and installs that database as db.DB for the test.
+func newTestSettingsManager(t *testing.T, settings map[string]string) *config.SystemSettingsManager {
+	t.Helper()
+	database := testutil.OpenDB(t, &models.SystemSetting{})
+	for key, value := range settings {
+		if err := database.Create(&models.SystemSetting{SettingKey: key, SettingValue: value}).Error; err != nil {
+			t.Fatalf("failed to save setting %s: %v", key, err)
+		}
+	}
+	testutil.UseDB(t, database)
+
+	settingsManager := config.NewSystemSettingsManager()
+	if err := settingsManager.Initialize(store.NewMemoryStore(), nil, false); err != nil {
+		t.Fatalf("failed to initialize settings: %v", err)
+	}
+	t.Cleanup(func() { settingsManager.Stop(context.Background()) })
+	return settingsManager
+}
+
+func TestFilterImportKeysKeepsOriginalCase(t *testing.T) {
+	s := &KeyService{SettingsManager: newTestSettingsManager(t, map[string]string{
+		"key_dedup_lowercase": "true",
+	})}
+	group := &models.Group{ChannelType: "openai"}
+	existing := map[string]bool{"sk-existingkey0001": true}
+
+	keys, breakdown := s.filterImportKeys(group, []string{
+		" sk-MixedCaseKey0001 ",
+		"sk-mixedcasekey0001",
+		"SK-EXISTINGKEY0001",
+		"",
+	}, existing)
+
+	if want := []string{"sk-MixedCaseKey0001"}; !slices.Equal(keys, want) {
+		t.Errorf("keys = %q, want %q", keys, want)
+	}
+	if breakdown.Duplicates != 2 || breakdown.Empty != 1 {
+		t.Errorf("breakdown = %+v, want 2 duplicates and 1 empty", breakdown)
+	}
+}
+
+func TestFilterImportKeysAcceptsOtherProviderKeysInOpenAIGroups(t *testing.T) {
+	s := &KeyService{SettingsManager: newTestSettingsManager(t, nil)}
+	input := []string{"sk-proj-0123456789", "AIzaSyExampleKey0123", "sk-ant-api03-example0123"}
+
+	keys, breakdown := s.filterImportKeys(&models.Group{Name: "compat", ChannelType: "openai"}, input, nil)
+	if !slices.Equal(keys, input) || breakdown.InvalidFormat != 0 {
+		t.Errorf("keys = %q, breakdown = %+v, want all keys accepted", keys, breakdown)
+	}
+
+	keys, breakdown = s.filterImportKeys(&models.Group{Name: "claude", ChannelType: "anthropic"}, input, nil)
+	if want := []string{"sk-ant-api03-example0123"}; !slices.Equal(keys, want) || breakdown.InvalidFormat != 2 {
+		t.Errorf("anthropic keys = %q, breakdown = %+v, want %q", keys, breakdown, want)
+	}
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gpt-load/internal/config"
+	"gpt-load/internal/keypool"
+	"gpt-load/internal/models"
+	"gpt-load/internal/store"
+	"gpt-load/internal/utils"
+)
+
+const (
+	keyTestResultKeyPrefix = "key_test_results:"
+	// KeyTestResultTTL is how long the last key test run of a group is kept.
+	KeyTestResultTTL = 24 * time.Hour
+)
+
+// KeyTestRun is the saved outcome of the last key test run of a group.
+type KeyTestRun struct {
+	GroupID      uint                    `json:"group_id"`
+	GroupName    string                  `json:"group_name"`
+	TestedAt     time.Time               `json:"tested_at"`
+	ExpiresAt    time.Time               `json:"expires_at"`
+	Total        int                     `json:"total"`
+	ValidCount   int                     `json:"valid_count"`
+	InvalidCount int                     `json:"invalid_count"`
+	KeysMasked   bool                    `json:"keys_masked"`
+	Results      []keypool.KeyTestResult `json:"results"`
+}
+
+// KeyTestResultService keeps the last key test run of each group in the shared store,
+// so the results can be reviewed after the response that returned them is gone.
+type KeyTestResultService struct {
+	store           store.Store
+	settingsManager *config.SystemSettingsManager
+}
+
+// NewKeyTestResultService creates a new KeyTestResultService.
+func NewKeyTestResultService(store store.Store, settingsManager *config.SystemSettingsManager) *KeyTestResultService {
+	return &KeyTestResultService{
+		store:           store,
+		settingsManager: settingsManager,
+	}
+}
+
+// Save replaces the saved test run of group with results. Keys are masked before they are
+// stored when hide_full_keys is enabled.
+func (s *KeyTestResultService) Save(group *models.Group, results []keypool.KeyTestResult) (*KeyTestRun, error) {
+	now := time.Now()
+	run := &KeyTestRun{
+		GroupID:    group.ID,
+		GroupName:  group.Name,
+		TestedAt:   now,
+		ExpiresAt:  now.Add(KeyTestResultTTL),
+		Total:      len(results),
+		KeysMasked: s.settingsManager.GetSettings().HideFullKeys,
+		Results:    make([]keypool.KeyTestResult, len(results)),
+	}
+	for i, result := range results {
+		if result.IsValid {
+			run.ValidCount++
+		} else {
+			run.InvalidCount++
+		}
+		if run.KeysMasked {
+			result.KeyValue = utils.MaskAPIKey(result.KeyValue)
+		}
+		run.Results[i] = result
+	}
+
+	data, err := json.Marshal(run)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize key test results: %w", err)
+	}
+	if err := s.store.Set(keyTestResultKey(group.ID), data, KeyTestResultTTL); err != nil {
+		return nil, fmt.Errorf("failed to save key test results: %w", err)
+	}
+	return run, nil
+}
+
+// Get returns the saved test run of the group, or nil if there is none or it has expired.
+func (s *KeyTestResultService) Get(groupID uint) (*KeyTestRun, error) {
+	data, err := s.store.Get(keyTestResultKey(groupID))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to load key test results: %w", err)
+	}
+
+	var run KeyTestRun
+	if err := json.Unmarshal(data, &run); err != nil {
+		return nil, fmt.Errorf("failed to parse key test results: %w", err)
+	}
+	return &run, nil
+}
+
+// keyTestResultKey returns the store key of a group's saved test run.
+func keyTestResultKey(groupID uint) string {
+	return fmt.Sprintf("%s%d", keyTestResultKeyPrefix, groupID)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"gpt-load/internal/config"
 	"gpt-load/internal/models"
+	"gpt-load/internal/utils"
 	"sync"
 	"time"
 
@@ -11,20 +12,29 @@ import (
 	"gorm.io/gorm"
 )
 
-// LogCleanupService 负责清理过期的请求日志
+// LogCleanupService 负责清理过期的请求日志、请求体记录和影子对比结果
 type LogCleanupService struct {
-	db              *gorm.DB
-	settingsManager *config.SystemSettingsManager
-	stopCh          chan struct{}
-	wg              sync.WaitGroup
+	db                      *gorm.DB
+	settingsManager         *config.SystemSettingsManager
+	bodyLogService          *RequestBodyLogService
+	shadowComparisonService *ShadowComparisonService
+	stopCh                  chan struct{}
+	wg                      sync.WaitGroup
 }
 
 // NewLogCleanupService 创建新的日志清理服务
-func NewLogCleanupService(db *gorm.DB, settingsManager *config.SystemSettingsManager) *LogCleanupService {
+func NewLogCleanupService(
+	db *gorm.DB,
+	settingsManager *config.SystemSettingsManager,
+	bodyLogService *RequestBodyLogService,
+	shadowComparisonService *ShadowComparisonService,
+) *LogCleanupService {
 	return &LogCleanupService{
-		db:              db,
-		settingsManager: settingsManager,
-		stopCh:          make(chan struct{}),
+		db:                      db,
+		settingsManager:         settingsManager,
+		bodyLogService:          bodyLogService,
+		shadowComparisonService: shadowComparisonService,
+		stopCh:                  make(chan struct{}),
 	}
 }
 
@@ -61,11 +71,13 @@ func (s *LogCleanupService) run() {
 
 	// 启动时先执行一次清理
 	s.cleanupExpiredLogs()
+	s.cleanupExpiredBodyLogs()
 
 	for {
 		select {
 		case <-ticker.C:
 			s.cleanupExpiredLogs()
+			s.cleanupExpiredBodyLogs()
 		case <-s.stopCh:
 			return
 		}
@@ -84,7 +96,8 @@ func (s *LogCleanupService) cleanupExpiredLogs() {
 	}
 
 	// 计算过期时间点
-	cutoffTime := time.Now().AddDate(0, 0, -retentionDays).UTC()
+	// 以显示时区的零点为边界，保证按天清理与按天统计一致
+	cutoffTime := utils.StartOfDay(time.Now().In(s.settingsManager.GetDisplayLocation())).AddDate(0, 0, -retentionDays).UTC()
 
 	// 执行删除操作
 	result := s.db.Where("timestamp < ?", cutoffTime).Delete(&models.RequestLog{})
@@ -102,4 +115,26 @@ func (s *LogCleanupService) cleanupExpiredLogs() {
 	} else {
 		logrus.Debug("No expired request logs found to cleanup")
 	}
+
+	// 影子对比结果与请求日志使用相同的保留天数
+	deleted, err := s.shadowComparisonService.DeleteBefore(cutoffTime)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to cleanup expired shadow comparisons")
+		return
+	}
+	if deleted > 0 {
+		logrus.WithField("deleted_count", deleted).Info("Successfully cleaned up expired shadow comparisons")
+	}
+}
+
+// cleanupExpiredBodyLogs 清理超过保留时长的请求体记录，不受请求日志保留天数影响
+func (s *LogCleanupService) cleanupExpiredBodyLogs() {
+	deleted, err := s.bodyLogService.DeleteExpired()
+	if err != nil {
+		logrus.WithError(err).Error("Failed to cleanup expired request body logs")
+		return
+	}
+	if deleted > 0 {
+		logrus.WithField("deleted_count", deleted).Info("Successfully cleaned up expired request body logs")
+	}
 }
@@ -3,7 +3,9 @@ package services
 import (
 	"encoding/csv"
 	"fmt"
+	"gpt-load/internal/db"
 	"gpt-load/internal/models"
+	"gpt-load/internal/utils"
 	"io"
 	"strconv"
 	"time"
@@ -12,7 +14,7 @@ import (
 	"gorm.io/gorm"
 )
 
-// ExportableLogKey defines the structure for the data to be exported to CSV.
+// ExportableLogKey defines the structure for the data to be exported to CSV. KeyValue is the masked key.
 type ExportableLogKey struct {
 	KeyValue   string `gorm:"column:key_value"`
 	GroupName  string `gorm:"column:group_name"`
@@ -20,13 +22,14 @@ type ExportableLogKey struct {
 }
 
 // LogService provides services related to request logs.
+// It only reads logs, so queries go to the read replica when one is configured.
 type LogService struct {
 	DB *gorm.DB
 }
 
 // NewLogService creates a new LogService.
-func NewLogService(db *gorm.DB) *LogService {
-	return &LogService{DB: db}
+func NewLogService(readDB *db.ReadDB) *LogService {
+	return &LogService{DB: readDB.DB}
 }
 
 // logFiltersScope returns a GORM scope function that applies filters from the Gin context.
@@ -43,13 +46,24 @@ func logFiltersScope(c *gin.Context) func(db *gorm.DB) *gorm.DB {
 			} else {
 				likePattern = "%" + keyValue + "%"
 			}
-			db = db.Where("key_value LIKE ?", likePattern)
+			// 日志只保存脱敏后的密钥：完整密钥按哈希匹配，片段按脱敏值模糊匹配
+			db = db.Where("key_hash = ? OR key_value LIKE ?", utils.HashKey(keyValue), likePattern)
 		}
 		if isSuccessStr := c.Query("is_success"); isSuccessStr != "" {
 			if isSuccess, err := strconv.ParseBool(isSuccessStr); err == nil {
 				db = db.Where("is_success = ?", isSuccess)
 			}
 		}
+		if isCancelledStr := c.Query("is_client_cancelled"); isCancelledStr != "" {
+			if isCancelled, err := strconv.ParseBool(isCancelledStr); err == nil {
+				db = db.Where("is_client_cancelled = ?", isCancelled)
+			}
+		}
+		if isShadowStr := c.Query("is_shadow"); isShadowStr != "" {
+			if isShadow, err := strconv.ParseBool(isShadowStr); err == nil {
+				db = db.Where("is_shadow = ?", isShadow)
+			}
+		}
 		if statusCodeStr := c.Query("status_code"); statusCodeStr != "" {
 			if statusCode, err := strconv.Atoi(statusCodeStr); err == nil {
 				db = db.Where("status_code = ?", statusCode)
@@ -96,7 +110,7 @@ func (s *LogService) StreamLogKeysToCSV(c *gin.Context, writer io.Writer) error
 
 	baseQuery := s.DB.Model(&models.RequestLog{}).Scopes(logFiltersScope(c))
 
-	// 使用窗口函数获取每个key_value的最新记录
+	// 使用窗口函数获取每个密钥的最新记录，按密钥哈希区分不同密钥
 	err := s.DB.Raw(`
 		SELECT
 			key_value,
@@ -107,7 +121,7 @@ func (s *LogService) StreamLogKeysToCSV(c *gin.Context, writer io.Writer) error
 				key_value,
 				group_name,
 				status_code,
-				ROW_NUMBER() OVER (PARTITION BY key_value ORDER BY timestamp DESC) as rn
+				ROW_NUMBER() OVER (PARTITION BY key_hash ORDER BY timestamp DESC) as rn
 			FROM (?) as filtered_logs
 		) ranked
 		WHERE rn = 1
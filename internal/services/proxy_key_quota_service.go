@@ -0,0 +1,175 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gpt-load/internal/config"
+	"gpt-load/internal/store"
+	"gpt-load/internal/types"
+	"gpt-load/internal/utils"
+)
+
+// QuotaExceededError is returned when a proxy key has used up one of its quotas.
+type QuotaExceededError struct {
+	Period  string
+	Quota   int
+	ResetAt time.Time
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("%s request quota of %d exceeded, resets at %s", e.Period, e.Quota, e.ResetAt.Format(time.RFC3339))
+}
+
+// ProxyKeyUsage reports a proxy key's requests in the current day and month against its quotas.
+type ProxyKeyUsage struct {
+	Key     string     `json:"key"`
+	Global  bool       `json:"global"`
+	Groups  []string   `json:"groups"`
+	Daily   QuotaUsage `json:"daily"`
+	Monthly QuotaUsage `json:"monthly"`
+}
+
+// quotaPeriod is a single counting window of a proxy key.
+type quotaPeriod struct {
+	name       string
+	counterKey string
+	quota      int
+	resetAt    time.Time
+}
+
+// ProxyKeyQuotaService tracks per-proxy-key usage and enforces the daily and monthly quotas
+// from the proxy_key_quotas setting. Counters are keyed by the proxy key hash in the shared store.
+type ProxyKeyQuotaService struct {
+	store           store.Store
+	settingsManager *config.SystemSettingsManager
+	groupManager    *GroupManager
+}
+
+// NewProxyKeyQuotaService creates a new ProxyKeyQuotaService.
+func NewProxyKeyQuotaService(store store.Store, settingsManager *config.SystemSettingsManager, groupManager *GroupManager) *ProxyKeyQuotaService {
+	return &ProxyKeyQuotaService{
+		store:           store,
+		settingsManager: settingsManager,
+		groupManager:    groupManager,
+	}
+}
+
+// Consume counts one request for the proxy key. It returns a *QuotaExceededError,
+// without counting the request, once the key has used up its daily or monthly quota.
+func (s *ProxyKeyQuotaService) Consume(key string) error {
+	quota := s.settingsManager.GetSettings().ProxyKeyQuotasMap[key]
+	periods := s.currentPeriods(key, quota)
+
+	var exceeded *QuotaExceededError
+	for i, period := range periods {
+		used, err := s.store.IncrBy(period.counterKey, 1, time.Until(period.resetAt)+time.Hour)
+		if err != nil {
+			s.release(periods[:i])
+			return fmt.Errorf("failed to increment proxy key usage: %w", err)
+		}
+		if exceeded == nil && period.quota > 0 && used > int64(period.quota) {
+			exceeded = &QuotaExceededError{Period: period.name, Quota: period.quota, ResetAt: period.resetAt}
+		}
+	}
+
+	if exceeded != nil {
+		s.release(periods)
+		return exceeded
+	}
+	return nil
+}
+
+// release gives back a request counted in the given periods.
+func (s *ProxyKeyQuotaService) release(periods []quotaPeriod) {
+	for _, period := range periods {
+		_, _ = s.store.IncrBy(period.counterKey, -1, time.Until(period.resetAt)+time.Hour)
+	}
+}
+
+// ListUsage returns the current usage of every global, group and quota-configured proxy key.
+func (s *ProxyKeyQuotaService) ListUsage() ([]ProxyKeyUsage, error) {
+	settings := s.settingsManager.GetSettings()
+	groups, err := s.groupManager.ListGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	usageByKey := make(map[string]*ProxyKeyUsage)
+	entry := func(key string) *ProxyKeyUsage {
+		if usage, ok := usageByKey[key]; ok {
+			return usage
+		}
+		usage := &ProxyKeyUsage{Key: key, Groups: []string{}}
+		usageByKey[key] = usage
+		return usage
+	}
+	for key := range settings.ProxyKeysMap {
+		entry(key).Global = true
+	}
+	for _, group := range groups {
+		for key := range group.ProxyKeysMap {
+			usage := entry(key)
+			usage.Groups = append(usage.Groups, group.Name)
+		}
+	}
+	for key := range settings.ProxyKeyQuotasMap {
+		entry(key)
+	}
+
+	result := make([]ProxyKeyUsage, 0, len(usageByKey))
+	for key, usage := range usageByKey {
+		periods := s.currentPeriods(key, settings.ProxyKeyQuotasMap[key])
+		if usage.Daily, err = s.periodUsage(periods[0]); err != nil {
+			return nil, err
+		}
+		if usage.Monthly, err = s.periodUsage(periods[1]); err != nil {
+			return nil, err
+		}
+		result = append(result, *usage)
+	}
+	slices.SortFunc(result, func(a, b ProxyKeyUsage) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	return result, nil
+}
+
+func (s *ProxyKeyQuotaService) periodUsage(period quotaPeriod) (QuotaUsage, error) {
+	usage := QuotaUsage{Quota: period.quota, ResetAt: period.resetAt}
+	value, err := s.store.Get(period.counterKey)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return usage, nil
+		}
+		return usage, fmt.Errorf("failed to get proxy key usage: %w", err)
+	}
+	usage.Used, _ = strconv.ParseInt(string(value), 10, 64)
+	return usage, nil
+}
+
+// currentPeriods returns the daily and monthly windows of the proxy key, following the display timezone.
+func (s *ProxyKeyQuotaService) currentPeriods(key string, quota types.ProxyKeyQuota) []quotaPeriod {
+	now := time.Now().In(s.settingsManager.GetDisplayLocation())
+	dayStart := utils.StartOfDay(now)
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	keyHash := utils.HashKey(key)
+
+	return []quotaPeriod{
+		{
+			name:       "daily",
+			counterKey: fmt.Sprintf("proxy_key:%s:usage:%s", keyHash, dayStart.Format("20060102")),
+			quota:      quota.Daily,
+			resetAt:    dayStart.AddDate(0, 0, 1),
+		},
+		{
+			name:       "monthly",
+			counterKey: fmt.Sprintf("proxy_key:%s:usage:%s", keyHash, monthStart.Format("200601")),
+			quota:      quota.Monthly,
+			resetAt:    monthStart.AddDate(0, 1, 0),
+		},
+	}
+}
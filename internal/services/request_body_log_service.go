@@ -0,0 +1,81 @@
+package services
+
+import (
+	"gpt-load/internal/models"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// RequestBodyLogRetention is how long captured request and response bodies are kept.
+// They may contain prompts and other sensitive data, so the retention is short and fixed.
+const RequestBodyLogRetention = 24 * time.Hour
+
+// RequestBodyLogService stores and queries the bodies captured for groups with log_bodies enabled.
+type RequestBodyLogService struct {
+	DB *gorm.DB
+}
+
+// NewRequestBodyLogService creates a new RequestBodyLogService.
+func NewRequestBodyLogService(db *gorm.DB) *RequestBodyLogService {
+	return &RequestBodyLogService{DB: db}
+}
+
+// Record writes a captured body entry. The request has already been served,
+// so a failure is logged rather than returned.
+func (s *RequestBodyLogService) Record(entry *models.RequestBodyLog) {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	if err := s.DB.Create(entry).Error; err != nil {
+		logrus.WithFields(logrus.Fields{
+			"group":        entry.GroupName,
+			"requestLogID": entry.RequestLogID,
+			"error":        err,
+		}).Error("Failed to write request body log")
+	}
+}
+
+// DeleteExpired removes the entries older than RequestBodyLogRetention.
+func (s *RequestBodyLogService) DeleteExpired() (int64, error) {
+	result := s.DB.Where("timestamp < ?", time.Now().Add(-RequestBodyLogRetention)).Delete(&models.RequestBodyLog{})
+	return result.RowsAffected, result.Error
+}
+
+// bodyLogFiltersScope returns a GORM scope function that applies body log filters from the Gin context.
+func bodyLogFiltersScope(c *gin.Context) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if groupIDStr := c.Query("group_id"); groupIDStr != "" {
+			if groupID, err := strconv.Atoi(groupIDStr); err == nil {
+				db = db.Where("group_id = ?", groupID)
+			}
+		}
+		if requestLogID := c.Query("request_log_id"); requestLogID != "" {
+			db = db.Where("request_log_id = ?", requestLogID)
+		}
+		if statusCodeStr := c.Query("status_code"); statusCodeStr != "" {
+			if statusCode, err := strconv.Atoi(statusCodeStr); err == nil {
+				db = db.Where("status_code = ?", statusCode)
+			}
+		}
+		if startTimeStr := c.Query("start_time"); startTimeStr != "" {
+			if startTime, err := time.Parse(time.RFC3339, startTimeStr); err == nil {
+				db = db.Where("timestamp >= ?", startTime)
+			}
+		}
+		if endTimeStr := c.Query("end_time"); endTimeStr != "" {
+			if endTime, err := time.Parse(time.RFC3339, endTimeStr); err == nil {
+				db = db.Where("timestamp <= ?", endTime)
+			}
+		}
+		return db
+	}
+}
+
+// GetRequestBodyLogsQuery returns a GORM query for fetching captured bodies with filters.
+func (s *RequestBodyLogService) GetRequestBodyLogsQuery(c *gin.Context) *gorm.DB {
+	return s.DB.Model(&models.RequestBodyLog{}).Scopes(bodyLogFiltersScope(c))
+}
@@ -0,0 +1,276 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gpt-load/internal/models"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxBufferedLogLineBytes 本地缓冲文件中单条日志的最大长度，超出的行会被跳过
+const maxBufferedLogLineBytes = 16 * 1024 * 1024
+
+// localLogBuffer 本地日志缓冲文件，request_log_write_mode 为 local_buffer 时请求日志以 JSON 行追加到该文件，
+// 再由本节点分批写入数据库。写入时先将文件重命名为 .flushing，全部写入成功后删除，
+// 写入失败或进程退出时下次继续写入，已写入的日志按 ID 跳过。
+type localLogBuffer struct {
+	path string
+	mu   sync.Mutex
+	file *os.File
+	// pending 缓冲文件和待写入文件中的日志条数
+	pending atomic.Int64
+}
+
+// newLocalLogBuffer 创建本地日志缓冲，并统计上次运行遗留的日志条数
+func newLocalLogBuffer(path string) *localLogBuffer {
+	b := &localLogBuffer{path: path}
+	for _, p := range []string{path, b.flushingPath()} {
+		lines, err := countLines(p)
+		if err != nil {
+			logrus.Warnf("Failed to count buffered request logs in %s: %v", p, err)
+			continue
+		}
+		b.pending.Add(lines)
+	}
+	if pending := b.pending.Load(); pending > 0 {
+		logrus.Infof("Found %d request logs buffered in %s from the last run", pending, path)
+	}
+	return b
+}
+
+func (b *localLogBuffer) flushingPath() string {
+	return b.path + ".flushing"
+}
+
+// rejectedPath 无法解析的日志行移到该文件，便于排查，不再写入数据库
+func (b *localLogBuffer) rejectedPath() string {
+	return b.path + ".rejected"
+}
+
+// reject 将无法解析的日志行追加到 rejectedPath
+func (b *localLogBuffer) reject(line []byte) error {
+	file, err := os.OpenFile(b.rejectedPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open rejected request log file: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write rejected request log file: %w", err)
+	}
+	return nil
+}
+
+// Append 追加一条日志到缓冲文件。写入后进程崩溃不会丢失，系统崩溃时可能丢失最近一次 Sync 后的日志。
+func (b *localLogBuffer) Append(log *models.RequestLog) error {
+	line, err := json.Marshal(log)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request log: %w", err)
+	}
+	line = append(line, '\n')
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.file == nil {
+		if err := b.open(); err != nil {
+			return err
+		}
+	}
+	if _, err := b.file.Write(line); err != nil {
+		return fmt.Errorf("failed to write request log buffer: %w", err)
+	}
+	b.pending.Add(1)
+	return nil
+}
+
+// open 打开缓冲文件用于追加。上次写入中断留下的不完整行会先补上换行，避免与新日志连在一起。
+func (b *localLogBuffer) open() error {
+	if err := os.MkdirAll(filepath.Dir(b.path), 0755); err != nil {
+		return fmt.Errorf("failed to create request log buffer directory: %w", err)
+	}
+	file, err := os.OpenFile(b.path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open request log buffer: %w", err)
+	}
+	if info, err := file.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := file.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			if _, err := file.Write([]byte{'\n'}); err != nil {
+				file.Close()
+				return fmt.Errorf("failed to repair request log buffer: %w", err)
+			}
+		}
+	}
+	b.file = file
+	return nil
+}
+
+// Sync 将缓冲文件刷入磁盘
+func (b *localLogBuffer) Sync() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.file == nil {
+		return
+	}
+	if err := b.file.Sync(); err != nil {
+		logrus.Warnf("Failed to sync request log buffer: %v", err)
+	}
+}
+
+// Pending 返回等待写入数据库的日志条数
+func (b *localLogBuffer) Pending() int64 {
+	return b.pending.Load()
+}
+
+// rotate 将缓冲文件转为待写入文件，之后的日志写入新的缓冲文件。上次未写完的待写入文件会先被写入。
+func (b *localLogBuffer) rotate() (bool, error) {
+	if _, err := os.Stat(b.flushingPath()); err == nil {
+		return true, nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.file != nil {
+		if err := b.file.Sync(); err != nil {
+			logrus.Warnf("Failed to sync request log buffer: %v", err)
+		}
+		if err := b.file.Close(); err != nil {
+			logrus.Warnf("Failed to close request log buffer: %v", err)
+		}
+		b.file = nil
+	}
+	if err := os.Rename(b.path, b.flushingPath()); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to rotate request log buffer: %w", err)
+	}
+	return true, nil
+}
+
+// drain 分批读取待写入文件中的日志并交给 write，全部成功后删除该文件。
+// beforeBatch 在每批写入前调用，用于控制写入节奏。
+func (b *localLogBuffer) drain(batchSize int, beforeBatch func(), write func([]*models.RequestLog) error) (int, error) {
+	ok, err := b.rotate()
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	file, err := os.Open(b.flushingPath())
+	if err != nil {
+		return 0, fmt.Errorf("failed to open request log buffer: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReaderSize(file, 64*1024)
+	var lines int64
+	var flushed int
+	batch := make([]*models.RequestLog, 0, batchSize)
+	writeBatch := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		beforeBatch()
+		if err := write(batch); err != nil {
+			return err
+		}
+		flushed += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	for {
+		line, err := readBufferedLine(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return flushed, fmt.Errorf("failed to read request log buffer: %w", err)
+		}
+		lines++
+		if len(line) == 0 {
+			continue
+		}
+		var log models.RequestLog
+		if err := json.Unmarshal(line, &log); err != nil {
+			logrus.Warnf("Moving malformed request log in buffer to %s: %v", b.rejectedPath(), err)
+			if err := b.reject(line); err != nil {
+				logrus.Warnf("Dropping malformed request log: %v", err)
+			}
+			continue
+		}
+		batch = append(batch, &log)
+		if len(batch) >= batchSize {
+			if err := writeBatch(); err != nil {
+				return flushed, err
+			}
+		}
+	}
+	if err := writeBatch(); err != nil {
+		return flushed, err
+	}
+
+	if err := os.Remove(b.flushingPath()); err != nil {
+		return flushed, fmt.Errorf("failed to remove flushed request log buffer: %w", err)
+	}
+	b.pending.Add(-lines)
+	return flushed, nil
+}
+
+// readBufferedLine 读取一行，不含换行。过长的行会被跳过并返回空行。
+func readBufferedLine(reader *bufio.Reader) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, isPrefix, err := reader.ReadLine()
+		if err != nil {
+			if err == io.EOF && len(line) > 0 {
+				return line, nil
+			}
+			return nil, err
+		}
+		if len(line)+len(chunk) > maxBufferedLogLineBytes {
+			logrus.Warnf("Skipping request log over %d bytes in buffer", maxBufferedLogLineBytes)
+			for isPrefix {
+				if _, isPrefix, err = reader.ReadLine(); err != nil {
+					return nil, err
+				}
+			}
+			return []byte{}, nil
+		}
+		line = append(line, chunk...)
+		if !isPrefix {
+			return line, nil
+		}
+	}
+}
+
+// countLines 统计文件中的行数，文件不存在时返回 0
+func countLines(path string) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReaderSize(file, 64*1024)
+	var lines int64
+	for {
+		if _, err := readBufferedLine(reader); err != nil {
+			if err == io.EOF {
+				return lines, nil
+			}
+			return lines, err
+		}
+		lines++
+	}
+}
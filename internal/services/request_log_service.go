@@ -3,10 +3,13 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"gpt-load/internal/config"
 	"gpt-load/internal/models"
 	"gpt-load/internal/store"
+	"gpt-load/internal/types"
+	"gpt-load/internal/utils"
 	"strings"
 	"sync"
 	"time"
@@ -21,24 +24,51 @@ const (
 	RequestLogCachePrefix    = "request_log:"
 	PendingLogKeysSet        = "pending_log_keys"
 	DefaultLogFlushBatchSize = 200
+	// LogWriteModeLocalBuffer buffers request logs in a local file instead of the store before they are written.
+	LogWriteModeLocalBuffer = "local_buffer"
+	// earlyFlushCheckInterval is how often the master checks the backlog against request_log_flush_trigger_size.
+	earlyFlushCheckInterval = 10 * time.Second
 )
 
+// LogFlushStats reports the write-behind of request logs. The backlog in the store is shared by the
+// cluster and flushed by the master, while logs in the local buffer are flushed by the node that
+// recorded them. The last flush is the one run by this node.
+type LogFlushStats struct {
+	// PendingLogs is the number of request logs waiting in the store to be written.
+	PendingLogs         int64      `json:"pending_logs"`
+	FlushInProgress     bool       `json:"flush_in_progress"`
+	LastFlushAt         *time.Time `json:"last_flush_at,omitempty"`
+	LastFlushDurationMs int64      `json:"last_flush_duration_ms"`
+	LastFlushedLogs     int        `json:"last_flushed_logs"`
+	LastFlushBatches    int        `json:"last_flush_batches"`
+	LastFlushError      string     `json:"last_flush_error,omitempty"`
+}
+
 // RequestLogService is responsible for managing request logs.
 type RequestLogService struct {
 	db              *gorm.DB
 	store           store.Store
 	settingsManager *config.SystemSettingsManager
+	isMaster        bool
+	localBuffer     *localLogBuffer
 	stopChan        chan struct{}
 	wg              sync.WaitGroup
-	ticker          *time.Ticker
+	// flushMu keeps flushes from overlapping, such as the final flush on shutdown and a scheduled one.
+	flushMu    sync.Mutex
+	statsMu    sync.RWMutex
+	flushStats LogFlushStats
+	// lastCompleteFlush is when a flush last emptied the backlog without errors.
+	lastCompleteFlush time.Time
 }
 
 // NewRequestLogService creates a new RequestLogService instance
-func NewRequestLogService(db *gorm.DB, store store.Store, sm *config.SystemSettingsManager) *RequestLogService {
+func NewRequestLogService(db *gorm.DB, store store.Store, sm *config.SystemSettingsManager, configManager types.ConfigManager) *RequestLogService {
 	return &RequestLogService{
 		db:              db,
 		store:           store,
 		settingsManager: sm,
+		isMaster:        configManager.IsMaster(),
+		localBuffer:     newLocalLogBuffer(configManager.GetLogConfig().RequestLogBufferPath),
 		stopChan:        make(chan struct{}),
 	}
 }
@@ -53,28 +83,35 @@ func (s *RequestLogService) runLoop() {
 	defer s.wg.Done()
 
 	// Initial flush on start
+	s.statsMu.Lock()
+	s.lastCompleteFlush = time.Now()
+	s.statsMu.Unlock()
 	s.flush()
+	lastFlush := time.Now()
 
-	interval := time.Duration(s.settingsManager.GetSettings().RequestLogWriteIntervalMinutes) * time.Minute
-	if interval <= 0 {
-		interval = time.Minute
-	}
-	s.ticker = time.NewTicker(interval)
-	defer s.ticker.Stop()
+	// The interval is checked every minute, so a changed setting applies without waiting for the old interval.
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	earlyFlushTicker := time.NewTicker(earlyFlushCheckInterval)
+	defer earlyFlushTicker.Stop()
 
 	for {
 		select {
-		case <-s.ticker.C:
-			newInterval := time.Duration(s.settingsManager.GetSettings().RequestLogWriteIntervalMinutes) * time.Minute
-			if newInterval <= 0 {
-				newInterval = time.Minute
+		case <-ticker.C:
+			s.checkBacklog()
+			interval := time.Duration(s.settingsManager.GetSettings().RequestLogWriteIntervalMinutes) * time.Minute
+			if time.Since(lastFlush) < interval && !s.flushOverdue() {
+				continue
 			}
-			if newInterval != interval {
-				s.ticker.Reset(newInterval)
-				interval = newInterval
-				logrus.Debugf("Request log write interval updated to: %v", interval)
+			s.flush()
+			lastFlush = time.Now()
+		case <-earlyFlushTicker.C:
+			s.localBuffer.Sync()
+			if !s.backlogTriggersFlush() {
+				continue
 			}
 			s.flush()
+			lastFlush = time.Now()
 		case <-s.stopChan:
 			return
 		}
@@ -104,8 +141,18 @@ func (s *RequestLogService) Stop(ctx context.Context) {
 func (s *RequestLogService) Record(log *models.RequestLog) error {
 	log.ID = uuid.NewString()
 	log.Timestamp = time.Now()
+	log.ErrorMessage = utils.RedactText(log.ErrorMessage, s.settingsManager.GetSettings().RedactionPatterns)
 
-	if s.settingsManager.GetSettings().RequestLogWriteIntervalMinutes == 0 {
+	settings := s.settingsManager.GetSettings()
+	if settings.RequestLogWriteIntervalMinutes == 0 {
+		return s.writeLogsToDB([]*models.RequestLog{log})
+	}
+	if settings.RequestLogWriteMode == LogWriteModeLocalBuffer {
+		return s.localBuffer.Append(log)
+	}
+	// While Redis is down the store is this node's in-memory fallback, which is dropped once Redis
+	// recovers and never flushed on slaves, so logs are written to the database right away.
+	if s.storeDegraded() {
 		return s.writeLogsToDB([]*models.RequestLog{log})
 	}
 
@@ -116,38 +163,215 @@ func (s *RequestLogService) Record(log *models.RequestLog) error {
 		return fmt.Errorf("failed to marshal request log: %w", err)
 	}
 
-	ttl := time.Duration(s.settingsManager.GetSettings().RequestLogWriteIntervalMinutes*5) * time.Minute
-	if err := s.store.Set(cacheKey, logBytes, ttl); err != nil {
+	if err := s.store.Set(cacheKey, logBytes, s.cacheTTL()); err != nil {
 		return err
 	}
 
 	return s.store.SAdd(PendingLogKeysSet, cacheKey)
 }
 
-// flush data from cache to database
+// storeDegraded reports whether the store is serving from its fallback instead of Redis.
+func (s *RequestLogService) storeDegraded() bool {
+	degradable, ok := s.store.(store.Degradable)
+	return ok && degradable.IsDegraded()
+}
+
+// cacheTTL is how long a log waits in the store to be flushed before it expires. It leaves room for
+// slow or failed flushes.
+func (s *RequestLogService) cacheTTL() time.Duration {
+	settings := s.settingsManager.GetSettings()
+	minutes := max(settings.RequestLogCacheTTLMinutes, settings.RequestLogWriteIntervalMinutes*settings.RequestLogCacheTTLMultiplier)
+	return time.Duration(minutes) * time.Minute
+}
+
+// flushOverdue reports whether the backlog has not been fully flushed for half the cache TTL, such as
+// after failed flushes. Such a flush is retried without waiting for the write interval, before the
+// oldest logs expire.
+func (s *RequestLogService) flushOverdue() bool {
+	s.statsMu.RLock()
+	lastComplete := s.lastCompleteFlush
+	s.statsMu.RUnlock()
+	if time.Since(lastComplete) < s.cacheTTL()/2 {
+		return false
+	}
+	if pending, err := s.flushablePending(); err != nil || pending == 0 {
+		return false
+	}
+	logrus.Warnf("Request logs have not been fully flushed since %s, flushing now before they expire", lastComplete.Format(time.RFC3339))
+	return true
+}
+
+// backlogTriggersFlush reports whether the backlog reached request_log_flush_trigger_size, so it is
+// flushed right away instead of waiting for the write interval.
+func (s *RequestLogService) backlogTriggersFlush() bool {
+	settings := s.settingsManager.GetSettings()
+	if settings.RequestLogFlushTriggerSize <= 0 || settings.RequestLogWriteIntervalMinutes == 0 {
+		return false
+	}
+	pending, err := s.flushablePending()
+	if err != nil {
+		logrus.Warnf("Failed to check request log backlog for an early flush: %v", err)
+		return false
+	}
+	if pending < int64(settings.RequestLogFlushTriggerSize) {
+		return false
+	}
+	logrus.Infof("Request log backlog reached %d, flushing before the write interval", pending)
+	return true
+}
+
+// PendingLogCount returns the number of request logs waiting in the store and in this node's local
+// buffer to be written.
+func (s *RequestLogService) PendingLogCount() (int64, error) {
+	pending, err := s.store.SCard(PendingLogKeysSet)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count pending request logs: %w", err)
+	}
+	return pending + s.localBuffer.Pending(), nil
+}
+
+// flushablePending returns the number of request logs this node flushes. Slaves only flush their
+// local buffer, since the master flushes the store.
+func (s *RequestLogService) flushablePending() (int64, error) {
+	if !s.isMaster {
+		return s.localBuffer.Pending(), nil
+	}
+	return s.PendingLogCount()
+}
+
+// checkBacklog warns when the pending request logs exceed request_log_backlog_warn_threshold, as logs
+// in the store are lost once their cache TTL expires. Slaves only check their local buffer, since the
+// master reports the store.
+func (s *RequestLogService) checkBacklog() {
+	threshold := s.settingsManager.GetSettings().RequestLogBacklogWarnThreshold
+	if threshold <= 0 {
+		return
+	}
+	pending, err := s.flushablePending()
+	if err != nil {
+		logrus.Warnf("Failed to check request log backlog: %v", err)
+		return
+	}
+	if pending > int64(threshold) {
+		logrus.Warnf("Request log backlog is %d, above the threshold of %d: logs may expire before they are written", pending, threshold)
+	}
+}
+
+// FlushStats returns the request log backlog and the result of the last flush on this node.
+func (s *RequestLogService) FlushStats() (LogFlushStats, error) {
+	s.statsMu.RLock()
+	stats := s.flushStats
+	s.statsMu.RUnlock()
+
+	if !s.flushMu.TryLock() {
+		stats.FlushInProgress = true
+	} else {
+		s.flushMu.Unlock()
+	}
+
+	pending, err := s.PendingLogCount()
+	if err != nil {
+		return stats, err
+	}
+	stats.PendingLogs = pending
+	return stats, nil
+}
+
+// flush data from the local buffer and the cache to database
 func (s *RequestLogService) flush() {
-	if s.settingsManager.GetSettings().RequestLogWriteIntervalMinutes == 0 {
-		logrus.Debug("Sync mode enabled, skipping scheduled log flush.")
+	settings := s.settingsManager.GetSettings()
+	// Logs left in the local buffer are written even after switching to another mode.
+	hasBuffered := s.localBuffer.Pending() > 0
+	flushStore := s.isMaster && settings.RequestLogWriteIntervalMinutes > 0
+	if !hasBuffered && !flushStore {
+		if settings.RequestLogWriteIntervalMinutes == 0 {
+			logrus.Debug("Sync mode enabled, skipping scheduled log flush.")
+		}
+		// Nothing is left for this node to flush, so its backlog counts as flushed.
+		s.statsMu.Lock()
+		s.lastCompleteFlush = time.Now()
+		s.statsMu.Unlock()
 		return
 	}
 
-	logrus.Debug("Master starting to flush request logs...")
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	logrus.Debug("Starting to flush request logs...")
+
+	start := time.Now()
+	var flushed, batches int
+	var flushErr error
+	defer func() {
+		duration := time.Since(start)
+		s.statsMu.Lock()
+		s.flushStats = LogFlushStats{
+			LastFlushAt:         &start,
+			LastFlushDurationMs: duration.Milliseconds(),
+			LastFlushedLogs:     flushed,
+			LastFlushBatches:    batches,
+		}
+		if flushErr != nil {
+			s.flushStats.LastFlushError = flushErr.Error()
+		} else {
+			s.lastCompleteFlush = start
+		}
+		s.statsMu.Unlock()
+		if flushed > 0 {
+			logrus.Debugf("Flushed %d request logs in %d batches in %s", flushed, batches, duration)
+		}
+	}()
+
+	batchSize := settings.RequestLogFlushBatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultLogFlushBatchSize
+	}
+	batchDelay := time.Duration(settings.RequestLogFlushBatchDelayMs) * time.Millisecond
+	pace := func() {
+		if batches > 0 && batchDelay > 0 {
+			// Pacing is skipped once the service stops, so the final flush is not held up.
+			select {
+			case <-time.After(batchDelay):
+			case <-s.stopChan:
+			}
+		}
+	}
+
+	if hasBuffered {
+		n, err := s.localBuffer.drain(batchSize, func() { pace(); batches++ }, s.writeBufferedLogs)
+		flushed += n
+		if err != nil {
+			// The store is still flushed, so a buffer that keeps failing does not hold up its backlog.
+			flushErr = err
+			logrus.Errorf("Failed to flush buffered request logs, will retry next time. Error: %v", err)
+		} else if n > 0 {
+			logrus.Infof("Successfully flushed %d buffered request logs.", n)
+		}
+	}
+	if !flushStore {
+		return
+	}
 
 	for {
-		keys, err := s.store.SPopN(PendingLogKeysSet, DefaultLogFlushBatchSize)
+		pace()
+
+		keys, err := s.store.SPopN(PendingLogKeysSet, int64(batchSize))
 		if err != nil {
 			logrus.Errorf("Failed to pop pending log keys from store: %v", err)
+			flushErr = errors.Join(flushErr, err)
 			return
 		}
 
 		if len(keys) == 0 {
 			return
 		}
+		batches++
 
 		logrus.Debugf("Popped %d request logs to flush.", len(keys))
 
 		var logs []*models.RequestLog
 		var processedKeys []string
+		var processedBodies [][]byte
 		for _, key := range keys {
 			logBytes, err := s.store.Get(key)
 			if err != nil {
@@ -165,6 +389,7 @@ func (s *RequestLogService) flush() {
 			}
 			logs = append(logs, &log)
 			processedKeys = append(processedKeys, key)
+			processedBodies = append(processedBodies, logBytes)
 		}
 
 		if len(logs) == 0 {
@@ -174,7 +399,15 @@ func (s *RequestLogService) flush() {
 		err = s.writeLogsToDB(logs)
 
 		if err != nil {
+			flushErr = errors.Join(flushErr, err)
 			logrus.Errorf("Failed to flush request logs batch, will retry next time. Error: %v", err)
+			// The TTL of the kept logs is renewed, so they do not expire while the database stays unavailable.
+			ttl := s.cacheTTL()
+			for i, key := range processedKeys {
+				if setErr := s.store.Set(key, processedBodies[i], ttl); setErr != nil {
+					logrus.Warnf("Failed to renew TTL of log key %s: %v", key, setErr)
+				}
+			}
 			if len(keys) > 0 {
 				keysToRetry := make([]any, len(keys))
 				for i, k := range keys {
@@ -192,42 +425,86 @@ func (s *RequestLogService) flush() {
 				logrus.Errorf("Failed to delete flushed log bodies from store: %v", err)
 			}
 		}
+		flushed += len(logs)
 		logrus.Infof("Successfully flushed %d request logs.", len(logs))
 	}
 }
 
+// writeBufferedLogs writes a batch of logs from the local buffer. Logs already written by an
+// interrupted flush are skipped, so retrying a buffer file does not count them twice.
+func (s *RequestLogService) writeBufferedLogs(logs []*models.RequestLog) error {
+	ids := make([]string, len(logs))
+	for i, log := range logs {
+		ids[i] = log.ID
+	}
+	var written []string
+	if err := s.db.Model(&models.RequestLog{}).Where("id IN ?", ids).Pluck("id", &written).Error; err != nil {
+		return fmt.Errorf("failed to check written request logs: %w", err)
+	}
+	if len(written) > 0 {
+		skip := make(map[string]bool, len(written))
+		for _, id := range written {
+			skip[id] = true
+		}
+		remaining := logs[:0:0]
+		for _, log := range logs {
+			if !skip[log.ID] {
+				remaining = append(remaining, log)
+			}
+		}
+		logs = remaining
+	}
+	return s.writeLogsToDB(logs)
+}
+
+// redactLogKey replaces a plaintext key with its masked value and hash. Entries buffered by an older
+// version still carry the plaintext key, and it must not reach the database.
+func redactLogKey(log *models.RequestLog) {
+	if log.KeyHash != "" || log.KeyValue == "" {
+		return
+	}
+	log.KeyHash = utils.HashKey(log.KeyValue)
+	log.KeyValue = utils.MaskAPIKey(log.KeyValue)
+}
+
 // writeLogsToDB writes a batch of request logs to the database
 func (s *RequestLogService) writeLogsToDB(logs []*models.RequestLog) error {
 	if len(logs) == 0 {
 		return nil
 	}
 
+	for _, log := range logs {
+		redactLogKey(log)
+	}
+
 	return s.db.Transaction(func(tx *gorm.DB) error {
 		if err := tx.CreateInBatches(logs, len(logs)).Error; err != nil {
 			return fmt.Errorf("failed to batch insert request logs: %w", err)
 		}
 
-		keyStats := make(map[string]int64)
+		keyStats := make(map[uint]int64)
 		for _, log := range logs {
-			if log.IsSuccess && log.KeyValue != "" {
-				keyStats[log.KeyValue]++
+			if log.IsSuccess && log.APIKeyID != 0 {
+				keyStats[log.APIKeyID]++
 			}
 		}
 
 		if len(keyStats) > 0 {
 			var caseStmt strings.Builder
-			var keyValues []string
-			caseStmt.WriteString("CASE key_value ")
-			for keyValue, count := range keyStats {
-				caseStmt.WriteString(fmt.Sprintf("WHEN '%s' THEN request_count + %d ", keyValue, count))
-				keyValues = append(keyValues, keyValue)
+			var keyIDs []uint
+			caseStmt.WriteString("CASE id ")
+			for keyID, count := range keyStats {
+				caseStmt.WriteString(fmt.Sprintf("WHEN %d THEN request_count + %d ", keyID, count))
+				keyIDs = append(keyIDs, keyID)
 			}
 			caseStmt.WriteString("END")
 
-			if err := tx.Model(&models.APIKey{}).Where("key_value IN ?", keyValues).
+			now := time.Now()
+			if err := tx.Model(&models.APIKey{}).Where("id IN ?", keyIDs).
 				Updates(map[string]any{
-					"request_count": gorm.Expr(caseStmt.String()),
-					"last_used_at":  time.Now(),
+					"request_count":   gorm.Expr(caseStmt.String()),
+					"last_used_at":    now,
+					"last_success_at": now,
 				}).Error; err != nil {
 				return fmt.Errorf("failed to batch update api_key stats: %w", err)
 			}
@@ -237,7 +514,7 @@ func (s *RequestLogService) writeLogsToDB(logs []*models.RequestLog) error {
 		hourlyStats := make(map[struct {
 			Time    time.Time
 			GroupID uint
-		}]struct{ Success, Failure int64 })
+		}]struct{ Success, Failure, Cancelled int64 })
 		for _, log := range logs {
 			hourlyTime := log.Timestamp.Truncate(time.Hour)
 			key := struct {
@@ -246,9 +523,12 @@ func (s *RequestLogService) writeLogsToDB(logs []*models.RequestLog) error {
 			}{Time: hourlyTime, GroupID: log.GroupID}
 
 			counts := hourlyStats[key]
-			if log.IsSuccess {
+			switch {
+			case log.IsClientCancelled:
+				counts.Cancelled++
+			case log.IsSuccess:
 				counts.Success++
-			} else {
+			default:
 				counts.Failure++
 			}
 			hourlyStats[key] = counts
@@ -259,15 +539,17 @@ func (s *RequestLogService) writeLogsToDB(logs []*models.RequestLog) error {
 				err := tx.Clauses(clause.OnConflict{
 					Columns: []clause.Column{{Name: "time"}, {Name: "group_id"}},
 					DoUpdates: clause.Assignments(map[string]any{
-						"success_count": gorm.Expr("group_hourly_stats.success_count + ?", counts.Success),
-						"failure_count": gorm.Expr("group_hourly_stats.failure_count + ?", counts.Failure),
-						"updated_at":    time.Now(),
+						"success_count":   gorm.Expr("group_hourly_stats.success_count + ?", counts.Success),
+						"failure_count":   gorm.Expr("group_hourly_stats.failure_count + ?", counts.Failure),
+						"cancelled_count": gorm.Expr("group_hourly_stats.cancelled_count + ?", counts.Cancelled),
+						"updated_at":      time.Now(),
 					}),
 				}).Create(&models.GroupHourlyStat{
-					Time:         key.Time,
-					GroupID:      key.GroupID,
-					SuccessCount: counts.Success,
-					FailureCount: counts.Failure,
+					Time:           key.Time,
+					GroupID:        key.GroupID,
+					SuccessCount:   counts.Success,
+					FailureCount:   counts.Failure,
+					CancelledCount: counts.Cancelled,
 				}).Error
 
 				if err != nil {
@@ -276,6 +558,109 @@ func (s *RequestLogService) writeLogsToDB(logs []*models.RequestLog) error {
 			}
 		}
 
+		// 更新用量统计表，按分组、代理密钥和模型聚合
+		usageStats := make(map[struct {
+			Time         time.Time
+			GroupID      uint
+			ProxyKeyHash string
+			Model        string
+		}]struct{ Success, Failure, PromptTokens, CompletionTokens int64 })
+		for _, log := range logs {
+			// Cancelled requests are only counted in group_hourly_stats, and shadow requests are not the client's usage.
+			if log.IsClientCancelled || log.IsShadow {
+				continue
+			}
+			key := struct {
+				Time         time.Time
+				GroupID      uint
+				ProxyKeyHash string
+				Model        string
+			}{Time: log.Timestamp.Truncate(time.Hour), GroupID: log.GroupID, ProxyKeyHash: log.ProxyKeyHash, Model: log.Model}
+
+			counts := usageStats[key]
+			if log.IsSuccess {
+				counts.Success++
+			} else {
+				counts.Failure++
+			}
+			counts.PromptTokens += int64(log.PromptTokens)
+			counts.CompletionTokens += int64(log.CompletionTokens)
+			usageStats[key] = counts
+		}
+
+		for key, counts := range usageStats {
+			err := tx.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "time"}, {Name: "group_id"}, {Name: "proxy_key_hash"}, {Name: "model"}},
+				DoUpdates: clause.Assignments(map[string]any{
+					"success_count":     gorm.Expr("usage_hourly_stats.success_count + ?", counts.Success),
+					"failure_count":     gorm.Expr("usage_hourly_stats.failure_count + ?", counts.Failure),
+					"prompt_tokens":     gorm.Expr("usage_hourly_stats.prompt_tokens + ?", counts.PromptTokens),
+					"completion_tokens": gorm.Expr("usage_hourly_stats.completion_tokens + ?", counts.CompletionTokens),
+					"updated_at":        time.Now(),
+				}),
+			}).Create(&models.UsageHourlyStat{
+				Time:             key.Time,
+				GroupID:          key.GroupID,
+				ProxyKeyHash:     key.ProxyKeyHash,
+				Model:            key.Model,
+				SuccessCount:     counts.Success,
+				FailureCount:     counts.Failure,
+				PromptTokens:     counts.PromptTokens,
+				CompletionTokens: counts.CompletionTokens,
+			}).Error
+
+			if err != nil {
+				return fmt.Errorf("failed to upsert usage hourly stat: %w", err)
+			}
+		}
+
+		// 更新密钥每日统计表
+		dailyStats := make(map[struct {
+			Day     time.Time
+			GroupID uint
+			KeyHash string
+		}]struct{ Success, Failure int64 })
+		loc := s.settingsManager.GetDisplayLocation()
+		for _, log := range logs {
+			if log.KeyHash == "" || log.IsClientCancelled {
+				continue
+			}
+			key := struct {
+				Day     time.Time
+				GroupID uint
+				KeyHash string
+			}{Day: utils.StartOfDay(log.Timestamp.In(loc)).UTC(), GroupID: log.GroupID, KeyHash: log.KeyHash}
+
+			counts := dailyStats[key]
+			if log.IsSuccess {
+				counts.Success++
+			} else {
+				counts.Failure++
+			}
+			dailyStats[key] = counts
+		}
+
+		for key, counts := range dailyStats {
+			err := tx.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "day"}, {Name: "group_id"}, {Name: "key_hash"}},
+				DoUpdates: clause.Assignments(map[string]any{
+					"success_count": gorm.Expr("key_daily_stats.success_count + ?", counts.Success),
+					"failure_count": gorm.Expr("key_daily_stats.failure_count + ?", counts.Failure),
+					"updated_at":    time.Now(),
+				}),
+			}).Create(&models.KeyDailyStat{
+				Day:          key.Day,
+				GroupID:      key.GroupID,
+				KeyHash:      key.KeyHash,
+				SuccessCount: counts.Success,
+				FailureCount: counts.Failure,
+			}).Error
+
+			if err != nil {
+				return fmt.Errorf("failed to upsert key daily stat: %w", err)
+			}
+		}
+
 		return nil
 	})
 }
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"gpt-load/internal/db"
+	"gpt-load/internal/models"
+	"gpt-load/internal/store"
+)
+
+// newTestRequestLogService returns a request log service over a memory store and a database without
+// the request_logs table, so writing logs fails.
+func newTestRequestLogService(t *testing.T, isMaster bool, settings map[string]string) (*RequestLogService, store.Store) {
+	t.Helper()
+	settingsManager := newTestSettingsManager(t, settings)
+	memStore := store.NewMemoryStore()
+	return &RequestLogService{
+		db:              db.DB,
+		store:           memStore,
+		settingsManager: settingsManager,
+		isMaster:        isMaster,
+		localBuffer:     newLocalLogBuffer(""),
+		stopChan:        make(chan struct{}),
+	}, memStore
+}
+
+func TestFlushRenewsTTLOfPendingLogsOnFailure(t *testing.T) {
+	s, memStore := newTestRequestLogService(t, true, nil)
+
+	logBytes, err := json.Marshal(&models.RequestLog{ID: "log-1", Timestamp: time.Now()})
+	if err != nil {
+		t.Fatalf("failed to marshal log: %v", err)
+	}
+	cacheKey := RequestLogCachePrefix + "log-1"
+	if err := memStore.Set(cacheKey, logBytes, 100*time.Millisecond); err != nil {
+		t.Fatalf("failed to cache log: %v", err)
+	}
+	if err := memStore.SAdd(PendingLogKeysSet, cacheKey); err != nil {
+		t.Fatalf("failed to add pending key: %v", err)
+	}
+
+	s.flush()
+
+	if stats, _ := s.FlushStats(); stats.LastFlushError == "" {
+		t.Fatal("flush succeeded, want a failed write")
+	}
+	if pending, err := memStore.SCard(PendingLogKeysSet); err != nil || pending != 1 {
+		t.Errorf("pending keys = %d, %v, want 1", pending, err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if _, err := memStore.Get(cacheKey); err != nil {
+		t.Errorf("log expired after a failed flush: %v", err)
+	}
+}
+
+func TestSlaveIgnoresStoreBacklog(t *testing.T) {
+	s, memStore := newTestRequestLogService(t, false, map[string]string{
+		"request_log_flush_trigger_size": "10",
+	})
+	for i := range 20 {
+		if err := memStore.SAdd(PendingLogKeysSet, fmt.Sprintf("%slog-%d", RequestLogCachePrefix, i)); err != nil {
+			t.Fatalf("failed to add pending key: %v", err)
+		}
+	}
+
+	// The store is flushed by the master, so a slave has nothing to flush and its backlog is complete.
+	s.flush()
+	s.statsMu.Lock()
+	flushedAt := s.lastCompleteFlush
+	s.lastCompleteFlush = time.Now().Add(-24 * time.Hour)
+	s.statsMu.Unlock()
+	if flushedAt.IsZero() {
+		t.Error("lastCompleteFlush not set by a slave flush")
+	}
+
+	if s.flushOverdue() {
+		t.Error("flushOverdue() = true on a slave with only a store backlog")
+	}
+	if s.backlogTriggersFlush() {
+		t.Error("backlogTriggersFlush() = true on a slave with only a store backlog")
+	}
+}
+
+// migrateRequestLogTables creates the tables written along with request logs in db.DB.
+func migrateRequestLogTables(t *testing.T) {
+	t.Helper()
+	if err := db.DB.AutoMigrate(&models.RequestLog{}, &models.APIKey{}, &models.GroupHourlyStat{}, &models.UsageHourlyStat{}, &models.KeyDailyStat{}); err != nil {
+		t.Fatalf("failed to migrate request log tables: %v", err)
+	}
+}
+
+// degradableStore is a memory store that reports itself as degraded, standing in for a FailoverStore
+// serving from its fallback.
+type degradableStore struct {
+	*store.MemoryStore
+	degraded bool
+}
+
+func (s *degradableStore) IsDegraded() bool                    { return s.degraded }
+func (s *degradableStore) OnBackendChange(func(degraded bool)) {}
+
+func TestRecordWritesLogsToDatabaseWhileStoreIsDegraded(t *testing.T) {
+	s, _ := newTestRequestLogService(t, false, nil)
+	migrateRequestLogTables(t)
+	fallback := &degradableStore{MemoryStore: store.NewMemoryStore(), degraded: true}
+	s.store = fallback
+
+	for range 2 {
+		if err := s.Record(&models.RequestLog{GroupID: 1, IsSuccess: true}); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+	if pending, _ := fallback.SCard(PendingLogKeysSet); pending != 0 {
+		t.Errorf("pending logs in the fallback = %d, want 0", pending)
+	}
+
+	// On recovery the fallback and anything left in it are dropped.
+	s.store = &degradableStore{MemoryStore: store.NewMemoryStore()}
+	s.flush()
+
+	var count int64
+	if err := db.DB.Model(&models.RequestLog{}).Count(&count).Error; err != nil {
+		t.Fatalf("failed to count request logs: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("persisted request logs = %d, want 2", count)
+	}
+}
+
+// cacheTestLogs adds request logs with the given IDs to the store's backlog.
+func cacheTestLogs(t *testing.T, memStore store.Store, ids ...string) {
+	t.Helper()
+	for _, id := range ids {
+		logBytes, err := json.Marshal(&models.RequestLog{ID: id, Timestamp: time.Now(), GroupID: 1})
+		if err != nil {
+			t.Fatalf("failed to marshal log: %v", err)
+		}
+		if err := memStore.Set(RequestLogCachePrefix+id, logBytes, time.Hour); err != nil {
+			t.Fatalf("failed to cache log: %v", err)
+		}
+		if err := memStore.SAdd(PendingLogKeysSet, RequestLogCachePrefix+id); err != nil {
+			t.Fatalf("failed to add pending key: %v", err)
+		}
+	}
+}
+
+// requestLogIDs returns the IDs of the request logs in db.DB.
+func requestLogIDs(t *testing.T) []string {
+	t.Helper()
+	var ids []string
+	if err := db.DB.Model(&models.RequestLog{}).Order("id").Pluck("id", &ids).Error; err != nil {
+		t.Fatalf("failed to list request logs: %v", err)
+	}
+	return ids
+}
+
+func TestFlushWritesStoreLogsDespiteCorruptBuffer(t *testing.T) {
+	s, memStore := newTestRequestLogService(t, true, nil)
+	migrateRequestLogTables(t)
+
+	// A buffer file left to flush that cannot be read fails every drain.
+	bufferPath := filepath.Join(t.TempDir(), "request_logs.buffer")
+	if err := os.Mkdir(bufferPath+".flushing", 0700); err != nil {
+		t.Fatalf("failed to create corrupt buffer: %v", err)
+	}
+	s.localBuffer = newLocalLogBuffer(bufferPath)
+	s.localBuffer.pending.Add(1)
+	cacheTestLogs(t, memStore, "stored-1", "stored-2")
+
+	s.flush()
+
+	if stats, _ := s.FlushStats(); stats.LastFlushError == "" {
+		t.Error("flush reported no error for the corrupt buffer")
+	}
+	if ids := requestLogIDs(t); !slices.Equal(ids, []string{"stored-1", "stored-2"}) {
+		t.Errorf("written request logs = %q, want the stored logs", ids)
+	}
+}
+
+func TestDrainMovesMalformedBufferLinesAside(t *testing.T) {
+	s, _ := newTestRequestLogService(t, true, nil)
+	migrateRequestLogTables(t)
+
+	bufferPath := filepath.Join(t.TempDir(), "request_logs.buffer")
+	s.localBuffer = newLocalLogBuffer(bufferPath)
+	if err := s.localBuffer.Append(&models.RequestLog{ID: "buffered-1", Timestamp: time.Now(), GroupID: 1}); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	s.localBuffer.mu.Lock()
+	s.localBuffer.file.Write([]byte("{not json\n"))
+	s.localBuffer.mu.Unlock()
+	s.localBuffer.pending.Add(1)
+
+	s.flush()
+
+	if ids := requestLogIDs(t); !slices.Equal(ids, []string{"buffered-1"}) {
+		t.Errorf("written request logs = %q, want the valid buffered log", ids)
+	}
+	rejected, err := os.ReadFile(bufferPath + ".rejected")
+	if err != nil || string(rejected) != "{not json\n" {
+		t.Errorf("rejected lines = %q, %v, want the malformed line", rejected, err)
+	}
+	if pending := s.localBuffer.Pending(); pending != 0 {
+		t.Errorf("pending buffered logs = %d, want 0", pending)
+	}
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"gpt-load/internal/models"
+	"gpt-load/internal/store"
+)
+
+// defaultSessionRetryWindow is how long a session's retry count is kept after its last retry
+// when session_retry_window_seconds is not set.
+const defaultSessionRetryWindow = 5 * time.Minute
+
+// SessionRetryBudgetService limits the retries a client session may spend on a group.
+// Counters live in the shared store, so the budget is global across cluster nodes.
+type SessionRetryBudgetService struct {
+	store store.Store
+}
+
+// NewSessionRetryBudgetService creates a new SessionRetryBudgetService.
+func NewSessionRetryBudgetService(store store.Store) *SessionRetryBudgetService {
+	return &SessionRetryBudgetService{store: store}
+}
+
+// Spend takes one retry from the session's budget on the group and reports whether the retry may
+// be made. Requests without a session ID and groups without a budget are always allowed.
+func (s *SessionRetryBudgetService) Spend(group *models.Group, sessionID string) (bool, error) {
+	budget, window := sessionRetryBudget(group)
+	if budget <= 0 || sessionID == "" {
+		return true, nil
+	}
+
+	key := sessionRetryKey(group.ID, sessionID)
+	// An exhausted budget is checked without writing, so a session that keeps failing does not
+	// extend its own window.
+	value, err := s.store.Get(key)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return true, fmt.Errorf("failed to get session retry counter: %w", err)
+	}
+	if used, _ := strconv.ParseInt(string(value), 10, 64); used >= int64(budget) {
+		return false, nil
+	}
+
+	used, err := s.store.IncrBy(key, 1, window)
+	if err != nil {
+		return true, fmt.Errorf("failed to increment session retry counter: %w", err)
+	}
+	return used <= int64(budget), nil
+}
+
+// sessionRetryKey returns the store key of a session's retry counter. The session ID is hashed,
+// so clients cannot inflate the key size.
+func sessionRetryKey(groupID uint, sessionID string) string {
+	sum := sha256.Sum256([]byte(sessionID))
+	return fmt.Sprintf("group:%d:session_retries:%s", groupID, hex.EncodeToString(sum[:16]))
+}
+
+func sessionRetryBudget(group *models.Group) (int, time.Duration) {
+	groupOptions := group.Options
+	window := defaultSessionRetryWindow
+	if groupOptions.SessionRetryWindowSeconds > 0 {
+		window = time.Duration(groupOptions.SessionRetryWindowSeconds) * time.Second
+	}
+	return groupOptions.SessionRetryBudget, window
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"gpt-load/internal/models"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// ShadowComparisonService stores and queries the divergences found between primary and shadow
+// responses of groups with shadow_compare enabled.
+type ShadowComparisonService struct {
+	DB *gorm.DB
+}
+
+// NewShadowComparisonService creates a new ShadowComparisonService.
+func NewShadowComparisonService(db *gorm.DB) *ShadowComparisonService {
+	return &ShadowComparisonService{DB: db}
+}
+
+// Record writes a comparison result. Both requests have already been served,
+// so a failure is logged rather than returned.
+func (s *ShadowComparisonService) Record(entry *models.ShadowComparison) {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	if err := s.DB.Create(entry).Error; err != nil {
+		logrus.WithFields(logrus.Fields{
+			"group":       entry.GroupName,
+			"shadowGroup": entry.ShadowGroupName,
+			"error":       err,
+		}).Error("Failed to write shadow comparison")
+	}
+}
+
+// DeleteBefore removes the comparison results older than cutoff.
+func (s *ShadowComparisonService) DeleteBefore(cutoff time.Time) (int64, error) {
+	result := s.DB.Where("timestamp < ?", cutoff).Delete(&models.ShadowComparison{})
+	return result.RowsAffected, result.Error
+}
+
+// shadowComparisonFiltersScope returns a GORM scope function that applies comparison filters from the Gin context.
+func shadowComparisonFiltersScope(c *gin.Context) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if groupIDStr := c.Query("group_id"); groupIDStr != "" {
+			if groupID, err := strconv.Atoi(groupIDStr); err == nil {
+				db = db.Where("group_id = ?", groupID)
+			}
+		}
+		if shadowGroupIDStr := c.Query("shadow_group_id"); shadowGroupIDStr != "" {
+			if shadowGroupID, err := strconv.Atoi(shadowGroupIDStr); err == nil {
+				db = db.Where("shadow_group_id = ?", shadowGroupID)
+			}
+		}
+		if primaryStatusStr := c.Query("primary_status"); primaryStatusStr != "" {
+			if primaryStatus, err := strconv.Atoi(primaryStatusStr); err == nil {
+				db = db.Where("primary_status = ?", primaryStatus)
+			}
+		}
+		if shadowStatusStr := c.Query("shadow_status"); shadowStatusStr != "" {
+			if shadowStatus, err := strconv.Atoi(shadowStatusStr); err == nil {
+				db = db.Where("shadow_status = ?", shadowStatus)
+			}
+		}
+		if startTimeStr := c.Query("start_time"); startTimeStr != "" {
+			if startTime, err := time.Parse(time.RFC3339, startTimeStr); err == nil {
+				db = db.Where("timestamp >= ?", startTime)
+			}
+		}
+		if endTimeStr := c.Query("end_time"); endTimeStr != "" {
+			if endTime, err := time.Parse(time.RFC3339, endTimeStr); err == nil {
+				db = db.Where("timestamp <= ?", endTime)
+			}
+		}
+		return db
+	}
+}
+
+// GetShadowComparisonsQuery returns a GORM query for fetching comparison results with filters.
+func (s *ShadowComparisonService) GetShadowComparisonsQuery(c *gin.Context) *gorm.DB {
+	return s.DB.Model(&models.ShadowComparison{}).Scopes(shadowComparisonFiltersScope(c))
+}
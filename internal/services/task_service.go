@@ -1,16 +1,27 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"gpt-load/internal/config"
 	"gpt-load/internal/store"
+	"math"
+	"sort"
 	"time"
+
+	"github.com/sirupsen/logrus"
 )
 
 const (
-	globalTaskKey = "global_task"
-	ResultTTL     = 60 * time.Minute
+	taskKeyPrefix       = "task:"
+	taskCancelKeyPrefix = "task_cancel:"
+	taskIndexKey        = "tasks"
+	ResultTTL           = 60 * time.Minute
+
+	// MaxConcurrentTasks bounds how many tasks may run at the same time across all groups.
+	MaxConcurrentTasks = 5
 )
 
 const (
@@ -20,6 +31,7 @@ const (
 
 // TaskStatus represents the full lifecycle of a long-running task.
 type TaskStatus struct {
+	ID              string     `json:"id,omitempty"`
 	TaskType        string     `json:"task_type"`
 	IsRunning       bool       `json:"is_running"`
 	GroupName       string     `json:"group_name,omitempty"`
@@ -27,35 +39,86 @@ type TaskStatus struct {
 	Total           int        `json:"total"`
 	Result          any        `json:"result,omitempty"`
 	Error           string     `json:"error,omitempty"`
+	Cancelled       bool       `json:"cancelled,omitempty"`
 	StartedAt       time.Time  `json:"started_at"`
 	FinishedAt      *time.Time `json:"finished_at,omitempty"`
 	DurationSeconds float64    `json:"duration_seconds,omitempty"`
+
+	// Derived fields, computed when the status is read.
+	ProgressPercent           float64  `json:"progress_percent"`
+	EstimatedSecondsRemaining *float64 `json:"estimated_seconds_remaining,omitempty"`
 }
 
-// TaskService manages the state of a single, global, long-running task using the store interface.
+// computeProgress fills in the derived progress percentage and remaining time estimate.
+// The estimate is omitted when the total is unknown or nothing has been processed yet.
+func (t *TaskStatus) computeProgress() {
+	t.ProgressPercent = 0
+	t.EstimatedSecondsRemaining = nil
+
+	if t.Total <= 0 {
+		if !t.IsRunning && t.FinishedAt != nil {
+			t.ProgressPercent = 100
+		}
+		return
+	}
+
+	t.ProgressPercent = math.Round(float64(t.Processed)/float64(t.Total)*10000) / 100
+	if !t.IsRunning || t.Processed <= 0 {
+		return
+	}
+
+	elapsed := time.Since(t.StartedAt).Seconds()
+	remaining := max(float64(t.Total-t.Processed), 0) * elapsed / float64(t.Processed)
+	remaining = math.Round(remaining)
+	t.EstimatedSecondsRemaining = &remaining
+}
+
+// TaskService manages the state of long-running tasks using the store interface.
+// Tasks are keyed by type and group, so unrelated groups can run tasks concurrently.
 type TaskService struct {
-	store store.Store
+	store           store.Store
+	settingsManager *config.SystemSettingsManager
 }
 
 // NewTaskService creates a new TaskService.
-func NewTaskService(store store.Store) *TaskService {
+func NewTaskService(store store.Store, settingsManager *config.SystemSettingsManager) *TaskService {
 	return &TaskService{
-		store: store,
+		store:           store,
+		settingsManager: settingsManager,
 	}
 }
 
-// StartTask attempts to start a new task. It returns an error if a task is already running.
+// taskID builds the identifier of a task from its type and group.
+func taskID(taskType, groupName string) string {
+	return taskType + ":" + groupName
+}
+
+// StartTask attempts to start a new task. It returns an error if a task of the same type
+// is already running for the group, or if too many tasks are running.
 func (s *TaskService) StartTask(taskType, groupName string, total int, timeout time.Duration) (*TaskStatus, error) {
-	currentStatus, err := s.GetTaskStatus()
+	id := taskID(taskType, groupName)
+
+	tasks, err := s.ListTasks("")
 	if err != nil {
 		return nil, fmt.Errorf("failed to check current task status before starting a new one: %w", err)
 	}
 
-	if currentStatus.IsRunning {
-		return nil, errors.New("a task is already running, please wait")
+	runningCount := 0
+	for _, task := range tasks {
+		if !task.IsRunning {
+			continue
+		}
+		if task.ID == id {
+			return nil, fmt.Errorf("a %s task is already running for group %s, please wait", taskType, groupName)
+		}
+		runningCount++
+	}
+	if runningCount >= MaxConcurrentTasks {
+		return nil, errors.New("too many tasks are running, please wait")
 	}
 
 	status := &TaskStatus{
+		ID:        id,
 		TaskType:  taskType,
 		IsRunning: true,
 		GroupName: groupName,
@@ -68,16 +131,19 @@ func (s *TaskService) StartTask(taskType, groupName string, total int, timeout t
 		return nil, fmt.Errorf("failed to serialize new task status: %w", err)
 	}
 
-	if err := s.store.Set(globalTaskKey, statusBytes, timeout); err != nil {
+	if err := s.store.Set(taskKeyPrefix+id, statusBytes, timeout); err != nil {
 		return nil, fmt.Errorf("failed to set initial task status: %w", err)
 	}
+	if err := s.store.SAdd(taskIndexKey, id); err != nil {
+		return nil, fmt.Errorf("failed to index task: %w", err)
+	}
 
 	return status, nil
 }
 
-// GetTaskStatus returns the current status of the task.
-func (s *TaskService) GetTaskStatus() (*TaskStatus, error) {
-	statusBytes, err := s.store.Get(globalTaskKey)
+// GetTask returns the status of a single task. A task that does not exist is reported as not running.
+func (s *TaskService) GetTask(id string) (*TaskStatus, error) {
+	statusBytes, err := s.store.Get(taskKeyPrefix + id)
 	if err != nil {
 		if errors.Is(err, store.ErrNotFound) {
 			return &TaskStatus{IsRunning: false}, nil
@@ -93,13 +159,63 @@ func (s *TaskService) GetTaskStatus() (*TaskStatus, error) {
 	if !status.IsRunning && status.FinishedAt != nil {
 		status.DurationSeconds = status.FinishedAt.Sub(status.StartedAt).Seconds()
 	}
+	status.computeProgress()
 
 	return &status, nil
 }
 
-// UpdateProgress updates the progress of the current task.
-func (s *TaskService) UpdateProgress(processed int) error {
-	status, err := s.GetTaskStatus()
+// ListTasks returns all known tasks, newest first, optionally filtered by group name.
+func (s *TaskService) ListTasks(groupName string) ([]*TaskStatus, error) {
+	ids, err := s.store.SMembers(taskIndexKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tasks: %w", err)
+	}
+
+	tasks := make([]*TaskStatus, 0, len(ids))
+	for _, id := range ids {
+		status, err := s.GetTask(id)
+		if err != nil {
+			return nil, err
+		}
+		// The status has expired, drop it from the index.
+		if status.ID == "" {
+			if err := s.store.SRem(taskIndexKey, id); err != nil {
+				logrus.Warnf("Failed to remove expired task %s from index: %v", id, err)
+			}
+			continue
+		}
+		if groupName != "" && status.GroupName != groupName {
+			continue
+		}
+		tasks = append(tasks, status)
+	}
+
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].StartedAt.After(tasks[j].StartedAt)
+	})
+	return tasks, nil
+}
+
+// GetTaskStatus returns the most relevant task, preferring running ones, optionally filtered by group name.
+func (s *TaskService) GetTaskStatus(groupName string) (*TaskStatus, error) {
+	tasks, err := s.ListTasks(groupName)
+	if err != nil {
+		return nil, err
+	}
+	if len(tasks) == 0 {
+		return &TaskStatus{IsRunning: false}, nil
+	}
+	for _, task := range tasks {
+		if task.IsRunning {
+			return task, nil
+		}
+	}
+	return tasks[0], nil
+}
+
+// UpdateProgress updates the progress of a task.
+func (s *TaskService) UpdateProgress(id string, processed int) error {
+	status, err := s.GetTask(id)
 	if err != nil {
 		return err
 	}
@@ -113,12 +229,12 @@ func (s *TaskService) UpdateProgress(processed int) error {
 		return fmt.Errorf("failed to serialize updated status: %w", err)
 	}
 
-	return s.store.Set(globalTaskKey, statusBytes, ResultTTL)
+	return s.store.Set(taskKeyPrefix+id, statusBytes, ResultTTL)
 }
 
-// EndTask marks the current task as finished and stores its final result.
-func (s *TaskService) EndTask(resultData any, taskErr error) error {
-	status, err := s.GetTaskStatus()
+// EndTask marks a task as finished and stores its final result.
+func (s *TaskService) EndTask(id string, resultData any, taskErr error) error {
+	status, err := s.GetTask(id)
 	if err != nil {
 		return fmt.Errorf("failed to get task object to end task: %w", err)
 	}
@@ -130,10 +246,14 @@ func (s *TaskService) EndTask(resultData any, taskErr error) error {
 	status.IsRunning = false
 	status.FinishedAt = &now
 	status.DurationSeconds = now.Sub(status.StartedAt).Seconds()
-	if taskErr != nil {
+	status.Result = resultData
+	if errors.Is(taskErr, context.Canceled) {
+		status.Cancelled = true
+	} else if taskErr != nil {
 		status.Error = taskErr.Error()
-	} else {
-		status.Result = resultData
+	}
+	if err := s.store.Delete(taskCancelKeyPrefix + id); err != nil {
+		logrus.Warnf("Failed to clear cancel flag for task %s: %v", id, err)
 	}
 
 	updatedTaskBytes, err := json.Marshal(status)
@@ -141,5 +261,53 @@ func (s *TaskService) EndTask(resultData any, taskErr error) error {
 		return fmt.Errorf("failed to serialize final task status: %w", err)
 	}
 
-	return s.store.Set(globalTaskKey, updatedTaskBytes, ResultTTL)
+	if err := s.store.Set(taskKeyPrefix+id, updatedTaskBytes, ResultTTL); err != nil {
+		return err
+	}
+
+	s.notifyTaskWebhook(status)
+	return nil
+}
+
+// CancelTask requests cancellation of a running task. The node running the task picks up
+// the flag through WatchCancel, so any node may accept the request.
+func (s *TaskService) CancelTask(id string) error {
+	status, err := s.GetTask(id)
+	if err != nil {
+		return err
+	}
+	if !status.IsRunning {
+		return fmt.Errorf("task %s is not running", id)
+	}
+	return s.store.Set(taskCancelKeyPrefix+id, []byte("1"), ResultTTL)
+}
+
+// WatchCancel returns a context that is cancelled once cancellation of the task is requested.
+// The caller must call the returned cancel function when the task finishes.
+func (s *TaskService) WatchCancel(id string) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				requested, err := s.store.Exists(taskCancelKeyPrefix + id)
+				if err != nil {
+					logrus.Warnf("Failed to check cancel flag for task %s: %v", id, err)
+					continue
+				}
+				if requested {
+					logrus.Infof("Task %s cancellation requested", id)
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	return ctx, cancel
 }
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	taskWebhookMaxAttempts = 3
+	taskWebhookRetryDelay  = 2 * time.Second
+	taskWebhookTimeout     = 10 * time.Second
+)
+
+var taskWebhookClient = &http.Client{Timeout: taskWebhookTimeout}
+
+// notifyTaskWebhook posts the final task status to the configured webhook URL in the background.
+func (s *TaskService) notifyTaskWebhook(status *TaskStatus) {
+	webhookURL := s.settingsManager.GetSettings().TaskWebhookURL
+	if webhookURL == "" {
+		return
+	}
+
+	payload, err := json.Marshal(status)
+	if err != nil {
+		logrus.Errorf("Failed to serialize task webhook payload: %v", err)
+		return
+	}
+
+	go func() {
+		for attempt := 1; attempt <= taskWebhookMaxAttempts; attempt++ {
+			err := postTaskWebhook(webhookURL, payload)
+			if err == nil {
+				logrus.Debugf("Task webhook delivered for task %s", status.TaskType)
+				return
+			}
+			logrus.Warnf("Task webhook delivery failed (attempt %d/%d): %v", attempt, taskWebhookMaxAttempts, err)
+			if attempt < taskWebhookMaxAttempts {
+				time.Sleep(taskWebhookRetryDelay * time.Duration(attempt))
+			}
+		}
+	}()
+}
+
+func postTaskWebhook(webhookURL string, payload []byte) error {
+	resp, err := taskWebhookClient.Post(webhookURL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"gpt-load/internal/config"
+	"gpt-load/internal/db"
+	"gpt-load/internal/models"
+	"gpt-load/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// Usage report dimensions.
+const (
+	UsageGroupByGroup    = "group"
+	UsageGroupByProxyKey = "proxy_key"
+	UsageGroupByModel    = "model"
+)
+
+// UsageReportRow is the aggregated usage of one group, proxy key or model.
+// Cost is only set when the model_pricing setting prices at least one of the row's models, and
+// then covers the priced models only.
+type UsageReportRow struct {
+	Name             string   `json:"name"`
+	TotalRequests    int64    `json:"total_requests"`
+	SuccessRequests  int64    `json:"success_requests"`
+	FailedRequests   int64    `json:"failed_requests"`
+	PromptTokens     int64    `json:"prompt_tokens"`
+	CompletionTokens int64    `json:"completion_tokens"`
+	Cost             *float64 `json:"cost,omitempty"`
+}
+
+// usageDimensionColumns are the usage_hourly_stats columns of each report dimension.
+var usageDimensionColumns = map[string]string{
+	UsageGroupByGroup:    "group_id",
+	UsageGroupByProxyKey: "proxy_key_hash",
+	UsageGroupByModel:    "model",
+}
+
+// UsageReportService builds usage reports from the hourly stats tables instead of the raw request logs.
+type UsageReportService struct {
+	db              *gorm.DB
+	groupManager    *GroupManager
+	settingsManager *config.SystemSettingsManager
+}
+
+// NewUsageReportService creates a new UsageReportService. It reads from the replica when one is configured.
+func NewUsageReportService(readDB *db.ReadDB, groupManager *GroupManager, settingsManager *config.SystemSettingsManager) *UsageReportService {
+	return &UsageReportService{
+		db:              readDB.DB,
+		groupManager:    groupManager,
+		settingsManager: settingsManager,
+	}
+}
+
+// Report aggregates the requests in [from, to) by the given dimension, ordered by total requests.
+// Stats are kept per hour, so the range effectively covers the hours it starts in and ends before.
+func (s *UsageReportService) Report(from, to time.Time, groupBy string) ([]UsageReportRow, error) {
+	var rows []struct {
+		Dimension    string
+		SuccessCount int64
+		FailureCount int64
+	}
+
+	var query *gorm.DB
+	switch groupBy {
+	case UsageGroupByGroup:
+		// group_hourly_stats predates the per-key and per-model stats, so it covers the longest history.
+		query = s.db.Model(&models.GroupHourlyStat{}).Select("group_id as dimension, SUM(success_count) as success_count, SUM(failure_count) as failure_count").Group("group_id")
+	case UsageGroupByProxyKey:
+		query = s.db.Model(&models.UsageHourlyStat{}).Select("proxy_key_hash as dimension, SUM(success_count) as success_count, SUM(failure_count) as failure_count").Group("proxy_key_hash")
+	case UsageGroupByModel:
+		query = s.db.Model(&models.UsageHourlyStat{}).Select("model as dimension, SUM(success_count) as success_count, SUM(failure_count) as failure_count").Group("model")
+	default:
+		return nil, fmt.Errorf("group_by must be one of '%s', '%s' or '%s'", UsageGroupByGroup, UsageGroupByProxyKey, UsageGroupByModel)
+	}
+
+	if err := query.Where("time >= ? AND time < ?", from, to).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to aggregate usage: %w", err)
+	}
+
+	tokens, err := s.tokenUsage(from, to, groupBy)
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := s.dimensionNames(groupBy)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]UsageReportRow, 0, len(rows))
+	for _, row := range rows {
+		usage := tokens[row.Dimension]
+		result = append(result, UsageReportRow{
+			Name:             names(row.Dimension),
+			TotalRequests:    row.SuccessCount + row.FailureCount,
+			SuccessRequests:  row.SuccessCount,
+			FailedRequests:   row.FailureCount,
+			PromptTokens:     usage.PromptTokens,
+			CompletionTokens: usage.CompletionTokens,
+			Cost:             usage.Cost,
+		})
+	}
+	slices.SortFunc(result, func(a, b UsageReportRow) int {
+		if a.TotalRequests != b.TotalRequests {
+			return cmp.Compare(b.TotalRequests, a.TotalRequests)
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return result, nil
+}
+
+// tokenUsage sums the tokens in [from, to) per dimension value from usage_hourly_stats, and prices
+// them per model with the model_pricing setting.
+func (s *UsageReportService) tokenUsage(from, to time.Time, groupBy string) (map[string]UsageReportRow, error) {
+	var rows []struct {
+		Dimension        string
+		Model            string
+		PromptTokens     int64
+		CompletionTokens int64
+	}
+	column := usageDimensionColumns[groupBy]
+	query := s.db.Model(&models.UsageHourlyStat{}).
+		Select(column+" as dimension, model, SUM(prompt_tokens) as prompt_tokens, SUM(completion_tokens) as completion_tokens").
+		Where("time >= ? AND time < ?", from, to).
+		Group(column)
+	if column != "model" {
+		query = query.Group("model")
+	}
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to aggregate token usage: %w", err)
+	}
+
+	pricing := s.settingsManager.GetSettings().ModelPricingMap
+	usage := make(map[string]UsageReportRow)
+	for _, row := range rows {
+		total := usage[row.Dimension]
+		total.PromptTokens += row.PromptTokens
+		total.CompletionTokens += row.CompletionTokens
+		if price, ok := pricing[row.Model]; ok {
+			cost := float64(row.PromptTokens)/1e6*price.Prompt + float64(row.CompletionTokens)/1e6*price.Completion
+			if total.Cost != nil {
+				cost += *total.Cost
+			}
+			total.Cost = &cost
+		}
+		usage[row.Dimension] = total
+	}
+	return usage, nil
+}
+
+// dimensionNames returns a function resolving stored group IDs and proxy key hashes to readable names.
+func (s *UsageReportService) dimensionNames(groupBy string) (func(string) string, error) {
+	switch groupBy {
+	case UsageGroupByGroup:
+		var groups []models.Group
+		if err := s.db.Model(&models.Group{}).Select("id, name").Find(&groups).Error; err != nil {
+			return nil, fmt.Errorf("failed to load groups: %w", err)
+		}
+		names := make(map[string]string, len(groups))
+		for _, group := range groups {
+			names[fmt.Sprint(group.ID)] = group.Name
+		}
+		return func(id string) string {
+			if name, ok := names[id]; ok {
+				return name
+			}
+			return fmt.Sprintf("deleted group #%s", id)
+		}, nil
+
+	case UsageGroupByProxyKey:
+		groups, err := s.groupManager.ListGroups()
+		if err != nil {
+			return nil, err
+		}
+		keys := make(map[string]string)
+		for key := range s.settingsManager.GetSettings().ProxyKeysMap {
+			keys[utils.HashKey(key)] = key
+		}
+		for _, group := range groups {
+			for key := range group.ProxyKeysMap {
+				keys[utils.HashKey(key)] = key
+			}
+		}
+		return func(hash string) string {
+			if hash == "" {
+				return "(none)"
+			}
+			if key, ok := keys[hash]; ok {
+				return key
+			}
+			return fmt.Sprintf("removed key %s", hash[:min(len(hash), 12)])
+		}, nil
+
+	default:
+		return func(model string) string {
+			if model == "" {
+				return "(unknown)"
+			}
+			return model
+		}, nil
+	}
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"gpt-load/internal/db"
+	"gpt-load/internal/models"
+)
+
+func TestUsageReportSumsTokensAndCost(t *testing.T) {
+	settingsManager := newTestSettingsManager(t, map[string]string{
+		"model_pricing": "gpt-4o=2.5/10",
+	})
+	if err := db.DB.AutoMigrate(&models.UsageHourlyStat{}); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	hour := time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)
+	stats := []models.UsageHourlyStat{
+		{Time: hour, GroupID: 1, Model: "gpt-4o", SuccessCount: 2, PromptTokens: 1_000_000, CompletionTokens: 200_000},
+		{Time: hour.Add(time.Hour), GroupID: 2, Model: "gpt-4o", SuccessCount: 1, FailureCount: 1, PromptTokens: 400_000},
+		{Time: hour, GroupID: 1, Model: "llama-3", SuccessCount: 1, PromptTokens: 50, CompletionTokens: 10},
+	}
+	if err := db.DB.Create(&stats).Error; err != nil {
+		t.Fatalf("failed to create stats: %v", err)
+	}
+
+	s := &UsageReportService{db: db.DB, settingsManager: settingsManager}
+	rows, err := s.Report(hour, hour.Add(24*time.Hour), UsageGroupByModel)
+	if err != nil {
+		t.Fatalf("Report() error = %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want 2: %+v", len(rows), rows)
+	}
+
+	priced := rows[0]
+	if priced.Name != "gpt-4o" || priced.TotalRequests != 4 || priced.PromptTokens != 1_400_000 || priced.CompletionTokens != 200_000 {
+		t.Errorf("gpt-4o row = %+v", priced)
+	}
+	// 1.4M prompt tokens at 2.5 plus 0.2M completion tokens at 10.
+	if priced.Cost == nil || math.Abs(*priced.Cost-5.5) > 1e-9 {
+		t.Errorf("gpt-4o cost = %v, want 5.5", priced.Cost)
+	}
+
+	unpriced := rows[1]
+	if unpriced.Name != "llama-3" || unpriced.PromptTokens != 50 || unpriced.CompletionTokens != 10 || unpriced.Cost != nil {
+		t.Errorf("llama-3 row = %+v, want tokens without cost", unpriced)
+	}
+}
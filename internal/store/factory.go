@@ -4,29 +4,185 @@ import (
 	"context"
 	"fmt"
 	"gpt-load/internal/types"
+	"gpt-load/internal/utils"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/sirupsen/logrus"
 )
 
+// Redis DSN schemes selecting Sentinel and Cluster mode. Their "s" variants enable TLS.
+const (
+	sentinelScheme = "redis-sentinel"
+	clusterScheme  = "redis-cluster"
+)
+
 // NewStore creates a new store based on the application configuration.
 func NewStore(cfg types.ConfigManager) (Store, error) {
 	redisDSN := cfg.GetRedisDSN()
 	if redisDSN != "" {
-		opts, err := redis.ParseURL(redisDSN)
+		client, err := newRedisClient(redisDSN)
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse redis DSN: %w", err)
 		}
 
-		client := redis.NewClient(opts)
-		if err := client.Ping(context.Background()).Err(); err != nil {
+		maxWait := time.Duration(cfg.GetEffectiveServerConfig().StartupConnectTimeout) * time.Second
+		if err := utils.RetryWithBackoff("Redis", maxWait, func() error {
+			return client.Ping(context.Background()).Err()
+		}); err != nil {
 			return nil, fmt.Errorf("failed to connect to redis: %w", err)
 		}
 
 		logrus.Debug("Successfully connected to Redis.")
-		return NewRedisStore(client), nil
+		return NewFailoverStore(NewRedisStore(client, cfg.GetRedisKeyPrefix())), nil
 	}
 
 	logrus.Info("Redis DSN not configured, falling back to in-memory store.")
 	return NewMemoryStore(), nil
 }
+
+// newRedisClient creates a Redis client from a DSN:
+//
+//	redis://[[user]:password@]host:6379[/db]                                       standalone
+//	redis-sentinel://[[user]:password@]host1:26379[,host2:26379...][/db]?master_name=mymaster
+//	redis-cluster://[[user]:password@]host1:6379[,host2:6379...]
+//
+// rediss, rediss-sentinel and rediss-cluster enable TLS. Sentinel DSNs also accept
+// sentinel_username and sentinel_password when the sentinels require their own credentials.
+func newRedisClient(dsn string) (redis.UniversalClient, error) {
+	scheme, _, _ := strings.Cut(dsn, "://")
+	switch strings.Replace(scheme, "rediss", "redis", 1) {
+	case sentinelScheme:
+		opts, err := parseSentinelURL(dsn)
+		if err != nil {
+			return nil, err
+		}
+		return redis.NewFailoverClient(opts), nil
+	case clusterScheme:
+		opts, err := parseClusterURL(dsn)
+		if err != nil {
+			return nil, err
+		}
+		return redis.NewClusterClient(opts), nil
+	default:
+		opts, err := redis.ParseURL(dsn)
+		if err != nil {
+			return nil, err
+		}
+		return redis.NewClient(opts), nil
+	}
+}
+
+// splitHostList extracts the comma-separated host list of a multi-host DSN and returns the
+// DSN rewritten with the given scheme and only the first host, so that it can be parsed as a URL.
+func splitHostList(dsn, scheme string) (string, []string, error) {
+	_, rest, ok := strings.Cut(dsn, "://")
+	if !ok {
+		return "", nil, fmt.Errorf("invalid redis DSN: missing scheme")
+	}
+
+	var userInfo string
+	if at := strings.LastIndex(rest, "@"); at >= 0 {
+		userInfo, rest = rest[:at+1], rest[at+1:]
+	}
+	hostPart, tail := rest, ""
+	if end := strings.IndexAny(rest, "/?"); end >= 0 {
+		hostPart, tail = rest[:end], rest[end:]
+	}
+
+	var hosts []string
+	for _, host := range strings.Split(hostPart, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	if len(hosts) == 0 {
+		return "", nil, fmt.Errorf("invalid redis DSN: no hosts")
+	}
+	return scheme + "://" + userInfo + hosts[0] + tail, hosts, nil
+}
+
+// isTLSScheme reports whether a multi-host DSN uses a TLS scheme such as rediss-cluster.
+func isTLSScheme(dsn string) bool {
+	return strings.HasPrefix(dsn, "rediss-")
+}
+
+// parseClusterURL parses a redis-cluster:// DSN into cluster options.
+func parseClusterURL(dsn string) (*redis.ClusterOptions, error) {
+	scheme := "redis"
+	if isTLSScheme(dsn) {
+		scheme = "rediss"
+	}
+	rewritten, hosts, err := splitHostList(dsn, scheme)
+	if err != nil {
+		return nil, err
+	}
+	opts, err := redis.ParseClusterURL(rewritten)
+	if err != nil {
+		return nil, err
+	}
+	opts.Addrs = append(hosts, opts.Addrs[1:]...)
+	return opts, nil
+}
+
+// parseSentinelURL parses a redis-sentinel:// DSN into failover options. The credentials, database
+// and query options other than the sentinel ones apply to the master connection.
+func parseSentinelURL(dsn string) (*redis.FailoverOptions, error) {
+	scheme := "redis"
+	if isTLSScheme(dsn) {
+		scheme = "rediss"
+	}
+	rewritten, hosts, err := splitHostList(dsn, scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(rewritten)
+	if err != nil {
+		return nil, err
+	}
+	query := u.Query()
+	masterName := query.Get("master_name")
+	if masterName == "" {
+		return nil, fmt.Errorf("sentinel DSN requires the master_name parameter")
+	}
+	sentinelUsername := query.Get("sentinel_username")
+	sentinelPassword := query.Get("sentinel_password")
+	for _, name := range []string{"master_name", "sentinel_username", "sentinel_password"} {
+		query.Del(name)
+	}
+	u.RawQuery = query.Encode()
+
+	opts, err := redis.ParseURL(u.String())
+	if err != nil {
+		return nil, err
+	}
+	return &redis.FailoverOptions{
+		MasterName:       masterName,
+		SentinelAddrs:    hosts,
+		SentinelUsername: sentinelUsername,
+		SentinelPassword: sentinelPassword,
+		Protocol:         opts.Protocol,
+		Username:         opts.Username,
+		Password:         opts.Password,
+		DB:               opts.DB,
+		ClientName:       opts.ClientName,
+		MaxRetries:       opts.MaxRetries,
+		MinRetryBackoff:  opts.MinRetryBackoff,
+		MaxRetryBackoff:  opts.MaxRetryBackoff,
+		DialTimeout:      opts.DialTimeout,
+		ReadTimeout:      opts.ReadTimeout,
+		WriteTimeout:     opts.WriteTimeout,
+		PoolFIFO:         opts.PoolFIFO,
+		PoolSize:         opts.PoolSize,
+		PoolTimeout:      opts.PoolTimeout,
+		MinIdleConns:     opts.MinIdleConns,
+		MaxIdleConns:     opts.MaxIdleConns,
+		MaxActiveConns:   opts.MaxActiveConns,
+		ConnMaxIdleTime:  opts.ConnMaxIdleTime,
+		ConnMaxLifetime:  opts.ConnMaxLifetime,
+		TLSConfig:        opts.TLSConfig,
+	}, nil
+}
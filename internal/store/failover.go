@@ -0,0 +1,326 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// failoverThreshold is the number of consecutive Redis connection errors that switch the store to its fallback.
+	failoverThreshold = 3
+	// failoverProbeInterval is how often Redis is pinged while the store is degraded.
+	failoverProbeInterval = 5 * time.Second
+)
+
+// Degradable is implemented by stores that can switch to a fallback backend at runtime.
+type Degradable interface {
+	// IsDegraded reports whether the store is currently serving from its fallback backend.
+	IsDegraded() bool
+	// OnBackendChange registers fn to be called in a new goroutine after the store switches
+	// to (degraded is true) or back from its fallback backend.
+	OnBackendChange(fn func(degraded bool))
+}
+
+// FailoverStore wraps a RedisStore and switches to a local in-memory store after repeated Redis
+// connection errors, so that a single-node deployment keeps serving while Redis is down. It pings
+// Redis in the background and switches back once it recovers. Pub/Sub always uses Redis.
+type FailoverStore struct {
+	primary *RedisStore
+
+	mu        sync.RWMutex
+	fallback  *MemoryStore
+	failures  int
+	listeners []func(degraded bool)
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewFailoverStore creates a new FailoverStore in front of primary.
+func NewFailoverStore(primary *RedisStore) *FailoverStore {
+	return &FailoverStore{
+		primary: primary,
+		stopCh:  make(chan struct{}),
+	}
+}
+
+// IsDegraded reports whether the store is serving from the in-memory fallback.
+func (s *FailoverStore) IsDegraded() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.fallback != nil
+}
+
+// OnBackendChange registers fn to be called after the store switches to or from the fallback.
+func (s *FailoverStore) OnBackendChange(fn func(degraded bool)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
+// backend returns the store operations should currently go to, and whether it is Redis.
+func (s *FailoverStore) backend() (Store, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.fallback != nil {
+		return s.fallback, false
+	}
+	return s.primary, true
+}
+
+// record counts consecutive Redis connection errors and switches to the fallback at the threshold.
+func (s *FailoverStore) record(err error) {
+	connErr := isConnectionError(err)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !connErr {
+		s.failures = 0
+		return
+	}
+	if s.fallback != nil {
+		return
+	}
+	s.failures++
+	if s.failures < failoverThreshold {
+		return
+	}
+
+	logrus.WithError(err).Error("Redis is unavailable, switching to the in-memory store. Keys are reloaded from the database; data in Redis is not available until it recovers.")
+	s.fallback = NewMemoryStore()
+	s.failures = 0
+	s.notify(true)
+
+	s.wg.Add(1)
+	go s.probe()
+}
+
+// probe pings Redis until it responds, then switches back to it.
+func (s *FailoverStore) probe() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(failoverProbeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.primary.Ping(); err != nil {
+				logrus.WithError(err).Warn("Redis is still unavailable, serving from the in-memory store.")
+				continue
+			}
+			s.mu.Lock()
+			s.fallback.Close()
+			s.fallback = nil
+			s.notify(false)
+			s.mu.Unlock()
+			logrus.Info("Redis has recovered, switched back from the in-memory store.")
+			return
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+// notify calls the registered listeners. It must be called with s.mu held.
+func (s *FailoverStore) notify(degraded bool) {
+	for _, fn := range s.listeners {
+		go fn(degraded)
+	}
+}
+
+// isConnectionError reports whether err means Redis could not be reached, as opposed to
+// a missing key or a command error.
+func isConnectionError(err error) bool {
+	if err == nil || errors.Is(err, ErrNotFound) || errors.Is(err, redis.Nil) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, redis.ErrClosed) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
+
+// do runs op on the current backend and records the outcome if it ran on Redis.
+func do[T any](s *FailoverStore, op func(Store) (T, error)) (T, error) {
+	backend, isPrimary := s.backend()
+	result, err := op(backend)
+	if isPrimary {
+		s.record(err)
+	}
+	return result, err
+}
+
+// doErr is do for operations that only return an error.
+func doErr(s *FailoverStore, op func(Store) error) error {
+	_, err := do(s, func(backend Store) (struct{}, error) {
+		return struct{}{}, op(backend)
+	})
+	return err
+}
+
+// Set stores a key-value pair with an optional TTL.
+func (s *FailoverStore) Set(key string, value []byte, ttl time.Duration) error {
+	return doErr(s, func(b Store) error { return b.Set(key, value, ttl) })
+}
+
+// Get retrieves a value by its key.
+func (s *FailoverStore) Get(key string) ([]byte, error) {
+	return do(s, func(b Store) ([]byte, error) { return b.Get(key) })
+}
+
+// Delete removes a value by its key.
+func (s *FailoverStore) Delete(key string) error {
+	return doErr(s, func(b Store) error { return b.Delete(key) })
+}
+
+// Del deletes multiple keys.
+func (s *FailoverStore) Del(keys ...string) error {
+	return doErr(s, func(b Store) error { return b.Del(keys...) })
+}
+
+// Exists checks if a key exists in the store.
+func (s *FailoverStore) Exists(key string) (bool, error) {
+	return do(s, func(b Store) (bool, error) { return b.Exists(key) })
+}
+
+// SetNX sets a key-value pair if the key does not already exist.
+func (s *FailoverStore) SetNX(key string, value []byte, ttl time.Duration) (bool, error) {
+	return do(s, func(b Store) (bool, error) { return b.SetNX(key, value, ttl) })
+}
+
+// Rename atomically moves a key to a new name.
+func (s *FailoverStore) Rename(key, newKey string) error {
+	return doErr(s, func(b Store) error { return b.Rename(key, newKey) })
+}
+
+// IncrBy atomically increments an integer counter and refreshes its TTL.
+func (s *FailoverStore) IncrBy(key string, incr int64, ttl time.Duration) (int64, error) {
+	return do(s, func(b Store) (int64, error) { return b.IncrBy(key, incr, ttl) })
+}
+
+// HSet sets fields in a hash.
+func (s *FailoverStore) HSet(key string, values map[string]any) error {
+	return doErr(s, func(b Store) error { return b.HSet(key, values) })
+}
+
+// HGetAll retrieves all fields of a hash.
+func (s *FailoverStore) HGetAll(key string) (map[string]string, error) {
+	return do(s, func(b Store) (map[string]string, error) { return b.HGetAll(key) })
+}
+
+// HIncrBy increments an integer field of a hash.
+func (s *FailoverStore) HIncrBy(key, field string, incr int64) (int64, error) {
+	return do(s, func(b Store) (int64, error) { return b.HIncrBy(key, field, incr) })
+}
+
+// LPush prepends values to a list.
+func (s *FailoverStore) LPush(key string, values ...any) error {
+	return doErr(s, func(b Store) error { return b.LPush(key, values...) })
+}
+
+// LRem removes elements from a list.
+func (s *FailoverStore) LRem(key string, count int64, value any) error {
+	return doErr(s, func(b Store) error { return b.LRem(key, count, value) })
+}
+
+// Rotate atomically moves the last element of a list to the head and returns it.
+func (s *FailoverStore) Rotate(key string) (string, error) {
+	return do(s, func(b Store) (string, error) { return b.Rotate(key) })
+}
+
+// LLen returns the length of a list.
+func (s *FailoverStore) LLen(key string) (int64, error) {
+	return do(s, func(b Store) (int64, error) { return b.LLen(key) })
+}
+
+// Shuffle atomically reorders a list randomly.
+func (s *FailoverStore) Shuffle(key string) error {
+	return doErr(s, func(b Store) error { return b.Shuffle(key) })
+}
+
+// SAdd adds members to a set.
+func (s *FailoverStore) SAdd(key string, members ...any) error {
+	return doErr(s, func(b Store) error { return b.SAdd(key, members...) })
+}
+
+// SPopN removes and returns up to count random members of a set.
+func (s *FailoverStore) SPopN(key string, count int64) ([]string, error) {
+	return do(s, func(b Store) ([]string, error) { return b.SPopN(key, count) })
+}
+
+// SMembers returns all members of a set.
+func (s *FailoverStore) SMembers(key string) ([]string, error) {
+	return do(s, func(b Store) ([]string, error) { return b.SMembers(key) })
+}
+
+// SCard returns the number of members of a set.
+func (s *FailoverStore) SCard(key string) (int64, error) {
+	return do(s, func(b Store) (int64, error) { return b.SCard(key) })
+}
+
+// SRem removes members from a set.
+func (s *FailoverStore) SRem(key string, members ...any) error {
+	return doErr(s, func(b Store) error { return b.SRem(key, members...) })
+}
+
+// Pipeline creates a pipeline on the current backend, or applies commands one by one on the fallback.
+func (s *FailoverStore) Pipeline() Pipeliner {
+	backend, isPrimary := s.backend()
+	if isPrimary {
+		return s.primary.Pipeline()
+	}
+	return &sequentialPipeliner{store: backend}
+}
+
+// Publish sends a message to a given channel on Redis.
+func (s *FailoverStore) Publish(channel string, message []byte) error {
+	return s.primary.Publish(channel, message)
+}
+
+// Subscribe listens for messages on a given channel on Redis.
+func (s *FailoverStore) Subscribe(channel string) (Subscription, error) {
+	return s.primary.Subscribe(channel)
+}
+
+// Close stops the recovery probe and closes the underlying stores.
+func (s *FailoverStore) Close() error {
+	close(s.stopCh)
+	s.wg.Wait()
+
+	s.mu.Lock()
+	if s.fallback != nil {
+		s.fallback.Close()
+		s.fallback = nil
+	}
+	s.mu.Unlock()
+	return s.primary.Close()
+}
+
+// sequentialPipeliner applies pipelined commands one by one on a store without pipelining.
+type sequentialPipeliner struct {
+	store    Store
+	commands []func() error
+}
+
+// HSet adds an HSET command to the pipeline.
+func (p *sequentialPipeliner) HSet(key string, values map[string]any) {
+	p.commands = append(p.commands, func() error { return p.store.HSet(key, values) })
+}
+
+// Exec executes all commands in the pipeline.
+func (p *sequentialPipeliner) Exec() error {
+	for _, command := range p.commands {
+		if err := command(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package store
 
 import (
 	"fmt"
+	"math/rand"
 	"strconv"
 	"sync"
 	"time"
@@ -146,6 +147,38 @@ func (s *MemoryStore) SetNX(key string, value []byte, ttl time.Duration) (bool,
 	return true, nil
 }
 
+// IncrBy atomically increments an integer counter and refreshes its TTL.
+func (s *MemoryStore) IncrBy(key string, incr int64, ttl time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var current int64
+	if rawItem, exists := s.data[key]; exists {
+		item, ok := rawItem.(memoryStoreItem)
+		if !ok {
+			return 0, fmt.Errorf("type mismatch: key '%s' holds a different data type", key)
+		}
+		if item.expiresAt == 0 || time.Now().UnixNano() < item.expiresAt {
+			var err error
+			current, err = strconv.ParseInt(string(item.value), 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("value of key '%s' is not an integer", key)
+			}
+		}
+	}
+
+	var expiresAt int64
+	if ttl > 0 {
+		expiresAt = time.Now().UnixNano() + ttl.Nanoseconds()
+	}
+	newVal := current + incr
+	s.data[key] = memoryStoreItem{
+		value:     []byte(strconv.FormatInt(newVal, 10)),
+		expiresAt: expiresAt,
+	}
+	return newVal, nil
+}
+
 // --- HASH operations ---
 
 func (s *MemoryStore) HSet(key string, values map[string]any) error {
@@ -217,6 +250,20 @@ func (s *MemoryStore) HIncrBy(key, field string, incr int64) (int64, error) {
 	return newVal, nil
 }
 
+// Rename moves a key to a new name, replacing any existing value at the new name.
+func (s *MemoryStore) Rename(key, newKey string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	value, exists := s.data[key]
+	if !exists {
+		return ErrNotFound
+	}
+	s.data[newKey] = value
+	delete(s.data, key)
+	return nil
+}
+
 // --- LIST operations ---
 
 func (s *MemoryStore) LPush(key string, values ...any) error {
@@ -235,9 +282,10 @@ func (s *MemoryStore) LPush(key string, values ...any) error {
 		}
 	}
 
+	// Like Redis LPUSH, each value is prepended in turn, so the last value ends up at the head.
 	strValues := make([]string, len(values))
 	for i, v := range values {
-		strValues[i] = fmt.Sprint(v)
+		strValues[len(values)-1-i] = fmt.Sprint(v)
 	}
 
 	s.data[key] = append(strValues, list...) // Prepend
@@ -302,6 +350,44 @@ func (s *MemoryStore) Rotate(key string) (string, error) {
 	return item, nil
 }
 
+// LLen returns the length of a list, or 0 if the key does not exist.
+func (s *MemoryStore) LLen(key string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rawList, exists := s.data[key]
+	if !exists {
+		return 0, nil
+	}
+
+	list, ok := rawList.([]string)
+	if !ok {
+		return 0, fmt.Errorf("type mismatch: key '%s' holds a different data type", key)
+	}
+	return int64(len(list)), nil
+}
+
+// Shuffle randomly reorders a list in place.
+func (s *MemoryStore) Shuffle(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rawList, exists := s.data[key]
+	if !exists {
+		return nil
+	}
+
+	list, ok := rawList.([]string)
+	if !ok {
+		return fmt.Errorf("type mismatch: key '%s' holds a different data type", key)
+	}
+
+	rand.Shuffle(len(list), func(i, j int) {
+		list[i], list[j] = list[j], list[i]
+	})
+	return nil
+}
+
 // --- SET operations ---
 
 // SAdd adds members to a set.
@@ -359,6 +445,66 @@ func (s *MemoryStore) SPopN(key string, count int64) ([]string, error) {
 	return popped, nil
 }
 
+// SMembers returns all members of a set.
+func (s *MemoryStore) SMembers(key string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rawSet, exists := s.data[key]
+	if !exists {
+		return []string{}, nil
+	}
+
+	set, ok := rawSet.(map[string]struct{})
+	if !ok {
+		return nil, fmt.Errorf("type mismatch: key '%s' holds a different data type", key)
+	}
+
+	members := make([]string, 0, len(set))
+	for member := range set {
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+// SCard returns the number of members of a set.
+func (s *MemoryStore) SCard(key string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rawSet, exists := s.data[key]
+	if !exists {
+		return 0, nil
+	}
+
+	set, ok := rawSet.(map[string]struct{})
+	if !ok {
+		return 0, fmt.Errorf("type mismatch: key '%s' holds a different data type", key)
+	}
+	return int64(len(set)), nil
+}
+
+// SRem removes members from a set.
+func (s *MemoryStore) SRem(key string, members ...any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rawSet, exists := s.data[key]
+	if !exists {
+		return nil
+	}
+
+	set, ok := rawSet.(map[string]struct{})
+	if !ok {
+		return fmt.Errorf("type mismatch: key '%s' holds a different data type", key)
+	}
+
+	for _, member := range members {
+		delete(set, fmt.Sprint(member))
+	}
+	return nil
+}
+
 // --- Pub/Sub operations ---
 
 // memorySubscription implements the Subscription interface for the in-memory store.
@@ -4,30 +4,59 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math/rand"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
-// RedisStore is a Redis-backed key-value store.
+// RedisStore is a Redis-backed key-value store. It works with a standalone, Sentinel or Cluster client.
 type RedisStore struct {
-	client *redis.Client
+	client redis.UniversalClient
+	// cluster is set for Redis Cluster, where multi-key commands must not span hash slots.
+	cluster bool
+	// prefix is prepended to every key and channel, so several instances can share one Redis.
+	prefix string
 }
 
-// NewRedisStore creates a new RedisStore instance.
-func NewRedisStore(client *redis.Client) *RedisStore {
-	return &RedisStore{client: client}
+// NewRedisStore creates a new RedisStore instance whose keys and channels are namespaced by prefix.
+func NewRedisStore(client redis.UniversalClient, prefix string) *RedisStore {
+	_, cluster := client.(*redis.ClusterClient)
+	return &RedisStore{client: client, cluster: cluster, prefix: prefix}
+}
+
+// prefixed returns the Redis name of a store key or channel.
+func (s *RedisStore) prefixed(key string) string {
+	return s.prefix + key
+}
+
+// prefixedAll returns the Redis names of several store keys.
+func (s *RedisStore) prefixedAll(keys []string) []string {
+	if s.prefix == "" {
+		return keys
+	}
+	result := make([]string, len(keys))
+	for i, key := range keys {
+		result[i] = s.prefixed(key)
+	}
+	return result
+}
+
+// Ping checks that Redis is reachable.
+func (s *RedisStore) Ping() error {
+	return s.client.Ping(context.Background()).Err()
 }
 
 // Set stores a key-value pair in Redis.
 func (s *RedisStore) Set(key string, value []byte, ttl time.Duration) error {
-	return s.client.Set(context.Background(), key, value, ttl).Err()
+	return s.client.Set(context.Background(), s.prefixed(key), value, ttl).Err()
 }
 
 // Get retrieves a value from Redis.
 func (s *RedisStore) Get(key string) ([]byte, error) {
-	val, err := s.client.Get(context.Background(), key).Bytes()
+	val, err := s.client.Get(context.Background(), s.prefixed(key)).Bytes()
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
 			return nil, ErrNotFound
@@ -39,20 +68,31 @@ func (s *RedisStore) Get(key string) ([]byte, error) {
 
 // Delete removes a value from Redis.
 func (s *RedisStore) Delete(key string) error {
-	return s.client.Del(context.Background(), key).Err()
+	return s.client.Del(context.Background(), s.prefixed(key)).Err()
 }
 
-// Del removes multiple values from Redis.
+// Del removes multiple values from Redis. On Cluster the keys are deleted one by one in a
+// pipeline, since they may live in different hash slots.
 func (s *RedisStore) Del(keys ...string) error {
 	if len(keys) == 0 {
 		return nil
 	}
-	return s.client.Del(context.Background(), keys...).Err()
+	if !s.cluster {
+		return s.client.Del(context.Background(), s.prefixedAll(keys)...).Err()
+	}
+	ctx := context.Background()
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Del(ctx, s.prefixed(key))
+		}
+		return nil
+	})
+	return err
 }
 
 // Exists checks if a key exists in Redis.
 func (s *RedisStore) Exists(key string) (bool, error) {
-	val, err := s.client.Exists(context.Background(), key).Result()
+	val, err := s.client.Exists(context.Background(), s.prefixed(key)).Result()
 	if err != nil {
 		return false, err
 	}
@@ -61,7 +101,65 @@ func (s *RedisStore) Exists(key string) (bool, error) {
 
 // SetNX sets a key-value pair in Redis if the key does not already exist.
 func (s *RedisStore) SetNX(key string, value []byte, ttl time.Duration) (bool, error) {
-	return s.client.SetNX(context.Background(), key, value, ttl).Result()
+	return s.client.SetNX(context.Background(), s.prefixed(key), value, ttl).Result()
+}
+
+// Rename atomically renames a key in Redis, replacing any existing value at the new name.
+// On Cluster, keys in different hash slots are moved with DUMP and RESTORE instead: readers of
+// the new name still see either the old or the new value, but both names exist briefly.
+func (s *RedisStore) Rename(key, newKey string) error {
+	ctx := context.Background()
+	from, to := s.prefixed(key), s.prefixed(newKey)
+	if !s.cluster || hashSlotKey(from) == hashSlotKey(to) {
+		return s.client.Rename(ctx, from, to).Err()
+	}
+
+	value, err := s.client.Dump(ctx, from).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return ErrNotFound
+		}
+		return err
+	}
+	ttl, err := s.client.PTTL(ctx, from).Result()
+	if err != nil {
+		return err
+	}
+	if ttl < 0 {
+		ttl = 0
+	}
+	if err := s.client.RestoreReplace(ctx, to, ttl, value).Err(); err != nil {
+		return err
+	}
+	return s.client.Del(ctx, from).Err()
+}
+
+// hashSlotKey returns the part of a key Redis Cluster hashes: the content of the first
+// non-empty {hash tag}, or the whole key.
+func hashSlotKey(key string) string {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			return key[start+1 : start+1+end]
+		}
+	}
+	return key
+}
+
+// IncrBy atomically increments a counter in Redis and refreshes its TTL.
+func (s *RedisStore) IncrBy(key string, incr int64, ttl time.Duration) (int64, error) {
+	ctx := context.Background()
+	var incrCmd *redis.IntCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incrCmd = pipe.IncrBy(ctx, s.prefixed(key), incr)
+		if ttl > 0 {
+			pipe.Expire(ctx, s.prefixed(key), ttl)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return incrCmd.Val(), nil
 }
 
 // Close closes the Redis client connection.
@@ -72,29 +170,29 @@ func (s *RedisStore) Close() error {
 // --- HASH operations ---
 
 func (s *RedisStore) HSet(key string, values map[string]any) error {
-	return s.client.HSet(context.Background(), key, values).Err()
+	return s.client.HSet(context.Background(), s.prefixed(key), values).Err()
 }
 
 func (s *RedisStore) HGetAll(key string) (map[string]string, error) {
-	return s.client.HGetAll(context.Background(), key).Result()
+	return s.client.HGetAll(context.Background(), s.prefixed(key)).Result()
 }
 
 func (s *RedisStore) HIncrBy(key, field string, incr int64) (int64, error) {
-	return s.client.HIncrBy(context.Background(), key, field, incr).Result()
+	return s.client.HIncrBy(context.Background(), s.prefixed(key), field, incr).Result()
 }
 
 // --- LIST operations ---
 
 func (s *RedisStore) LPush(key string, values ...any) error {
-	return s.client.LPush(context.Background(), key, values...).Err()
+	return s.client.LPush(context.Background(), s.prefixed(key), values...).Err()
 }
 
 func (s *RedisStore) LRem(key string, count int64, value any) error {
-	return s.client.LRem(context.Background(), key, count, value).Err()
+	return s.client.LRem(context.Background(), s.prefixed(key), count, value).Err()
 }
 
 func (s *RedisStore) Rotate(key string) (string, error) {
-	val, err := s.client.RPopLPush(context.Background(), key, key).Result()
+	val, err := s.client.RPopLPush(context.Background(), s.prefixed(key), s.prefixed(key)).Result()
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
 			return "", ErrNotFound
@@ -104,25 +202,67 @@ func (s *RedisStore) Rotate(key string) (string, error) {
 	return val, nil
 }
 
+func (s *RedisStore) LLen(key string) (int64, error) {
+	return s.client.LLen(context.Background(), s.prefixed(key)).Result()
+}
+
+// shuffleScript performs a Fisher-Yates shuffle of a list inside Redis, so concurrent
+// rotations never observe a partially rewritten list. Scripts share a fixed PRNG seed,
+// so a fresh seed is passed in.
+var shuffleScript = redis.NewScript(`
+local items = redis.call('LRANGE', KEYS[1], 0, -1)
+if #items < 2 then
+	return 0
+end
+math.randomseed(tonumber(ARGV[1]))
+for i = #items, 2, -1 do
+	local j = math.random(i)
+	items[i], items[j] = items[j], items[i]
+end
+redis.call('DEL', KEYS[1])
+-- unpack is limited by the Lua stack size, so large lists are pushed in batches.
+for i = 1, #items, 1000 do
+	redis.call('RPUSH', KEYS[1], unpack(items, i, math.min(i + 999, #items)))
+end
+return #items
+`)
+
+func (s *RedisStore) Shuffle(key string) error {
+	return shuffleScript.Run(context.Background(), s.client, []string{s.prefixed(key)}, rand.Int63()).Err()
+}
+
 // --- SET operations ---
 
 func (s *RedisStore) SAdd(key string, members ...any) error {
-	return s.client.SAdd(context.Background(), key, members...).Err()
+	return s.client.SAdd(context.Background(), s.prefixed(key), members...).Err()
 }
 
 func (s *RedisStore) SPopN(key string, count int64) ([]string, error) {
-	return s.client.SPopN(context.Background(), key, count).Result()
+	return s.client.SPopN(context.Background(), s.prefixed(key), count).Result()
+}
+
+func (s *RedisStore) SMembers(key string) ([]string, error) {
+	return s.client.SMembers(context.Background(), s.prefixed(key)).Result()
+}
+
+func (s *RedisStore) SCard(key string) (int64, error) {
+	return s.client.SCard(context.Background(), s.prefixed(key)).Result()
+}
+
+func (s *RedisStore) SRem(key string, members ...any) error {
+	return s.client.SRem(context.Background(), s.prefixed(key), members...).Err()
 }
 
 // --- Pipeliner implementation ---
 
 type redisPipeliner struct {
-	pipe redis.Pipeliner
+	pipe  redis.Pipeliner
+	store *RedisStore
 }
 
 // HSet adds an HSET command to the pipeline.
 func (p *redisPipeliner) HSet(key string, values map[string]any) {
-	p.pipe.HSet(context.Background(), key, values)
+	p.pipe.HSet(context.Background(), p.store.prefixed(key), values)
 }
 
 // Exec executes all commands in the pipeline.
@@ -134,7 +274,8 @@ func (p *redisPipeliner) Exec() error {
 // Pipeline creates a new pipeline.
 func (s *RedisStore) Pipeline() Pipeliner {
 	return &redisPipeliner{
-		pipe: s.client.Pipeline(),
+		pipe:  s.client.Pipeline(),
+		store: s,
 	}
 }
 
@@ -143,6 +284,7 @@ func (s *RedisStore) Pipeline() Pipeliner {
 // redisSubscription wraps the redis.PubSub to implement the Subscription interface.
 type redisSubscription struct {
 	pubsub  *redis.PubSub
+	prefix  string
 	msgChan chan *Message
 	once    sync.Once
 }
@@ -155,7 +297,7 @@ func (rs *redisSubscription) Channel() <-chan *Message {
 			defer close(rs.msgChan)
 			for redisMsg := range rs.pubsub.Channel() {
 				rs.msgChan <- &Message{
-					Channel: redisMsg.Channel,
+					Channel: strings.TrimPrefix(redisMsg.Channel, rs.prefix),
 					Payload: []byte(redisMsg.Payload),
 				}
 			}
@@ -171,17 +313,17 @@ func (rs *redisSubscription) Close() error {
 
 // Publish sends a message to a given channel.
 func (s *RedisStore) Publish(channel string, message []byte) error {
-	return s.client.Publish(context.Background(), channel, message).Err()
+	return s.client.Publish(context.Background(), s.prefixed(channel), message).Err()
 }
 
 // Subscribe listens for messages on a given channel.
 func (s *RedisStore) Subscribe(channel string) (Subscription, error) {
-	pubsub := s.client.Subscribe(context.Background(), channel)
+	pubsub := s.client.Subscribe(context.Background(), s.prefixed(channel))
 
 	_, err := pubsub.Receive(context.Background())
 	if err != nil {
 		return nil, fmt.Errorf("failed to subscribe to channel %s: %w", channel, err)
 	}
 
-	return &redisSubscription{pubsub: pubsub}, nil
+	return &redisSubscription{pubsub: pubsub, prefix: s.prefix}, nil
 }
@@ -40,6 +40,12 @@ type Store interface {
 	// SetNX sets a key-value pair if the key does not already exist.
 	SetNX(key string, value []byte, ttl time.Duration) (bool, error)
 
+	// Rename atomically moves a key to a new name, replacing any existing value at the new name.
+	Rename(key, newKey string) error
+
+	// IncrBy atomically increments an integer counter and refreshes its TTL, creating it at zero if missing.
+	IncrBy(key string, incr int64, ttl time.Duration) (int64, error)
+
 	// HASH operations
 	HSet(key string, values map[string]any) error
 	HGetAll(key string) (map[string]string, error)
@@ -49,10 +55,17 @@ type Store interface {
 	LPush(key string, values ...any) error
 	LRem(key string, count int64, value any) error
 	Rotate(key string) (string, error)
+	LLen(key string) (int64, error)
+	// Shuffle atomically reorders a list randomly. A missing list is not an error.
+	Shuffle(key string) error
 
 	// SET operations
 	SAdd(key string, members ...any) error
 	SPopN(key string, count int64) ([]string, error)
+	SMembers(key string) ([]string, error)
+	// SCard returns the number of members of a set, 0 if it does not exist.
+	SCard(key string) (int64, error)
+	SRem(key string, members ...any) error
 
 	// Close closes the store and releases any underlying resources.
 	Close() error
@@ -2,6 +2,7 @@ package syncer
 
 import (
 	"fmt"
+	"reflect"
 	"sync"
 	"time"
 
@@ -13,6 +14,9 @@ import (
 // LoaderFunc defines a generic function signature for loading data from the source of truth (e.g., database).
 type LoaderFunc[T any] func() (T, error)
 
+// resyncRecheckInterval is how often a disabled periodic resync checks whether it has been enabled.
+const resyncRecheckInterval = time.Minute
+
 // CacheSyncer is a generic service that manages in-memory caching and cross-instance synchronization.
 type CacheSyncer[T any] struct {
 	mu          sync.RWMutex
@@ -24,23 +28,29 @@ type CacheSyncer[T any] struct {
 	stopChan    chan struct{}
 	wg          sync.WaitGroup
 	afterReload func(newValue T)
+	// resyncInterval returns, given the cached data, how often the cache is reloaded without a notification, 0 to disable.
+	resyncInterval func(current T) time.Duration
 }
 
 // NewCacheSyncer creates and initializes a new CacheSyncer.
+// Besides reloading on notifications, it reloads every resyncInterval so that an instance which
+// missed a notification still converges. A nil resyncInterval disables the periodic reload.
 func NewCacheSyncer[T any](
 	loader LoaderFunc[T],
 	store store.Store,
 	channelName string,
 	logger *logrus.Entry,
 	afterReload func(newValue T),
+	resyncInterval func(current T) time.Duration,
 ) (*CacheSyncer[T], error) {
 	s := &CacheSyncer[T]{
-		loader:      loader,
-		store:       store,
-		channelName: channelName,
-		logger:      logger,
-		stopChan:    make(chan struct{}),
-		afterReload: afterReload,
+		loader:         loader,
+		store:          store,
+		channelName:    channelName,
+		logger:         logger,
+		stopChan:       make(chan struct{}),
+		afterReload:    afterReload,
+		resyncInterval: resyncInterval,
 	}
 
 	if err := s.reload(); err != nil {
@@ -50,6 +60,11 @@ func NewCacheSyncer[T any](
 	s.wg.Add(1)
 	go s.listenForUpdates()
 
+	if resyncInterval != nil {
+		s.wg.Add(1)
+		go s.resyncPeriodically()
+	}
+
 	return s, nil
 }
 
@@ -82,17 +97,67 @@ func (s *CacheSyncer[T]) reload() error {
 		return err
 	}
 
+	s.apply(newData)
+	s.logger.Info("cache reloaded successfully")
+	return nil
+}
+
+// apply replaces the cached data and triggers the afterReload hook.
+func (s *CacheSyncer[T]) apply(newData T) {
 	s.mu.Lock()
 	s.cache = newData
 	s.mu.Unlock()
 
-	s.logger.Info("cache reloaded successfully")
-	// After successfully reloading and updating the cache, trigger the hook.
 	if s.afterReload != nil {
 		s.logger.Debug("triggering afterReload hook")
 		s.afterReload(newData)
 	}
-	return nil
+}
+
+// resyncPeriodically reloads the cache at the configured interval, as a safety net for missed notifications.
+// The interval is re-read at least every resyncRecheckInterval, so a changed setting applies promptly.
+func (s *CacheSyncer[T]) resyncPeriodically() {
+	defer s.wg.Done()
+	lastResync := time.Now()
+
+	for {
+		interval := s.resyncInterval(s.Get())
+		wait := resyncRecheckInterval
+		if interval > 0 {
+			wait = min(interval-time.Since(lastResync), resyncRecheckInterval)
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-s.stopChan:
+			return
+		}
+
+		if interval > 0 && time.Since(lastResync) >= interval {
+			s.resync()
+			lastResync = time.Now()
+		}
+	}
+}
+
+// resync loads the data and applies it only if it differs from the cache.
+func (s *CacheSyncer[T]) resync() {
+	newData, err := s.loader()
+	if err != nil {
+		s.logger.Errorf("failed to resync cache: %v", err)
+		return
+	}
+
+	s.mu.RLock()
+	unchanged := reflect.DeepEqual(s.cache, newData)
+	s.mu.RUnlock()
+	if unchanged {
+		s.logger.Debug("periodic resync found the cache up to date")
+		return
+	}
+
+	s.logger.Warn("cache was out of date, an invalidation notification may have been missed; reloaded")
+	s.apply(newData)
 }
 
 // listenForUpdates runs in the background, listening for invalidation messages.
@@ -0,0 +1,81 @@
+package syncer
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gpt-load/internal/store"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestCacheSyncerResyncsAfterDroppedNotification(t *testing.T) {
+	var source atomic.Int64
+	source.Store(1)
+	var reloaded atomic.Int64
+
+	s, err := NewCacheSyncer(
+		func() (int64, error) { return source.Load(), nil },
+		store.NewMemoryStore(),
+		"test:cache",
+		logrus.NewEntry(logrus.New()),
+		func(v int64) { reloaded.Store(v) },
+		func(int64) time.Duration { return 20 * time.Millisecond },
+	)
+	if err != nil {
+		t.Fatalf("NewCacheSyncer() error = %v", err)
+	}
+	defer s.Stop()
+
+	// The source changes without Invalidate, as when this instance misses the notification.
+	source.Store(2)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for s.Get() != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Get() = %d after the resync interval, want 2", s.Get())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := reloaded.Load(); got != 2 {
+		t.Errorf("afterReload got %d, want 2", got)
+	}
+}
+
+func TestCacheSyncerWithoutResyncKeepsStaleCache(t *testing.T) {
+	var source atomic.Int64
+	source.Store(1)
+
+	s, err := NewCacheSyncer(
+		func() (int64, error) { return source.Load(), nil },
+		store.NewMemoryStore(),
+		"test:cache",
+		logrus.NewEntry(logrus.New()),
+		nil,
+		nil,
+	)
+	if err != nil {
+		t.Fatalf("NewCacheSyncer() error = %v", err)
+	}
+	defer s.Stop()
+
+	source.Store(2)
+	time.Sleep(50 * time.Millisecond)
+	if got := s.Get(); got != 1 {
+		t.Fatalf("Get() = %d without a notification or resync, want 1", got)
+	}
+
+	// A delivered notification reloads the cache. It is published again until then, as the listener
+	// subscribes in the background.
+	deadline := time.Now().Add(2 * time.Second)
+	for s.Get() != 2 {
+		if err := s.Invalidate(); err != nil {
+			t.Fatalf("Invalidate() error = %v", err)
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Get() = %d after Invalidate(), want 2", s.Get())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
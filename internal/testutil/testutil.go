@@ -0,0 +1,60 @@
+// Package testutil provides fixtures shared by the tests of other packages.
+package testutil
+
+import (
+	"fmt"
+	"testing"
+
+	"gpt-load/internal/db"
+	"gpt-load/internal/encryption"
+	"gpt-load/internal/types"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// OpenDB opens an in-memory SQLite database named after the test and migrates the given models.
+// Connections of the same test share it, and it is closed when the test ends.
+func OpenDB(t testing.TB, models ...any) *gorm.DB {
+	t.Helper()
+	database, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	// The shared in-memory database lives until its last connection closes.
+	if sqlDB, err := database.DB(); err == nil {
+		t.Cleanup(func() { sqlDB.Close() })
+	}
+	if len(models) > 0 {
+		if err := database.AutoMigrate(models...); err != nil {
+			t.Fatalf("failed to migrate database: %v", err)
+		}
+	}
+	return database
+}
+
+// UseDB installs database as db.DB until the test ends.
+func UseDB(t testing.TB, database *gorm.DB) {
+	t.Helper()
+	previousDB := db.DB
+	db.DB = database
+	t.Cleanup(func() { db.DB = previousDB })
+}
+
+// noEncryptionKey is a config manager without ENCRYPTION_KEY.
+type noEncryptionKey struct{ types.ConfigManager }
+
+func (noEncryptionKey) GetEncryptionKey() string { return "" }
+
+// NoEncryption returns the encryption service used without ENCRYPTION_KEY, which stores keys as plaintext.
+func NoEncryption(t testing.TB) encryption.Service {
+	t.Helper()
+	service, err := encryption.NewService(noEncryptionKey{})
+	if err != nil {
+		t.Fatalf("failed to create encryption service: %v", err)
+	}
+	return service
+}
@@ -1,15 +1,19 @@
 package utils
 
 import (
+	"encoding/json"
 	"fmt"
 	"gpt-load/internal/models"
 	"gpt-load/internal/types"
+	"math"
 	"os"
 	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
 
 	"github.com/sirupsen/logrus"
+	"gorm.io/datatypes"
 )
 
 // GenerateSettingsMetadata 使用反射从 SystemSettings 结构体动态生成元数据
@@ -34,10 +38,14 @@ func GenerateSettingsMetadata(s *types.SystemSettings) []models.SystemSettingInf
 		categoryTag := field.Tag.Get("category")
 
 		var minValue *int
-		if strings.HasPrefix(validateTag, "min=") {
-			valStr := strings.TrimPrefix(validateTag, "min=")
-			if val, err := strconv.Atoi(valStr); err == nil {
-				minValue = &val
+		if field.Type.Kind() == reflect.Int {
+			for _, rule := range ParseValidateTag(validateTag) {
+				if rule.Name != "min" {
+					continue
+				}
+				if val, err := strconv.Atoi(rule.Param); err == nil {
+					minValue = &val
+				}
 			}
 		}
 
@@ -56,6 +64,22 @@ func GenerateSettingsMetadata(s *types.SystemSettings) []models.SystemSettingInf
 	return settingsInfo
 }
 
+// ValidateRule is a single rule of a setting's validate tag, e.g. "min=1" or "url".
+type ValidateRule struct {
+	Name  string
+	Param string
+}
+
+// ParseValidateTag splits a validate tag such as "min=200,max=599" into its rules.
+func ParseValidateTag(tag string) []ValidateRule {
+	var rules []ValidateRule
+	for _, part := range SplitAndTrim(tag, ",") {
+		name, param, _ := strings.Cut(part, "=")
+		rules = append(rules, ValidateRule{Name: strings.TrimSpace(name), Param: strings.TrimSpace(param)})
+	}
+	return rules
+}
+
 // DefaultSystemSettings 返回默认的系统配置
 func DefaultSystemSettings() types.SystemSettings {
 	s := types.SystemSettings{}
@@ -161,3 +185,127 @@ func GetEnvOrDefault(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// GetSecretEnv gets a secret from an environment variable, or from the file named by <key>_FILE.
+// The environment variable takes precedence; surrounding whitespace in the file is trimmed.
+func GetSecretEnv(key string) (string, error) {
+	if value := os.Getenv(key); value != "" {
+		return value, nil
+	}
+	path := os.Getenv(key + "_FILE")
+	if path == "" {
+		return "", nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s_FILE: %w", key, err)
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// ParseGroupConfig converts a group's raw config JSON into a GroupConfig struct.
+func ParseGroupConfig(configJSON datatypes.JSONMap) (models.GroupConfig, error) {
+	var groupConfig models.GroupConfig
+	if configJSON == nil {
+		return groupConfig, nil
+	}
+
+	configBytes, err := configJSON.MarshalJSON()
+	if err != nil {
+		return groupConfig, fmt.Errorf("failed to marshal group config: %w", err)
+	}
+	if err := json.Unmarshal(configBytes, &groupConfig); err != nil {
+		return groupConfig, fmt.Errorf("failed to unmarshal group config: %w", err)
+	}
+	return groupConfig, nil
+}
+
+// paramPathSegmentPattern matches one segment of a request body parameter path.
+var paramPathSegmentPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// SplitParamPath splits a dot-separated request body parameter path such as "stream_options.include_usage".
+func SplitParamPath(path string) ([]string, error) {
+	segments := strings.Split(path, ".")
+	for _, segment := range segments {
+		if !paramPathSegmentPattern.MatchString(segment) {
+			return nil, fmt.Errorf("invalid parameter path '%s'", path)
+		}
+	}
+	return segments, nil
+}
+
+// ParseProxyKeyQuotas parses a "key=daily/monthly,key2=daily" string into a proxy key to quota mapping.
+// The monthly cap may be omitted, and 0 means unlimited.
+func ParseProxyKeyQuotas(value string) (map[string]types.ProxyKeyQuota, error) {
+	quotas := make(map[string]types.ProxyKeyQuota)
+	for _, item := range SplitAndTrim(value, ",") {
+		// Split on the last '=' so proxy keys containing '=' still parse.
+		idx := strings.LastIndex(item, "=")
+		if idx <= 0 {
+			return nil, fmt.Errorf("invalid proxy key quota '%s': expected key=daily/monthly", MaskAPIKey(item))
+		}
+		key := strings.TrimSpace(item[:idx])
+		dailyStr, monthlyStr, _ := strings.Cut(item[idx+1:], "/")
+
+		daily, err := parseQuotaLimit(dailyStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid daily quota for proxy key '%s': %w", MaskAPIKey(key), err)
+		}
+		monthly, err := parseQuotaLimit(monthlyStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid monthly quota for proxy key '%s': %w", MaskAPIKey(key), err)
+		}
+		quotas[key] = types.ProxyKeyQuota{Daily: daily, Monthly: monthly}
+	}
+	return quotas, nil
+}
+
+// ParseModelPricing parses a "model=prompt/completion,model2=prompt/completion" string into a model to
+// price mapping. Prices are per million tokens.
+func ParseModelPricing(value string) (map[string]types.ModelPrice, error) {
+	pricing := make(map[string]types.ModelPrice)
+	for _, item := range SplitAndTrim(value, ",") {
+		// Split on the last '=' so model names containing '=' still parse.
+		idx := strings.LastIndex(item, "=")
+		if idx <= 0 {
+			return nil, fmt.Errorf("invalid model price '%s': expected model=prompt/completion", item)
+		}
+		model := strings.TrimSpace(item[:idx])
+		promptStr, completionStr, ok := strings.Cut(item[idx+1:], "/")
+		if !ok {
+			return nil, fmt.Errorf("invalid model price '%s': expected model=prompt/completion", item)
+		}
+
+		prompt, err := parsePrice(promptStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid prompt price for model '%s': %w", model, err)
+		}
+		completion, err := parsePrice(completionStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid completion price for model '%s': %w", model, err)
+		}
+		pricing[model] = types.ModelPrice{Prompt: prompt, Completion: completion}
+	}
+	return pricing, nil
+}
+
+func parsePrice(value string) (float64, error) {
+	value = strings.TrimSpace(value)
+	price, err := strconv.ParseFloat(value, 64)
+	if err != nil || price < 0 || math.IsInf(price, 0) || math.IsNaN(price) {
+		return 0, fmt.Errorf("'%s' is not a non-negative number", value)
+	}
+	return price, nil
+}
+
+func parseQuotaLimit(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("'%s' is not a non-negative integer", value)
+	}
+	return n, nil
+}
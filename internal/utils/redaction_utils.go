@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// RedactedText replaces the matches of log redaction patterns.
+const RedactedText = "[REDACTED]"
+
+// builtinRedactionPatterns are the named patterns that may be used in place of a regex.
+var builtinRedactionPatterns = map[string]string{
+	"email":       `[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`,
+	"credit_card": `\b(?:\d[ -]?){12,18}\d\b`,
+	"phone":       `\+?\d{1,3}[ -]?\(?\d{2,4}\)?[ -]?\d{3,4}[ -]?\d{3,4}\b`,
+	"ipv4":        `\b(?:\d{1,3}\.){3}\d{1,3}\b`,
+}
+
+// ParseRedactionPatterns parses one regex per line into the patterns redacted from logged content.
+// A line may also name a built-in pattern: email, credit_card, phone or ipv4.
+// Blank lines and lines starting with '#' are ignored. Lines that do not compile are reported in the
+// error, and the patterns of the other lines are still returned.
+func ParseRedactionPatterns(value string) ([]*regexp.Regexp, error) {
+	var patterns []*regexp.Regexp
+	var errs []error
+	for _, line := range strings.Split(value, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		expr := line
+		if builtin, ok := builtinRedactionPatterns[line]; ok {
+			expr = builtin
+		}
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid log redaction pattern '%s': %w", line, err))
+			continue
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, errors.Join(errs...)
+}
+
+// RedactText replaces every match of patterns in s with RedactedText.
+func RedactText(s string, patterns []*regexp.Regexp) string {
+	for _, pattern := range patterns {
+		s = pattern.ReplaceAllString(s, RedactedText)
+	}
+	return s
+}
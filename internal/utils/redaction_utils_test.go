@@ -0,0 +1,28 @@
+package utils
+
+import "testing"
+
+func TestParseRedactionPatternsKeepsValidPatterns(t *testing.T) {
+	patterns, err := ParseRedactionPatterns("email\n# comment\n(unclosed\nsecret-[0-9]+\n")
+	if err == nil {
+		t.Fatal("ParseRedactionPatterns() error = nil, want the invalid pattern reported")
+	}
+	if len(patterns) != 2 {
+		t.Fatalf("ParseRedactionPatterns() returned %d patterns, want the 2 valid ones", len(patterns))
+	}
+
+	got := RedactText("mail a@example.com with secret-42", patterns)
+	if want := "mail [REDACTED] with [REDACTED]"; got != want {
+		t.Errorf("RedactText() = %q, want %q", got, want)
+	}
+}
+
+func TestParseRedactionPatterns(t *testing.T) {
+	patterns, err := ParseRedactionPatterns("ipv4\n\n")
+	if err != nil {
+		t.Fatalf("ParseRedactionPatterns() error = %v", err)
+	}
+	if got := RedactText("from 10.0.0.1", patterns); got != "from [REDACTED]" {
+		t.Errorf("RedactText() = %q", got)
+	}
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const maxRetryDelay = 10 * time.Second
+
+// RetryWithBackoff calls fn until it succeeds or maxWait has passed, doubling the delay between
+// attempts from one second up to ten. A maxWait of zero makes a single attempt.
+func RetryWithBackoff(name string, maxWait time.Duration, fn func() error) error {
+	deadline := time.Now().Add(maxWait)
+	delay := time.Second
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			if attempt > 1 {
+				return fmt.Errorf("%s still unavailable after %d attempts: %w", name, attempt, err)
+			}
+			return err
+		}
+
+		wait := min(delay, remaining)
+		logrus.Infof("%s is not ready (attempt %d): %v. Retrying in %s...", name, attempt, err, wait)
+		time.Sleep(wait)
+		delay = min(delay*2, maxRetryDelay)
+	}
+}
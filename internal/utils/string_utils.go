@@ -1,10 +1,18 @@
 package utils
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"strings"
 )
 
+// HashKey returns the hex encoded SHA-256 digest of an API key, used to index keys without storing them.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
 // MaskAPIKey masks an API key for safe logging.
 func MaskAPIKey(key string) string {
 	length := len(key)
@@ -0,0 +1,9 @@
+package utils
+
+import "time"
+
+// StartOfDay returns midnight of the given time in its location.
+func StartOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
@@ -1,3 +1,43 @@
 package version
 
-var Version = "1.0.0"
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Build information, overridden at build time, e.g.
+// -ldflags "-X gpt-load/internal/version.Version=v1.0.0 -X gpt-load/internal/version.Commit=abc123 -X gpt-load/internal/version.BuildTime=2025-01-01T00:00:00Z"
+var (
+	Version   = "1.0.0"
+	Commit    = "unknown"
+	BuildTime = "unknown"
+)
+
+// Info describes the running build.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the build information. Without ldflags, the commit falls back
+// to the VCS revision the Go toolchain stamps into the binary.
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+	}
+
+	if buildInfo, ok := debug.ReadBuildInfo(); ok && info.Commit == "unknown" {
+		for _, setting := range buildInfo.Settings {
+			if setting.Key == "vcs.revision" {
+				info.Commit = setting.Value
+			}
+		}
+	}
+
+	return info
+}
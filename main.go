@@ -4,6 +4,7 @@ package main
 import (
 	"context"
 	"embed"
+	"flag"
 	"os"
 	"os/signal"
 	"syscall"
@@ -11,10 +12,14 @@ import (
 
 	"gpt-load/internal/app"
 	"gpt-load/internal/container"
+	migrations "gpt-load/internal/db/migrations"
+	"gpt-load/internal/encryption"
 	"gpt-load/internal/types"
 	"gpt-load/internal/utils"
 
 	"github.com/sirupsen/logrus"
+	"go.uber.org/dig"
+	"gorm.io/gorm"
 )
 
 //go:embed web/dist
@@ -24,6 +29,9 @@ var buildFS embed.FS
 var indexPage []byte
 
 func main() {
+	skipMigrations := flag.Bool("skip-migrations", false, "start the server without running database migrations, which are then run with the migrate command")
+	flag.Parse()
+
 	// Build the dependency injection container
 	container, err := container.BuildContainer()
 	if err != nil {
@@ -45,9 +53,20 @@ func main() {
 		logrus.Fatalf("Failed to setup logger: %v", err)
 	}
 
+	switch flag.Arg(0) {
+	case "encrypt-keys":
+		// One-off command: encrypt existing key values with ENCRYPTION_KEY, then exit
+		runEncryptKeys(container)
+		return
+	case "migrate":
+		// One-off command: migrate the database, then exit
+		runMigrate(container)
+		return
+	}
+
 	// Create and run the application
 	if err := container.Invoke(func(application *app.App, configManager types.ConfigManager) {
-		if err := application.Start(); err != nil {
+		if err := application.Start(app.StartOptions{SkipMigrations: *skipMigrations}); err != nil {
 			logrus.Fatalf("Failed to start application: %v", err)
 		}
 
@@ -68,3 +87,28 @@ func main() {
 		logrus.Fatalf("Failed to run application: %v", err)
 	}
 }
+
+// runEncryptKeys encrypts all plaintext key values in the database.
+func runEncryptKeys(container *dig.Container) {
+	if err := container.Invoke(func(db *gorm.DB, encryptionSvc encryption.Service) {
+		count, err := encryption.EncryptExistingKeys(db, encryptionSvc)
+		if err != nil {
+			logrus.Fatalf("Failed to encrypt keys: %v", err)
+		}
+		logrus.Infof("Encrypted %d existing keys", count)
+	}); err != nil {
+		logrus.Fatalf("Failed to run encrypt-keys: %v", err)
+	}
+}
+
+// runMigrate migrates the database schema and runs pending data migrations.
+func runMigrate(container *dig.Container) {
+	if err := container.Invoke(func(gormDB *gorm.DB) {
+		if err := migrations.Run(gormDB); err != nil {
+			logrus.Fatalf("Failed to migrate database: %v", err)
+		}
+		logrus.Info("Database migration completed")
+	}); err != nil {
+		logrus.Fatalf("Failed to run migrate: %v", err)
+	}
+}
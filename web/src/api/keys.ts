@@ -3,7 +3,10 @@ import type {
   Group,
   GroupConfigOption,
   GroupStatsResponse,
+  IgnoredKeysBreakdown,
+  ImportPreviewResult,
   KeyStatus,
+  KeyTestRun,
   TaskInfo,
 } from "@/types/models";
 import http from "@/utils/http";
@@ -69,6 +72,7 @@ export const keysApi = {
   ): Promise<{
     added_count: number;
     ignored_count: number;
+    ignored_breakdown?: IgnoredKeysBreakdown;
     total_in_group: number;
   }> {
     const res = await http.post("/keys/add-multiple", {
@@ -87,10 +91,39 @@ export const keysApi = {
     return res.data;
   },
 
+  // 使用新的密钥列表整体替换分组密钥
+  async replaceAllKeys(
+    group_id: number,
+    keys_text: string
+  ): Promise<{
+    added_count: number;
+    removed_count: number;
+    ignored_count: number;
+    ignored_breakdown: IgnoredKeysBreakdown;
+    total_in_group: number;
+  }> {
+    const res = await http.post("/keys/replace-all", {
+      group_id,
+      keys_text,
+    });
+    return res.data;
+  },
+
+  // 预览导入结果，不写入密钥
+  async previewImport(group_id: number, keys_text: string): Promise<ImportPreviewResult> {
+    const res = await http.post("/keys/import-preview", {
+      group_id,
+      keys_text,
+    });
+    return res.data;
+  },
+
   // 测试密钥
   async testKeys(
     group_id: number,
-    keys_text: string
+    keys_text: string,
+    save_results = false,
+    concurrency?: number
   ): Promise<
     {
       key_value: string;
@@ -103,6 +136,8 @@ export const keysApi = {
       {
         group_id,
         keys_text,
+        save_results,
+        concurrency,
       },
       {
         hideMessage: true,
@@ -111,6 +146,12 @@ export const keysApi = {
     return res.data;
   },
 
+  // 获取分组最近一次保存的测试结果
+  async getTestResults(group_id: number): Promise<KeyTestRun | null> {
+    const res = await http.get("/keys/test-results", { params: { group_id } });
+    return res.data;
+  },
+
   // 删除密钥
   async deleteKeys(
     group_id: number,
@@ -131,6 +172,20 @@ export const keysApi = {
     });
   },
 
+  // 批量设置密钥状态
+  async setKeysStatus(
+    group_id: number,
+    keys_text: string,
+    status: "active" | "invalid"
+  ): Promise<{ updated_count: number; ignored_count: number; total_in_group: number }> {
+    const res = await http.post("/keys/set-status", {
+      group_id,
+      keys_text,
+      status,
+    });
+    return res.data;
+  },
+
   // 恢复所有无效密钥
   restoreAllInvalidKeys(group_id: number): Promise<void> {
     return http.post("/keys/restore-all-invalid", { group_id });
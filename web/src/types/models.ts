@@ -16,7 +16,10 @@ export interface APIKey {
   status: KeyStatus;
   request_count: number;
   failure_count: number;
+  invalid_reason?: string;
   last_used_at?: string;
+  last_success_at?: string;
+  last_failure_at?: string;
   created_at: string;
   updated_at: string;
 }
@@ -36,7 +39,7 @@ export interface Group {
   description: string;
   sort: number;
   test_model: string;
-  channel_type: "openai" | "gemini" | "anthropic";
+  channel_type: "openai" | "gemini" | "anthropic" | "azure" | "virtual";
   upstreams: UpstreamInfo[];
   validation_endpoint: string;
   config: Record<string, unknown>;
@@ -44,6 +47,7 @@ export interface Group {
   endpoint?: string;
   param_overrides: Record<string, unknown>;
   proxy_keys: string;
+  draining?: boolean;
   created_at?: string;
   updated_at?: string;
 }
@@ -52,7 +56,7 @@ export interface GroupConfigOption {
   key: string;
   name: string;
   description: string;
-  default_value: number;
+  default_value: number | string | boolean;
 }
 
 // GroupStatsResponse defines the complete statistics for a group.
@@ -61,6 +65,14 @@ export interface GroupStatsResponse {
   hourly_stats: RequestStats;
   daily_stats: RequestStats;
   weekly_stats: RequestStats;
+  quota?: QuotaUsage;
+}
+
+// QuotaUsage defines today's requests against a group's daily request quota.
+export interface QuotaUsage {
+  used: number;
+  quota: number;
+  reset_at: string;
 }
 
 // KeyStats defines the statistics for API keys in a group.
@@ -68,13 +80,28 @@ export interface KeyStats {
   total_keys: number;
   active_keys: number;
   invalid_keys: number;
+  request_count_variance: number;
+  request_count_cv: number;
 }
 
 // RequestStats defines the statistics for requests over a period.
 export interface RequestStats {
   total_requests: number;
   failed_requests: number;
+  cancelled_requests?: number;
   failure_rate: number;
+  latency?: LatencyStats;
+}
+
+// LatencyStats defines request latency percentiles (milliseconds) over a period.
+export interface LatencyStats {
+  p50_ms: number;
+  p95_ms: number;
+  p99_ms: number;
+  upstream_p50_ms: number;
+  upstream_p95_ms: number;
+  upstream_p99_ms: number;
+  sample_size: number;
 }
 
 export type TaskType = "KEY_VALIDATION" | "KEY_IMPORT";
@@ -85,12 +112,47 @@ export interface KeyValidationResult {
   valid_keys: number;
 }
 
+export interface KeyTestResult {
+  key_value: string;
+  is_valid: boolean;
+  error?: string;
+}
+
+// KeyTestRun is the saved last key test run of a group.
+export interface KeyTestRun {
+  group_id: number;
+  group_name: string;
+  tested_at: string;
+  expires_at: string;
+  total: number;
+  valid_count: number;
+  invalid_count: number;
+  keys_masked: boolean;
+  results: KeyTestResult[];
+}
+
+// IgnoredKeysBreakdown counts keys skipped during an import by reason.
+export interface IgnoredKeysBreakdown {
+  duplicates: number;
+  invalid_format: number;
+  empty: number;
+}
+
+export interface ImportPreviewResult {
+  total_count: number;
+  new_count: number;
+  ignored_count: number;
+  ignored_breakdown: IgnoredKeysBreakdown;
+}
+
 export interface KeyImportResult {
   added_count: number;
   ignored_count: number;
+  ignored_breakdown?: IgnoredKeysBreakdown;
 }
 
 export interface TaskInfo {
+  id?: string;
   task_type: TaskType;
   is_running: boolean;
   group_name?: string;
@@ -100,6 +162,9 @@ export interface TaskInfo {
   finished_at?: string;
   result?: KeyValidationResult | KeyImportResult;
   error?: string;
+  cancelled?: boolean;
+  progress_percent?: number;
+  estimated_seconds_remaining?: number;
 }
 
 // Based on backend response
@@ -109,10 +174,12 @@ export interface RequestLog {
   group_id: number;
   key_id: number;
   is_success: boolean;
+  is_client_cancelled?: boolean;
   source_ip: string;
   status_code: number;
   request_path: string;
   duration_ms: number;
+  upstream_duration_ms: number;
   error_message: string;
   user_agent: string;
   retries: number;
@@ -120,11 +187,16 @@ export interface RequestLog {
   key_value?: string;
   upstream_addr: string;
   is_stream: boolean;
+  model?: string;
+  prompt_tokens?: number;
+  completion_tokens?: number;
+  is_shadow?: boolean;
 }
 
 export interface Pagination {
   page: number;
   page_size: number;
+  max_page_size: number;
   total_items: number;
   total_pages: number;
 }
@@ -140,6 +212,8 @@ export interface LogFilter {
   group_name?: string;
   key_value?: string;
   is_success?: boolean | null;
+  is_client_cancelled?: boolean | null;
+  is_shadow?: boolean | null;
   status_code?: number | null;
   source_ip?: string;
   error_contains?: string;